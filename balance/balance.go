@@ -0,0 +1,383 @@
+package balance
+
+import (
+	"context"
+	"fmt"
+	"github.com/dotbitHQ/das-lib/bitcoin"
+	"github.com/dotbitHQ/das-lib/chain/chain_evm"
+	"github.com/dotbitHQ/das-lib/chain/chain_tron"
+	"github.com/dotbitHQ/das-lib/core"
+	"github.com/dotbitHQ/das-lib/http_api/logger"
+	"github.com/shopspring/decimal"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unipay/config"
+	"unipay/dao"
+	"unipay/notify"
+	"unipay/tables"
+	"unipay/txtool"
+)
+
+var (
+	log = logger.NewLogger("balance", logger.LevelDebug)
+	// Monitor is nil until Init
+	Monitor *BalanceMonitor
+)
+
+const (
+	defaultInterval = time.Minute * 30
+	// the old check of the balance_check_map of ckb
+	defaultCkbMinBalance = 1
+)
+
+type WalletStatus string
+
+const (
+	WalletStatusOk        WalletStatus = "ok"
+	WalletStatusLow       WalletStatus = "low"       // under a threshold of the config
+	WalletStatusShortfall WalletStatus = "shortfall" // can not cover the refund queue
+	WalletStatusError     WalletStatus = "error"
+)
+
+// WalletBalance the amounts are in the token unit
+type WalletBalance struct {
+	ParserType  tables.ParserType `json:"parser_type"`
+	Address     string            `json:"address"`
+	PayTokenId  tables.PayTokenId `json:"pay_token_id"`
+	Balance     decimal.Decimal   `json:"balance"`
+	MinBalance  decimal.Decimal   `json:"min_balance"`
+	RefundCount int64             `json:"refund_count"` // the token refunds of the wallet included for the native token
+	RefundNeed  decimal.Decimal   `json:"refund_need"`  // the refund queue of the wallet, the fees included for the native token
+	Energy      *int64            `json:"energy,omitempty"`
+	Bandwidth   *int64            `json:"bandwidth,omitempty"`
+	UtxoCount   *int              `json:"utxo_count,omitempty"`
+	Status      WalletStatus      `json:"status"`
+	Reason      string            `json:"reason"`
+	Timestamp   int64             `json:"timestamp"`
+}
+
+type BalanceMonitor struct {
+	Ctx     context.Context
+	Wg      *sync.WaitGroup
+	DbDao   *dao.DbDao
+	DasCore *core.DasCore
+
+	chainDoge    *bitcoin.TxTool
+	chainEth     *chain_evm.ChainEvm
+	chainBsc     *chain_evm.ChainEvm
+	chainPolygon *chain_evm.ChainEvm
+	chainTron    *chain_tron.ChainTron
+
+	checkLock sync.Mutex
+	lock      sync.RWMutex
+	list      []WalletBalance
+	checkedAt int64
+}
+
+// Init the clients of the chains with a node and an addr_map
+func Init(ctx context.Context, wg *sync.WaitGroup, dbDao *dao.DbDao, dasCore *core.DasCore) error {
+	m := &BalanceMonitor{Ctx: ctx, Wg: wg, DbDao: dbDao, DasCore: dasCore}
+	chain := config.GetCfg().Chain
+	if doge := getFirstNode(chain.Doge.Node, chain.Doge.Nodes); doge.Url != "" && len(chain.Doge.AddrMap) > 0 {
+		if doge.User == "" {
+			doge.User, doge.Password = chain.Doge.User, chain.Doge.Password
+		}
+		m.chainDoge = &bitcoin.TxTool{
+			RpcClient: &bitcoin.BaseRequest{
+				RpcUrl:   doge.Url,
+				User:     doge.User,
+				Password: doge.Password,
+				Proxy:    chain.Doge.Proxy,
+			},
+			Ctx:       ctx,
+			DustLimit: bitcoin.DustLimitDoge,
+			Params:    bitcoin.GetDogeMainNetParams(),
+		}
+	}
+	var err error
+	if node := getFirstNode(chain.Eth.Node, chain.Eth.Nodes); node.Url != "" && len(chain.Eth.AddrMap) > 0 {
+		if m.chainEth, err = chain_evm.NewChainEvm(ctx, node.Url, 0); err != nil {
+			return fmt.Errorf("NewChainEvm eth err: %s", err.Error())
+		}
+	}
+	if node := getFirstNode(chain.Bsc.Node, chain.Bsc.Nodes); node.Url != "" && len(chain.Bsc.AddrMap) > 0 {
+		if m.chainBsc, err = chain_evm.NewChainEvm(ctx, node.Url, 0); err != nil {
+			return fmt.Errorf("NewChainEvm bsc err: %s", err.Error())
+		}
+	}
+	if node := getFirstNode(chain.Polygon.Node, chain.Polygon.Nodes); node.Url != "" && len(chain.Polygon.AddrMap) > 0 {
+		if m.chainPolygon, err = chain_evm.NewChainEvm(ctx, node.Url, 0); err != nil {
+			return fmt.Errorf("NewChainEvm polygon err: %s", err.Error())
+		}
+	}
+	if node := getFirstNode(chain.Tron.Node, chain.Tron.Nodes); node.Url != "" && len(chain.Tron.AddrMap) > 0 {
+		if m.chainTron, err = chain_tron.NewChainTron(ctx, node.Url); err != nil {
+			return fmt.Errorf("NewChainTron err: %s", err.Error())
+		}
+	}
+	Monitor = m
+	return nil
+}
+
+// getFirstNode the checks are occasional and go to the first node of the chain
+func getFirstNode(node string, nodes []config.NodeEndpoint) config.NodeEndpoint {
+	if list := config.GetNodeList(node, nodes); len(list) > 0 {
+		return list[0]
+	}
+	return config.NodeEndpoint{}
+}
+
+func (m *BalanceMonitor) RunMonitor() {
+	interval := time.Duration(config.GetCfg().BalanceMonitor.Interval) * time.Second
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+	ticker := time.NewTicker(interval)
+	m.Wg.Add(1)
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := m.Check(); err != nil {
+					log.Error("Check err: ", err.Error())
+					notify.SendAlert(notify.SeverityError, notify.CategoryBalance, "BalanceCheck", err.Error())
+				}
+			case <-m.Ctx.Done():
+				log.Warn("RunMonitor done")
+				m.Wg.Done()
+				return
+			}
+		}
+	}()
+}
+
+// GetWalletBalanceList the result of the last check, nil before the first one
+func (m *BalanceMonitor) GetWalletBalanceList() ([]WalletBalance, int64) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return m.list, m.checkedAt
+}
+
+// Check the wallets one by one, a failed wallet is kept in the list with the error status
+func (m *BalanceMonitor) Check() ([]WalletBalance, error) {
+	m.checkLock.Lock()
+	defer m.checkLock.Unlock()
+
+	needList, err := m.DbDao.GetRefundNeedList()
+	if err != nil {
+		return nil, fmt.Errorf("GetRefundNeedList err: %s", err.Error())
+	}
+	needMap := make(map[string]dao.RefundNeed)
+	for _, v := range needList {
+		needMap[v.PaymentAddress+string(v.PayTokenId)] = v
+	}
+
+	var list []WalletBalance
+	for _, w := range m.getWalletList() {
+		list = append(list, m.checkWallet(w, needMap)...)
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].ParserType != list[j].ParserType {
+			return list[i].ParserType < list[j].ParserType
+		}
+		return list[i].Address < list[j].Address
+	})
+
+	m.lock.Lock()
+	m.list, m.checkedAt = list, time.Now().UnixMilli()
+	m.lock.Unlock()
+
+	m.setMetrics(list)
+	m.sendAlerts(list)
+	return list, nil
+}
+
+type wallet struct {
+	parserType tables.ParserType
+	address    string
+	native     tables.PayTokenId
+	token      tables.PayTokenId   // empty if the chain has no token
+	needIds    []tables.PayTokenId // refunded with the native token
+	checkOnly  bool                // balance_check_map of ckb, not a refund wallet
+}
+
+func (m *BalanceMonitor) getWalletList() []wallet {
+	var list []wallet
+	add := func(parserType tables.ParserType, addrMap map[string]string, native, token tables.PayTokenId, needIds ...tables.PayTokenId) {
+		for addr := range addrMap {
+			list = append(list, wallet{parserType: parserType, address: addr, native: native, token: token, needIds: append([]tables.PayTokenId{native}, needIds...)})
+		}
+	}
+	chain := config.GetCfg().Chain
+	if m.DasCore != nil {
+		add(tables.ParserTypeCKB, chain.Ckb.AddrMap, tables.PayTokenIdCKB, "", tables.PayTokenIdDAS, tables.PayTokenIdCkbCCC)
+		for addr := range chain.Ckb.BalanceCheckMap {
+			if _, ok := chain.Ckb.AddrMap[addr]; !ok {
+				list = append(list, wallet{parserType: tables.ParserTypeCKB, address: addr, native: tables.PayTokenIdCKB, checkOnly: true})
+			}
+		}
+	}
+	if m.chainDoge != nil {
+		add(tables.ParserTypeDoge, chain.Doge.AddrMap, tables.PayTokenIdDOGE, "")
+	}
+	if m.chainEth != nil {
+		add(tables.ParserTypeETH, chain.Eth.AddrMap, tables.PayTokenIdETH, tables.PayTokenIdErc20USDT)
+	}
+	if m.chainBsc != nil {
+		add(tables.ParserTypeBSC, chain.Bsc.AddrMap, tables.PayTokenIdBNB, tables.PayTokenIdBep20USDT)
+	}
+	if m.chainPolygon != nil {
+		add(tables.ParserTypePOLYGON, chain.Polygon.AddrMap, tables.PayTokenIdPOL, "")
+	}
+	if m.chainTron != nil {
+		add(tables.ParserTypeTRON, chain.Tron.AddrMap, tables.PayTokenIdTRX, tables.PayTokenIdTrc20USDT)
+	}
+	return list
+}
+
+func (m *BalanceMonitor) checkWallet(w wallet, needMap map[string]dao.RefundNeed) []WalletBalance {
+	now := time.Now().UnixMilli()
+	paymentAddress, _ := config.GetPaymentAddress(w.native, w.address)
+	native := WalletBalance{
+		ParserType: w.parserType,
+		Address:    w.address,
+		PayTokenId: w.native,
+		Timestamp:  now,
+	}
+	threshold := config.GetCfg().BalanceMonitor.TokenMap[w.native]
+	native.MinBalance = decimal.NewFromFloat(threshold.MinBalance)
+	if w.checkOnly {
+		if _, ok := config.GetCfg().BalanceMonitor.TokenMap[w.native]; !ok {
+			native.MinBalance = decimal.NewFromInt(defaultCkbMinBalance)
+		}
+	}
+	for _, id := range w.needIds {
+		need := needMap[paymentAddress+string(id)]
+		native.RefundCount += need.Count
+		native.RefundNeed = native.RefundNeed.Add(need.Amount.Shift(-id.GetDecimals()))
+	}
+	var token *WalletBalance
+	if w.token != "" {
+		need := needMap[paymentAddress+string(w.token)]
+		token = &WalletBalance{
+			ParserType:  w.parserType,
+			Address:     w.address,
+			PayTokenId:  w.token,
+			MinBalance:  decimal.NewFromFloat(config.GetCfg().BalanceMonitor.TokenMap[w.token].MinBalance),
+			RefundCount: need.Count,
+			RefundNeed:  need.Amount.Shift(-w.token.GetDecimals()),
+			Timestamp:   now,
+		}
+		// the token refunds pay the fee in the native token
+		native.RefundCount += need.Count
+	}
+	native.RefundNeed = native.RefundNeed.Add(decimal.NewFromFloat(threshold.RefundFee).Mul(decimal.NewFromInt(native.RefundCount)))
+	list := []WalletBalance{native}
+	if token != nil {
+		list = append(list, *token)
+	}
+
+	if err := m.getWalletBalance(w, list); err != nil {
+		log.Error("getWalletBalance err:", w.parserType, w.address, err.Error())
+		for i := range list {
+			list[i].Status, list[i].Reason = WalletStatusError, err.Error()
+		}
+		return list
+	}
+	for i := range list {
+		setWalletStatus(&list[i], config.GetCfg().BalanceMonitor.TokenMap[list[i].PayTokenId])
+	}
+	return list
+}
+
+// getWalletBalance sets the balances of the native token at 0 and the token at 1
+func (m *BalanceMonitor) getWalletBalance(w wallet, list []WalletBalance) error {
+	switch w.parserType {
+	case tables.ParserTypeCKB:
+		return m.getCkbBalance(w.address, &list[0])
+	case tables.ParserTypeDoge:
+		return m.getDogeBalance(w.address, &list[0])
+	case tables.ParserTypeETH:
+		return getEvmBalance(m.chainEth, w.address, list)
+	case tables.ParserTypeBSC:
+		return getEvmBalance(m.chainBsc, w.address, list)
+	case tables.ParserTypePOLYGON:
+		return getEvmBalance(m.chainPolygon, w.address, list)
+	case tables.ParserTypeTRON:
+		return m.getTronBalance(w.address, list)
+	}
+	return fmt.Errorf("unknown parser type[%d]", w.parserType)
+}
+
+func setWalletStatus(w *WalletBalance, threshold config.BalanceThreshold) {
+	var reasons []string
+	if w.Balance.LessThan(w.RefundNeed) {
+		w.Status = WalletStatusShortfall
+		reasons = append(reasons, fmt.Sprintf("balance below the refund need %s", w.RefundNeed.String()))
+	}
+	if w.Balance.LessThan(w.MinBalance) {
+		reasons = append(reasons, fmt.Sprintf("balance below %s", w.MinBalance.String()))
+	}
+	if w.Energy != nil && *w.Energy < threshold.MinEnergy {
+		reasons = append(reasons, fmt.Sprintf("energy below %d", threshold.MinEnergy))
+	}
+	if w.Bandwidth != nil && *w.Bandwidth < threshold.MinBandwidth {
+		reasons = append(reasons, fmt.Sprintf("bandwidth below %d", threshold.MinBandwidth))
+	}
+	if w.UtxoCount != nil && *w.UtxoCount < threshold.MinUtxoCount {
+		reasons = append(reasons, fmt.Sprintf("utxo count below %d", threshold.MinUtxoCount))
+	}
+	if w.Status == "" && len(reasons) > 0 {
+		w.Status = WalletStatusLow
+	} else if w.Status == "" {
+		w.Status = WalletStatusOk
+	}
+	w.Reason = strings.Join(reasons, ", ")
+}
+
+func (m *BalanceMonitor) setMetrics(list []WalletBalance) {
+	for _, v := range list {
+		if v.Status == WalletStatusError {
+			continue
+		}
+		balance, _ := v.Balance.Float64()
+		need, _ := v.RefundNeed.Float64()
+		txtool.Tools.Metrics.WalletBalance().WithLabelValues(string(v.PayTokenId), v.Address).Set(balance)
+		txtool.Tools.Metrics.WalletRefundNeed().WithLabelValues(string(v.PayTokenId), v.Address).Set(need)
+		if v.Energy != nil {
+			txtool.Tools.Metrics.WalletTronResource().WithLabelValues(v.Address, "energy").Set(float64(*v.Energy))
+		}
+		if v.Bandwidth != nil {
+			txtool.Tools.Metrics.WalletTronResource().WithLabelValues(v.Address, "bandwidth").Set(float64(*v.Bandwidth))
+		}
+		if v.UtxoCount != nil {
+			txtool.Tools.Metrics.WalletUtxoCount().WithLabelValues(v.Address).Set(float64(*v.UtxoCount))
+		}
+	}
+}
+
+func (m *BalanceMonitor) sendAlerts(list []WalletBalance) {
+	var summary []string
+	for _, v := range list {
+		msg := fmt.Sprintf(`- Wallet: %s
+- Token: %s
+- Balance: %s
+- Refund: %d, %s
+- Status: %s %s`, v.Address, v.PayTokenId, v.Balance.String(), v.RefundCount, v.RefundNeed.String(), v.Status, v.Reason)
+		switch v.Status {
+		case WalletStatusShortfall:
+			notify.SendAlert(notify.SeverityCritical, notify.CategoryBalance, "WalletShortfall", msg)
+		case WalletStatusLow:
+			notify.SendAlert(notify.SeverityCritical, notify.CategoryBalance, "WalletBalanceLow", msg)
+		case WalletStatusError:
+			notify.SendAlert(notify.SeverityError, notify.CategoryBalance, "WalletBalanceErr", msg)
+		}
+		summary = append(summary, fmt.Sprintf("%s %s: %s [%s]", v.PayTokenId, v.Address, v.Balance.String(), v.Status))
+	}
+	if len(summary) > 0 {
+		summary = append(summary, "- Time: "+time.Now().Format("2006-01-02 15:04:05"))
+		notify.SendAlert(notify.SeverityInfo, notify.CategoryBalance, "WalletBalance", strings.Join(summary, "\n"))
+	}
+}
@@ -0,0 +1,119 @@
+package balance
+
+import (
+	"encoding/hex"
+	"fmt"
+	"github.com/dotbitHQ/das-lib/bitcoin"
+	"github.com/dotbitHQ/das-lib/chain/chain_evm"
+	"github.com/dotbitHQ/das-lib/common"
+	"github.com/dotbitHQ/das-lib/core"
+	"github.com/ethereum/go-ethereum"
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/fbsobreira/gotron-sdk/pkg/proto/api"
+	troncore "github.com/fbsobreira/gotron-sdk/pkg/proto/core"
+	"github.com/nervosnetwork/ckb-sdk-go/address"
+	"github.com/nervosnetwork/ckb-sdk-go/indexer"
+	"github.com/shopspring/decimal"
+	"math/big"
+	"unipay/config"
+)
+
+func (m *BalanceMonitor) getCkbBalance(addr string, w *WalletBalance) error {
+	parseAddr, err := address.Parse(addr)
+	if err != nil {
+		return fmt.Errorf("address.Parse err: %s", err.Error())
+	}
+	_, total, err := m.DasCore.GetBalanceCells(&core.ParamGetBalanceCells{
+		LockScript:  parseAddr.Script,
+		SearchOrder: indexer.SearchOrderDesc,
+	})
+	if err != nil {
+		return fmt.Errorf("GetBalanceCells err: %s", err.Error())
+	}
+	w.Balance = decimal.NewFromInt(int64(total)).Shift(-w.PayTokenId.GetDecimals())
+	return nil
+}
+
+// getDogeBalance the spendable utxos, the same api as the refund
+func (m *BalanceMonitor) getDogeBalance(addr string, w *WalletBalance) error {
+	total, uos, err := m.chainDoge.GetUnspentOutputsDoge(addr, "", 1<<62)
+	if err != nil && err != bitcoin.InsufficientBalanceError {
+		return fmt.Errorf("GetUnspentOutputsDoge err: %s", err.Error())
+	}
+	count := len(uos)
+	w.Balance = decimal.NewFromInt(total).Shift(-w.PayTokenId.GetDecimals())
+	w.UtxoCount = &count
+	return nil
+}
+
+func getEvmBalance(chainEvm *chain_evm.ChainEvm, addr string, list []WalletBalance) error {
+	balance, err := chainEvm.GetBalance(addr)
+	if err != nil {
+		return fmt.Errorf("GetBalance err: %s", err.Error())
+	}
+	list[0].Balance = balance.Shift(-list[0].PayTokenId.GetDecimals())
+	if len(list) < 2 {
+		return nil
+	}
+	data, err := chain_evm.PackMessage("balanceOf", ethcommon.HexToAddress(addr))
+	if err != nil {
+		return fmt.Errorf("PackMessage err: %s", err.Error())
+	}
+	contract := ethcommon.HexToAddress(list[1].PayTokenId.GetContractAddress(config.GetCfg().Server.Net))
+	res, err := chainEvm.Client.CallContract(chainEvm.Ctx, ethereum.CallMsg{To: &contract, Data: data}, nil)
+	if err != nil {
+		return fmt.Errorf("CallContract err: %s", err.Error())
+	}
+	list[1].Balance = decimal.NewFromBigInt(new(big.Int).SetBytes(res), -list[1].PayTokenId.GetDecimals())
+	return nil
+}
+
+// getTronBalance the trc20 refunds burn trx for the energy and the bandwidth they lack
+func (m *BalanceMonitor) getTronBalance(addr string, list []WalletBalance) error {
+	balance, err := m.chainTron.GetBalance(addr)
+	if err != nil {
+		return fmt.Errorf("GetBalance err: %s", err.Error())
+	}
+	list[0].Balance = decimal.NewFromInt(balance).Shift(-list[0].PayTokenId.GetDecimals())
+
+	addrHex, err := common.TronBase58ToHex(addr)
+	if err != nil {
+		return fmt.Errorf("TronBase58ToHex err: %s", err.Error())
+	}
+	addrBys, err := hex.DecodeString(addrHex)
+	if err != nil {
+		return fmt.Errorf("hex.DecodeString err: %s", err.Error())
+	}
+	res, err := m.chainTron.Client.GetAccountResource(m.chainTron.Ctx, &troncore.Account{Address: addrBys})
+	if err != nil {
+		return fmt.Errorf("GetAccountResource err: %s", err.Error())
+	}
+	energy := res.EnergyLimit - res.EnergyUsed
+	bandwidth := res.FreeNetLimit - res.FreeNetUsed + res.NetLimit - res.NetUsed
+	list[0].Energy, list[0].Bandwidth = &energy, &bandwidth
+
+	contractHex, err := common.TronBase58ToHex(list[1].PayTokenId.GetContractAddress(config.GetCfg().Server.Net))
+	if err != nil {
+		return fmt.Errorf("TronBase58ToHex err: %s", err.Error())
+	}
+	contractBys, err := hex.DecodeString(contractHex)
+	if err != nil {
+		return fmt.Errorf("hex.DecodeString err: %s", err.Error())
+	}
+	data, err := chain_evm.PackMessage("balanceOf", ethcommon.BytesToAddress(addrBys))
+	if err != nil {
+		return fmt.Errorf("PackMessage err: %s", err.Error())
+	}
+	tx, err := m.chainTron.Client.TriggerConstantContract(m.chainTron.Ctx, &troncore.TriggerSmartContract{
+		OwnerAddress:    addrBys,
+		ContractAddress: contractBys,
+		Data:            data,
+	})
+	if err != nil {
+		return fmt.Errorf("TriggerConstantContract err: %s", err.Error())
+	} else if tx.Result.Code != api.Return_SUCCESS || len(tx.ConstantResult) == 0 {
+		return fmt.Errorf("TriggerConstantContract failed: %s %s", tx.Result.Code.String(), tx.Result.Message)
+	}
+	list[1].Balance = decimal.NewFromBigInt(new(big.Int).SetBytes(tx.ConstantResult[0]), -list[1].PayTokenId.GetDecimals())
+	return nil
+}
@@ -0,0 +1,181 @@
+package business
+
+import (
+	"context"
+	"fmt"
+	"github.com/dotbitHQ/das-lib/http_api/logger"
+	"sync"
+	"time"
+	"unipay/config"
+	"unipay/dao"
+	"unipay/tables"
+)
+
+var (
+	log = logger.NewLogger("business", logger.LevelDebug)
+	// Cache is nil until Init, the lookups then fall back to business_ids in the config
+	Cache *BusinessCache
+)
+
+type BusinessCache struct {
+	Ctx   context.Context
+	Wg    *sync.WaitGroup
+	DbDao *dao.DbDao
+
+	lock      sync.RWMutex
+	m         map[string]tables.TableBusinessInfo
+	secretMap map[string]businessSecrets
+}
+
+// businessSecrets decrypted once per refresh
+type businessSecrets struct {
+	api      []string
+	callback []string
+}
+
+// Init bootstraps t_business_info from business_ids of the config, the rows in db win,
+// the secrets included, rotate them with the admin api afterwards
+func Init(ctx context.Context, wg *sync.WaitGroup, dbDao *dao.DbDao) error {
+	c := &BusinessCache{Ctx: ctx, Wg: wg, DbDao: dbDao}
+	for businessId := range config.GetCfg().BusinessIds {
+		info, err := getBootstrapBusiness(businessId)
+		if err != nil {
+			return fmt.Errorf("getBootstrapBusiness err: %s[%s]", err.Error(), businessId)
+		}
+		if ok, err := dbDao.CreateBusinessInfo(info); err != nil {
+			return fmt.Errorf("CreateBusinessInfo err: %s[%s]", err.Error(), businessId)
+		} else if ok {
+			log.Info("bootstrap business:", businessId)
+		}
+	}
+	if err := c.Refresh(); err != nil {
+		return fmt.Errorf("Refresh err: %s", err.Error())
+	}
+	Cache = c
+	return nil
+}
+
+func (c *BusinessCache) Refresh() error {
+	list, err := c.DbDao.GetBusinessList()
+	if err != nil {
+		return fmt.Errorf("GetBusinessList err: %s", err.Error())
+	}
+	m := make(map[string]tables.TableBusinessInfo, len(list))
+	secretMap := make(map[string]businessSecrets, len(list))
+	for _, v := range list {
+		m[v.BusinessId] = v
+		// a business with secrets that can not be decrypted fails the auth
+		var secrets businessSecrets
+		if secrets.api, err = DecryptSecrets(v.ApiSecrets); err != nil {
+			log.Error("DecryptSecrets api err:", v.BusinessId, err.Error())
+		}
+		if secrets.callback, err = DecryptSecrets(v.CallbackSecrets); err != nil {
+			log.Error("DecryptSecrets callback err:", v.BusinessId, err.Error())
+		}
+		secretMap[v.BusinessId] = secrets
+	}
+	c.lock.Lock()
+	c.m = m
+	c.secretMap = secretMap
+	c.lock.Unlock()
+	return nil
+}
+
+// RunRefresh picks up the changes made by the other instances
+func (c *BusinessCache) RunRefresh() {
+	ticker := time.NewTicker(time.Minute)
+	c.Wg.Add(1)
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				if err := c.Refresh(); err != nil {
+					log.Error("Refresh err: ", err.Error())
+				}
+			case <-c.Ctx.Done():
+				log.Warn("RunRefresh done")
+				c.Wg.Done()
+				return
+			}
+		}
+	}()
+}
+
+// GetBusiness returns the enabled business
+func GetBusiness(businessId string) (tables.TableBusinessInfo, bool) {
+	if Cache == nil {
+		if _, ok := config.GetCfg().BusinessIds[businessId]; !ok {
+			return tables.TableBusinessInfo{}, false
+		}
+		return newBootstrapBusiness(businessId), true
+	}
+	Cache.lock.RLock()
+	info, ok := Cache.m[businessId]
+	Cache.lock.RUnlock()
+	if !ok || info.Status != tables.BusinessStatusEnabled {
+		return tables.TableBusinessInfo{}, false
+	}
+	return info, true
+}
+
+func IsBusinessEnabled(businessId string) bool {
+	_, ok := GetBusiness(businessId)
+	return ok
+}
+
+// GetApiSecrets of the enabled business, from the config only until Init
+func GetApiSecrets(businessId string) []string {
+	if !IsBusinessEnabled(businessId) {
+		return nil
+	}
+	if Cache == nil {
+		return config.GetApiAuthSecrets(businessId)
+	}
+	Cache.lock.RLock()
+	defer Cache.lock.RUnlock()
+	return Cache.secretMap[businessId].api
+}
+
+// GetCallbackSecrets the hmac secrets of the callbacks, the ed25519 key stays in callback_sign of the config
+func GetCallbackSecrets(businessId string) []string {
+	if Cache == nil {
+		secrets, _, _, _ := config.GetCallbackSignKey(businessId)
+		return secrets
+	}
+	Cache.lock.RLock()
+	defer Cache.lock.RUnlock()
+	return Cache.secretMap[businessId].callback
+}
+
+func newBootstrapBusiness(businessId string) tables.TableBusinessInfo {
+	return tables.TableBusinessInfo{
+		BusinessId:  businessId,
+		Name:        businessId,
+		CallbackUrl: config.GetCfg().BusinessIds[businessId],
+		Status:      tables.BusinessStatusEnabled,
+	}
+}
+
+// getBootstrapBusiness with the secrets of the config encrypted
+func getBootstrapBusiness(businessId string) (info tables.TableBusinessInfo, e error) {
+	info = newBootstrapBusiness(businessId)
+	if info.ApiSecrets, e = EncryptSecrets(getNonEmptyList(config.GetApiAuthSecrets(businessId))); e != nil {
+		e = fmt.Errorf("EncryptSecrets api err: %s", e.Error())
+		return
+	}
+	callbackSecrets, _, _, _ := config.GetCallbackSignKey(businessId)
+	if info.CallbackSecrets, e = EncryptSecrets(getNonEmptyList(callbackSecrets)); e != nil {
+		e = fmt.Errorf("EncryptSecrets callback err: %s", e.Error())
+	}
+	return
+}
+
+func getNonEmptyList(list []string) []string {
+	var res []string
+	for _, v := range list {
+		if v != "" {
+			res = append(res, v)
+		}
+	}
+	return res
+}
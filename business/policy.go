@@ -0,0 +1,156 @@
+package business
+
+import (
+	"fmt"
+	"github.com/shopspring/decimal"
+	"sort"
+	"strings"
+	"time"
+	"unipay/config"
+	"unipay/tables"
+)
+
+type PolicyViolation int
+
+const (
+	PolicyViolationTokenNotAllowed PolicyViolation = iota + 1
+	PolicyViolationAmountOutOfRange
+	PolicyViolationAddressNotAllowed
+	PolicyViolationTooManyOpenOrders
+)
+
+type PolicyErr struct {
+	Violation PolicyViolation
+	Msg       string
+}
+
+func (e *PolicyErr) Error() string {
+	return e.Msg
+}
+
+// CheckOrderCreate checks the enabled tokens and the policy of the business,
+// the open orders are counted by the caller, see CheckOpenOrders
+func CheckOrderCreate(info tables.TableBusinessInfo, payTokenId tables.PayTokenId, amount decimal.Decimal, paymentAddress string) *PolicyErr {
+	if !info.IsTokenEnabled(payTokenId) {
+		return &PolicyErr{PolicyViolationTokenNotAllowed, fmt.Sprintf("pay token id[%s] is not allowed", payTokenId)}
+	}
+	policy := getPolicy(info)
+	if len(policy.Chains) > 0 && !containsFold(policy.Chains, payTokenId.GetChain()) {
+		return &PolicyErr{PolicyViolationTokenNotAllowed, fmt.Sprintf("chain of pay token id[%s] is not allowed", payTokenId)}
+	}
+	if limit, ok := policy.AmountLimits[payTokenId]; ok {
+		if limit.Min.GreaterThan(decimal.Zero) && amount.LessThan(limit.Min) {
+			return &PolicyErr{PolicyViolationAmountOutOfRange, fmt.Sprintf("amount not less than %s", limit.Min.String())}
+		}
+		if limit.Max.GreaterThan(decimal.Zero) && amount.GreaterThan(limit.Max) {
+			return &PolicyErr{PolicyViolationAmountOutOfRange, fmt.Sprintf("amount not more than %s", limit.Max.String())}
+		}
+	}
+	if list, ok := policy.PaymentAddresses[payTokenId]; ok && len(list) > 0 && !containsFold(list, paymentAddress) {
+		return &PolicyErr{PolicyViolationAddressNotAllowed, fmt.Sprintf("payment address[%s] is not allowed", paymentAddress)}
+	}
+	return nil
+}
+
+// GetTokenPolicy the view of CheckOrderCreate for the payment methods, addresses are the keys of addr_map
+// allowed by the policy
+func GetTokenPolicy(info tables.TableBusinessInfo, payTokenId tables.PayTokenId) (allowed bool, limit tables.PolicyAmountLimit, addresses []string) {
+	if !info.IsTokenEnabled(payTokenId) {
+		return
+	}
+	policy := getPolicy(info)
+	if len(policy.Chains) > 0 && !containsFold(policy.Chains, payTokenId.GetChain()) {
+		return
+	}
+	addresses = make([]string, 0)
+	policyAddresses := policy.PaymentAddresses[payTokenId]
+	for k := range config.GetAddrMap(payTokenId) {
+		if len(policyAddresses) == 0 || containsFold(policyAddresses, k) {
+			addresses = append(addresses, k)
+		}
+	}
+	sort.Strings(addresses)
+	return true, policy.AmountLimits[payTokenId], addresses
+}
+
+// CheckOpenOrders openOrders is the number of unpaid orders of the payer before this one
+func CheckOpenOrders(info tables.TableBusinessInfo, openOrders int64) *PolicyErr {
+	policy := getPolicy(info)
+	if policy.MaxOpenOrders > 0 && openOrders >= policy.MaxOpenOrders {
+		return &PolicyErr{PolicyViolationTooManyOpenOrders, fmt.Sprintf("at most %d unpaid orders", policy.MaxOpenOrders)}
+	}
+	return nil
+}
+
+// NeedRefundApproval a refund over refund_auto_approve waits for the approval of the admin
+func NeedRefundApproval(businessId string, payTokenId tables.PayTokenId, amount decimal.Decimal) bool {
+	info, ok := GetBusiness(businessId)
+	if !ok {
+		return false
+	}
+	limit, ok := getPolicy(info).RefundAutoApprove[payTokenId]
+	return ok && amount.GreaterThan(limit)
+}
+
+// GetOrderExpiredAt 0 for the default 3 days, see TableOrderInfo.GetExpiredAt
+func GetOrderExpiredAt(info tables.TableBusinessInfo, timestamp int64) int64 {
+	ttl := time.Duration(info.DefaultExpiry) * time.Second
+	if ttl <= 0 || ttl >= tables.MaxOrderTTL {
+		return 0
+	}
+	return timestamp + ttl.Milliseconds()
+}
+
+// ValidatePolicy is called by the admin api before saving the policy
+func ValidatePolicy(policy tables.BusinessPolicy) error {
+	for _, v := range policy.Chains {
+		if !containsFold(policyChains, v) {
+			return fmt.Errorf("chain[%s] invalid", v)
+		}
+	}
+	for payTokenId, limit := range policy.AmountLimits {
+		if limit.Min.LessThan(decimal.Zero) || limit.Max.LessThan(decimal.Zero) {
+			return fmt.Errorf("amount limit of [%s] invalid", payTokenId)
+		}
+		if limit.Max.GreaterThan(decimal.Zero) && limit.Min.GreaterThan(limit.Max) {
+			return fmt.Errorf("min amount of [%s] is more than max", payTokenId)
+		}
+	}
+	for payTokenId, list := range policy.PaymentAddresses {
+		addrMap := config.GetAddrMap(payTokenId)
+		for _, addr := range list {
+			if _, ok := addrMap[addr]; !ok {
+				return fmt.Errorf("payment address[%s] of [%s] is not in addr_map", addr, payTokenId)
+			}
+		}
+	}
+	if policy.MaxOpenOrders < 0 {
+		return fmt.Errorf("max_open_orders invalid")
+	}
+	for payTokenId, limit := range policy.RefundAutoApprove {
+		if limit.LessThan(decimal.Zero) {
+			return fmt.Errorf("refund auto approve limit of [%s] invalid", payTokenId)
+		}
+	}
+	return nil
+}
+
+var policyChains = []string{"CKB", "ETH", "TRON", "BSC", "POLYGON", "DOGE", "STRIPE", "DP"}
+
+// getPolicy an invalid policy is rejected by the admin api, a row edited by hand is logged and ignored
+func getPolicy(info tables.TableBusinessInfo) tables.BusinessPolicy {
+	policy, err := info.GetPolicy()
+	if err != nil {
+		log.Error("GetPolicy err:", err.Error())
+	}
+	return policy
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,127 @@
+package business
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"unipay/config"
+)
+
+// the secrets of a business are stored in t_business_info with aes-256-gcm under admin.secret_key,
+// v1:base64(nonce + sealed json list)
+const (
+	secretsPrefix   = "v1:"
+	secretMinLen    = 16
+	secretMaxCount  = 2
+	newSecretLength = 32
+)
+
+var ErrSecretKeyMissing = errors.New("admin.secret_key is not set")
+
+func getSecretsAead() (cipher.AEAD, error) {
+	secretKey := config.GetCfg().Admin.SecretKey
+	if secretKey == "" {
+		return nil, ErrSecretKeyMissing
+	}
+	key, err := hex.DecodeString(strings.TrimPrefix(secretKey, "0x"))
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("admin.secret_key is not the hex of 32 bytes")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("aes.NewCipher err: %s", err.Error())
+	}
+	return cipher.NewGCM(block)
+}
+
+// EncryptSecrets the column value of the secrets, empty for none
+func EncryptSecrets(list []string) (string, error) {
+	if len(list) == 0 {
+		return "", nil
+	}
+	aead, err := getSecretsAead()
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := json.Marshal(list)
+	if err != nil {
+		return "", fmt.Errorf("json.Marshal err: %s", err.Error())
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("rand.Read err: %s", err.Error())
+	}
+	sealed := aead.Seal(nonce, nonce, plaintext, nil)
+	return secretsPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// DecryptSecrets the secrets of the column value
+func DecryptSecrets(value string) ([]string, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(value, secretsPrefix) {
+		return nil, fmt.Errorf("secrets not encrypted")
+	}
+	aead, err := getSecretsAead()
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, secretsPrefix))
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("secrets invalid")
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, fmt.Errorf("aead.Open err: %s", err.Error())
+	}
+	var list []string
+	if err := json.Unmarshal(plaintext, &list); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal err: %s", err.Error())
+	}
+	return list, nil
+}
+
+// CheckSecrets returns the reason if the secrets set by the admin api are invalid
+func CheckSecrets(list []string) string {
+	if len(list) > secretMaxCount {
+		return fmt.Sprintf("At most %d secrets", secretMaxCount)
+	}
+	for _, v := range list {
+		if len(v) < secretMinLen {
+			return fmt.Sprintf("A secret has at least %d characters", secretMinLen)
+		}
+	}
+	return ""
+}
+
+// NewSecret a random secret of the rotation
+func NewSecret() (string, error) {
+	bys := make([]byte, newSecretLength)
+	if _, err := rand.Read(bys); err != nil {
+		return "", fmt.Errorf("rand.Read err: %s", err.Error())
+	}
+	return hex.EncodeToString(bys), nil
+}
+
+// RotateSecrets the new secret comes first, the current one is still accepted until RevokeSecrets
+func RotateSecrets(list []string, secret string) []string {
+	if len(list) == 0 {
+		return []string{secret}
+	}
+	return []string{secret, list[0]}
+}
+
+// RevokeSecrets ends the rotation, only the newest secret is kept
+func RevokeSecrets(list []string) []string {
+	if len(list) <= 1 {
+		return list
+	}
+	return list[:1]
+}
@@ -0,0 +1,197 @@
+package chainswitch
+
+import (
+	"context"
+	"fmt"
+	"github.com/dotbitHQ/das-lib/http_api/logger"
+	"sync"
+	"time"
+	"unipay/dao"
+	"unipay/notify"
+	"unipay/tables"
+)
+
+var (
+	log = logger.NewLogger("chainswitch", logger.LevelDebug)
+	// Cache is nil until Init, nothing is paused without it
+	Cache *SwitchCache
+)
+
+// ChainList the names of PayTokenId.GetChain and ParserType.ToString, stripe has no parser
+var ChainList = []string{"CKB", "ETH", "TRON", "BSC", "POLYGON", "DOGE", "DP", "STRIPE"}
+
+// refreshInterval the delay of a toggle made by another instance
+const refreshInterval = time.Second * 10
+
+// SwitchCache the runtime kill switches on top of the Switch and Refund of the config,
+// a chain can only be paused here, never turned on
+type SwitchCache struct {
+	Ctx   context.Context
+	Wg    *sync.WaitGroup
+	DbDao *dao.DbDao
+
+	lock sync.RWMutex
+	m    map[string]tables.TableChainSwitchInfo
+}
+
+func Init(ctx context.Context, wg *sync.WaitGroup, dbDao *dao.DbDao) error {
+	c := &SwitchCache{Ctx: ctx, Wg: wg, DbDao: dbDao}
+	if err := c.Refresh(); err != nil {
+		return fmt.Errorf("Refresh err: %s", err.Error())
+	}
+	Cache = c
+	return nil
+}
+
+func (c *SwitchCache) Refresh() error {
+	list, err := c.DbDao.GetChainSwitchList()
+	if err != nil {
+		return fmt.Errorf("GetChainSwitchList err: %s", err.Error())
+	}
+	m := make(map[string]tables.TableChainSwitchInfo, len(list))
+	for _, v := range list {
+		m[v.Chain] = v
+	}
+	c.lock.Lock()
+	old := c.m
+	c.m = m
+	c.lock.Unlock()
+	if old == nil {
+		return nil
+	}
+	for _, v := range list {
+		if o := old[v.Chain]; o.ParsePaused != v.ParsePaused || o.OrderPaused != v.OrderPaused || o.RefundPaused != v.RefundPaused {
+			log.Warn("chain switch changed:", v.Chain, v.ParsePaused, v.OrderPaused, v.RefundPaused, v.Operator)
+		}
+	}
+	return nil
+}
+
+// RunRefresh picks up the toggles made by the other instances and the refund process
+func (c *SwitchCache) RunRefresh() {
+	ticker := time.NewTicker(refreshInterval)
+	c.Wg.Add(1)
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				if err := c.Refresh(); err != nil {
+					log.Error("Refresh err: ", err.Error())
+				}
+			case <-c.Ctx.Done():
+				log.Warn("RunRefresh done")
+				c.Wg.Done()
+				return
+			}
+		}
+	}()
+}
+
+// GetChainSwitch a chain without a row is not paused
+func GetChainSwitch(chain string) tables.TableChainSwitchInfo {
+	if Cache == nil {
+		return tables.TableChainSwitchInfo{Chain: chain}
+	}
+	Cache.lock.RLock()
+	info, ok := Cache.m[chain]
+	Cache.lock.RUnlock()
+	if !ok {
+		return tables.TableChainSwitchInfo{Chain: chain}
+	}
+	return info
+}
+
+func IsParsePaused(parserType tables.ParserType) bool {
+	return GetChainSwitch(parserType.ToString()).ParsePaused
+}
+
+func IsOrderPaused(payTokenId tables.PayTokenId) bool {
+	return GetChainSwitch(payTokenId.GetChain()).OrderPaused
+}
+
+func IsRefundPaused(payTokenId tables.PayTokenId) bool {
+	return GetChainSwitch(payTokenId.GetChain()).RefundPaused
+}
+
+// SwitchUpdate nil for the targets left as they are
+type SwitchUpdate struct {
+	Chain        string
+	ParsePaused  *bool
+	OrderPaused  *bool
+	RefundPaused *bool
+	Operator     string
+	Reason       string
+	ClientIp     string
+}
+
+// Update reads the state from db rather than the cache, so a toggle of another instance is never reverted,
+// only the targets which really change are written to the audit log
+func (c *SwitchCache) Update(u SwitchUpdate) (tables.TableChainSwitchInfo, []tables.TableChainSwitchLog, error) {
+	info, err := c.DbDao.GetChainSwitchInfo(u.Chain)
+	if err != nil {
+		return info, nil, fmt.Errorf("GetChainSwitchInfo err: %s", err.Error())
+	}
+	info.Chain = u.Chain
+
+	var logList []tables.TableChainSwitchLog
+	nowTime := time.Now().UnixMilli()
+	toggle := func(target tables.ChainSwitchTarget, paused *bool, current *bool) {
+		if paused == nil || *paused == *current {
+			return
+		}
+		*current = *paused
+		logList = append(logList, tables.TableChainSwitchLog{
+			Chain:     u.Chain,
+			Target:    target,
+			Paused:    *paused,
+			Operator:  u.Operator,
+			Reason:    u.Reason,
+			ClientIp:  u.ClientIp,
+			Timestamp: nowTime,
+		})
+	}
+	toggle(tables.ChainSwitchTargetParse, u.ParsePaused, &info.ParsePaused)
+	toggle(tables.ChainSwitchTargetOrder, u.OrderPaused, &info.OrderPaused)
+	toggle(tables.ChainSwitchTargetRefund, u.RefundPaused, &info.RefundPaused)
+	if len(logList) == 0 {
+		return info, nil, nil
+	}
+
+	info.Operator, info.Reason = u.Operator, u.Reason
+	if err := c.DbDao.UpdateChainSwitch(info, logList); err != nil {
+		return info, nil, fmt.Errorf("UpdateChainSwitch err: %s", err.Error())
+	}
+	if err := c.Refresh(); err != nil {
+		log.Error("Refresh err: ", err.Error())
+	}
+
+	msg := fmt.Sprintf("- Chain: %s\n- Operator: %s\n- Reason: %s", u.Chain, u.Operator, u.Reason)
+	for _, v := range logList {
+		action := "resume"
+		if v.Paused {
+			action = "pause"
+		}
+		log.Warn("ChainSwitch:", u.Chain, v.Target, action, u.Operator, u.ClientIp, u.Reason)
+		msg += fmt.Sprintf("\n- %s: %s", v.Target, action)
+	}
+	notify.SendAlert(notify.SeverityWarn, notify.CategoryNode, "ChainSwitch", msg)
+	return info, logList, nil
+}
+
+// GetChainSwitchList every chain of ChainList, paused or not
+func GetChainSwitchList() []tables.TableChainSwitchInfo {
+	list := make([]tables.TableChainSwitchInfo, 0, len(ChainList))
+	for _, v := range ChainList {
+		list = append(list, GetChainSwitch(v))
+	}
+	return list
+}
+
+func IsValidChain(chain string) bool {
+	for _, v := range ChainList {
+		if v == chain {
+			return true
+		}
+	}
+	return false
+}
@@ -9,6 +9,9 @@ import (
 	"os"
 	"sync"
 	"time"
+	"unipay/balance"
+	"unipay/business"
+	"unipay/chainswitch"
 	"unipay/config"
 	"unipay/dao"
 	"unipay/http_svr"
@@ -56,16 +59,34 @@ func runServer(ctx *cli.Context) error {
 	if err != nil {
 		return err
 	}
+	notify.SubscribeConfig()
 	// ============= service start =============
 	// tx tool
 	txtool.Init()
 	txtool.Tools.Run()
 
 	// db
-	dbDao, err := dao.NewGormDB(config.Cfg.DB.Mysql)
+	dbDao, err := dao.NewGormDB(config.GetCfg().DB.Mysql)
 	if err != nil {
 		return fmt.Errorf("dao.NewGormDB err: %s", err.Error())
 	}
+	if sqlDB, err := dbDao.SqlDB(); err != nil {
+		return fmt.Errorf("SqlDB err: %s", err.Error())
+	} else if err := txtool.RegisterDBStats(config.GetCfg().DB.Mysql.DbName, sqlDB); err != nil {
+		return fmt.Errorf("RegisterDBStats err: %s", err.Error())
+	}
+
+	// business
+	if err := business.Init(ctxServer, &wgServer, dbDao); err != nil {
+		return fmt.Errorf("business.Init err: %s", err.Error())
+	}
+	business.Cache.RunRefresh()
+
+	// the runtime kill switches of the chains
+	if err := chainswitch.Init(ctxServer, &wgServer, dbDao); err != nil {
+		return fmt.Errorf("chainswitch.Init err: %s", err.Error())
+	}
+	chainswitch.Cache.RunRefresh()
 
 	// das core
 	dasCore, _, err := config.InitDasCore(ctxServer, &wgServer)
@@ -79,16 +100,20 @@ func runServer(ctx *cli.Context) error {
 	// http
 	httpSvr := http_svr.HttpSvr{
 		Ctx:     ctxServer,
-		Address: config.Cfg.Server.HttpPort,
+		Address: config.GetCfg().Server.HttpPort,
 		H: &handle.HttpHandle{
 			Ctx:     ctxServer,
 			DbDao:   dbDao,
 			DasCore: dasCore,
 			CN:      cn,
 		},
-		StripeAddr: config.Cfg.Chain.Stripe.WebhooksAddr,
+		StripeAddr: config.GetCfg().Chain.Stripe.WebhooksAddr,
 	}
 	httpSvr.Run()
+	// prometheus, on its own port so that the metrics are not public with the api
+	if config.GetCfg().Server.MetricsPort != "" {
+		txtool.ServeMetrics(config.GetCfg().Server.MetricsPort)
+	}
 
 	// tool parser
 	toolParser, err := parser.NewToolParser(ctxServer, &wgServer, dbDao, cn, dasCore)
@@ -108,8 +133,14 @@ func runServer(ctx *cli.Context) error {
 	toolTimer.RunCallbackNotice()
 	toolTimer.RunCheckNode()
 	toolTimer.RunCheckStripeStatus()
-	toolTimer.RunCkbBalance()
 	toolTimer.RunCheckRefundNum()
+	toolTimer.RunMetrics()
+
+	// balance monitor
+	if err := balance.Init(ctxServer, &wgServer, dbDao, dasCore); err != nil {
+		return fmt.Errorf("balance.Init err: %s", err.Error())
+	}
+	balance.Monitor.RunMonitor()
 
 	// ============= service end =============
 	toolib.ExitMonitoring(func(sig os.Signal) {
@@ -9,8 +9,11 @@ import (
 	"os"
 	"sync"
 	"time"
+	"unipay/business"
+	"unipay/chainswitch"
 	"unipay/config"
 	"unipay/dao"
+	"unipay/notify"
 	"unipay/refund"
 	"unipay/txtool"
 )
@@ -52,6 +55,7 @@ func runServer(ctx *cli.Context) error {
 	if err != nil {
 		return err
 	}
+	notify.SubscribeConfig()
 	// ============= service start =============
 
 	// tx tool
@@ -59,10 +63,31 @@ func runServer(ctx *cli.Context) error {
 	txtool.Tools.Run()
 
 	// db
-	dbDao, err := dao.NewGormDBNotAutoMigrate(config.Cfg.DB.Mysql)
+	dbDao, err := dao.NewGormDBNotAutoMigrate(config.GetCfg().DB.Mysql)
 	if err != nil {
 		return fmt.Errorf("dao.NewGormDB err: %s", err.Error())
 	}
+	if sqlDB, err := dbDao.SqlDB(); err != nil {
+		return fmt.Errorf("SqlDB err: %s", err.Error())
+	} else if err := txtool.RegisterDBStats(config.GetCfg().DB.Mysql.DbName, sqlDB); err != nil {
+		return fmt.Errorf("RegisterDBStats err: %s", err.Error())
+	}
+	// no http server in this process, the refund metrics are on this port or the push gateway
+	if config.GetCfg().Server.RefundMetricsPort != "" {
+		txtool.ServeMetrics(config.GetCfg().Server.RefundMetricsPort)
+	}
+
+	// business, for the refund policy
+	if err := business.Init(ctxServer, &wgServer, dbDao); err != nil {
+		return fmt.Errorf("business.Init err: %s", err.Error())
+	}
+	business.Cache.RunRefresh()
+
+	// the runtime kill switches of the chains
+	if err := chainswitch.Init(ctxServer, &wgServer, dbDao); err != nil {
+		return fmt.Errorf("chainswitch.Init err: %s", err.Error())
+	}
+	chainswitch.Cache.RunRefresh()
 
 	// das core
 	dasCore, _, err := config.InitDasCore(ctxServer, &wgServer)
@@ -80,6 +105,7 @@ func runServer(ctx *cli.Context) error {
 	if err := toolRefund.InitRefundInfo(); err != nil {
 		return fmt.Errorf("InitRefundInfo err: %s", err.Error())
 	}
+	config.Subscribe("refund", toolRefund.ReloadRefund)
 	toolRefund.RunRefundOnce()
 	if err := toolRefund.RunRefund(); err != nil {
 		return fmt.Errorf("RunRefund err: %s", err.Error())
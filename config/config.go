@@ -2,6 +2,7 @@ package config
 
 import (
 	"context"
+	"crypto/ed25519"
 	"fmt"
 	"github.com/dotbitHQ/das-lib/common"
 	"github.com/dotbitHQ/das-lib/core"
@@ -24,7 +25,6 @@ import (
 )
 
 var (
-	Cfg CfgServer
 	log = logger.NewLogger("config", logger.LevelDebug)
 )
 
@@ -33,25 +33,26 @@ func InitCfg(configFilePath string) error {
 		configFilePath = "./config/config.yaml"
 	}
 	log.Debug("config file path：", configFilePath)
-	if err := toolib.UnmarshalYamlFile(configFilePath, &Cfg); err != nil {
-		return fmt.Errorf("UnmarshalYamlFile err:%s", err.Error())
+	cfg, err := loadCfg(configFilePath)
+	if err != nil {
+		return err
 	}
-	log.Debug("config file：", toolib.JsonString(Cfg))
+	cfgValue.Store(cfg)
 	initStripe()
 	return nil
 }
 
+// AddCfgFileWatcher reloads the config on change, an invalid file is logged and the running config is kept
 func AddCfgFileWatcher(configFilePath string) (*fsnotify.Watcher, error) {
 	if configFilePath == "" {
 		configFilePath = "./config/config.yaml"
 	}
 	return toolib.AddFileWatcher(configFilePath, func() {
 		log.Debug("config file path：", configFilePath)
-		if err := toolib.UnmarshalYamlFile(configFilePath, &Cfg); err != nil {
-			log.Error("UnmarshalYamlFile err:", err.Error())
+		if err := reloadCfg(configFilePath); err != nil {
+			log.Error("reloadCfg err:", err.Error())
+			onReloadFailed(err)
 		}
-		log.Debug("config file：", toolib.JsonString(Cfg))
-		initStripe()
 	})
 }
 
@@ -63,13 +64,71 @@ type CfgServer struct {
 		CronSpec              string            `json:"cron_spec" yaml:"cron_spec"`
 		RemoteSignApiUrl      string            `json:"remote_sign_api_url" yaml:"remote_sign_api_url"`
 		PrometheusPushGateway string            `json:"prometheus_push_gateway" yaml:"prometheus_push_gateway"`
+		MetricsPort           string            `json:"metrics_port" yaml:"metrics_port"`
+		RefundMetricsPort     string            `json:"refund_metrics_port" yaml:"refund_metrics_port"`
+		OrderFailAutoRefund   bool              `json:"order_fail_auto_refund" yaml:"order_fail_auto_refund"`
 	} `json:"server" yaml:"server"`
 	BusinessIds map[string]string `json:"business_ids" yaml:"business_ids"`
-	Notify      struct {
+	ApiAuth     struct {
+		Switch          bool                  `json:"switch" yaml:"switch"`
+		TimestampWindow int64                 `json:"timestamp_window" yaml:"timestamp_window"` // seconds
+		BusinessMap     map[string]ApiAuthKey `json:"business_map" yaml:"business_map"`
+	} `json:"api_auth" yaml:"api_auth"`
+	Admin struct {
+		Token     string `json:"-" yaml:"token"`
+		SecretKey string `json:"-" yaml:"secret_key"` // hex of the 32 bytes aes key of the business secrets in t_business_info
+	} `json:"admin" yaml:"admin"`
+	CallbackSign struct {
+		BusinessMap map[string]CallbackSignKey `json:"business_map" yaml:"business_map"`
+	} `json:"callback_sign" yaml:"callback_sign"`
+	OrderMeta struct {
+		IndexedKeys []string `json:"indexed_keys" yaml:"indexed_keys"` // default account
+	} `json:"order_meta" yaml:"order_meta"`
+	OrderStream struct {
+		Secret      string `json:"-" yaml:"secret"`                  // signs the stream tokens, random per process if empty
+		TokenTTL    int64  `json:"token_ttl" yaml:"token_ttl"`       // seconds
+		MaxDuration int64  `json:"max_duration" yaml:"max_duration"` // seconds
+	} `json:"order_stream" yaml:"order_stream"`
+	ChainHealth struct {
+		Switch bool `json:"switch" yaml:"switch"`
+		// create the order of an unhealthy chain with chain_unhealthy in the response, rejected otherwise
+		Flag           bool              `json:"flag" yaml:"flag"`
+		MaxLag         map[string]uint64 `json:"max_lag" yaml:"max_lag"`                 // blocks behind the confirm num, by chain, e.g. ETH
+		NodeErrCount   int               `json:"node_err_count" yaml:"node_err_count"`   // consecutive, default 3
+		StaleSeconds   int64             `json:"stale_seconds" yaml:"stale_seconds"`     // without a report of the parser, default 300
+		RecoverSeconds int64             `json:"recover_seconds" yaml:"recover_seconds"` // healthy for so long to close the breaker, default 60
+	} `json:"chain_health" yaml:"chain_health"`
+	UniqueAmount struct {
+		Switch   bool                                    `json:"switch" yaml:"switch"`
+		TokenMap map[tables.PayTokenId]UniqueAmountToken `json:"token_map" yaml:"token_map"`
+	} `json:"unique_amount" yaml:"unique_amount"`
+	Checkout struct {
+		Switch      bool                        `json:"switch" yaml:"switch"`
+		BaseUrl     string                      `json:"base_url" yaml:"base_url"`
+		BusinessMap map[string]CheckoutBranding `json:"business_map" yaml:"business_map"`
+	} `json:"checkout" yaml:"checkout"`
+	Notify struct {
 		LarkErrorKey   string `json:"lark_error_key" yaml:"lark_error_key"`
 		LarkDasInfoKey string `json:"lark_das_info_key" yaml:"lark_das_info_key"`
 		StripeKey      string `json:"stripe_key" yaml:"stripe_key"`
+		DedupWindow    int64  `json:"dedup_window" yaml:"dedup_window"` // s, default 300
+		RateLimit      int    `json:"rate_limit" yaml:"rate_limit"`     // per notifier per minute, default 30
+		// the lark keys above are used if empty
+		Notifiers []NotifierConf `json:"notifiers" yaml:"notifiers"`
 	} `json:"notify" yaml:"notify"`
+	NodePool struct {
+		ProbeInterval int64   `json:"probe_interval" yaml:"probe_interval"` // s, default 15
+		MaxHeadLag    uint64  `json:"max_head_lag" yaml:"max_head_lag"`     // blocks behind the best endpoint of the chain, default 5
+		StaleSeconds  int64   `json:"stale_seconds" yaml:"stale_seconds"`   // the head has not moved, default 300
+		ErrRate       float64 `json:"err_rate" yaml:"err_rate"`             // of the recent calls, default 0.5
+		DownSeconds   int64   `json:"down_seconds" yaml:"down_seconds"`     // a down endpoint is probed for so long before it is back, default 60
+		HashCheck     bool    `json:"hash_check" yaml:"hash_check"`         // compare the block hash at the parse height with the other endpoints
+	} `json:"node_pool" yaml:"node_pool"`
+	BalanceMonitor struct {
+		Interval int64 `json:"interval" yaml:"interval"` // s, default 1800
+		// the thresholds of the refund wallets, not checked if missing
+		TokenMap map[tables.PayTokenId]BalanceThreshold `json:"token_map" yaml:"token_map"`
+	} `json:"balance_monitor" yaml:"balance_monitor"`
 	DB struct {
 		Mysql DbMysql `json:"mysql" yaml:"mysql"`
 	} `json:"db" yaml:"db"`
@@ -86,7 +145,9 @@ type CfgServer struct {
 		Ckb struct {
 			Refund          bool              `json:"refund" yaml:"refund"`
 			Switch          bool              `json:"switch" yaml:"switch"`
-			Node            string            `json:"node" yaml:"node"`
+			Node            string            `json:"node" yaml:"node"` // das core, and the parser if nodes is empty
+			Nodes           []NodeEndpoint    `json:"nodes" yaml:"nodes"`
+			Mempool         bool              `json:"mempool" yaml:"mempool"`
 			AddrMap         map[string]string `json:"addr_map" yaml:"addr_map"`
 			BalanceCheckMap map[string]string `json:"balance_check_map" yaml:"balance_check_map"`
 		} `json:"ckb" yaml:"ckb"`
@@ -99,15 +160,18 @@ type CfgServer struct {
 			Refund    bool              `json:"refund" yaml:"refund"`
 			Switch    bool              `json:"switch" yaml:"switch"`
 			Node      string            `json:"node" yaml:"node"`
+			Nodes     []NodeEndpoint    `json:"nodes" yaml:"nodes"`
 			User      string            `json:"user" yaml:"user"`
 			Password  string            `json:"password" yaml:"password"`
 			Proxy     string            `json:"proxy" yaml:"proxy"`
+			Mempool   bool              `json:"mempool" yaml:"mempool"`
 			AddrMap   map[string]string `json:"addr_map" yaml:"addr_map"`
 		} `json:"doge" yaml:"doge"`
 		Stripe struct {
 			Refund         bool   `json:"refund" yaml:"refund"`
 			Switch         bool   `json:"switch" yaml:"switch"`
 			Key            string `json:"key" yaml:"key"`
+			PublishableKey string `json:"publishable_key" yaml:"publishable_key"`
 			EndpointSecret string `json:"endpoint_secret" yaml:"endpoint_secret"`
 			WebhooksAddr   string `json:"webhooks_addr" yaml:"webhooks_addr"`
 			LargeAmount    int64  `json:"large_amount" yaml:"large_amount"`
@@ -122,14 +186,149 @@ type DbMysql struct {
 	DbName   string `json:"db_name" yaml:"db_name"`
 }
 
+// BalanceThreshold the amounts are in the token unit, e.g. 0.05 for 0.05 ETH
+type BalanceThreshold struct {
+	MinBalance   float64 `json:"min_balance" yaml:"min_balance"`
+	RefundFee    float64 `json:"refund_fee" yaml:"refund_fee"`         // native token, the fee of a refund tx, added to the projected need
+	MinEnergy    int64   `json:"min_energy" yaml:"min_energy"`         // tron_trx
+	MinBandwidth int64   `json:"min_bandwidth" yaml:"min_bandwidth"`   // tron_trx
+	MinUtxoCount int     `json:"min_utxo_count" yaml:"min_utxo_count"` // doge_doge
+}
+
+type UniqueAmountToken struct {
+	Step     uint64 `json:"step" yaml:"step"`           // offset step in the smallest unit of the token
+	MaxCount uint64 `json:"max_count" yaml:"max_count"` // max number of steps tried before giving up
+}
+
+func GetUniqueAmountToken(payTokenId tables.PayTokenId) (UniqueAmountToken, bool) {
+	if !GetCfg().UniqueAmount.Switch {
+		return UniqueAmountToken{}, false
+	}
+	item, ok := GetCfg().UniqueAmount.TokenMap[payTokenId]
+	if !ok || item.Step == 0 || item.MaxCount == 0 {
+		return UniqueAmountToken{}, false
+	}
+	return item, true
+}
+
+// ApiAuthKey up to two secrets are active at the same time, so that the business can switch to a new one without downtime
+type ApiAuthKey struct {
+	Secrets []string `json:"-" yaml:"secrets"`
+}
+
+func GetApiAuthSecrets(businessId string) []string {
+	secrets := GetCfg().ApiAuth.BusinessMap[businessId].Secrets
+	if len(secrets) > 2 {
+		secrets = secrets[:2]
+	}
+	return secrets
+}
+
+// CallbackSignKey signs the callbacks, see webhookverify
+type CallbackSignKey struct {
+	Secrets           []string `json:"-" yaml:"secrets"`             // hmac, the current and the next one during rotation
+	Ed25519PrivateKey string   `json:"-" yaml:"ed25519_private_key"` // optional, hex of the 32 bytes seed
+	KeyId             string   `json:"key_id" yaml:"key_id"`
+}
+
+func GetCallbackSignKey(businessId string) (secrets []string, privateKey ed25519.PrivateKey, keyId string, e error) {
+	return GetCfg().getCallbackSignKey(businessId)
+}
+
+var defaultOrderMetaIndexedKeys = []string{"account"}
+
+// GetOrderMetaIndexedKeys the meta_data keys searchable in the order list
+func GetOrderMetaIndexedKeys() []string {
+	if len(GetCfg().OrderMeta.IndexedKeys) == 0 {
+		return defaultOrderMetaIndexedKeys
+	}
+	return GetCfg().OrderMeta.IndexedKeys
+}
+
+func IsOrderMetaIndexedKey(key string) bool {
+	for _, v := range GetOrderMetaIndexedKeys() {
+		if v == key {
+			return true
+		}
+	}
+	return false
+}
+
+type CheckoutBranding struct {
+	Name         string `json:"name" yaml:"name"`
+	LogoUrl      string `json:"logo_url" yaml:"logo_url"`
+	PrimaryColor string `json:"primary_color" yaml:"primary_color"`
+	ReturnUrl    string `json:"return_url" yaml:"return_url"` // {order_id} is replaced with the order id
+}
+
+func GetCheckoutBranding(businessId string) CheckoutBranding {
+	branding := GetCfg().Checkout.BusinessMap[businessId]
+	if branding.Name == "" {
+		branding.Name = businessId
+	}
+	if branding.PrimaryColor == "" {
+		branding.PrimaryColor = "#3b82f6"
+	}
+	return branding
+}
+
+// GetCheckoutUrl returns the hosted checkout page of the order, empty if checkout is off
+func GetCheckoutUrl(businessId, orderId string) string {
+	cfg := GetCfg()
+	if !cfg.Checkout.Switch || cfg.Checkout.BaseUrl == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/checkout/%s/%s", strings.TrimRight(cfg.Checkout.BaseUrl, "/"), businessId, orderId)
+}
+
+// NotifierConf an alert channel, empty severities or categories match all
+type NotifierConf struct {
+	Name       string   `json:"name" yaml:"name"`
+	Type       string   `json:"type" yaml:"type"` // lark slack telegram smtp webhook
+	Url        string   `json:"url" yaml:"url"`   // slack, webhook
+	Key        string   `json:"-" yaml:"key"`     // lark
+	BotToken   string   `json:"-" yaml:"bot_token"`
+	ChatId     string   `json:"chat_id" yaml:"chat_id"`
+	SmtpAddr   string   `json:"smtp_addr" yaml:"smtp_addr"` // host:port
+	User       string   `json:"user" yaml:"user"`
+	Password   string   `json:"-" yaml:"password"`
+	From       string   `json:"from" yaml:"from"`
+	To         []string `json:"to" yaml:"to"`
+	Severities []string `json:"severities" yaml:"severities"` // info warn error critical
+	Categories []string `json:"categories" yaml:"categories"` // parser refund callback order stripe balance node config
+}
+
 type EvmNode struct {
 	Refund       bool              `json:"refund" yaml:"refund"`
 	Switch       bool              `json:"switch" yaml:"switch"`
 	Node         string            `json:"node" yaml:"node"`
+	Nodes        []NodeEndpoint    `json:"nodes" yaml:"nodes"`
+	Ws           string            `json:"ws" yaml:"ws"` // new heads by eth_subscribe for the parser, not for tron
 	RefundAddFee float64           `json:"refund_add_fee" yaml:"refund_add_fee"`
+	Mempool      bool              `json:"mempool" yaml:"mempool"` // not for tron
 	AddrMap      map[string]string `json:"addr_map" yaml:"addr_map"`
 }
 
+// NodeEndpoint one of the nodes of a chain, the calls are shared by weight among the healthy ones
+type NodeEndpoint struct {
+	Url      string `json:"url" yaml:"url"`       // host:port for tron
+	Weight   int    `json:"weight" yaml:"weight"` // default 1
+	Backup   bool   `json:"backup" yaml:"backup"` // only used while all the others are down
+	User     string `json:"user" yaml:"user"`     // doge, the user of the chain if empty
+	Password string `json:"-" yaml:"password"`
+}
+
+// GetNodeList the nodes of a chain, or the single node if nodes is empty
+func GetNodeList(node string, nodes []NodeEndpoint) []NodeEndpoint {
+	if len(nodes) > 0 {
+		return nodes
+	}
+	if node == "" {
+		return nil
+	}
+	return []NodeEndpoint{{Url: node, Weight: 1}}
+}
+
 func FormatAddrMap(parserType tables.ParserType, addrMap map[string]string) map[string]string {
 	var res = make(map[string]string)
 	switch parserType {
@@ -164,11 +363,11 @@ func FormatAddrMap(parserType tables.ParserType, addrMap map[string]string) map[
 func GetPaymentAddress(payTokenId tables.PayTokenId, paymentAddress string) (string, error) {
 	switch payTokenId {
 	case tables.PayTokenIdETH, tables.PayTokenIdErc20USDT:
-		if _, ok := Cfg.Chain.Eth.AddrMap[paymentAddress]; ok {
+		if _, ok := GetCfg().Chain.Eth.AddrMap[paymentAddress]; ok {
 			return strings.ToLower(paymentAddress), nil
 		}
 	case tables.PayTokenIdTRX, tables.PayTokenIdTrc20USDT:
-		if _, ok := Cfg.Chain.Tron.AddrMap[paymentAddress]; ok {
+		if _, ok := GetCfg().Chain.Tron.AddrMap[paymentAddress]; ok {
 			if tronAddr, err := common.TronBase58ToHex(paymentAddress); err != nil {
 				return "", fmt.Errorf("common.TronBase58ToHex err: %s[%s]", err.Error(), paymentAddress)
 			} else {
@@ -176,15 +375,15 @@ func GetPaymentAddress(payTokenId tables.PayTokenId, paymentAddress string) (str
 			}
 		}
 	case tables.PayTokenIdBNB, tables.PayTokenIdBep20USDT:
-		if _, ok := Cfg.Chain.Bsc.AddrMap[paymentAddress]; ok {
+		if _, ok := GetCfg().Chain.Bsc.AddrMap[paymentAddress]; ok {
 			return strings.ToLower(paymentAddress), nil
 		}
 	case tables.PayTokenIdPOL: //,tables.PayTokenIdMATIC:
-		if _, ok := Cfg.Chain.Polygon.AddrMap[paymentAddress]; ok {
+		if _, ok := GetCfg().Chain.Polygon.AddrMap[paymentAddress]; ok {
 			return strings.ToLower(paymentAddress), nil
 		}
 	case tables.PayTokenIdDAS, tables.PayTokenIdCKB, tables.PayTokenIdCkbCCC:
-		if _, ok := Cfg.Chain.Ckb.AddrMap[paymentAddress]; ok {
+		if _, ok := GetCfg().Chain.Ckb.AddrMap[paymentAddress]; ok {
 			if parseAddr, err := address.Parse(paymentAddress); err != nil {
 				return "", fmt.Errorf("address.Parse err: %s[%s]", err.Error(), paymentAddress)
 			} else if parseAddr.Script.CodeHash.String() != transaction.SECP256K1_BLAKE160_SIGHASH_ALL_TYPE_HASH {
@@ -194,7 +393,7 @@ func GetPaymentAddress(payTokenId tables.PayTokenId, paymentAddress string) (str
 			}
 		}
 	case tables.PayTokenIdDOGE:
-		if _, ok := Cfg.Chain.Doge.AddrMap[paymentAddress]; ok {
+		if _, ok := GetCfg().Chain.Doge.AddrMap[paymentAddress]; ok {
 			return paymentAddress, nil
 		}
 	case tables.PayTokenIdStripeUSD:
@@ -205,16 +404,45 @@ func GetPaymentAddress(payTokenId tables.PayTokenId, paymentAddress string) (str
 	return "", fmt.Errorf("unknow pay token id[%s] in AddrMap[%s]", payTokenId, paymentAddress)
 }
 
+func GetAddrMap(payTokenId tables.PayTokenId) map[string]string {
+	switch payTokenId {
+	case tables.PayTokenIdETH, tables.PayTokenIdErc20USDT:
+		return GetCfg().Chain.Eth.AddrMap
+	case tables.PayTokenIdTRX, tables.PayTokenIdTrc20USDT:
+		return GetCfg().Chain.Tron.AddrMap
+	case tables.PayTokenIdBNB, tables.PayTokenIdBep20USDT:
+		return GetCfg().Chain.Bsc.AddrMap
+	case tables.PayTokenIdPOL:
+		return GetCfg().Chain.Polygon.AddrMap
+	case tables.PayTokenIdDAS, tables.PayTokenIdCKB, tables.PayTokenIdCkbCCC:
+		return GetCfg().Chain.Ckb.AddrMap
+	case tables.PayTokenIdDOGE:
+		return GetCfg().Chain.Doge.AddrMap
+	}
+	return nil
+}
+
+// GetPaymentAddressOrigin returns the configured address for the payment address saved in the order
+func GetPaymentAddressOrigin(payTokenId tables.PayTokenId, paymentAddress string) string {
+	for k := range GetAddrMap(payTokenId) {
+		if addr, err := GetPaymentAddress(payTokenId, k); err == nil && addr == paymentAddress {
+			return k
+		}
+	}
+	return paymentAddress
+}
+
 func InitDasCore(ctx context.Context, wg *sync.WaitGroup) (*core.DasCore, *dascache.DasCache, error) {
+	cfg := GetCfg()
 	// ckb node
-	ckbClient, err := rpc.DialWithIndexer(Cfg.Chain.Ckb.Node, Cfg.Chain.Ckb.Node)
+	ckbClient, err := rpc.DialWithIndexer(cfg.Chain.Ckb.Node, cfg.Chain.Ckb.Node)
 	if err != nil {
 		return nil, nil, fmt.Errorf("rpc.DialWithIndexer err: %s", err.Error())
 	}
 	log.Info("ckb node ok")
 
 	// das init
-	net := Cfg.Server.Net
+	net := cfg.Server.Net
 	env := core.InitEnvOpt(net,
 		common.DasContractNameConfigCellType,
 		common.DasContractNameDispatchCellType,
@@ -251,7 +479,7 @@ func InitDasCore(ctx context.Context, wg *sync.WaitGroup) (*core.DasCore, *dasca
 }
 
 func initStripe() {
-	stripe.Key = Cfg.Chain.Stripe.Key
+	stripe.Key = GetCfg().Chain.Stripe.Key
 }
 
 func InitDasTxBuilderBaseV2(ctx context.Context, dasCore *core.DasCore, fromScript *types.Script, private string) (*txbuilder.DasTxBuilderBase, error) {
@@ -262,16 +490,16 @@ func InitDasTxBuilderBaseV2(ctx context.Context, dasCore *core.DasCore, fromScri
 	var handleSign sign.HandleSignCkbMessage
 	if private != "" {
 		handleSign = sign.LocalSign(private)
-	} else if Cfg.Server.RemoteSignApiUrl != "" {
+	} else if cfg := GetCfg(); cfg.Server.RemoteSignApiUrl != "" {
 		mode := address.Testnet
-		if Cfg.Server.Net == common.DasNetTypeMainNet {
+		if cfg.Server.Net == common.DasNetTypeMainNet {
 			mode = address.Mainnet
 		}
 		addr, err := address.ConvertScriptToShortAddress(mode, fromScript)
 		if err != nil {
 			return nil, fmt.Errorf("address.ConvertScriptToShortAddress err: %s", err.Error())
 		}
-		handleSign = remote_sign.SignTxForCKBHandle(Cfg.Server.RemoteSignApiUrl, addr)
+		handleSign = remote_sign.SignTxForCKBHandle(cfg.Server.RemoteSignApiUrl, addr)
 	}
 	txBuilderBase := txbuilder.NewDasTxBuilderBase(ctx, dasCore, handleSign, svrArgs)
 	return txBuilderBase, nil
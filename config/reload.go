@@ -0,0 +1,223 @@
+package config
+
+import (
+	"fmt"
+	"github.com/scorpiotzh/toolib"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	// cfgValue the current *CfgServer, a snapshot is never changed after it is stored
+	cfgValue atomic.Value
+	emptyCfg = &CfgServer{}
+
+	reloadLock      sync.Mutex
+	subscriberLock  sync.Mutex
+	subscriberList  []subscriber
+	reloadFailedFns []func(err error)
+)
+
+// GetCfg the current snapshot of the config, read it once for the values which belong together,
+// and never modify it
+func GetCfg() *CfgServer {
+	if cfg, ok := cfgValue.Load().(*CfgServer); ok {
+		return cfg
+	}
+	return emptyCfg
+}
+
+type subscriber struct {
+	name string
+	fn   func(oldCfg, newCfg *CfgServer) error
+}
+
+// Subscribe fn is called in order of subscription after a reload is swapped in,
+// e.g. to rebuild the chain clients, its error is logged and does not roll the reload back
+func Subscribe(name string, fn func(oldCfg, newCfg *CfgServer) error) {
+	subscriberLock.Lock()
+	defer subscriberLock.Unlock()
+	subscriberList = append(subscriberList, subscriber{name: name, fn: fn})
+}
+
+// OnReloadFailed fn is called when a changed file is rejected, e.g. to send an alert
+func OnReloadFailed(fn func(err error)) {
+	subscriberLock.Lock()
+	defer subscriberLock.Unlock()
+	reloadFailedFns = append(reloadFailedFns, fn)
+}
+
+func onReloadFailed(err error) {
+	subscriberLock.Lock()
+	fns := reloadFailedFns
+	subscriberLock.Unlock()
+	for _, fn := range fns {
+		fn(err)
+	}
+}
+
+func loadCfg(configFilePath string) (*CfgServer, error) {
+	var cfg CfgServer
+	if err := toolib.UnmarshalYamlFile(configFilePath, &cfg); err != nil {
+		return nil, fmt.Errorf("UnmarshalYamlFile err: %s", err.Error())
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("Validate err: %s", err.Error())
+	}
+	return &cfg, nil
+}
+
+func reloadCfg(configFilePath string) error {
+	reloadLock.Lock()
+	defer reloadLock.Unlock()
+
+	newCfg, err := loadCfg(configFilePath)
+	if err != nil {
+		return err
+	}
+	oldCfg := GetCfg()
+	diffList := DiffCfg(oldCfg, newCfg)
+	if len(diffList) == 0 {
+		log.Info("reloadCfg: nothing changed")
+		return nil
+	}
+	for _, v := range diffList {
+		log.Warn("reloadCfg:", v)
+	}
+	cfgValue.Store(newCfg)
+	initStripe()
+
+	subscriberLock.Lock()
+	list := subscriberList
+	subscriberLock.Unlock()
+	for _, v := range list {
+		if err := v.fn(oldCfg, newCfg); err != nil {
+			log.Error("reloadCfg subscriber err:", v.name, err.Error())
+		}
+	}
+	return nil
+}
+
+// restartPathList the config read only at startup
+var restartPathList = []string{"server.name", "server.net", "server.http_port", "server.metrics_port", "server.refund_metrics_port", "db.", "chain.stripe.webhooks_addr"}
+
+// secretNameMap the values under these names are not logged, the private keys of addr_map included
+var secretNameMap = map[string]struct{}{
+	"addr_map": {}, "balance_check_map": {}, "password": {}, "token": {}, "bot_token": {}, "key": {},
+	"secret": {}, "secrets": {}, "endpoint_secret": {}, "lark_error_key": {}, "lark_das_info_key": {}, "stripe_key": {},
+	"transfer_whitelist_private": {}, "ed25519_private_key": {}, "secret_key": {},
+}
+
+// urlNameMap the node urls may carry an api key in the path or the query, only their host is logged
+var urlNameMap = map[string]struct{}{"node": {}, "ws": {}, "url": {}, "proxy": {}}
+
+// DiffCfg the changed paths, e.g. "chain.eth.node: a -> b", the secrets are masked and the urls cut to the host
+func DiffCfg(oldCfg, newCfg *CfgServer) []string {
+	oldMap, newMap := make(map[string]string), make(map[string]string)
+	flattenCfg(oldCfg, oldMap)
+	flattenCfg(newCfg, newMap)
+
+	var diffList []string
+	for k, v := range newMap {
+		o, ok := oldMap[k]
+		if ok && o == v {
+			continue
+		}
+		if isSecretPath(k) {
+			o, v = "***", "***"
+		} else if isUrlPath(k) {
+			if o, v = maskUrl(o), maskUrl(v); ok && o == v {
+				v += " (path changed)"
+			}
+		}
+		if !ok {
+			diffList = append(diffList, fmt.Sprintf("%s: added %s%s", k, v, restartNote(k)))
+		} else {
+			diffList = append(diffList, fmt.Sprintf("%s: %s -> %s%s", k, o, v, restartNote(k)))
+		}
+	}
+	for k := range oldMap {
+		if _, ok := newMap[k]; !ok {
+			diffList = append(diffList, fmt.Sprintf("%s: removed%s", k, restartNote(k)))
+		}
+	}
+	sort.Strings(diffList)
+	return diffList
+}
+
+// flattenCfg by the yaml names, so the json:"-" secrets are compared too
+func flattenCfg(cfg *CfgServer, m map[string]string) {
+	flatten("", reflect.ValueOf(*cfg), m)
+}
+
+func flatten(prefix string, v reflect.Value, m map[string]string) {
+	join := func(name string) string {
+		if prefix == "" {
+			return name
+		}
+		return prefix + "." + name
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+			if name == "" {
+				name = strings.ToLower(field.Name)
+			}
+			flatten(join(name), v.Field(i), m)
+		}
+	case reflect.Map:
+		for _, k := range v.MapKeys() {
+			flatten(join(fmt.Sprintf("%v", k.Interface())), v.MapIndex(k), m)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			flatten(join(fmt.Sprintf("%d", i)), v.Index(i), m)
+		}
+	default:
+		m[prefix] = fmt.Sprintf("%v", v.Interface())
+	}
+}
+
+func isSecretPath(path string) bool {
+	for _, v := range strings.Split(path, ".") {
+		if _, ok := secretNameMap[v]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func isUrlPath(path string) bool {
+	list := strings.Split(path, ".")
+	_, ok := urlNameMap[list[len(list)-1]]
+	return ok
+}
+
+// maskUrl the scheme and the host of the url, or the host:port of tron, without the user, the path and the query
+func maskUrl(value string) string {
+	if value == "" {
+		return ""
+	}
+	if u, err := url.Parse(value); err == nil && u.Host != "" {
+		return u.Scheme + "://" + u.Host
+	}
+	if host := strings.Split(value, "/")[0]; host != "" && !strings.ContainsAny(host, "@?") {
+		return host
+	}
+	return "***"
+}
+
+func restartNote(path string) string {
+	for _, v := range restartPathList {
+		if strings.HasPrefix(path, v) {
+			return " (needs a restart)"
+		}
+	}
+	return ""
+}
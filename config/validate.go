@@ -0,0 +1,308 @@
+package config
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"fmt"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/dotbitHQ/das-lib/bitcoin"
+	"github.com/dotbitHQ/das-lib/common"
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/nervosnetwork/ckb-sdk-go/address"
+	"github.com/nervosnetwork/ckb-sdk-go/transaction"
+	"github.com/robfig/cron/v3"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+type validator struct {
+	errList []string
+}
+
+func (v *validator) add(format string, a ...interface{}) {
+	v.errList = append(v.errList, fmt.Sprintf(format, a...))
+}
+
+// Validate every problem of the config in one error, checked before it is swapped in
+func (c *CfgServer) Validate() error {
+	var v validator
+
+	switch c.Server.Net {
+	case common.DasNetTypeMainNet, common.DasNetTypeTestnet2, common.DasNetTypeTestnet3:
+	default:
+		v.add("server.net: %d invalid", c.Server.Net)
+	}
+	if c.Server.CronSpec != "" {
+		parser := cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+		if _, err := parser.Parse(c.Server.CronSpec); err != nil {
+			v.add("server.cron_spec: %s", err.Error())
+		}
+	}
+	v.url("server.remote_sign_api_url", c.Server.RemoteSignApiUrl, false)
+	v.url("server.prometheus_push_gateway", c.Server.PrometheusPushGateway, false)
+	for businessId, callbackUrl := range c.BusinessIds {
+		v.url("business_ids."+businessId, callbackUrl, false)
+	}
+	for businessId := range c.CallbackSign.BusinessMap {
+		if _, _, _, err := c.getCallbackSignKey(businessId); err != nil {
+			v.add("callback_sign.business_map.%s: %s", businessId, err.Error())
+		}
+	}
+	v.url("checkout.base_url", c.Checkout.BaseUrl, c.Checkout.Switch)
+	for i, item := range c.Notify.Notifiers {
+		v.notifier(fmt.Sprintf("notify.notifiers.%d", i), item)
+	}
+	if c.Admin.SecretKey != "" {
+		if key, err := hex.DecodeString(strings.TrimPrefix(c.Admin.SecretKey, "0x")); err != nil || len(key) != 32 {
+			v.add("admin.secret_key: not the hex of 32 bytes")
+		}
+	}
+	if c.NodePool.ErrRate < 0 || c.NodePool.ErrRate > 1 {
+		v.add("node_pool.err_rate: %v not in [0, 1]", c.NodePool.ErrRate)
+	}
+	v.chain(c)
+
+	if len(v.errList) == 0 {
+		return nil
+	}
+	sort.Strings(v.errList)
+	return fmt.Errorf("%s", strings.Join(v.errList, "; "))
+}
+
+// url an absolute http(s) url, or ws(s)
+func (v *validator) url(path, value string, required bool) {
+	if value == "" {
+		if required {
+			v.add("%s: required", path)
+		}
+		return
+	}
+	// the error of url.Parse quotes the whole url
+	u, err := url.Parse(value)
+	if err != nil {
+		v.add("%s: [%s] invalid", path, maskUrl(value))
+		return
+	}
+	switch u.Scheme {
+	case "http", "https", "ws", "wss":
+	default:
+		v.add("%s: scheme of [%s] invalid", path, maskUrl(value))
+		return
+	}
+	if u.Host == "" {
+		v.add("%s: host is empty", path)
+	}
+}
+
+// hostPort the grpc node of tron and the smtp server
+func (v *validator) hostPort(path, value string, required bool) {
+	if value == "" {
+		if required {
+			v.add("%s: required", path)
+		}
+		return
+	}
+	if _, _, err := net.SplitHostPort(value); err != nil {
+		v.add("%s: [%s] is not host:port", path, maskUrl(value))
+	}
+}
+
+// ws the new heads of an evm chain
+func (v *validator) ws(path, value string) {
+	v.url(path, value, false)
+	if value != "" && !strings.HasPrefix(value, "ws://") && !strings.HasPrefix(value, "wss://") {
+		v.add("%s: scheme of [%s] is not ws", path, maskUrl(value))
+	}
+}
+
+// nodes the node, or the list of nodes of a chain that replaces it
+func (v *validator) nodes(path, node string, nodes []NodeEndpoint, required, hostPort bool) {
+	if len(nodes) == 0 {
+		if hostPort {
+			v.hostPort(path+".node", node, required)
+		} else {
+			v.url(path+".node", node, required)
+		}
+		return
+	}
+	primary := 0
+	for i, item := range nodes {
+		itemPath := fmt.Sprintf("%s.nodes.%d.url", path, i)
+		if hostPort {
+			v.hostPort(itemPath, item.Url, true)
+		} else {
+			v.url(itemPath, item.Url, true)
+		}
+		if item.Weight < 0 {
+			v.add("%s.nodes.%d.weight: %d invalid", path, i, item.Weight)
+		}
+		if !item.Backup {
+			primary++
+		}
+	}
+	if primary == 0 {
+		v.add("%s.nodes: all backup", path)
+	}
+}
+
+func (v *validator) notifier(path string, item NotifierConf) {
+	switch item.Type {
+	case "lark":
+		if item.Key == "" {
+			v.add("%s.key: required", path)
+		}
+	case "slack", "webhook":
+		v.url(path+".url", item.Url, true)
+	case "telegram":
+		if item.BotToken == "" || item.ChatId == "" {
+			v.add("%s: bot_token and chat_id required", path)
+		}
+	case "smtp":
+		v.hostPort(path+".smtp_addr", item.SmtpAddr, true)
+		if len(item.To) == 0 {
+			v.add("%s.to: required", path)
+		}
+	default:
+		v.add("%s.type: [%s] invalid", path, item.Type)
+	}
+}
+
+func (v *validator) chain(c *CfgServer) {
+	ch := c.Chain
+	// the das core always dials the ckb node
+	v.url("chain.ckb.node", ch.Ckb.Node, true)
+	v.url("chain.dp.node", ch.DP.Node, false)
+	v.url("chain.dp.refund_url", ch.DP.RefundUrl, ch.DP.Refund)
+	v.nodes("chain.ckb", "", ch.Ckb.Nodes, false, false)
+	v.nodes("chain.eth", ch.Eth.Node, ch.Eth.Nodes, ch.Eth.Switch || ch.Eth.Refund, false)
+	v.nodes("chain.bsc", ch.Bsc.Node, ch.Bsc.Nodes, ch.Bsc.Switch || ch.Bsc.Refund, false)
+	v.nodes("chain.polygon", ch.Polygon.Node, ch.Polygon.Nodes, ch.Polygon.Switch || ch.Polygon.Refund, false)
+	v.nodes("chain.tron", ch.Tron.Node, ch.Tron.Nodes, ch.Tron.Switch || ch.Tron.Refund, true)
+	v.nodes("chain.doge", ch.Doge.Node, ch.Doge.Nodes, ch.Doge.Switch || ch.Doge.Refund, false)
+	v.ws("chain.eth.ws", ch.Eth.Ws)
+	v.ws("chain.bsc.ws", ch.Bsc.Ws)
+	v.ws("chain.polygon.ws", ch.Polygon.Ws)
+	if ch.Tron.Ws != "" {
+		v.add("chain.tron.ws: not supported")
+	}
+	v.url("chain.doge.proxy", ch.Doge.Proxy, false)
+
+	for addr, private := range ch.Ckb.AddrMap {
+		v.ckbAddr("chain.ckb.addr_map", addr, private)
+	}
+	for addr, private := range ch.Ckb.BalanceCheckMap {
+		v.ckbAddr("chain.ckb.balance_check_map", addr, private)
+	}
+	for addr, private := range ch.Eth.AddrMap {
+		v.evmAddr("chain.eth.addr_map", addr, private)
+	}
+	for addr, private := range ch.Bsc.AddrMap {
+		v.evmAddr("chain.bsc.addr_map", addr, private)
+	}
+	for addr, private := range ch.Polygon.AddrMap {
+		v.evmAddr("chain.polygon.addr_map", addr, private)
+	}
+	for addr, private := range ch.Tron.AddrMap {
+		v.tronAddr("chain.tron.addr_map", addr, private)
+	}
+	for addr, private := range ch.Doge.AddrMap {
+		v.dogeAddr("chain.doge.addr_map", addr, private)
+	}
+	// the private of the whitelist signs eip712, not the ckb lock
+	if ch.DP.TransferWhitelist != "" {
+		v.ckbAddr("chain.dp", ch.DP.TransferWhitelist, "")
+	}
+}
+
+// ckbAddr private is the key of the address in the addr_map, empty if it is signed remotely
+func (v *validator) ckbAddr(path, addr, private string) {
+	parseAddr, err := address.Parse(addr)
+	if err != nil {
+		v.add("%s.%s: address.Parse err: %s", path, addr, err.Error())
+		return
+	}
+	if private == "" || parseAddr.Script.CodeHash.String() != transaction.SECP256K1_BLAKE160_SIGHASH_ALL_TYPE_HASH {
+		return
+	}
+	key, err := crypto.HexToECDSA(strings.TrimPrefix(private, "0x"))
+	if err != nil {
+		v.add("%s.%s: private key invalid", path, addr)
+		return
+	}
+	args := common.Blake2b(crypto.CompressPubkey(&key.PublicKey))[:20]
+	if hex.EncodeToString(args) != hex.EncodeToString(parseAddr.Script.Args) {
+		v.add("%s.%s: private key does not match the address", path, addr)
+	}
+}
+
+func (v *validator) evmAddr(path, addr, private string) {
+	if !ethcommon.IsHexAddress(addr) {
+		v.add("%s.%s: address invalid", path, addr)
+		return
+	}
+	if private == "" {
+		return
+	}
+	key, err := crypto.HexToECDSA(strings.TrimPrefix(private, "0x"))
+	if err != nil {
+		v.add("%s.%s: private key invalid", path, addr)
+		return
+	}
+	if !strings.EqualFold(crypto.PubkeyToAddress(key.PublicKey).Hex(), addr) {
+		v.add("%s.%s: private key does not match the address", path, addr)
+	}
+}
+
+func (v *validator) tronAddr(path, addr, private string) {
+	addrHex, err := common.TronBase58ToHex(addr)
+	if err != nil {
+		v.add("%s.%s: TronBase58ToHex err: %s", path, addr, err.Error())
+		return
+	}
+	if private == "" {
+		return
+	}
+	key, err := crypto.HexToECDSA(strings.TrimPrefix(private, "0x"))
+	if err != nil {
+		v.add("%s.%s: private key invalid", path, addr)
+		return
+	}
+	if !strings.EqualFold(common.TronPreFix+hex.EncodeToString(crypto.PubkeyToAddress(key.PublicKey).Bytes()), addrHex) {
+		v.add("%s.%s: private key does not match the address", path, addr)
+	}
+}
+
+func (v *validator) dogeAddr(path, addr, private string) {
+	params := bitcoin.GetDogeMainNetParams()
+	if private == "" {
+		if _, err := btcutil.DecodeAddress(addr, &params); err != nil {
+			v.add("%s.%s: DecodeAddress err: %s", path, addr, err.Error())
+		}
+		return
+	}
+	if _, _, _, err := bitcoin.HexPrivateKeyToScript(addr, params, strings.TrimPrefix(private, "0x")); err != nil {
+		v.add("%s.%s: private key does not match the address: %s", path, addr, err.Error())
+	}
+}
+
+// getCallbackSignKey of the config c instead of the current one
+func (c *CfgServer) getCallbackSignKey(businessId string) (secrets []string, privateKey ed25519.PrivateKey, keyId string, e error) {
+	item := c.CallbackSign.BusinessMap[businessId]
+	secrets = item.Secrets
+	if len(secrets) > 2 {
+		secrets = secrets[:2]
+	}
+	keyId = item.KeyId
+	if item.Ed25519PrivateKey != "" {
+		seed, err := hex.DecodeString(strings.TrimPrefix(item.Ed25519PrivateKey, "0x"))
+		if err != nil || len(seed) != ed25519.SeedSize {
+			e = fmt.Errorf("ed25519_private_key of business[%s] invalid", businessId)
+			return
+		}
+		privateKey = ed25519.NewKeyFromSeed(seed)
+	}
+	return
+}
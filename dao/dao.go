@@ -1,6 +1,7 @@
 package dao
 
 import (
+	"database/sql"
 	"fmt"
 	"github.com/dotbitHQ/das-lib/http_api"
 	"gorm.io/gorm"
@@ -12,6 +13,11 @@ type DbDao struct {
 	db *gorm.DB
 }
 
+// SqlDB for the pool stats
+func (d *DbDao) SqlDB() (*sql.DB, error) {
+	return d.db.DB()
+}
+
 func NewGormDBNotAutoMigrate(dbMysql config.DbMysql) (*DbDao, error) {
 	db, err := http_api.NewGormDB(dbMysql.Addr, dbMysql.User, dbMysql.Password, dbMysql.DbName, 100, 100)
 	if err != nil {
@@ -32,6 +38,13 @@ func NewGormDB(dbMysql config.DbMysql) (*DbDao, error) {
 		&tables.TableOrderInfo{},
 		&tables.TablePaymentInfo{},
 		&tables.TableNoticeInfo{},
+		&tables.TableUniqueAmountInfo{},
+		&tables.TableIdempotencyInfo{},
+		&tables.TableBusinessInfo{},
+		&tables.TableOrderMetaIndex{},
+		&tables.TablePendingTxInfo{},
+		&tables.TableChainSwitchInfo{},
+		&tables.TableChainSwitchLog{},
 	); err != nil {
 		return nil, err
 	}
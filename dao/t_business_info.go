@@ -0,0 +1,38 @@
+package dao
+
+import (
+	"gorm.io/gorm/clause"
+	"unipay/tables"
+)
+
+func (d *DbDao) GetBusinessList() (list []tables.TableBusinessInfo, err error) {
+	err = d.db.Order("id").Find(&list).Error
+	return
+}
+
+func (d *DbDao) GetBusinessInfo(businessId string) (info tables.TableBusinessInfo, err error) {
+	err = d.db.Where("business_id=?", businessId).Limit(1).Find(&info).Error
+	return
+}
+
+// CreateBusinessInfo returns false if the business id exists
+func (d *DbDao) CreateBusinessInfo(info tables.TableBusinessInfo) (bool, error) {
+	res := d.db.Clauses(clause.Insert{
+		Modifier: "IGNORE",
+	}).Create(&info)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+func (d *DbDao) UpdateBusinessInfo(businessId string, updates map[string]interface{}) error {
+	return d.db.Model(tables.TableBusinessInfo{}).
+		Where("business_id=?", businessId).
+		Updates(updates).Error
+}
+
+func (d *DbDao) DeleteBusinessInfo(businessId string) error {
+	return d.db.Where("business_id=?", businessId).
+		Delete(&tables.TableBusinessInfo{}).Error
+}
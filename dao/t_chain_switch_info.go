@@ -0,0 +1,55 @@
+package dao
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"unipay/tables"
+)
+
+func (d *DbDao) GetChainSwitchList() (list []tables.TableChainSwitchInfo, err error) {
+	err = d.db.Order("id").Find(&list).Error
+	return
+}
+
+func (d *DbDao) GetChainSwitchInfo(chain string) (info tables.TableChainSwitchInfo, err error) {
+	err = d.db.Where("chain=?", chain).Limit(1).Find(&info).Error
+	return
+}
+
+// UpdateChainSwitch saves the state of the chain and the audit logs of the toggled targets together
+func (d *DbDao) UpdateChainSwitch(info tables.TableChainSwitchInfo, logList []tables.TableChainSwitchLog) error {
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{
+			DoUpdates: clause.AssignmentColumns([]string{
+				"parse_paused", "order_paused", "refund_paused", "operator", "reason",
+			}),
+		}).Create(&info).Error; err != nil {
+			return err
+		}
+		if len(logList) > 0 {
+			if err := tx.Create(&logList).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+type ChainSwitchLogParams struct {
+	Chain  string
+	Cursor uint64 // id of the last log of the previous page
+	Limit  int
+}
+
+// GetChainSwitchLogList logs in id desc
+func (d *DbDao) GetChainSwitchLogList(params ChainSwitchLogParams) (list []tables.TableChainSwitchLog, err error) {
+	db := d.db
+	if params.Chain != "" {
+		db = db.Where("chain=?", params.Chain)
+	}
+	if params.Cursor > 0 {
+		db = db.Where("id<?", params.Cursor)
+	}
+	err = db.Order("id DESC").Limit(params.Limit).Find(&list).Error
+	return
+}
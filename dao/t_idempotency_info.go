@@ -0,0 +1,54 @@
+package dao
+
+import (
+	"gorm.io/gorm/clause"
+	"time"
+	"unipay/tables"
+)
+
+// CreateIdempotency returns false if the key already exists for the business
+func (d *DbDao) CreateIdempotency(info tables.TableIdempotencyInfo) (bool, error) {
+	res := d.db.Clauses(clause.Insert{
+		Modifier: "IGNORE",
+	}).Create(&info)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+func (d *DbDao) GetIdempotency(businessId, idempotencyKey string) (info tables.TableIdempotencyInfo, err error) {
+	err = d.db.Where("business_id=? AND idempotency_key=?", businessId, idempotencyKey).
+		Limit(1).Find(&info).Error
+	return
+}
+
+// TakeOverIdempotency takes over a request lost while processing
+func (d *DbDao) TakeOverIdempotency(id uint64) (bool, error) {
+	res := d.db.Model(tables.TableIdempotencyInfo{}).
+		Where("id=? AND status=? AND updated_at<?",
+			id, tables.IdempotencyStatusProcessing, time.Now().Add(-tables.IdempotencyProcessingTimeout)).
+		Updates(map[string]interface{}{
+			"updated_at": time.Now(),
+		})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+func (d *DbDao) UpdateIdempotencyToDone(businessId, idempotencyKey, response string) error {
+	return d.db.Model(tables.TableIdempotencyInfo{}).
+		Where("business_id=? AND idempotency_key=? AND status=?",
+			businessId, idempotencyKey, tables.IdempotencyStatusProcessing).
+		Updates(map[string]interface{}{
+			"status":   tables.IdempotencyStatusDone,
+			"response": response,
+		}).Error
+}
+
+func (d *DbDao) DeleteIdempotency(businessId, idempotencyKey string) error {
+	return d.db.Where("business_id=? AND idempotency_key=? AND status=?",
+		businessId, idempotencyKey, tables.IdempotencyStatusProcessing).
+		Delete(&tables.TableIdempotencyInfo{}).Error
+}
@@ -1,15 +1,20 @@
 package dao
 
 import (
+	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 	"time"
 	"unipay/tables"
 )
 
-func (d *DbDao) Get24HUnNotifyList() (list []tables.TableNoticeInfo, err error) {
-	nowTimestamp := time.Now().Add(-time.Hour * 24).UnixMilli()
-	err = d.db.Where("timestamp>=? AND notice_status=?",
-		nowTimestamp, tables.NoticeStatusDefault).Find(&list).Error
+// GetDueNoticeList the notices due for the next attempt, the rows created before next_attempt_at
+// were given up after 24h and are left alone. A notice sent right after its insert has its
+// next_attempt_at at the first retry, so the timer does not send it at the same time
+func (d *DbDao) GetDueNoticeList(limit int) (list []tables.TableNoticeInfo, err error) {
+	now := time.Now()
+	err = d.db.Where("notice_status=? AND next_attempt_at<=? AND (next_attempt_at>0 OR timestamp>=?)",
+		tables.NoticeStatusDefault, now.UnixMilli(), now.Add(-time.Hour*24).UnixMilli()).
+		Order("next_attempt_at,id").Limit(limit).Find(&list).Error
 	return
 }
 
@@ -24,24 +29,85 @@ func (d *DbDao) UpdateNoticeStatusToOK(ids []uint64) error {
 		}).Error
 }
 
-func (d *DbDao) UpdateNoticeCount(id uint64, noticeCount int) error {
+// UpdateNoticeAttempt saves the failed attempt, the notice goes to the dead letter when notice_status is Fail
+func (d *DbDao) UpdateNoticeAttempt(notice tables.TableNoticeInfo) error {
 	return d.db.Model(tables.TableNoticeInfo{}).
-		Where("id=? AND notice_status=?", id, tables.NoticeStatusDefault).
+		Where("notice_id=? AND notice_status=?", notice.NoticeId, tables.NoticeStatusDefault).
 		Updates(map[string]interface{}{
-			"notice_count": noticeCount,
+			"notice_count":     notice.NoticeCount,
+			"notice_status":    notice.NoticeStatus,
+			"next_attempt_at":  notice.NextAttemptAt,
+			"last_error":       notice.LastError,
+			"last_http_status": notice.LastHttpStatus,
 		}).Error
 }
 
-func (d *DbDao) UpdateNoticeStatusToFail(id uint64) error {
+func (d *DbDao) CreateNoticeList(list []tables.TableNoticeInfo) error {
+	return d.db.Clauses(clause.Insert{
+		Modifier: "IGNORE",
+	}).Create(&list).Error
+}
+
+func (d *DbDao) UpdateNoticeStatusToOKByNoticeId(noticeId string) error {
 	return d.db.Model(tables.TableNoticeInfo{}).
-		Where("id=? AND notice_status=?", id, tables.NoticeStatusDefault).
+		Where("notice_id=? AND notice_status=?", noticeId, tables.NoticeStatusDefault).
 		Updates(map[string]interface{}{
-			"notice_status": tables.NoticeStatusFail,
+			"notice_status": tables.NoticeStatusOK,
 		}).Error
 }
 
-func (d *DbDao) CreateNoticeList(list []tables.TableNoticeInfo) error {
-	return d.db.Clauses(clause.Insert{
-		Modifier: "IGNORE",
-	}).Create(&list).Error
+type NoticeListParams struct {
+	BusinessId   string
+	NoticeIds    []string
+	EventType    tables.EventType
+	NoticeStatus *tables.NoticeStatus
+	BeginTime    int64
+	EndTime      int64
+	Cursor       uint64 // id of the last notice of the previous page
+	Limit        int
+}
+
+func (p *NoticeListParams) where(db *gorm.DB) *gorm.DB {
+	if p.BusinessId != "" {
+		db = db.Where("business_id=?", p.BusinessId)
+	}
+	if len(p.NoticeIds) > 0 {
+		db = db.Where("notice_id IN(?)", p.NoticeIds)
+	}
+	if p.EventType != "" {
+		db = db.Where("event_type=?", p.EventType)
+	}
+	if p.NoticeStatus != nil {
+		db = db.Where("notice_status=?", *p.NoticeStatus)
+	}
+	if p.BeginTime > 0 {
+		db = db.Where("timestamp>=?", p.BeginTime)
+	}
+	if p.EndTime > 0 {
+		db = db.Where("timestamp<?", p.EndTime)
+	}
+	return db
+}
+
+// GetNoticeList notices in id desc, filtered by the non-empty params
+func (d *DbDao) GetNoticeList(params NoticeListParams) (list []tables.TableNoticeInfo, err error) {
+	db := params.where(d.db)
+	if params.Cursor > 0 {
+		db = db.Where("id<?", params.Cursor)
+	}
+	err = db.Order("id DESC").Limit(params.Limit).Find(&list).Error
+	return
+}
+
+// ReplayNoticeList puts the matched notices back to the queue with a fresh retry schedule
+func (d *DbDao) ReplayNoticeList(params NoticeListParams) (int64, error) {
+	res := params.where(d.db.Model(tables.TableNoticeInfo{})).
+		Updates(map[string]interface{}{
+			"notice_count":     0,
+			"notice_status":    tables.NoticeStatusDefault,
+			"next_attempt_at":  time.Now().UnixMilli(),
+			"last_error":       "",
+			"last_http_status": 0,
+		})
+	return res.RowsAffected, res.Error
 }
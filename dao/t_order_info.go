@@ -1,8 +1,12 @@
 package dao
 
 import (
+	"fmt"
 	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+	"unipay/config"
 	"unipay/tables"
 )
 
@@ -11,6 +15,9 @@ func (d *DbDao) CreateOrderInfoWithPaymentInfo(orderInfo tables.TableOrderInfo,
 		if err := tx.Create(&orderInfo).Error; err != nil {
 			return err
 		}
+		if err := createOrderMetaIndex(tx, orderInfo); err != nil {
+			return err
+		}
 		if paymentInfo.PayHash != "" {
 			if err := tx.Create(&paymentInfo).Error; err != nil {
 				return err
@@ -27,6 +34,9 @@ func (d *DbDao) CreateOrderInfNoNeedPay(orderInfo tables.TableOrderInfo, payment
 		if err := tx.Create(&orderInfo).Error; err != nil {
 			return err
 		}
+		if err := createOrderMetaIndex(tx, orderInfo); err != nil {
+			return err
+		}
 		if err := tx.Create(&paymentInfo).Error; err != nil {
 			return err
 		}
@@ -37,6 +47,14 @@ func (d *DbDao) CreateOrderInfNoNeedPay(orderInfo tables.TableOrderInfo, payment
 	})
 }
 
+func createOrderMetaIndex(tx *gorm.DB, orderInfo tables.TableOrderInfo) error {
+	list := orderInfo.GetMetaIndexList(config.GetOrderMetaIndexedKeys())
+	if len(list) == 0 {
+		return nil
+	}
+	return tx.Create(&list).Error
+}
+
 func (d *DbDao) GetOrderInfo(orderId, businessId string) (info tables.TableOrderInfo, err error) {
 	err = d.db.Where("order_id=? AND business_id=?",
 		orderId, businessId).Find(&info).Error
@@ -53,16 +71,20 @@ func (d *DbDao) GetOrderInfoByOrderIdWithAddr(orderId, receiptAddr string) (info
 	return
 }
 
+// orderNormalFirst a cancelled order of the same payer and amount does not take the transfer of a live one,
+// it only gets the transfer as a late payment without a live one
+var orderNormalFirst = fmt.Sprintf("order_status=%d DESC, id DESC", tables.OrderStatusNormal)
+
 func (d *DbDao) GetOrderByAddrWithAmount(addr string, payTokenId tables.PayTokenId, amount decimal.Decimal) (order tables.TableOrderInfo, err error) {
 	err = d.db.Where("pay_address=? AND pay_token_id=? AND amount=? AND pay_status=?", addr, payTokenId, amount, tables.PayStatusUnpaid).
-		Order("id DESC").Limit(1).Find(&order).Error
+		Order(orderNormalFirst).Limit(1).Find(&order).Error
 	return
 }
 
 func (d *DbDao) GetOrderByAddrWithAmountAndAddr(addr, receiptAddr string, payTokenId tables.PayTokenId, amount decimal.Decimal) (order tables.TableOrderInfo, err error) {
 	err = d.db.Where("pay_address=? AND payment_address=? AND pay_token_id=? AND amount=? AND pay_status=?",
 		addr, receiptAddr, payTokenId, amount, tables.PayStatusUnpaid).
-		Order("id DESC").Limit(1).Find(&order).Error
+		Order(orderNormalFirst).Limit(1).Find(&order).Error
 	return
 }
 
@@ -72,3 +94,141 @@ func (d *DbDao) GetLatestOrderByAddrWithAmountAndAddr(addr, receiptAddr string,
 		Order("id DESC").Limit(1).Find(&order).Error
 	return
 }
+
+// GetOpenOrderCount unpaid orders of the payer that can still be paid
+func (d *DbDao) GetOpenOrderCount(businessId, payAddress string) (count int64, err error) {
+	now := time.Now().UnixMilli()
+	err = d.db.Model(tables.TableOrderInfo{}).
+		Where("business_id=? AND pay_address=? AND timestamp>=? AND pay_status=? AND order_status=? AND (expired_at=0 OR expired_at>?)",
+			businessId, payAddress, tables.GetEfficientOrderTimestamp(), tables.PayStatusUnpaid, tables.OrderStatusNormal, now).
+		Count(&count).Error
+	return
+}
+
+// UpdateOrderToCancel cancels an unpaid order, returns false if the order is no longer cancelable
+func (d *DbDao) UpdateOrderToCancel(orderId string, noticeInfo tables.TableNoticeInfo) (ok bool, e error) {
+	e = d.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(tables.TableOrderInfo{}).
+			Where("order_id=? AND pay_status=? AND order_status=?",
+				orderId, tables.PayStatusUnpaid, tables.OrderStatusNormal).
+			Updates(map[string]interface{}{
+				"order_status": tables.OrderStatusCancel,
+			})
+		if res.Error != nil {
+			return res.Error
+		} else if res.RowsAffected == 0 {
+			return nil
+		}
+		ok = true
+
+		if err := tx.Model(tables.TableUniqueAmountInfo{}).
+			Where("order_id=? AND status=?",
+				orderId, tables.UniqueAmountStatusOccupied).
+			Updates(map[string]interface{}{
+				"status": tables.UniqueAmountStatusReleased,
+			}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(tables.TablePaymentInfo{}).
+			Where("order_id=? AND pay_hash_status=?",
+				orderId, tables.PayHashStatusPending).
+			Updates(map[string]interface{}{
+				"pay_hash_status": tables.PayHashStatusFail,
+			}).Error; err != nil {
+			return err
+		}
+
+		return tx.Clauses(clause.Insert{
+			Modifier: "IGNORE",
+		}).Create(&noticeInfo).Error
+	})
+	if e != nil {
+		ok = false
+	}
+	return
+}
+
+// UpdateOrderResult sets the fulfilment result of a paid order reported by the business,
+// the payments are queued for refund if refund is true
+func (d *DbDao) UpdateOrderResult(orderId string, orderStatus tables.OrderStatus, refund bool) (ok bool, e error) {
+	e = d.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(tables.TableOrderInfo{}).
+			Where("order_id=? AND pay_status=? AND order_status=?",
+				orderId, tables.PayStatusPaid, tables.OrderStatusNormal).
+			Updates(map[string]interface{}{
+				"order_status": orderStatus,
+			})
+		if res.Error != nil {
+			return res.Error
+		} else if res.RowsAffected == 0 {
+			return nil
+		}
+		ok = true
+
+		if !refund {
+			return nil
+		}
+		return tx.Model(tables.TablePaymentInfo{}).
+			Where("order_id=? AND pay_hash_status=? AND refund_status=?",
+				orderId, tables.PayHashStatusConfirm, tables.RefundStatusDefault).
+			Updates(map[string]interface{}{
+				"refund_status": tables.RefundStatusUnRefund,
+			}).Error
+	})
+	if e != nil {
+		ok = false
+	}
+	return
+}
+
+type OrderListParams struct {
+	BusinessId     string
+	PayAddressList []string // the forms of one address
+	PayTokenId     tables.PayTokenId
+	PayStatus      *tables.PayStatus
+	OrderStatus    *tables.OrderStatus
+	RefundStatus   *tables.RefundStatus
+	MetaKey        string // an indexed key, with MetaValue
+	MetaValue      string
+	BeginTime      int64
+	EndTime        int64
+	Cursor         uint64 // id of the last order of the previous page
+	Limit          int
+}
+
+// GetOrderList orders of a business in id desc, filtered by the non-empty params
+func (d *DbDao) GetOrderList(params OrderListParams) (list []tables.TableOrderInfo, err error) {
+	db := d.db.Where("business_id=?", params.BusinessId)
+	if len(params.PayAddressList) > 0 {
+		db = db.Where("pay_address IN(?)", params.PayAddressList)
+	}
+	if params.PayTokenId != "" {
+		db = db.Where("pay_token_id=?", params.PayTokenId)
+	}
+	if params.PayStatus != nil {
+		db = db.Where("pay_status=?", *params.PayStatus)
+	}
+	if params.OrderStatus != nil {
+		db = db.Where("order_status=?", *params.OrderStatus)
+	}
+	if params.BeginTime > 0 {
+		db = db.Where("timestamp>=?", params.BeginTime)
+	}
+	if params.EndTime > 0 {
+		db = db.Where("timestamp<?", params.EndTime)
+	}
+	if params.RefundStatus != nil {
+		db = db.Where(fmt.Sprintf("EXISTS(SELECT 1 FROM %s p WHERE p.order_id=%s.order_id AND p.refund_status=?)",
+			tables.TableNamePaymentInfo, tables.TableNameOrderInfo), *params.RefundStatus)
+	}
+	if params.MetaKey != "" {
+		db = db.Where(fmt.Sprintf("EXISTS(SELECT 1 FROM %s m WHERE m.order_id=%s.order_id AND m.business_id=? AND m.meta_key=? AND m.meta_value=?)",
+			tables.TableNameOrderMetaIndex, tables.TableNameOrderInfo), params.BusinessId, params.MetaKey, params.MetaValue)
+	}
+	if params.Cursor > 0 {
+		db = db.Where("id<?", params.Cursor)
+	}
+	err = db.Order("id DESC").Limit(params.Limit).Find(&list).Error
+	return
+}
@@ -2,6 +2,7 @@ package dao
 
 import (
 	"fmt"
+	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 	"time"
@@ -23,19 +24,79 @@ func (d *DbDao) UpdatePaymentInfoToUnRefunded(payHash string) error {
 		}).Error
 }
 
+// UpdatePaymentInfoToPendingApproval fromStatus is RefundStatusDefault for a refund request of the business,
+// or RefundStatusUnRefund for a refund held by the refund executor
+func (d *DbDao) UpdatePaymentInfoToPendingApproval(payHash string, fromStatus tables.RefundStatus) error {
+	return d.db.Model(tables.TablePaymentInfo{}).
+		Where("pay_hash=? AND pay_hash_status=? AND refund_status=? AND refund_approved_at=0",
+			payHash, tables.PayHashStatusConfirm, fromStatus).
+		Updates(map[string]interface{}{
+			"refund_status": tables.RefundStatusPendingApproval,
+		}).Error
+}
+
+func (d *DbDao) GetPendingApprovalRefundList() (list []tables.TablePaymentInfo, err error) {
+	err = d.db.Where("pay_hash_status=? AND refund_status=?",
+		tables.PayHashStatusConfirm, tables.RefundStatusPendingApproval).
+		Order("id").Find(&list).Error
+	return
+}
+
+func (d *DbDao) UpdatePendingApprovalToUnRefunded(payHashList []string) (int64, error) {
+	res := d.db.Model(tables.TablePaymentInfo{}).
+		Where("pay_hash IN(?) AND pay_hash_status=? AND refund_status=?",
+			payHashList, tables.PayHashStatusConfirm, tables.RefundStatusPendingApproval).
+		Updates(map[string]interface{}{
+			"refund_status":      tables.RefundStatusUnRefund,
+			"refund_approved_at": time.Now().UnixMilli(),
+		})
+	return res.RowsAffected, res.Error
+}
+
+func (d *DbDao) UpdatePendingApprovalToRejected(payHashList []string) (int64, error) {
+	res := d.db.Model(tables.TablePaymentInfo{}).
+		Where("pay_hash IN(?) AND pay_hash_status=? AND refund_status=?",
+			payHashList, tables.PayHashStatusConfirm, tables.RefundStatusPendingApproval).
+		Updates(map[string]interface{}{
+			"refund_status": tables.RefundStatusRefuseToRefund,
+		})
+	return res.RowsAffected, res.Error
+}
+
 func (d *DbDao) CreatePayment(paymentInfo tables.TablePaymentInfo) error {
 	return d.db.Clauses(clause.Insert{
 		Modifier: "IGNORE",
 	}).Create(&paymentInfo).Error
 }
 
-func (d *DbDao) UpdatePaymentStatus(paymentInfo tables.TablePaymentInfo, noticeInfo tables.TableNoticeInfo) error {
-	return d.db.Transaction(func(tx *gorm.DB) error {
-		if err := tx.Model(tables.TableOrderInfo{}).
-			Where("order_id=? AND pay_status=?",
-				paymentInfo.OrderId, tables.PayStatusUnpaid).
+// UpdatePaymentStatus credits a normal order, or records a repeated payment of a paid one,
+// returns false without any change if the order was cancelled or failed unpaid meanwhile
+func (d *DbDao) UpdatePaymentStatus(paymentInfo tables.TablePaymentInfo, noticeInfo tables.TableNoticeInfo) (ok bool, e error) {
+	e = d.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(tables.TableOrderInfo{}).
+			Where("order_id=? AND pay_status=? AND order_status=?",
+				paymentInfo.OrderId, tables.PayStatusUnpaid, tables.OrderStatusNormal).
 			Updates(map[string]interface{}{
 				"pay_status": tables.PayStatusPaid,
+			})
+		if res.Error != nil {
+			return res.Error
+		} else if res.RowsAffected == 0 {
+			var order tables.TableOrderInfo
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("order_id=?", paymentInfo.OrderId).Find(&order).Error; err != nil {
+				return err
+			} else if order.PayStatus != tables.PayStatusPaid {
+				return nil
+			}
+		}
+		ok = true
+
+		if err := tx.Model(tables.TableUniqueAmountInfo{}).
+			Where("order_id=? AND status=?",
+				paymentInfo.OrderId, tables.UniqueAmountStatusOccupied).
+			Updates(map[string]interface{}{
+				"status": tables.UniqueAmountStatusReleased,
 			}).Error; err != nil {
 			return err
 		}
@@ -66,11 +127,36 @@ func (d *DbDao) UpdatePaymentStatus(paymentInfo tables.TablePaymentInfo, noticeI
 		}
 		return nil
 	})
+	if e != nil {
+		ok = false
+	}
+	return
+}
+
+// CreateLatePayment the payment of a cancelled or expired order goes to the refund,
+// also the pending stripe payment of the order
+func (d *DbDao) CreateLatePayment(paymentInfo tables.TablePaymentInfo) error {
+	paymentInfo.PayHashStatus = tables.PayHashStatusConfirm
+	paymentInfo.RefundStatus = tables.RefundStatusUnRefund
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Insert{
+			Modifier: "IGNORE",
+		}).Create(&paymentInfo).Error; err != nil {
+			return err
+		}
+		return tx.Model(tables.TablePaymentInfo{}).
+			Where("pay_hash=? AND refund_status=?", paymentInfo.PayHash, tables.RefundStatusDefault).
+			Updates(map[string]interface{}{
+				"pay_hash_status": tables.PayHashStatusConfirm,
+				"refund_status":   tables.RefundStatusUnRefund,
+			}).Error
+	})
 }
 
+// GetViewRefundListWithin3d the approved refunds are exempt from the 3 days, they may wait longer for the approval
 func (d *DbDao) GetViewRefundListWithin3d() (list []tables.ViewRefundPaymentInfo, err error) {
 	timestamp := time.Now().Add(-time.Hour * 24 * 3).UnixMilli()
-	sql := fmt.Sprintf(`SELECT p.*,o.business_id,o.payment_address,o.premium_percentage,o.premium_base FROM %s p LEFT JOIN %s o ON o.order_id=p.order_id WHERE p.timestamp>=? AND p.order_id!='' AND p.pay_hash_status=? AND p.refund_status=?`,
+	sql := fmt.Sprintf(`SELECT p.*,o.business_id,o.payment_address,o.premium_percentage,o.premium_base,o.meta_data FROM %s p LEFT JOIN %s o ON o.order_id=p.order_id WHERE (p.timestamp>=? OR p.refund_approved_at>0) AND p.order_id!='' AND p.pay_hash_status=? AND p.refund_status=?`,
 		tables.TableNamePaymentInfo, tables.TableNameOrderInfo)
 	err = d.db.Raw(sql, timestamp, tables.PayHashStatusConfirm, tables.RefundStatusUnRefund).Find(&list).Error
 	return
@@ -229,9 +315,41 @@ func (d *DbDao) GetPaymentInfoByOrderId(orderId string) (info tables.TablePaymen
 	return
 }
 
+type RefundQueueCount struct {
+	RefundStatus tables.RefundStatus `json:"refund_status" gorm:"column:refund_status"`
+	Count        int64               `json:"count" gorm:"column:count"`
+}
+
+// GetRefundQueueCount the confirmed payments waiting for the refund, by refund status
+func (d *DbDao) GetRefundQueueCount() (list []RefundQueueCount, err error) {
+	err = d.db.Model(tables.TablePaymentInfo{}).
+		Select("refund_status, COUNT(*) AS count").
+		Where("pay_hash_status=? AND refund_status IN(?)", tables.PayHashStatusConfirm,
+			[]tables.RefundStatus{tables.RefundStatusUnRefund, tables.RefundStatusRefunding, tables.RefundStatusPendingApproval}).
+		Group("refund_status").Scan(&list).Error
+	return
+}
+
 func (d *DbDao) GetUnRefundTxCount() (count int64, err error) {
 	err = d.db.Model(tables.TablePaymentInfo{}).
 		Where("pay_hash_status=? AND refund_status=?",
 			tables.PayHashStatusConfirm, tables.RefundStatusUnRefund).Count(&count).Error
 	return
 }
+
+type RefundNeed struct {
+	PaymentAddress string            `json:"payment_address" gorm:"column:payment_address"`
+	PayTokenId     tables.PayTokenId `json:"pay_token_id" gorm:"column:pay_token_id"`
+	Count          int64             `json:"count" gorm:"column:count"`
+	Amount         decimal.Decimal   `json:"amount" gorm:"column:amount"`
+}
+
+// GetRefundNeedList the un-refunded and pending approval payments of GetViewRefundListWithin3d, by the refund wallet
+func (d *DbDao) GetRefundNeedList() (list []RefundNeed, err error) {
+	timestamp := time.Now().Add(-time.Hour * 24 * 3).UnixMilli()
+	sql := fmt.Sprintf(`SELECT o.payment_address,p.pay_token_id,COUNT(*) AS count,SUM(p.amount) AS amount FROM %s p LEFT JOIN %s o ON o.order_id=p.order_id WHERE (p.timestamp>=? OR p.refund_approved_at>0) AND p.order_id!='' AND p.pay_hash_status=? AND p.refund_status IN(?) GROUP BY o.payment_address,p.pay_token_id`,
+		tables.TableNamePaymentInfo, tables.TableNameOrderInfo)
+	err = d.db.Raw(sql, timestamp, tables.PayHashStatusConfirm,
+		[]tables.RefundStatus{tables.RefundStatusUnRefund, tables.RefundStatusPendingApproval}).Scan(&list).Error
+	return
+}
@@ -0,0 +1,44 @@
+package dao
+
+import (
+	"gorm.io/gorm/clause"
+	"unipay/tables"
+)
+
+// CreatePendingTx false if the tx was already seen
+func (d *DbDao) CreatePendingTx(info tables.TablePendingTxInfo) (bool, error) {
+	res := d.db.Clauses(clause.Insert{
+		Modifier: "IGNORE",
+	}).Create(&info)
+	return res.RowsAffected > 0, res.Error
+}
+
+func (d *DbDao) GetPendingTxList(parserType tables.ParserType, status tables.PendingTxStatus, limit int) (list []tables.TablePendingTxInfo, err error) {
+	err = d.db.Where("parser_type=? AND status=?", parserType, status).
+		Order("id").Limit(limit).Find(&list).Error
+	return
+}
+
+// GetPendingTxByOrderId the latest seen tx of the order
+func (d *DbDao) GetPendingTxByOrderId(orderId string) (info tables.TablePendingTxInfo, err error) {
+	err = d.db.Where("order_id=? AND status=?", orderId, tables.PendingTxStatusSeen).
+		Order("id DESC").Limit(1).Find(&info).Error
+	return
+}
+
+func (d *DbDao) UpdatePendingTxConfirmations(payHash string, blockNumber, confirmations uint64) error {
+	return d.db.Model(tables.TablePendingTxInfo{}).
+		Where("pay_hash=? AND status=?", payHash, tables.PendingTxStatusSeen).
+		Updates(map[string]interface{}{
+			"block_number":  blockNumber,
+			"confirmations": confirmations,
+		}).Error
+}
+
+func (d *DbDao) UpdatePendingTxStatus(payHash string, oldStatus, newStatus tables.PendingTxStatus) error {
+	return d.db.Model(tables.TablePendingTxInfo{}).
+		Where("pay_hash=? AND status=?", payHash, oldStatus).
+		Updates(map[string]interface{}{
+			"status": newStatus,
+		}).Error
+}
@@ -0,0 +1,68 @@
+package dao
+
+import (
+	"fmt"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+	"unipay/tables"
+)
+
+// ClaimUniqueAmount occupies the amount slot of payment_address + pay_token_id for the order,
+// a slot which is released or expired can be reused, otherwise the collision is counted
+func (d *DbDao) ClaimUniqueAmount(info tables.TableUniqueAmountInfo) (ok bool, err error) {
+	err = d.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(tables.TableUniqueAmountInfo{}).
+			Where("payment_address=? AND pay_token_id=? AND amount=? AND (status=? OR expired_at<?)",
+				info.PaymentAddress, info.PayTokenId, info.Amount, tables.UniqueAmountStatusReleased, time.Now().UnixMilli()).
+			Updates(map[string]interface{}{
+				"order_id":   info.OrderId,
+				"status":     tables.UniqueAmountStatusOccupied,
+				"expired_at": info.ExpiredAt,
+			})
+		if res.Error != nil {
+			return res.Error
+		} else if res.RowsAffected > 0 {
+			ok = true
+			return nil
+		}
+
+		res = tx.Clauses(clause.Insert{
+			Modifier: "IGNORE",
+		}).Create(&info)
+		if res.Error != nil {
+			return res.Error
+		} else if res.RowsAffected > 0 {
+			ok = true
+			return nil
+		}
+
+		if err := tx.Model(tables.TableUniqueAmountInfo{}).
+			Where("payment_address=? AND pay_token_id=? AND amount=?",
+				info.PaymentAddress, info.PayTokenId, info.Amount).
+			Updates(map[string]interface{}{
+				"collision_count": gorm.Expr("collision_count+1"),
+			}).Error; err != nil {
+			return fmt.Errorf("update collision_count err: %s", err.Error())
+		}
+		return nil
+	})
+	return
+}
+
+func (d *DbDao) ReleaseUniqueAmount(orderId string) error {
+	return d.db.Model(tables.TableUniqueAmountInfo{}).
+		Where("order_id=? AND status=?", orderId, tables.UniqueAmountStatusOccupied).
+		Updates(map[string]interface{}{
+			"status": tables.UniqueAmountStatusReleased,
+		}).Error
+}
+
+func (d *DbDao) GetOrderByUniqueAmount(receiptAddr string, payTokenId tables.PayTokenId, amount decimal.Decimal) (order tables.TableOrderInfo, err error) {
+	sql := fmt.Sprintf(`SELECT o.* FROM %s u JOIN %s o ON o.order_id=u.order_id WHERE u.payment_address=? AND u.pay_token_id=? AND u.amount=? AND u.status=? AND u.expired_at>=? AND o.pay_status=? LIMIT 1`,
+		tables.TableNameUniqueAmountInfo, tables.TableNameOrderInfo)
+	err = d.db.Raw(sql, receiptAddr, payTokenId, amount, tables.UniqueAmountStatusOccupied,
+		time.Now().UnixMilli(), tables.PayStatusUnpaid).Find(&order).Error
+	return
+}
@@ -0,0 +1,97 @@
+package eventbus
+
+import (
+	"github.com/dotbitHQ/das-lib/http_api/logger"
+	"sync"
+	"time"
+)
+
+var log = logger.NewLogger("eventbus", logger.LevelDebug)
+
+// the order events are in-process, a subscriber in another process (e.g. cmd/refund) sees nothing,
+// the order stream re-checks the db for those
+var bus = &Bus{subs: make(map[string]map[*Subscription]struct{})}
+
+type OrderStatus string
+
+const (
+	OrderStatusPending    OrderStatus = "pending"
+	OrderStatusSeen       OrderStatus = "seen"       // in the mempool
+	OrderStatusConfirming OrderStatus = "confirming" // in a block, waiting for the confirmations
+	OrderStatusPaid       OrderStatus = "paid"
+	OrderStatusExpired    OrderStatus = "expired"
+	OrderStatusCancelled  OrderStatus = "cancelled"
+	OrderStatusDisputed   OrderStatus = "disputed"
+	OrderStatusRefunding  OrderStatus = "refunding"
+	OrderStatusRefunded   OrderStatus = "refunded"
+)
+
+// IsFinal no more events are expected for the order
+func (o OrderStatus) IsFinal() bool {
+	switch o {
+	case OrderStatusExpired, OrderStatusCancelled, OrderStatusDisputed, OrderStatusRefunded:
+		return true
+	}
+	return false
+}
+
+type OrderEvent struct {
+	OrderId       string      `json:"order_id"`
+	Status        OrderStatus `json:"status"`
+	PayHash       string      `json:"pay_hash"`
+	Confirmations uint64      `json:"confirmations"`
+	ConfirmNum    uint64      `json:"confirm_num"` // required by the parser, 0 if unknown
+	Timestamp     int64       `json:"timestamp"`
+}
+
+type Bus struct {
+	lock sync.RWMutex
+	subs map[string]map[*Subscription]struct{}
+}
+
+type Subscription struct {
+	C       chan OrderEvent
+	orderId string
+	once    sync.Once
+}
+
+const subscriptionBufferSize = 16
+
+// Subscribe call Close when done
+func Subscribe(orderId string) *Subscription {
+	s := &Subscription{C: make(chan OrderEvent, subscriptionBufferSize), orderId: orderId}
+	bus.lock.Lock()
+	defer bus.lock.Unlock()
+	if _, ok := bus.subs[orderId]; !ok {
+		bus.subs[orderId] = make(map[*Subscription]struct{})
+	}
+	bus.subs[orderId][s] = struct{}{}
+	return s
+}
+
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		bus.lock.Lock()
+		defer bus.lock.Unlock()
+		delete(bus.subs[s.orderId], s)
+		if len(bus.subs[s.orderId]) == 0 {
+			delete(bus.subs, s.orderId)
+		}
+	})
+}
+
+// Publish never blocks, the event is dropped for a subscriber that does not keep up
+func Publish(event OrderEvent) {
+	if event.Timestamp == 0 {
+		event.Timestamp = time.Now().UnixMilli()
+	}
+	bus.lock.RLock()
+	defer bus.lock.RUnlock()
+	for s := range bus.subs[event.OrderId] {
+		select {
+		case s.C <- event:
+		default:
+			log.Warn("Publish dropped:", event.OrderId, event.Status)
+		}
+	}
+}
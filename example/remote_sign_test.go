@@ -101,10 +101,10 @@ func TestRemoteSignTron(t *testing.T) {
 func TestRemoteSignDoge(t *testing.T) {
 	_ = config.InitCfg("../config/config.yaml")
 	br := bitcoin.BaseRequest{
-		RpcUrl:   config.Cfg.Chain.Doge.Node,
-		User:     config.Cfg.Chain.Doge.User,
-		Password: config.Cfg.Chain.Doge.Password,
-		Proxy:    config.Cfg.Chain.Doge.Proxy,
+		RpcUrl:   config.GetCfg().Chain.Doge.Node,
+		User:     config.GetCfg().Chain.Doge.User,
+		Password: config.GetCfg().Chain.Doge.Password,
+		Proxy:    config.GetCfg().Chain.Doge.Proxy,
 	}
 	txTool := bitcoin.TxTool{
 		RpcClient:        &br,
@@ -421,10 +421,10 @@ func TestEvmTx2(t *testing.T) {
 func TestDogeTx(t *testing.T) {
 	_ = config.InitCfg("../config/config.yaml")
 	br := bitcoin.BaseRequest{
-		RpcUrl:   config.Cfg.Chain.Doge.Node,
-		User:     config.Cfg.Chain.Doge.User,
-		Password: config.Cfg.Chain.Doge.Password,
-		Proxy:    config.Cfg.Chain.Doge.Proxy,
+		RpcUrl:   config.GetCfg().Chain.Doge.Node,
+		User:     config.GetCfg().Chain.Doge.User,
+		Password: config.GetCfg().Chain.Doge.Password,
+		Proxy:    config.GetCfg().Chain.Doge.Proxy,
 	}
 	txTool := bitcoin.TxTool{
 		RpcClient:        &br,
@@ -464,10 +464,10 @@ func TestDogeTx(t *testing.T) {
 func TestDogeTx2(t *testing.T) {
 	_ = config.InitCfg("../config/config.yaml")
 	br := bitcoin.BaseRequest{
-		RpcUrl:   config.Cfg.Chain.Doge.Node,
-		User:     config.Cfg.Chain.Doge.User,
-		Password: config.Cfg.Chain.Doge.Password,
-		Proxy:    config.Cfg.Chain.Doge.Proxy,
+		RpcUrl:   config.GetCfg().Chain.Doge.Node,
+		User:     config.GetCfg().Chain.Doge.User,
+		Password: config.GetCfg().Chain.Doge.Password,
+		Proxy:    config.GetCfg().Chain.Doge.Proxy,
 	}
 	txTool := bitcoin.TxTool{
 		RpcClient:        &br,
@@ -528,10 +528,10 @@ func txDoge(txTool bitcoin.TxTool, orderId, addrFrom, addrTo, fromKey string, pa
 func TestDogeTx3(t *testing.T) {
 	_ = config.InitCfg("../config/config.yaml")
 	br := bitcoin.BaseRequest{
-		RpcUrl:   config.Cfg.Chain.Doge.Node,
-		User:     config.Cfg.Chain.Doge.User,
-		Password: config.Cfg.Chain.Doge.Password,
-		Proxy:    config.Cfg.Chain.Doge.Proxy,
+		RpcUrl:   config.GetCfg().Chain.Doge.Node,
+		User:     config.GetCfg().Chain.Doge.User,
+		Password: config.GetCfg().Chain.Doge.Password,
+		Proxy:    config.GetCfg().Chain.Doge.Proxy,
 	}
 	data, err := br.GetRawTransaction("ccd286a447d16cf5f166edac21b4b5f25df45612c19569043c1283d97fdc0189")
 	if err != nil {
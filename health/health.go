@@ -0,0 +1,281 @@
+package health
+
+import (
+	"fmt"
+	"github.com/dotbitHQ/das-lib/http_api/logger"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unipay/config"
+	"unipay/notify"
+	"unipay/tables"
+	"unipay/txtool"
+)
+
+var log = logger.NewLogger("health", logger.LevelDebug)
+
+type Status string
+
+const (
+	StatusHealthy   Status = "healthy"
+	StatusUnhealthy Status = "unhealthy"
+	StatusUnknown   Status = "unknown" // no parser of the chain in this process
+)
+
+// the fixed reason codes of the public health, the details stay in the alerts and the admin api
+const (
+	ReasonStale      = "stale"
+	ReasonNodeErr    = "node_error"
+	ReasonLag        = "lag"
+	ReasonRecovering = "recovering"
+)
+
+const (
+	defaultMaxLag         = 100
+	defaultNodeErrCount   = 3
+	defaultStaleSeconds   = 300
+	defaultRecoverSeconds = 60
+)
+
+// ChainHealth the breaker of a chain is open while it is unhealthy,
+// and only closes after it has been healthy for recover_seconds
+type ChainHealth struct {
+	ParserType         tables.ParserType `json:"parser_type"`
+	Chain              string            `json:"chain"`
+	Status             Status            `json:"status"`
+	Reason             string            `json:"reason"` // one of the reason codes
+	CurrentBlockNumber uint64            `json:"current_block_number"`
+	LatestBlockNumber  uint64            `json:"latest_block_number"`
+	ConfirmNum         uint64            `json:"confirm_num"`
+	Lag                uint64            `json:"lag"` // blocks behind the confirm num
+	NodeErrCount       int               `json:"node_err_count"`
+	LastErr            string            `json:"last_err"`    // the urls are cut to the host
+	ReportedAt         int64             `json:"reported_at"` // ms, the last report of the parser
+	UnhealthySince     int64             `json:"unhealthy_since"`
+	ParsePaused        bool              `json:"parse_paused"` // by the chain switch, the lag is not evaluated meanwhile
+
+	healthySince time.Time // of the current healthy run, for the recovery
+}
+
+// PublicChainHealth the health of /v1/payment/methods, without the node errors
+type PublicChainHealth struct {
+	Status             Status `json:"status"`
+	Reason             string `json:"reason"`
+	CurrentBlockNumber uint64 `json:"current_block_number"`
+	LatestBlockNumber  uint64 `json:"latest_block_number"`
+	Lag                uint64 `json:"lag"`
+}
+
+func (c ChainHealth) ToPublic() PublicChainHealth {
+	return PublicChainHealth{
+		Status:             c.Status,
+		Reason:             c.Reason,
+		CurrentBlockNumber: c.CurrentBlockNumber,
+		LatestBlockNumber:  c.LatestBlockNumber,
+		Lag:                c.Lag,
+	}
+}
+
+var (
+	lock     sync.Mutex
+	chainMap = make(map[tables.ParserType]*ChainHealth)
+)
+
+// ReportStart by the parser before the loop, a parser that never reports becomes stale
+func ReportStart(parserType tables.ParserType, confirmNum uint64) {
+	lock.Lock()
+	defer lock.Unlock()
+	c := getChain(parserType)
+	c.ConfirmNum = confirmNum
+	c.ReportedAt = time.Now().UnixMilli()
+}
+
+// ReportHead by the parser loop after every latest block number
+func ReportHead(parserType tables.ParserType, currentBlockNumber, latestBlockNumber, confirmNum uint64) {
+	lock.Lock()
+	defer lock.Unlock()
+	c := getChain(parserType)
+	c.ParsePaused = false
+	c.CurrentBlockNumber, c.LatestBlockNumber, c.ConfirmNum = currentBlockNumber, latestBlockNumber, confirmNum
+	c.Lag = 0
+	if latestBlockNumber > currentBlockNumber+confirmNum {
+		c.Lag = latestBlockNumber - currentBlockNumber - confirmNum
+	}
+	c.NodeErrCount, c.LastErr = 0, ""
+	c.ReportedAt = time.Now().UnixMilli()
+	c.evaluate(time.Now())
+}
+
+// ReportPaused by the parser loop while the parsing of the chain is paused, so it is not stale
+func ReportPaused(parserType tables.ParserType) {
+	lock.Lock()
+	defer lock.Unlock()
+	c := getChain(parserType)
+	c.ParsePaused = true
+	c.NodeErrCount, c.LastErr = 0, ""
+	c.ReportedAt = time.Now().UnixMilli()
+}
+
+// ReportNodeErr by the parser loop when the latest block number failed
+func ReportNodeErr(parserType tables.ParserType, err error) {
+	lock.Lock()
+	defer lock.Unlock()
+	c := getChain(parserType)
+	c.ParsePaused = false
+	c.NodeErrCount++
+	c.LastErr = maskErrUrl(err.Error())
+	c.ReportedAt = time.Now().UnixMilli()
+	c.evaluate(time.Now())
+}
+
+// ReportStop by the config reload when the parser of the chain is stopped, the chain is unknown afterwards
+func ReportStop(parserType tables.ParserType) {
+	lock.Lock()
+	defer lock.Unlock()
+	if _, ok := chainMap[parserType]; !ok {
+		return
+	}
+	delete(chainMap, parserType)
+	txtool.Tools.Metrics.ChainHealthy().DeleteLabelValues(parserType.ToString())
+}
+
+func getChain(parserType tables.ParserType) *ChainHealth {
+	c, ok := chainMap[parserType]
+	if !ok {
+		c = &ChainHealth{ParserType: parserType, Chain: parserType.ToString(), Status: StatusHealthy}
+		chainMap[parserType] = c
+		txtool.Tools.Metrics.ChainHealthy().WithLabelValues(c.Chain).Set(1)
+	}
+	return c
+}
+
+var urlRegexp = regexp.MustCompile(`([a-zA-Z][a-zA-Z0-9+.-]*)://([^/\s"'?#]+)[^\s"']*`)
+
+// maskErrUrl cuts the node urls in the err to the scheme and the host, the api keys are in the user, the path or the query
+func maskErrUrl(errMsg string) string {
+	return urlRegexp.ReplaceAllStringFunc(errMsg, func(s string) string {
+		list := urlRegexp.FindStringSubmatch(s)
+		host := list[2]
+		if i := strings.LastIndex(host, "@"); i >= 0 {
+			host = host[i+1:]
+		}
+		return list[1] + "://" + host
+	})
+}
+
+func (c *ChainHealth) evaluate(now time.Time) {
+	reason, detail := c.getUnhealthyReason(now)
+	status := c.Status
+	if reason != "" {
+		c.Reason = reason
+		c.healthySince = time.Time{}
+		status = StatusUnhealthy
+	} else if c.Status == StatusUnhealthy {
+		if c.healthySince.IsZero() {
+			c.healthySince = now
+		}
+		recoverSeconds := config.GetCfg().ChainHealth.RecoverSeconds
+		if recoverSeconds <= 0 {
+			recoverSeconds = defaultRecoverSeconds
+		}
+		if now.Sub(c.healthySince) >= time.Duration(recoverSeconds)*time.Second {
+			status = StatusHealthy
+		} else {
+			c.Reason = ReasonRecovering
+		}
+	}
+	if status == c.Status {
+		return
+	}
+
+	c.Status = status
+	msg := fmt.Sprintf("- Chain: %s\n- Block: %d / %d\n- Lag: %d\n- Reason: %s", c.Chain, c.CurrentBlockNumber, c.LatestBlockNumber, c.Lag, c.Reason)
+	if status == StatusUnhealthy {
+		msg += "\n- Detail: " + detail
+		c.UnhealthySince = now.UnixMilli()
+		log.Warn("ChainUnhealthy:", c.Chain, c.Reason, detail)
+		notify.SendAlert(notify.SeverityCritical, notify.CategoryNode, "ChainUnhealthy", msg)
+		txtool.Tools.Metrics.ChainHealthy().WithLabelValues(c.Chain).Set(0)
+	} else {
+		c.UnhealthySince, c.Reason = 0, ""
+		log.Info("ChainRecovered:", c.Chain)
+		notify.SendAlert(notify.SeverityWarn, notify.CategoryNode, "ChainRecovered", msg)
+		txtool.Tools.Metrics.ChainHealthy().WithLabelValues(c.Chain).Set(1)
+	}
+}
+
+// getUnhealthyReason the reason code and the detail, empty if healthy
+func (c *ChainHealth) getUnhealthyReason(now time.Time) (reason, detail string) {
+	conf := config.GetCfg().ChainHealth
+	staleSeconds := conf.StaleSeconds
+	if staleSeconds <= 0 {
+		staleSeconds = defaultStaleSeconds
+	}
+	nodeErrCount := conf.NodeErrCount
+	if nodeErrCount <= 0 {
+		nodeErrCount = defaultNodeErrCount
+	}
+	maxLag, ok := conf.MaxLag[c.Chain]
+	if !ok || maxLag == 0 {
+		maxLag = defaultMaxLag
+	}
+
+	if now.Sub(time.UnixMilli(c.ReportedAt)) > time.Duration(staleSeconds)*time.Second {
+		return ReasonStale, fmt.Sprintf("no report of the parser for %ds", staleSeconds)
+	} else if c.NodeErrCount >= nodeErrCount {
+		return ReasonNodeErr, fmt.Sprintf("node errors: %d, %s", c.NodeErrCount, c.LastErr)
+	} else if c.Lag > maxLag {
+		return ReasonLag, fmt.Sprintf("parser lag %d over %d", c.Lag, maxLag)
+	}
+	return "", ""
+}
+
+// GetChainHealth StatusUnknown if no parser of the chain has reported in this process
+func GetChainHealth(parserType tables.ParserType) ChainHealth {
+	lock.Lock()
+	defer lock.Unlock()
+	c, ok := chainMap[parserType]
+	if !ok {
+		return ChainHealth{ParserType: parserType, Chain: parserType.ToString(), Status: StatusUnknown}
+	}
+	// a hung parser stops reporting
+	c.evaluate(time.Now())
+	return *c
+}
+
+// GetChainHealthList the chains with a parser in this process, for the admin api
+func GetChainHealthList() []ChainHealth {
+	lock.Lock()
+	var parserTypes []tables.ParserType
+	for k := range chainMap {
+		parserTypes = append(parserTypes, k)
+	}
+	lock.Unlock()
+	sort.Slice(parserTypes, func(i, j int) bool { return parserTypes[i] < parserTypes[j] })
+
+	var list []ChainHealth
+	for _, v := range parserTypes {
+		list = append(list, GetChainHealth(v))
+	}
+	return list
+}
+
+// GetPayTokenHealth StatusUnknown for stripe and the tokens without a parser
+func GetPayTokenHealth(payTokenId tables.PayTokenId) ChainHealth {
+	parserType, ok := payTokenId.ToParserType()
+	if !ok {
+		return ChainHealth{Status: StatusUnknown}
+	}
+	return GetChainHealth(parserType)
+}
+
+// IsOrderCreateAllowed false if the breaker of the chain is open, always true with the switch off
+func IsOrderCreateAllowed(payTokenId tables.PayTokenId) (bool, ChainHealth) {
+	if !config.GetCfg().ChainHealth.Switch {
+		return true, ChainHealth{}
+	}
+	c := GetPayTokenHealth(payTokenId)
+	return c.Status != StatusUnhealthy, c
+}
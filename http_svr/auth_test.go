@@ -0,0 +1,256 @@
+package http_svr
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/dotbitHQ/das-lib/http_api"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+	"unipay/config"
+	"unipay/http_svr/handle"
+)
+
+const (
+	testBusinessId  = "test-business"
+	testSecret      = "test-secret"
+	testSecretNext  = "test-secret-next"
+	testTimeWindow  = 300
+	testPathCreate  = "/v1/order/create"
+	testPathInfo    = "/v1/order/info"
+	testOtherBizId  = "other-business"
+	testOtherSecret = "other-secret"
+)
+
+var testCfg = fmt.Sprintf(`
+server:
+  net: 1
+business_ids:
+  %[1]s: ""
+  %[4]s: ""
+api_auth:
+  switch: true
+  timestamp_window: %[6]d
+  business_map:
+    %[1]s:
+      secrets: ["%[2]s", "%[3]s"]
+    %[4]s:
+      secrets: ["%[5]s"]
+chain:
+  ckb:
+    node: "http://127.0.0.1:8114"
+`, testBusinessId, testSecret, testSecretNext, testOtherBizId, testOtherSecret, testTimeWindow)
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "unipay")
+	if err != nil {
+		panic(err)
+	}
+	configFilePath := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(configFilePath, []byte(testCfg), 0600); err != nil {
+		panic(err)
+	}
+	if err := config.InitCfg(configFilePath); err != nil {
+		panic(err)
+	}
+	gin.SetMode(gin.TestMode)
+	code := m.Run()
+	_ = os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func newTestAuthEngine() *gin.Engine {
+	engine := gin.New()
+	engine.Use(DoApiAuth())
+	ok := func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, http_api.ApiRespOK(nil))
+	}
+	engine.POST(testPathCreate, ok)
+	engine.GET(testPathInfo, ok)
+	return engine
+}
+
+type testAuthReq struct {
+	method     string
+	requestUri string
+	body       []byte
+	businessId string
+	secret     string
+	timestamp  int64
+	nonce      string
+}
+
+func newTestAuthReq(nonce string) testAuthReq {
+	return testAuthReq{
+		method:     http.MethodPost,
+		requestUri: testPathCreate,
+		body:       []byte(fmt.Sprintf(`{"business_id":"%s","amount":"1"}`, testBusinessId)),
+		businessId: testBusinessId,
+		secret:     testSecret,
+		timestamp:  time.Now().Unix(),
+		nonce:      nonce,
+	}
+}
+
+// do returns the err_no of the response
+func (r testAuthReq) do(t *testing.T, engine *gin.Engine) http_api.ApiCode {
+	timestamp := strconv.FormatInt(r.timestamp, 10)
+	req := httptest.NewRequest(r.method, r.requestUri, bytes.NewReader(r.body))
+	req.Header.Set(HeaderBusinessId, r.businessId)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderNonce, r.nonce)
+	req.Header.Set(HeaderSignature, GetApiSignature(r.secret, r.method, r.requestUri, timestamp, r.nonce, r.body))
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+
+	var resp http_api.ApiResp
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err, w.Body.String())
+	}
+	return resp.ErrNo
+}
+
+func TestDoApiAuth(t *testing.T) {
+	engine := newTestAuthEngine()
+
+	req := newTestAuthReq("nonce-valid")
+	if errNo := req.do(t, engine); errNo != http_api.ApiCodeSuccess {
+		t.Fatal("valid:", errNo)
+	}
+
+	req = newTestAuthReq("nonce-next")
+	req.secret = testSecretNext
+	if errNo := req.do(t, engine); errNo != http_api.ApiCodeSuccess {
+		t.Fatal("second secret:", errNo)
+	}
+
+	req = newTestAuthReq("nonce-get")
+	req.method, req.requestUri, req.body = http.MethodGet, testPathInfo+"?business_id="+testBusinessId, nil
+	if errNo := req.do(t, engine); errNo != http_api.ApiCodeSuccess {
+		t.Fatal("get:", errNo)
+	}
+}
+
+func TestDoApiAuthSecret(t *testing.T) {
+	engine := newTestAuthEngine()
+
+	req := newTestAuthReq("nonce-wrong")
+	req.secret = "wrong-secret"
+	if errNo := req.do(t, engine); errNo != handle.ApiCodeAuthFailed {
+		t.Fatal("wrong secret:", errNo)
+	}
+
+	// the secret of another business
+	req = newTestAuthReq("nonce-other")
+	req.secret = testOtherSecret
+	if errNo := req.do(t, engine); errNo != handle.ApiCodeAuthFailed {
+		t.Fatal("other secret:", errNo)
+	}
+
+	req = newTestAuthReq("nonce-unknown")
+	req.businessId = "unknown-business"
+	if errNo := req.do(t, engine); errNo != handle.ApiCodeAuthFailed {
+		t.Fatal("unknown business:", errNo)
+	}
+}
+
+func TestDoApiAuthTimestamp(t *testing.T) {
+	engine := newTestAuthEngine()
+	list := []struct {
+		name      string
+		timestamp int64
+		errNo     http_api.ApiCode
+	}{
+		{"inside", time.Now().Unix() - testTimeWindow + 10, http_api.ApiCodeSuccess},
+		{"expired", time.Now().Unix() - testTimeWindow - 10, handle.ApiCodeAuthFailed},
+		{"future", time.Now().Unix() + testTimeWindow + 10, handle.ApiCodeAuthFailed},
+	}
+	for _, v := range list {
+		req := newTestAuthReq("nonce-" + v.name)
+		req.timestamp = v.timestamp
+		if errNo := req.do(t, engine); errNo != v.errNo {
+			t.Fatal(v.name, errNo)
+		}
+	}
+}
+
+func TestDoApiAuthNonce(t *testing.T) {
+	engine := newTestAuthEngine()
+
+	req := newTestAuthReq("nonce-replay")
+	if errNo := req.do(t, engine); errNo != http_api.ApiCodeSuccess {
+		t.Fatal("first:", errNo)
+	}
+	if errNo := req.do(t, engine); errNo != handle.ApiCodeAuthFailed {
+		t.Fatal("replay:", errNo)
+	}
+
+	// the nonces are per business
+	req = newTestAuthReq("nonce-replay")
+	req.businessId, req.secret = testOtherBizId, testOtherSecret
+	req.body = []byte(fmt.Sprintf(`{"business_id":"%s"}`, testOtherBizId))
+	if errNo := req.do(t, engine); errNo != http_api.ApiCodeSuccess {
+		t.Fatal("other business:", errNo)
+	}
+}
+
+func TestDoApiAuthBusinessIdMismatch(t *testing.T) {
+	engine := newTestAuthEngine()
+
+	// signed by the business, but for the order of another one
+	req := newTestAuthReq("nonce-mismatch")
+	req.body = []byte(fmt.Sprintf(`{"business_id":"%s"}`, testOtherBizId))
+	if errNo := req.do(t, engine); errNo != handle.ApiCodeAuthFailed {
+		t.Fatal("body:", errNo)
+	}
+
+	req = newTestAuthReq("nonce-mismatch-get")
+	req.method, req.requestUri, req.body = http.MethodGet, testPathInfo+"?business_id="+testOtherBizId, nil
+	if errNo := req.do(t, engine); errNo != handle.ApiCodeAuthFailed {
+		t.Fatal("query:", errNo)
+	}
+}
+
+func TestDoApiAuthInvalidKeepsNonce(t *testing.T) {
+	engine := newTestAuthEngine()
+	nonce := "nonce-kept"
+
+	wrongSecret := newTestAuthReq(nonce)
+	wrongSecret.secret = "wrong-secret"
+	expired := newTestAuthReq(nonce)
+	expired.timestamp -= testTimeWindow * 2
+	mismatch := newTestAuthReq(nonce)
+	mismatch.body = []byte(fmt.Sprintf(`{"business_id":"%s"}`, testOtherBizId))
+	for i, req := range []testAuthReq{wrongSecret, expired, mismatch} {
+		if errNo := req.do(t, engine); errNo != handle.ApiCodeAuthFailed {
+			t.Fatal(i, errNo)
+		}
+	}
+
+	if errNo := newTestAuthReq(nonce).do(t, engine); errNo != http_api.ApiCodeSuccess {
+		t.Fatal("valid after the invalid ones:", errNo)
+	}
+}
+
+func TestNonceCache(t *testing.T) {
+	nonces := newNonceCache()
+	if !nonces.add("a", time.Minute) {
+		t.Fatal("first add")
+	}
+	if nonces.add("a", time.Minute) {
+		t.Fatal("replay")
+	}
+	if !nonces.add("b", -time.Second) {
+		t.Fatal("other key")
+	}
+	// an expired nonce can be used again
+	if !nonces.add("b", time.Minute) {
+		t.Fatal("expired")
+	}
+}
@@ -0,0 +1,500 @@
+package handle
+
+import (
+	"fmt"
+	"github.com/dotbitHQ/das-lib/http_api"
+	"github.com/gin-gonic/gin"
+	"github.com/scorpiotzh/toolib"
+	"net/http"
+	"net/url"
+	"unipay/business"
+	"unipay/tables"
+)
+
+type ReqAdminBusiness struct {
+	BusinessId string `json:"business_id"`
+}
+
+// ReqAdminBusinessSave nil fields are left unchanged on update
+type ReqAdminBusinessSave struct {
+	BusinessId        string                  `json:"business_id"`
+	Name              *string                 `json:"name"`
+	CallbackUrl       *string                 `json:"callback_url"`
+	CallbackUrlBackup *string                 `json:"callback_url_backup"`
+	ApiSecrets        []string                `json:"api_secrets"`
+	CallbackSecrets   []string                `json:"callback_secrets"`
+	EnabledTokens     []tables.PayTokenId     `json:"enabled_tokens"`
+	DefaultExpiry     *int64                  `json:"default_expiry"`
+	CallbackRetry     []int64                 `json:"callback_retry"`
+	CallbackVersion   *tables.CallbackVersion `json:"callback_version"`
+	Contact           *string                 `json:"contact"`
+	Status            *tables.BusinessStatus  `json:"status"`
+	Policy            *tables.BusinessPolicy  `json:"policy"`
+}
+
+type RespAdminBusiness struct {
+	BusinessId        string                 `json:"business_id"`
+	Name              string                 `json:"name"`
+	CallbackUrl       string                 `json:"callback_url"`
+	CallbackUrlBackup string                 `json:"callback_url_backup"`
+	ApiSecrets        []string               `json:"api_secrets"`
+	CallbackSecrets   []string               `json:"callback_secrets"`
+	EnabledTokens     []tables.PayTokenId    `json:"enabled_tokens"`
+	DefaultExpiry     int64                  `json:"default_expiry"`
+	CallbackRetry     []int64                `json:"callback_retry"`
+	CallbackVersion   tables.CallbackVersion `json:"callback_version"`
+	Contact           string                 `json:"contact"`
+	Status            tables.BusinessStatus  `json:"status"`
+	Policy            tables.BusinessPolicy  `json:"policy"`
+	CreatedAt         int64                  `json:"created_at"`
+	UpdatedAt         int64                  `json:"updated_at"`
+}
+
+func (h *HttpHandle) AdminBusinessList(ctx *gin.Context) {
+	var (
+		funcName             = "AdminBusinessList"
+		clientIp, remoteAddr = GetClientIp(ctx)
+		apiResp              http_api.ApiResp
+	)
+	log.Info("ApiReq:", funcName, clientIp, remoteAddr)
+
+	list, err := h.DbDao.GetBusinessList()
+	if err != nil {
+		log.Error("GetBusinessList err:", err.Error(), funcName, clientIp, remoteAddr)
+		apiResp.ApiRespErr(http_api.ApiCodeDbError, "Failed to get business list")
+	} else {
+		resp := make([]RespAdminBusiness, 0, len(list))
+		for _, v := range list {
+			resp = append(resp, toRespAdminBusiness(v))
+		}
+		apiResp.ApiRespOK(resp)
+	}
+
+	ctx.JSON(http.StatusOK, apiResp)
+}
+
+func (h *HttpHandle) AdminBusinessInfo(ctx *gin.Context) {
+	var (
+		funcName             = "AdminBusinessInfo"
+		clientIp, remoteAddr = GetClientIp(ctx)
+		req                  ReqAdminBusiness
+		apiResp              http_api.ApiResp
+	)
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		log.Error("ShouldBindJSON err: ", err.Error(), funcName, clientIp, remoteAddr)
+		apiResp.ApiRespErr(http_api.ApiCodeParamsInvalid, "params invalid")
+		ctx.JSON(http.StatusOK, apiResp)
+		return
+	}
+	log.Info("ApiReq:", funcName, clientIp, remoteAddr, req.BusinessId)
+
+	info, err := h.DbDao.GetBusinessInfo(req.BusinessId)
+	if err != nil {
+		log.Error("GetBusinessInfo err:", err.Error(), funcName, clientIp, remoteAddr)
+		apiResp.ApiRespErr(http_api.ApiCodeDbError, "Failed to get business info")
+	} else if info.Id == 0 {
+		apiResp.ApiRespErr(http_api.ApiCodeParamsInvalid, fmt.Sprintf("unknow bussiness id[%s]", req.BusinessId))
+	} else {
+		apiResp.ApiRespOK(toRespAdminBusiness(info))
+	}
+
+	ctx.JSON(http.StatusOK, apiResp)
+}
+
+func (h *HttpHandle) AdminBusinessCreate(ctx *gin.Context) {
+	var (
+		funcName             = "AdminBusinessCreate"
+		clientIp, remoteAddr = GetClientIp(ctx)
+		req                  ReqAdminBusinessSave
+		apiResp              http_api.ApiResp
+		err                  error
+	)
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		log.Error("ShouldBindJSON err: ", err.Error(), funcName, clientIp, remoteAddr)
+		apiResp.ApiRespErr(http_api.ApiCodeParamsInvalid, "params invalid")
+		ctx.JSON(http.StatusOK, apiResp)
+		return
+	}
+	// secrets are not logged
+	log.Info("ApiReq:", funcName, clientIp, remoteAddr, req.BusinessId)
+
+	if err = h.doAdminBusinessCreate(&req, &apiResp); err != nil {
+		log.Error("doAdminBusinessCreate err:", err.Error(), funcName, clientIp, remoteAddr)
+	}
+
+	ctx.JSON(http.StatusOK, apiResp)
+}
+
+func (h *HttpHandle) doAdminBusinessCreate(req *ReqAdminBusinessSave, apiResp *http_api.ApiResp) error {
+	if req.BusinessId == "" {
+		apiResp.ApiRespErr(http_api.ApiCodeParamsInvalid, "business_id is empty")
+		return nil
+	}
+	var info tables.TableBusinessInfo
+	info.BusinessId = req.BusinessId
+	info.Name = req.BusinessId
+	if errMsg := req.apply(&info); errMsg != "" {
+		apiResp.ApiRespErr(http_api.ApiCodeParamsInvalid, errMsg)
+		return nil
+	}
+
+	if ok, err := h.DbDao.CreateBusinessInfo(info); err != nil {
+		apiResp.ApiRespErr(http_api.ApiCodeDbError, "Failed to create business")
+		return fmt.Errorf("CreateBusinessInfo err: %s", err.Error())
+	} else if !ok {
+		apiResp.ApiRespErr(http_api.ApiCodeParamsInvalid, fmt.Sprintf("business id[%s] exists", req.BusinessId))
+		return nil
+	}
+	return h.refreshBusiness(req.BusinessId, apiResp)
+}
+
+func (h *HttpHandle) AdminBusinessUpdate(ctx *gin.Context) {
+	var (
+		funcName             = "AdminBusinessUpdate"
+		clientIp, remoteAddr = GetClientIp(ctx)
+		req                  ReqAdminBusinessSave
+		apiResp              http_api.ApiResp
+		err                  error
+	)
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		log.Error("ShouldBindJSON err: ", err.Error(), funcName, clientIp, remoteAddr)
+		apiResp.ApiRespErr(http_api.ApiCodeParamsInvalid, "params invalid")
+		ctx.JSON(http.StatusOK, apiResp)
+		return
+	}
+	log.Info("ApiReq:", funcName, clientIp, remoteAddr, req.BusinessId)
+
+	if err = h.doAdminBusinessUpdate(&req, &apiResp); err != nil {
+		log.Error("doAdminBusinessUpdate err:", err.Error(), funcName, clientIp, remoteAddr)
+	}
+
+	ctx.JSON(http.StatusOK, apiResp)
+}
+
+func (h *HttpHandle) doAdminBusinessUpdate(req *ReqAdminBusinessSave, apiResp *http_api.ApiResp) error {
+	info, err := h.DbDao.GetBusinessInfo(req.BusinessId)
+	if err != nil {
+		apiResp.ApiRespErr(http_api.ApiCodeDbError, "Failed to get business info")
+		return fmt.Errorf("GetBusinessInfo err: %s", err.Error())
+	} else if info.Id == 0 {
+		apiResp.ApiRespErr(http_api.ApiCodeParamsInvalid, fmt.Sprintf("unknow bussiness id[%s]", req.BusinessId))
+		return nil
+	}
+	if errMsg := req.apply(&info); errMsg != "" {
+		apiResp.ApiRespErr(http_api.ApiCodeParamsInvalid, errMsg)
+		return nil
+	}
+
+	if err := h.DbDao.UpdateBusinessInfo(req.BusinessId, map[string]interface{}{
+		"name":                info.Name,
+		"callback_url":        info.CallbackUrl,
+		"callback_url_backup": info.CallbackUrlBackup,
+		"api_secrets":         info.ApiSecrets,
+		"callback_secrets":    info.CallbackSecrets,
+		"enabled_tokens":      info.EnabledTokens,
+		"default_expiry":      info.DefaultExpiry,
+		"callback_retry":      info.CallbackRetry,
+		"callback_version":    info.CallbackVersion,
+		"contact":             info.Contact,
+		"status":              info.Status,
+		"policy":              info.Policy,
+	}); err != nil {
+		apiResp.ApiRespErr(http_api.ApiCodeDbError, "Failed to update business")
+		return fmt.Errorf("UpdateBusinessInfo err: %s", err.Error())
+	}
+	return h.refreshBusiness(req.BusinessId, apiResp)
+}
+
+func (h *HttpHandle) AdminBusinessDelete(ctx *gin.Context) {
+	var (
+		funcName             = "AdminBusinessDelete"
+		clientIp, remoteAddr = GetClientIp(ctx)
+		req                  ReqAdminBusiness
+		apiResp              http_api.ApiResp
+	)
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		log.Error("ShouldBindJSON err: ", err.Error(), funcName, clientIp, remoteAddr)
+		apiResp.ApiRespErr(http_api.ApiCodeParamsInvalid, "params invalid")
+		ctx.JSON(http.StatusOK, apiResp)
+		return
+	}
+	log.Info("ApiReq:", funcName, clientIp, remoteAddr, req.BusinessId)
+
+	if err := h.DbDao.DeleteBusinessInfo(req.BusinessId); err != nil {
+		log.Error("DeleteBusinessInfo err:", err.Error(), funcName, clientIp, remoteAddr)
+		apiResp.ApiRespErr(http_api.ApiCodeDbError, "Failed to delete business")
+	} else if err := business.Cache.Refresh(); err != nil {
+		log.Error("Refresh err:", err.Error(), funcName, clientIp, remoteAddr)
+		apiResp.ApiRespErr(http_api.ApiCodeDbError, "Failed to refresh business cache")
+	} else {
+		apiResp.ApiRespOK(nil)
+	}
+
+	ctx.JSON(http.StatusOK, apiResp)
+}
+
+func (h *HttpHandle) refreshBusiness(businessId string, apiResp *http_api.ApiResp) error {
+	if err := business.Cache.Refresh(); err != nil {
+		apiResp.ApiRespErr(http_api.ApiCodeDbError, "Failed to refresh business cache")
+		return fmt.Errorf("Refresh err: %s", err.Error())
+	}
+	info, err := h.DbDao.GetBusinessInfo(businessId)
+	if err != nil {
+		apiResp.ApiRespErr(http_api.ApiCodeDbError, "Failed to get business info")
+		return fmt.Errorf("GetBusinessInfo err: %s", err.Error())
+	}
+	apiResp.ApiRespOK(toRespAdminBusiness(info))
+	return nil
+}
+
+// apply returns the reason if the request is invalid
+func (r *ReqAdminBusinessSave) apply(info *tables.TableBusinessInfo) string {
+	if r.Name != nil {
+		info.Name = *r.Name
+	}
+	for _, v := range []*string{r.CallbackUrl, r.CallbackUrlBackup} {
+		if v == nil || *v == "" {
+			continue
+		}
+		if u, err := url.Parse(*v); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Sprintf("callback url[%s] invalid", *v)
+		}
+	}
+	if r.CallbackUrl != nil {
+		info.CallbackUrl = *r.CallbackUrl
+	}
+	if r.CallbackUrlBackup != nil {
+		info.CallbackUrlBackup = *r.CallbackUrlBackup
+	}
+	for _, v := range []struct {
+		secrets []string
+		column  *string
+	}{{r.ApiSecrets, &info.ApiSecrets}, {r.CallbackSecrets, &info.CallbackSecrets}} {
+		if v.secrets == nil {
+			continue
+		}
+		if errMsg := business.CheckSecrets(v.secrets); errMsg != "" {
+			return errMsg
+		}
+		value, err := business.EncryptSecrets(v.secrets)
+		if err != nil {
+			return fmt.Sprintf("Failed to encrypt the secrets: %s", err.Error())
+		}
+		*v.column = value
+	}
+	if r.EnabledTokens != nil {
+		var list []string
+		for _, v := range r.EnabledTokens {
+			list = append(list, string(v))
+		}
+		info.EnabledTokens = tables.JoinList(list)
+	}
+	if r.DefaultExpiry != nil {
+		if *r.DefaultExpiry < 0 || *r.DefaultExpiry > int64(tables.MaxOrderTTL.Seconds()) {
+			return "default_expiry invalid"
+		}
+		info.DefaultExpiry = *r.DefaultExpiry
+	}
+	if r.CallbackRetry != nil {
+		if len(r.CallbackRetry) > 20 {
+			return "At most 20 callback retries"
+		}
+		var list []string
+		for _, v := range r.CallbackRetry {
+			if v <= 0 || v > 7*24*3600 {
+				return fmt.Sprintf("callback retry[%d] invalid", v)
+			}
+			list = append(list, fmt.Sprintf("%d", v))
+		}
+		info.CallbackRetry = tables.JoinList(list)
+	}
+	if r.CallbackVersion != nil {
+		if *r.CallbackVersion != tables.CallbackVersion1 && *r.CallbackVersion != tables.CallbackVersion2 {
+			return "callback_version invalid"
+		}
+		info.CallbackVersion = *r.CallbackVersion
+	}
+	if r.Contact != nil {
+		info.Contact = *r.Contact
+	}
+	if r.Status != nil {
+		if *r.Status != tables.BusinessStatusEnabled && *r.Status != tables.BusinessStatusDisabled {
+			return "status invalid"
+		}
+		info.Status = *r.Status
+	}
+	if r.Policy != nil {
+		if err := business.ValidatePolicy(*r.Policy); err != nil {
+			return err.Error()
+		}
+		info.Policy = toolib.JsonString(r.Policy)
+	}
+	return ""
+}
+
+func toRespAdminBusiness(info tables.TableBusinessInfo) RespAdminBusiness {
+	resp := RespAdminBusiness{
+		BusinessId:        info.BusinessId,
+		Name:              info.Name,
+		CallbackUrl:       info.CallbackUrl,
+		CallbackUrlBackup: info.CallbackUrlBackup,
+		ApiSecrets:        maskSecrets(decryptSecrets(info.BusinessId, info.ApiSecrets)),
+		CallbackSecrets:   maskSecrets(decryptSecrets(info.BusinessId, info.CallbackSecrets)),
+		EnabledTokens:     info.GetEnabledTokens(),
+		DefaultExpiry:     info.DefaultExpiry,
+		CallbackRetry:     info.GetCallbackRetry(),
+		CallbackVersion:   info.GetCallbackVersion(),
+		Contact:           info.Contact,
+		Status:            info.Status,
+		CreatedAt:         info.CreatedAt.UnixMilli(),
+		UpdatedAt:         info.UpdatedAt.UnixMilli(),
+	}
+	resp.Policy, _ = info.GetPolicy()
+	if resp.EnabledTokens == nil {
+		resp.EnabledTokens = make([]tables.PayTokenId, 0)
+	}
+	if resp.CallbackRetry == nil {
+		resp.CallbackRetry = make([]int64, 0)
+	}
+	return resp
+}
+
+func decryptSecrets(businessId, value string) []string {
+	list, err := business.DecryptSecrets(value)
+	if err != nil {
+		log.Error("DecryptSecrets err:", businessId, err.Error())
+	}
+	return list
+}
+
+// maskSecrets keeps the last 4 characters, enough to tell which secret is in use
+func maskSecrets(secrets []string) []string {
+	list := make([]string, 0, len(secrets))
+	for _, v := range secrets {
+		if len(v) <= 8 {
+			list = append(list, "****")
+		} else {
+			list = append(list, "****"+v[len(v)-4:])
+		}
+	}
+	return list
+}
+
+const (
+	secretTypeApi      = "api"
+	secretTypeCallback = "callback"
+)
+
+// ReqAdminBusinessSecret the secret is generated if empty
+type ReqAdminBusinessSecret struct {
+	BusinessId string `json:"business_id"`
+	Type       string `json:"type"` // api callback
+	Secret     string `json:"secret"`
+}
+
+type RespAdminBusinessSecret struct {
+	BusinessId string   `json:"business_id"`
+	Type       string   `json:"type"`
+	Secret     string   `json:"secret"`  // the new secret, only returned by the rotate
+	Secrets    []string `json:"secrets"` // masked, the new one first
+}
+
+func (h *HttpHandle) AdminBusinessSecretRotate(ctx *gin.Context) {
+	h.adminBusinessSecret(ctx, "AdminBusinessSecretRotate", true)
+}
+
+func (h *HttpHandle) AdminBusinessSecretRevoke(ctx *gin.Context) {
+	h.adminBusinessSecret(ctx, "AdminBusinessSecretRevoke", false)
+}
+
+func (h *HttpHandle) adminBusinessSecret(ctx *gin.Context, funcName string, rotate bool) {
+	var (
+		clientIp, remoteAddr = GetClientIp(ctx)
+		req                  ReqAdminBusinessSecret
+		apiResp              http_api.ApiResp
+	)
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		log.Error("ShouldBindJSON err: ", err.Error(), funcName, clientIp, remoteAddr)
+		apiResp.ApiRespErr(http_api.ApiCodeParamsInvalid, "params invalid")
+		ctx.JSON(http.StatusOK, apiResp)
+		return
+	}
+	// the secret is not logged
+	log.Info("ApiReq:", funcName, clientIp, remoteAddr, req.BusinessId, req.Type)
+
+	if err := h.doAdminBusinessSecret(&req, &apiResp, rotate); err != nil {
+		log.Error("doAdminBusinessSecret err:", err.Error(), funcName, clientIp, remoteAddr)
+	}
+
+	ctx.JSON(http.StatusOK, apiResp)
+}
+
+// doAdminBusinessSecret the rotate puts a new secret in front of the current one, both are accepted
+// until the revoke drops the old one
+func (h *HttpHandle) doAdminBusinessSecret(req *ReqAdminBusinessSecret, apiResp *http_api.ApiResp, rotate bool) error {
+	column := ""
+	switch req.Type {
+	case secretTypeApi:
+		column = "api_secrets"
+	case secretTypeCallback:
+		column = "callback_secrets"
+	default:
+		apiResp.ApiRespErr(http_api.ApiCodeParamsInvalid, fmt.Sprintf("type[%s] invalid", req.Type))
+		return nil
+	}
+	info, err := h.DbDao.GetBusinessInfo(req.BusinessId)
+	if err != nil {
+		apiResp.ApiRespErr(http_api.ApiCodeDbError, "Failed to get business info")
+		return fmt.Errorf("GetBusinessInfo err: %s", err.Error())
+	} else if info.Id == 0 {
+		apiResp.ApiRespErr(http_api.ApiCodeParamsInvalid, fmt.Sprintf("unknow bussiness id[%s]", req.BusinessId))
+		return nil
+	}
+	value := info.ApiSecrets
+	if req.Type == secretTypeCallback {
+		value = info.CallbackSecrets
+	}
+	list, err := business.DecryptSecrets(value)
+	if err != nil {
+		apiResp.ApiRespErr(http_api.ApiCodeError500, "Failed to decrypt the secrets")
+		return fmt.Errorf("DecryptSecrets err: %s", err.Error())
+	}
+
+	resp := RespAdminBusinessSecret{BusinessId: req.BusinessId, Type: req.Type}
+	if rotate {
+		if resp.Secret = req.Secret; resp.Secret == "" {
+			if resp.Secret, err = business.NewSecret(); err != nil {
+				apiResp.ApiRespErr(http_api.ApiCodeError500, "Failed to generate a secret")
+				return fmt.Errorf("NewSecret err: %s", err.Error())
+			}
+		}
+		list = business.RotateSecrets(list, resp.Secret)
+	} else {
+		list = business.RevokeSecrets(list)
+	}
+	if errMsg := business.CheckSecrets(list); errMsg != "" {
+		apiResp.ApiRespErr(http_api.ApiCodeParamsInvalid, errMsg)
+		return nil
+	}
+	if value, err = business.EncryptSecrets(list); err != nil {
+		apiResp.ApiRespErr(http_api.ApiCodeError500, "Failed to encrypt the secrets")
+		return fmt.Errorf("EncryptSecrets err: %s", err.Error())
+	}
+
+	if err := h.DbDao.UpdateBusinessInfo(req.BusinessId, map[string]interface{}{column: value}); err != nil {
+		apiResp.ApiRespErr(http_api.ApiCodeDbError, "Failed to update business")
+		return fmt.Errorf("UpdateBusinessInfo err: %s", err.Error())
+	}
+	if err := business.Cache.Refresh(); err != nil {
+		apiResp.ApiRespErr(http_api.ApiCodeDbError, "Failed to refresh business cache")
+		return fmt.Errorf("Refresh err: %s", err.Error())
+	}
+	resp.Secrets = maskSecrets(list)
+	apiResp.ApiRespOK(resp)
+	return nil
+}
@@ -0,0 +1,186 @@
+package handle
+
+import (
+	"fmt"
+	"github.com/dotbitHQ/das-lib/http_api"
+	"github.com/gin-gonic/gin"
+	"github.com/scorpiotzh/toolib"
+	"net/http"
+	"strconv"
+	"unipay/chainswitch"
+	"unipay/dao"
+	"unipay/tables"
+)
+
+type RespAdminChainSwitchList struct {
+	SwitchList []tables.TableChainSwitchInfo `json:"switch_list"`
+}
+
+// ReqAdminChainSwitchUpdate nil for the targets left as they are
+type ReqAdminChainSwitchUpdate struct {
+	Chain        string `json:"chain"`
+	ParsePaused  *bool  `json:"parse_paused"`
+	OrderPaused  *bool  `json:"order_paused"`
+	RefundPaused *bool  `json:"refund_paused"`
+	Operator     string `json:"operator"`
+	Reason       string `json:"reason"`
+}
+
+type RespAdminChainSwitchUpdate struct {
+	SwitchInfo tables.TableChainSwitchInfo  `json:"switch_info"`
+	LogList    []tables.TableChainSwitchLog `json:"log_list"` // the toggled targets, empty if nothing changed
+}
+
+type ReqAdminChainSwitchLog struct {
+	Chain  string `json:"chain"` // empty for all
+	Cursor string `json:"cursor"`
+	Limit  int    `json:"limit"`
+}
+
+type RespAdminChainSwitchLog struct {
+	LogList    []tables.TableChainSwitchLog `json:"log_list"`
+	NextCursor string                       `json:"next_cursor"` // empty if no more
+}
+
+// AdminChainSwitchList the runtime kill switches of every chain
+func (h *HttpHandle) AdminChainSwitchList(ctx *gin.Context) {
+	var (
+		funcName             = "AdminChainSwitchList"
+		clientIp, remoteAddr = GetClientIp(ctx)
+		apiResp              http_api.ApiResp
+	)
+	log.Info("ApiReq:", funcName, clientIp, remoteAddr)
+
+	if chainswitch.Cache == nil {
+		apiResp.ApiRespErr(http_api.ApiCodeError500, "chain switch not ready")
+	} else {
+		apiResp.ApiRespOK(RespAdminChainSwitchList{SwitchList: chainswitch.GetChainSwitchList()})
+	}
+
+	ctx.JSON(http.StatusOK, apiResp)
+}
+
+// AdminChainSwitchUpdate pauses or resumes the parsing, the order create and the refund of a chain
+func (h *HttpHandle) AdminChainSwitchUpdate(ctx *gin.Context) {
+	var (
+		funcName             = "AdminChainSwitchUpdate"
+		clientIp, remoteAddr = GetClientIp(ctx)
+		req                  ReqAdminChainSwitchUpdate
+		apiResp              http_api.ApiResp
+		err                  error
+	)
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		log.Error("ShouldBindJSON err: ", err.Error(), funcName, clientIp, remoteAddr)
+		apiResp.ApiRespErr(http_api.ApiCodeParamsInvalid, "params invalid")
+		ctx.JSON(http.StatusOK, apiResp)
+		return
+	}
+	log.Info("ApiReq:", funcName, clientIp, remoteAddr, toolib.JsonString(req))
+
+	if err = h.doAdminChainSwitchUpdate(&req, clientIp, &apiResp); err != nil {
+		log.Error("doAdminChainSwitchUpdate err:", err.Error(), funcName, clientIp, remoteAddr)
+	}
+
+	ctx.JSON(http.StatusOK, apiResp)
+}
+
+func (h *HttpHandle) doAdminChainSwitchUpdate(req *ReqAdminChainSwitchUpdate, clientIp string, apiResp *http_api.ApiResp) error {
+	var resp RespAdminChainSwitchUpdate
+	resp.LogList = make([]tables.TableChainSwitchLog, 0)
+
+	if chainswitch.Cache == nil {
+		apiResp.ApiRespErr(http_api.ApiCodeError500, "chain switch not ready")
+		return nil
+	}
+	if !chainswitch.IsValidChain(req.Chain) {
+		apiResp.ApiRespErr(http_api.ApiCodeParamsInvalid, fmt.Sprintf("chain invalid, one of %v", chainswitch.ChainList))
+		return nil
+	} else if req.Operator == "" {
+		apiResp.ApiRespErr(http_api.ApiCodeParamsInvalid, "operator is required")
+		return nil
+	} else if req.ParsePaused == nil && req.OrderPaused == nil && req.RefundPaused == nil {
+		apiResp.ApiRespErr(http_api.ApiCodeParamsInvalid, "nothing to update")
+		return nil
+	}
+
+	info, logList, err := chainswitch.Cache.Update(chainswitch.SwitchUpdate{
+		Chain:        req.Chain,
+		ParsePaused:  req.ParsePaused,
+		OrderPaused:  req.OrderPaused,
+		RefundPaused: req.RefundPaused,
+		Operator:     req.Operator,
+		Reason:       req.Reason,
+		ClientIp:     clientIp,
+	})
+	if err != nil {
+		apiResp.ApiRespErr(http_api.ApiCodeDbError, "Failed to update chain switch")
+		return fmt.Errorf("Update err: %s", err.Error())
+	}
+	resp.SwitchInfo = info
+	resp.LogList = append(resp.LogList, logList...)
+
+	apiResp.ApiRespOK(resp)
+	return nil
+}
+
+// AdminChainSwitchLog the audit log of the toggles
+func (h *HttpHandle) AdminChainSwitchLog(ctx *gin.Context) {
+	var (
+		funcName             = "AdminChainSwitchLog"
+		clientIp, remoteAddr = GetClientIp(ctx)
+		req                  ReqAdminChainSwitchLog
+		apiResp              http_api.ApiResp
+		err                  error
+	)
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		log.Error("ShouldBindJSON err: ", err.Error(), funcName, clientIp, remoteAddr)
+		apiResp.ApiRespErr(http_api.ApiCodeParamsInvalid, "params invalid")
+		ctx.JSON(http.StatusOK, apiResp)
+		return
+	}
+	log.Info("ApiReq:", funcName, clientIp, remoteAddr, toolib.JsonString(req))
+
+	if err = h.doAdminChainSwitchLog(&req, &apiResp); err != nil {
+		log.Error("doAdminChainSwitchLog err:", err.Error(), funcName, clientIp, remoteAddr)
+	}
+
+	ctx.JSON(http.StatusOK, apiResp)
+}
+
+func (h *HttpHandle) doAdminChainSwitchLog(req *ReqAdminChainSwitchLog, apiResp *http_api.ApiResp) error {
+	var resp RespAdminChainSwitchLog
+	resp.LogList = make([]tables.TableChainSwitchLog, 0)
+
+	params := dao.ChainSwitchLogParams{
+		Chain: req.Chain,
+		Limit: req.Limit,
+	}
+	if req.Cursor != "" {
+		cursor, err := strconv.ParseUint(req.Cursor, 10, 64)
+		if err != nil {
+			apiResp.ApiRespErr(http_api.ApiCodeParamsInvalid, "cursor invalid")
+			return nil
+		}
+		params.Cursor = cursor
+	}
+	if params.Limit <= 0 {
+		params.Limit = OrderListDefaultLimit
+	} else if params.Limit > OrderListMaxLimit {
+		params.Limit = OrderListMaxLimit
+	}
+
+	list, err := h.DbDao.GetChainSwitchLogList(params)
+	if err != nil {
+		apiResp.ApiRespErr(http_api.ApiCodeDbError, "Failed to get chain switch log")
+		return fmt.Errorf("GetChainSwitchLogList err: %s", err.Error())
+	}
+	resp.LogList = append(resp.LogList, list...)
+	if len(list) == params.Limit {
+		resp.NextCursor = fmt.Sprintf("%d", list[len(list)-1].Id)
+	}
+
+	apiResp.ApiRespOK(resp)
+	return nil
+}
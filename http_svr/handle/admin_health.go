@@ -0,0 +1,28 @@
+package handle
+
+import (
+	"github.com/dotbitHQ/das-lib/http_api"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"unipay/health"
+)
+
+type RespAdminChainHealthList struct {
+	HealthList []health.ChainHealth `json:"health_list"`
+}
+
+// AdminChainHealthList the health of the parsers in this process with the node errors, /v1/payment/methods only has the reason code
+func (h *HttpHandle) AdminChainHealthList(ctx *gin.Context) {
+	var (
+		funcName             = "AdminChainHealthList"
+		clientIp, remoteAddr = GetClientIp(ctx)
+		apiResp              http_api.ApiResp
+		resp                 RespAdminChainHealthList
+	)
+	log.Info("ApiReq:", funcName, clientIp, remoteAddr)
+
+	resp.HealthList = append(make([]health.ChainHealth, 0), health.GetChainHealthList()...)
+	apiResp.ApiRespOK(resp)
+
+	ctx.JSON(http.StatusOK, apiResp)
+}
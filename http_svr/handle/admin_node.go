@@ -0,0 +1,56 @@
+package handle
+
+import (
+	"github.com/dotbitHQ/das-lib/http_api"
+	"github.com/gin-gonic/gin"
+	"github.com/scorpiotzh/toolib"
+	"net/http"
+	"unipay/nodepool"
+)
+
+type ReqAdminNodeList struct {
+	Chain string `json:"chain"` // empty for all
+}
+
+type RespAdminNodeList struct {
+	PoolList []nodepool.PoolInfo `json:"pool_list"`
+}
+
+// AdminNodeList the node endpoints of the parsers in this process, the refund process has its own
+func (h *HttpHandle) AdminNodeList(ctx *gin.Context) {
+	var (
+		funcName             = "AdminNodeList"
+		clientIp, remoteAddr = GetClientIp(ctx)
+		req                  ReqAdminNodeList
+		apiResp              http_api.ApiResp
+		err                  error
+	)
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		log.Error("ShouldBindJSON err: ", err.Error(), funcName, clientIp, remoteAddr)
+		apiResp.ApiRespErr(http_api.ApiCodeParamsInvalid, "params invalid")
+		ctx.JSON(http.StatusOK, apiResp)
+		return
+	}
+	log.Info("ApiReq:", funcName, clientIp, remoteAddr, toolib.JsonString(req))
+
+	if err = h.doAdminNodeList(&req, &apiResp); err != nil {
+		log.Error("doAdminNodeList err:", err.Error(), funcName, clientIp, remoteAddr)
+	}
+
+	ctx.JSON(http.StatusOK, apiResp)
+}
+
+func (h *HttpHandle) doAdminNodeList(req *ReqAdminNodeList, apiResp *http_api.ApiResp) error {
+	var resp RespAdminNodeList
+	resp.PoolList = make([]nodepool.PoolInfo, 0)
+
+	for _, v := range nodepool.GetPoolList() {
+		if req.Chain == "" || req.Chain == v.Chain {
+			resp.PoolList = append(resp.PoolList, v)
+		}
+	}
+
+	apiResp.ApiRespOK(resp)
+	return nil
+}
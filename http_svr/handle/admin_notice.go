@@ -0,0 +1,166 @@
+package handle
+
+import (
+	"fmt"
+	"github.com/dotbitHQ/das-lib/http_api"
+	"github.com/gin-gonic/gin"
+	"github.com/scorpiotzh/toolib"
+	"net/http"
+	"strconv"
+	"unipay/dao"
+	"unipay/tables"
+)
+
+type ReqAdminNoticeList struct {
+	BusinessId   string               `json:"business_id"`
+	EventType    tables.EventType     `json:"event_type"`
+	NoticeStatus *tables.NoticeStatus `json:"notice_status"` // default 2-Fail, the dead letter
+	BeginTime    int64                `json:"begin_time"`    // ms, inclusive
+	EndTime      int64                `json:"end_time"`      // ms, exclusive
+	Cursor       string               `json:"cursor"`
+	Limit        int                  `json:"limit"`
+}
+
+type RespAdminNoticeList struct {
+	NoticeList []tables.TableNoticeInfo `json:"notice_list"`
+	NextCursor string                   `json:"next_cursor"` // empty if no more
+}
+
+// ReqAdminNoticeReplay either notice_id_list, or business_id with begin_time and end_time
+type ReqAdminNoticeReplay struct {
+	NoticeIdList     []string         `json:"notice_id_list"`
+	BusinessId       string           `json:"business_id"`
+	EventType        tables.EventType `json:"event_type"`
+	BeginTime        int64            `json:"begin_time"`
+	EndTime          int64            `json:"end_time"`
+	IncludeDelivered bool             `json:"include_delivered"` // re-send the delivered notices of the range too
+}
+
+type RespAdminNoticeReplay struct {
+	Count int64 `json:"count"`
+}
+
+func (h *HttpHandle) AdminNoticeList(ctx *gin.Context) {
+	var (
+		funcName             = "AdminNoticeList"
+		clientIp, remoteAddr = GetClientIp(ctx)
+		req                  ReqAdminNoticeList
+		apiResp              http_api.ApiResp
+		err                  error
+	)
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		log.Error("ShouldBindJSON err: ", err.Error(), funcName, clientIp, remoteAddr)
+		apiResp.ApiRespErr(http_api.ApiCodeParamsInvalid, "params invalid")
+		ctx.JSON(http.StatusOK, apiResp)
+		return
+	}
+	log.Info("ApiReq:", funcName, clientIp, remoteAddr, toolib.JsonString(req))
+
+	if err = h.doAdminNoticeList(&req, &apiResp); err != nil {
+		log.Error("doAdminNoticeList err:", err.Error(), funcName, clientIp, remoteAddr)
+	}
+
+	ctx.JSON(http.StatusOK, apiResp)
+}
+
+func (h *HttpHandle) doAdminNoticeList(req *ReqAdminNoticeList, apiResp *http_api.ApiResp) error {
+	var resp RespAdminNoticeList
+	resp.NoticeList = make([]tables.TableNoticeInfo, 0)
+
+	params := dao.NoticeListParams{
+		BusinessId:   req.BusinessId,
+		EventType:    req.EventType,
+		NoticeStatus: req.NoticeStatus,
+		BeginTime:    req.BeginTime,
+		EndTime:      req.EndTime,
+		Limit:        req.Limit,
+	}
+	if params.NoticeStatus == nil {
+		status := tables.NoticeStatusFail
+		params.NoticeStatus = &status
+	}
+	if req.Cursor != "" {
+		cursor, err := strconv.ParseUint(req.Cursor, 10, 64)
+		if err != nil {
+			apiResp.ApiRespErr(http_api.ApiCodeParamsInvalid, "cursor invalid")
+			return nil
+		}
+		params.Cursor = cursor
+	}
+	if params.Limit <= 0 {
+		params.Limit = OrderListDefaultLimit
+	} else if params.Limit > OrderListMaxLimit {
+		params.Limit = OrderListMaxLimit
+	}
+
+	list, err := h.DbDao.GetNoticeList(params)
+	if err != nil {
+		apiResp.ApiRespErr(http_api.ApiCodeDbError, "Failed to get notice list")
+		return fmt.Errorf("GetNoticeList err: %s", err.Error())
+	}
+	resp.NoticeList = append(resp.NoticeList, list...)
+	if len(list) == params.Limit {
+		resp.NextCursor = fmt.Sprintf("%d", list[len(list)-1].Id)
+	}
+
+	apiResp.ApiRespOK(resp)
+	return nil
+}
+
+func (h *HttpHandle) AdminNoticeReplay(ctx *gin.Context) {
+	var (
+		funcName             = "AdminNoticeReplay"
+		clientIp, remoteAddr = GetClientIp(ctx)
+		req                  ReqAdminNoticeReplay
+		apiResp              http_api.ApiResp
+		err                  error
+	)
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		log.Error("ShouldBindJSON err: ", err.Error(), funcName, clientIp, remoteAddr)
+		apiResp.ApiRespErr(http_api.ApiCodeParamsInvalid, "params invalid")
+		ctx.JSON(http.StatusOK, apiResp)
+		return
+	}
+	log.Info("ApiReq:", funcName, clientIp, remoteAddr, toolib.JsonString(req))
+
+	if err = h.doAdminNoticeReplay(&req, &apiResp); err != nil {
+		log.Error("doAdminNoticeReplay err:", err.Error(), funcName, clientIp, remoteAddr)
+	}
+
+	ctx.JSON(http.StatusOK, apiResp)
+}
+
+func (h *HttpHandle) doAdminNoticeReplay(req *ReqAdminNoticeReplay, apiResp *http_api.ApiResp) error {
+	var resp RespAdminNoticeReplay
+
+	params := dao.NoticeListParams{
+		NoticeIds: req.NoticeIdList,
+		EventType: req.EventType,
+	}
+	if len(req.NoticeIdList) == 0 {
+		// a range must be bounded, a replay of everything is never intended
+		if req.BusinessId == "" || req.BeginTime <= 0 || req.EndTime <= req.BeginTime {
+			apiResp.ApiRespErr(http_api.ApiCodeParamsInvalid, "notice_id_list or business_id with begin_time and end_time is required")
+			return nil
+		}
+		params.BusinessId = req.BusinessId
+		params.BeginTime = req.BeginTime
+		params.EndTime = req.EndTime
+		if !req.IncludeDelivered {
+			status := tables.NoticeStatusFail
+			params.NoticeStatus = &status
+		}
+	}
+
+	count, err := h.DbDao.ReplayNoticeList(params)
+	if err != nil {
+		apiResp.ApiRespErr(http_api.ApiCodeDbError, "Failed to replay notice")
+		return fmt.Errorf("ReplayNoticeList err: %s", err.Error())
+	}
+	resp.Count = count
+
+	apiResp.ApiRespOK(resp)
+	return nil
+}
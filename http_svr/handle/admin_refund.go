@@ -0,0 +1,88 @@
+package handle
+
+import (
+	"fmt"
+	"github.com/dotbitHQ/das-lib/http_api"
+	"github.com/gin-gonic/gin"
+	"github.com/scorpiotzh/toolib"
+	"net/http"
+	"unipay/tables"
+)
+
+type ReqAdminRefundApprove struct {
+	PayHashList []string `json:"pay_hash_list"`
+	Reject      bool     `json:"reject"`
+}
+
+type RespAdminRefundApprove struct {
+	Count int64 `json:"count"`
+}
+
+// AdminRefundPendingList the refunds over the refund_auto_approve of the business policy
+func (h *HttpHandle) AdminRefundPendingList(ctx *gin.Context) {
+	var (
+		funcName             = "AdminRefundPendingList"
+		clientIp, remoteAddr = GetClientIp(ctx)
+		apiResp              http_api.ApiResp
+	)
+	log.Info("ApiReq:", funcName, clientIp, remoteAddr)
+
+	list, err := h.DbDao.GetPendingApprovalRefundList()
+	if err != nil {
+		log.Error("GetPendingApprovalRefundList err:", err.Error(), funcName, clientIp, remoteAddr)
+		apiResp.ApiRespErr(http_api.ApiCodeDbError, "Failed to get refund list")
+	} else {
+		if list == nil {
+			list = make([]tables.TablePaymentInfo, 0)
+		}
+		apiResp.ApiRespOK(list)
+	}
+
+	ctx.JSON(http.StatusOK, apiResp)
+}
+
+func (h *HttpHandle) AdminRefundApprove(ctx *gin.Context) {
+	var (
+		funcName             = "AdminRefundApprove"
+		clientIp, remoteAddr = GetClientIp(ctx)
+		req                  ReqAdminRefundApprove
+		apiResp              http_api.ApiResp
+		err                  error
+	)
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		log.Error("ShouldBindJSON err: ", err.Error(), funcName, clientIp, remoteAddr)
+		apiResp.ApiRespErr(http_api.ApiCodeParamsInvalid, "params invalid")
+		ctx.JSON(http.StatusOK, apiResp)
+		return
+	}
+	log.Info("ApiReq:", funcName, clientIp, remoteAddr, toolib.JsonString(req))
+
+	if err = h.doAdminRefundApprove(&req, &apiResp); err != nil {
+		log.Error("doAdminRefundApprove err:", err.Error(), funcName, clientIp, remoteAddr)
+	}
+
+	ctx.JSON(http.StatusOK, apiResp)
+}
+
+func (h *HttpHandle) doAdminRefundApprove(req *ReqAdminRefundApprove, apiResp *http_api.ApiResp) error {
+	var resp RespAdminRefundApprove
+	if len(req.PayHashList) == 0 {
+		apiResp.ApiRespErr(http_api.ApiCodeParamsInvalid, "pay_hash_list is empty")
+		return nil
+	}
+
+	var err error
+	if req.Reject {
+		resp.Count, err = h.DbDao.UpdatePendingApprovalToRejected(req.PayHashList)
+	} else {
+		resp.Count, err = h.DbDao.UpdatePendingApprovalToUnRefunded(req.PayHashList)
+	}
+	if err != nil {
+		apiResp.ApiRespErr(http_api.ApiCodeDbError, "Failed to update refund status")
+		return fmt.Errorf("UpdatePendingApproval err: %s", err.Error())
+	}
+
+	apiResp.ApiRespOK(resp)
+	return nil
+}
@@ -0,0 +1,74 @@
+package handle
+
+import (
+	"fmt"
+	"github.com/dotbitHQ/das-lib/http_api"
+	"github.com/gin-gonic/gin"
+	"github.com/scorpiotzh/toolib"
+	"net/http"
+	"unipay/balance"
+	"unipay/tables"
+)
+
+type ReqAdminWalletBalance struct {
+	PayTokenId tables.PayTokenId `json:"pay_token_id"` // empty for all
+	Refresh    bool              `json:"refresh"`      // check the chains now instead of the last result
+}
+
+type RespAdminWalletBalance struct {
+	CheckedAt  int64                   `json:"checked_at"` // ms, 0 before the first check
+	WalletList []balance.WalletBalance `json:"wallet_list"`
+}
+
+// AdminWalletBalance the refund wallets of the balance monitor
+func (h *HttpHandle) AdminWalletBalance(ctx *gin.Context) {
+	var (
+		funcName             = "AdminWalletBalance"
+		clientIp, remoteAddr = GetClientIp(ctx)
+		req                  ReqAdminWalletBalance
+		apiResp              http_api.ApiResp
+		err                  error
+	)
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		log.Error("ShouldBindJSON err: ", err.Error(), funcName, clientIp, remoteAddr)
+		apiResp.ApiRespErr(http_api.ApiCodeParamsInvalid, "params invalid")
+		ctx.JSON(http.StatusOK, apiResp)
+		return
+	}
+	log.Info("ApiReq:", funcName, clientIp, remoteAddr, toolib.JsonString(req))
+
+	if err = h.doAdminWalletBalance(&req, &apiResp); err != nil {
+		log.Error("doAdminWalletBalance err:", err.Error(), funcName, clientIp, remoteAddr)
+	}
+
+	ctx.JSON(http.StatusOK, apiResp)
+}
+
+func (h *HttpHandle) doAdminWalletBalance(req *ReqAdminWalletBalance, apiResp *http_api.ApiResp) error {
+	var resp RespAdminWalletBalance
+	resp.WalletList = make([]balance.WalletBalance, 0)
+
+	if balance.Monitor == nil {
+		apiResp.ApiRespErr(http_api.ApiCodeError500, "balance monitor not ready")
+		return nil
+	}
+	list, checkedAt := balance.Monitor.GetWalletBalanceList()
+	if req.Refresh {
+		var err error
+		if list, err = balance.Monitor.Check(); err != nil {
+			apiResp.ApiRespErr(http_api.ApiCodeError500, "Failed to check balance")
+			return fmt.Errorf("Check err: %s", err.Error())
+		}
+		_, checkedAt = balance.Monitor.GetWalletBalanceList()
+	}
+	resp.CheckedAt = checkedAt
+	for _, v := range list {
+		if req.PayTokenId == "" || req.PayTokenId == v.PayTokenId {
+			resp.WalletList = append(resp.WalletList, v)
+		}
+	}
+
+	apiResp.ApiRespOK(resp)
+	return nil
+}
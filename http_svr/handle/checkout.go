@@ -0,0 +1,220 @@
+package handle
+
+import (
+	"embed"
+	"fmt"
+	"github.com/dotbitHQ/das-lib/http_api"
+	"github.com/gin-gonic/gin"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"strings"
+	"unipay/business"
+	"unipay/config"
+	"unipay/stripe_api"
+	"unipay/tables"
+)
+
+//go:embed checkout
+var checkoutFS embed.FS
+
+var checkoutTmpl = template.Must(template.ParseFS(checkoutFS, "checkout/*.html"))
+
+// CheckoutAssets the css and js of the checkout page
+func CheckoutAssets() http.FileSystem {
+	sub, err := fs.Sub(checkoutFS, "checkout/assets")
+	if err != nil {
+		panic(err)
+	}
+	return http.FS(sub)
+}
+
+type CheckoutPage struct {
+	Branding             config.CheckoutBranding
+	ErrMsg               string
+	BusinessId           string
+	OrderId              string
+	PayTokenId           tables.PayTokenId
+	Symbol               string
+	Amount               string
+	PaymentAddress       string
+	ContractAddress      string
+	PaymentUriInfo       PaymentUriInfo
+	PaymentUri           template.URL // wallet schemes like ethereum: are not in the html/template allow list
+	PayStatus            tables.PayStatus
+	OrderStatus          tables.OrderStatus
+	ExpiredAt            int64
+	IsStripe             bool
+	StripePublishableKey string
+	ClientSecret         string
+	ReturnUrl            string
+	BasePath             string
+}
+
+type RespCheckoutStatus struct {
+	OrderId     string             `json:"order_id"`
+	PayStatus   tables.PayStatus   `json:"pay_status"`
+	OrderStatus tables.OrderStatus `json:"order_status"`
+	ExpiredAt   int64              `json:"expired_at"`
+	ReturnUrl   string             `json:"return_url"`
+}
+
+func (h *HttpHandle) Checkout(ctx *gin.Context) {
+	var (
+		funcName             = "Checkout"
+		clientIp, remoteAddr = GetClientIp(ctx)
+		businessId           = ctx.Param("business_id")
+		orderId              = ctx.Param("order_id")
+		apiResp              http_api.ApiResp
+	)
+	log.Info("ApiReq:", funcName, clientIp, remoteAddr, businessId, orderId)
+
+	page, err := h.doCheckout(businessId, orderId, &apiResp)
+	if err != nil {
+		log.Error("doCheckout err:", err.Error(), funcName, clientIp, remoteAddr)
+	}
+	if apiResp.ErrNo != http_api.ApiCodeSuccess {
+		page.ErrMsg = apiResp.ErrMsg
+	}
+	ctx.Status(http.StatusOK)
+	ctx.Header("Content-Type", "text/html; charset=utf-8")
+	if err := checkoutTmpl.ExecuteTemplate(ctx.Writer, "checkout.html", page); err != nil {
+		log.Error("ExecuteTemplate err:", err.Error(), funcName, clientIp, remoteAddr)
+	}
+}
+
+func (h *HttpHandle) doCheckout(businessId, orderId string, apiResp *http_api.ApiResp) (page CheckoutPage, e error) {
+	page.Branding = config.GetCheckoutBranding(businessId)
+	page.BasePath = fmt.Sprintf("/checkout/%s/%s", businessId, orderId)
+	if !config.GetCfg().Checkout.Switch {
+		apiResp.ApiRespErr(http_api.ApiCodeParamsInvalid, "Checkout is unavailable")
+		return
+	}
+	orderInfo, apiCode, err := h.getCheckoutOrder(businessId, orderId)
+	if err != nil || apiCode != http_api.ApiCodeSuccess {
+		apiResp.ApiRespErr(apiCode, "Order not exist")
+		return page, err
+	}
+
+	page.BusinessId = businessId
+	page.OrderId = orderInfo.OrderId
+	page.PayTokenId = orderInfo.PayTokenId
+	page.Symbol = orderInfo.PayTokenId.GetSymbol()
+	page.Amount = orderInfo.Amount.Shift(-orderInfo.PayTokenId.GetDecimals()).String()
+	page.PayStatus = orderInfo.PayStatus
+	page.OrderStatus = orderInfo.OrderStatus
+	page.ExpiredAt = orderInfo.GetExpiredAt()
+	page.ReturnUrl = getCheckoutReturnUrl(page.Branding, orderInfo.OrderId)
+
+	if orderInfo.PayTokenId == tables.PayTokenIdStripeUSD {
+		page.IsStripe = true
+		page.StripePublishableKey = config.GetCfg().Chain.Stripe.PublishableKey
+		if orderInfo.PayStatus == tables.PayStatusUnpaid {
+			paymentInfo, err := h.DbDao.GetPaymentInfoByOrderId(orderInfo.OrderId)
+			if err != nil {
+				apiResp.ApiRespErr(http_api.ApiCodeDbError, "Failed to get payment info")
+				return page, fmt.Errorf("GetPaymentInfoByOrderId err: %s", err.Error())
+			} else if paymentInfo.Id == 0 {
+				apiResp.ApiRespErr(http_api.ApiCodePaymentNotExist, "No payment")
+				return page, nil
+			}
+			pi, err := stripe_api.GetPaymentIntent(paymentInfo.PayHash)
+			if err != nil {
+				apiResp.ApiRespErr(http_api.ApiCodeError500, "Failed to get payment intent")
+				return page, fmt.Errorf("GetPaymentIntent err: %s", err.Error())
+			}
+			page.ClientSecret = pi.ClientSecret
+		}
+	} else {
+		page.PaymentAddress = config.GetPaymentAddressOrigin(orderInfo.PayTokenId, orderInfo.PaymentAddress)
+		page.ContractAddress = orderInfo.PayTokenId.GetContractAddress(config.GetCfg().Server.Net)
+		if page.PaymentUriInfo, err = GetPaymentUriInfo(orderInfo, page.PaymentAddress); err != nil {
+			log.Warn("GetPaymentUriInfo err:", err.Error(), orderInfo.OrderId)
+		}
+		page.PaymentUri = template.URL(page.PaymentUriInfo.PaymentUri)
+	}
+
+	apiResp.ApiRespOK(nil)
+	return
+}
+
+func (h *HttpHandle) CheckoutStatus(ctx *gin.Context) {
+	var (
+		businessId = ctx.Param("business_id")
+		orderId    = ctx.Param("order_id")
+		apiResp    http_api.ApiResp
+	)
+
+	if err := h.doCheckoutStatus(businessId, orderId, &apiResp); err != nil {
+		log.Error("doCheckoutStatus err:", err.Error(), businessId, orderId)
+	}
+
+	ctx.JSON(http.StatusOK, apiResp)
+}
+
+func (h *HttpHandle) doCheckoutStatus(businessId, orderId string, apiResp *http_api.ApiResp) error {
+	if !config.GetCfg().Checkout.Switch {
+		apiResp.ApiRespErr(http_api.ApiCodeParamsInvalid, "Checkout is unavailable")
+		return nil
+	}
+	orderInfo, apiCode, err := h.getCheckoutOrder(businessId, orderId)
+	if err != nil || apiCode != http_api.ApiCodeSuccess {
+		apiResp.ApiRespErr(apiCode, "Order not exist")
+		return err
+	}
+
+	resp := RespCheckoutStatus{
+		OrderId:     orderInfo.OrderId,
+		PayStatus:   orderInfo.PayStatus,
+		OrderStatus: orderInfo.OrderStatus,
+		ExpiredAt:   orderInfo.GetExpiredAt(),
+	}
+	if orderInfo.PayStatus == tables.PayStatusPaid {
+		resp.ReturnUrl = getCheckoutReturnUrl(config.GetCheckoutBranding(businessId), orderInfo.OrderId)
+	}
+	apiResp.ApiRespOK(resp)
+	return nil
+}
+
+func (h *HttpHandle) CheckoutQrCode(ctx *gin.Context) {
+	var (
+		funcName = "CheckoutQrCode"
+		req      = ReqOrderQrCode{
+			BusinessId: ctx.Param("business_id"),
+			OrderId:    ctx.Param("order_id"),
+			Format:     QrCodeFormatSvg,
+		}
+		apiResp http_api.ApiResp
+	)
+
+	if !config.GetCfg().Checkout.Switch {
+		ctx.Status(http.StatusNotFound)
+		return
+	}
+	contentType, data, err := h.doOrderQrCode(&req, &apiResp)
+	if err != nil {
+		log.Error("doOrderQrCode err:", err.Error(), funcName, req.OrderId)
+	}
+	if apiResp.ErrNo != http_api.ApiCodeSuccess {
+		ctx.Status(http.StatusNotFound)
+		return
+	}
+	ctx.Data(http.StatusOK, contentType, data)
+}
+
+func (h *HttpHandle) getCheckoutOrder(businessId, orderId string) (tables.TableOrderInfo, http_api.ApiCode, error) {
+	if !business.IsBusinessEnabled(businessId) || orderId == "" {
+		return tables.TableOrderInfo{}, http_api.ApiCodeOrderNotExist, nil
+	}
+	orderInfo, err := h.DbDao.GetOrderInfo(orderId, businessId)
+	if err != nil {
+		return orderInfo, http_api.ApiCodeDbError, fmt.Errorf("GetOrderInfo err: %s", err.Error())
+	} else if orderInfo.Id == 0 {
+		return orderInfo, http_api.ApiCodeOrderNotExist, nil
+	}
+	return orderInfo, http_api.ApiCodeSuccess, nil
+}
+
+func getCheckoutReturnUrl(branding config.CheckoutBranding, orderId string) string {
+	return strings.ReplaceAll(branding.ReturnUrl, "{order_id}", orderId)
+}
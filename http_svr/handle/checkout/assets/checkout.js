@@ -0,0 +1,132 @@
+(function () {
+  var root = document.getElementById("checkout");
+  var statusText = document.getElementById("status-text");
+  if (!root || !statusText) {
+    return;
+  }
+  var basePath = root.dataset.basePath;
+  var expiredAt = parseInt(root.dataset.expiredAt, 10) || 0;
+  var returnUrl = root.dataset.returnUrl;
+  var countdown = document.getElementById("countdown");
+  var done = false;
+
+  var PayStatusPaid = 1, PayStatusDispute = 2;
+  var OrderStatusNormal = 0;
+
+  function setStatus(text, cls) {
+    statusText.textContent = text;
+    statusText.className = "badge " + (cls || "");
+  }
+
+  function finish(text, cls) {
+    done = true;
+    setStatus(text, cls);
+    countdown.textContent = "";
+    var payInfo = document.getElementById("pay-info");
+    if (payInfo) {
+      payInfo.style.display = "none";
+    }
+    var form = document.getElementById("stripe-form");
+    if (form) {
+      form.style.display = "none";
+    }
+  }
+
+  function onPaid(url) {
+    finish("Paid", "paid");
+    url = url || returnUrl;
+    if (url) {
+      setTimeout(function () { window.location.href = url; }, 2000);
+    }
+  }
+
+  function apply(data) {
+    if (data.pay_status === PayStatusPaid) {
+      onPaid(data.return_url);
+    } else if (data.pay_status === PayStatusDispute) {
+      finish("Disputed", "closed");
+    } else if (data.order_status !== OrderStatusNormal) {
+      finish("Closed", "closed");
+    }
+  }
+
+  function poll() {
+    if (done) {
+      return;
+    }
+    fetch(basePath + "/status", {cache: "no-store"})
+      .then(function (res) { return res.json(); })
+      .then(function (res) {
+        if (res.err_no === 0 && res.data) {
+          apply(res.data);
+        }
+      })
+      .catch(function () {})
+      .then(function () {
+        if (!done) {
+          setTimeout(poll, 5000);
+        }
+      });
+  }
+
+  function tick() {
+    if (done) {
+      return;
+    }
+    var left = Math.floor((expiredAt - Date.now()) / 1000);
+    if (left <= 0) {
+      finish("Expired", "closed");
+      return;
+    }
+    var h = Math.floor(left / 3600), m = Math.floor(left % 3600 / 60), s = left % 60;
+    countdown.textContent = "Expires in " + h + ":" + (m < 10 ? "0" : "") + m + ":" + (s < 10 ? "0" : "") + s;
+    setTimeout(tick, 1000);
+  }
+
+  document.querySelectorAll("button.copy").forEach(function (btn) {
+    btn.addEventListener("click", function () {
+      if (navigator.clipboard) {
+        navigator.clipboard.writeText(btn.dataset.copy);
+        btn.textContent = "Copied";
+      }
+    });
+  });
+
+  function initStripe() {
+    var form = document.getElementById("stripe-form");
+    var key = root.dataset.publishableKey, clientSecret = root.dataset.clientSecret;
+    if (!form || !window.Stripe || !key || !clientSecret) {
+      return;
+    }
+    var stripe = window.Stripe(key);
+    var elements = stripe.elements({clientSecret: clientSecret});
+    elements.create("payment").mount("#payment-element");
+    var submit = document.getElementById("stripe-submit");
+    var errEl = document.getElementById("stripe-error");
+    form.addEventListener("submit", function (e) {
+      e.preventDefault();
+      submit.disabled = true;
+      errEl.textContent = "";
+      stripe.confirmPayment({
+        elements: elements,
+        confirmParams: {return_url: window.location.href},
+        redirect: "if_required"
+      }).then(function (result) {
+        if (result.error) {
+          errEl.textContent = result.error.message;
+          submit.disabled = false;
+        } else {
+          setStatus("Confirming payment", "");
+        }
+      });
+    });
+  }
+
+  if (parseInt(root.dataset.payStatus, 10) === PayStatusPaid) {
+    onPaid();
+    return;
+  }
+  initStripe();
+  tick();
+  poll();
+})();
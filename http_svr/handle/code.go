@@ -0,0 +1,39 @@
+package handle
+
+import (
+	"github.com/dotbitHQ/das-lib/http_api"
+	"unipay/business"
+)
+
+// unipay - 600XXX, extends the list in das-lib/http_api
+const (
+	ApiCodeUniqueAmountUnavailable http_api.ApiCode = 600005
+	ApiCodeOrderPaid               http_api.ApiCode = 600006
+	ApiCodeOrderStatusInvalid      http_api.ApiCode = 600007
+	ApiCodeOrderUnpaid             http_api.ApiCode = 600008
+	ApiCodeIdempotencyKeyReused    http_api.ApiCode = 600009
+	ApiCodeIdempotencyProcessing   http_api.ApiCode = 600010
+	ApiCodeAuthFailed              http_api.ApiCode = 600011
+	ApiCodePolicyTokenNotAllowed   http_api.ApiCode = 600012
+	ApiCodePolicyAmountOutOfRange  http_api.ApiCode = 600013
+	ApiCodePolicyAddressNotAllowed http_api.ApiCode = 600014
+	ApiCodePolicyTooManyOpenOrders http_api.ApiCode = 600015
+	ApiCodeChainUnhealthy          http_api.ApiCode = 600016
+)
+
+func policyErrToApiResp(e *business.PolicyErr, apiResp *http_api.ApiResp) {
+	var apiCode http_api.ApiCode
+	switch e.Violation {
+	case business.PolicyViolationTokenNotAllowed:
+		apiCode = ApiCodePolicyTokenNotAllowed
+	case business.PolicyViolationAmountOutOfRange:
+		apiCode = ApiCodePolicyAmountOutOfRange
+	case business.PolicyViolationAddressNotAllowed:
+		apiCode = ApiCodePolicyAddressNotAllowed
+	case business.PolicyViolationTooManyOpenOrders:
+		apiCode = ApiCodePolicyTooManyOpenOrders
+	default:
+		apiCode = http_api.ApiCodeParamsInvalid
+	}
+	apiResp.ApiRespErr(apiCode, e.Msg)
+}
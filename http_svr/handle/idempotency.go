@@ -0,0 +1,77 @@
+package handle
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"github.com/dotbitHQ/das-lib/http_api"
+	"github.com/scorpiotzh/toolib"
+	"unipay/tables"
+)
+
+const IdempotencyKeyMaxLen = 255
+
+// beginIdempotency returns true if the request with the key has been done,
+// apiResp is then filled with the original response
+func (h *HttpHandle) beginIdempotency(businessId, idempotencyKey, api string, req interface{}, apiResp *http_api.ApiResp) (bool, error) {
+	if len(idempotencyKey) > IdempotencyKeyMaxLen {
+		apiResp.ApiRespErr(http_api.ApiCodeParamsInvalid, "idempotency_key is too long")
+		return false, nil
+	}
+	requestHash := fmt.Sprintf("%x", sha256.Sum256([]byte(toolib.JsonString(req))))
+	ok, err := h.DbDao.CreateIdempotency(tables.TableIdempotencyInfo{
+		BusinessId:     businessId,
+		IdempotencyKey: idempotencyKey,
+		Api:            api,
+		RequestHash:    requestHash,
+		Status:         tables.IdempotencyStatusProcessing,
+	})
+	if err != nil {
+		apiResp.ApiRespErr(http_api.ApiCodeDbError, "Failed to check idempotency_key")
+		return false, fmt.Errorf("CreateIdempotency err: %s", err.Error())
+	} else if ok {
+		return false, nil
+	}
+
+	info, err := h.DbDao.GetIdempotency(businessId, idempotencyKey)
+	if err != nil {
+		apiResp.ApiRespErr(http_api.ApiCodeDbError, "Failed to check idempotency_key")
+		return false, fmt.Errorf("GetIdempotency err: %s", err.Error())
+	} else if info.Id == 0 {
+		apiResp.ApiRespErr(ApiCodeIdempotencyProcessing, "The request with the idempotency_key is in progress")
+		return false, nil
+	}
+	if info.Api != api || info.RequestHash != requestHash {
+		apiResp.ApiRespErr(ApiCodeIdempotencyKeyReused, "The idempotency_key has been used by a different request")
+		return false, nil
+	}
+	switch info.Status {
+	case tables.IdempotencyStatusDone:
+		if err := json.Unmarshal([]byte(info.Response), apiResp); err != nil {
+			apiResp.ApiRespErr(http_api.ApiCodeError500, "Failed to get the original response")
+			return false, fmt.Errorf("json.Unmarshal err: %s", err.Error())
+		}
+		return true, nil
+	default:
+		if ok, err := h.DbDao.TakeOverIdempotency(info.Id); err != nil {
+			apiResp.ApiRespErr(http_api.ApiCodeDbError, "Failed to check idempotency_key")
+			return false, fmt.Errorf("TakeOverIdempotency err: %s", err.Error())
+		} else if !ok {
+			apiResp.ApiRespErr(ApiCodeIdempotencyProcessing, "The request with the idempotency_key is in progress")
+		}
+		return false, nil
+	}
+}
+
+// endIdempotency saves a successful response for retries, failed requests can be retried with the same key
+func (h *HttpHandle) endIdempotency(businessId, idempotencyKey string, apiResp *http_api.ApiResp) {
+	if apiResp.ErrNo != http_api.ApiCodeSuccess {
+		if err := h.DbDao.DeleteIdempotency(businessId, idempotencyKey); err != nil {
+			log.Error("DeleteIdempotency err:", err.Error(), businessId, idempotencyKey)
+		}
+		return
+	}
+	if err := h.DbDao.UpdateIdempotencyToDone(businessId, idempotencyKey, toolib.JsonString(apiResp)); err != nil {
+		log.Error("UpdateIdempotencyToDone err:", err.Error(), businessId, idempotencyKey)
+	}
+}
@@ -0,0 +1,111 @@
+package handle
+
+import (
+	"fmt"
+	"github.com/dotbitHQ/das-lib/http_api"
+	"github.com/gin-gonic/gin"
+	"github.com/scorpiotzh/toolib"
+	"github.com/stripe/stripe-go/v74"
+	"net/http"
+	"unipay/stripe_api"
+	"unipay/tables"
+)
+
+type ReqOrderCancel struct {
+	BusinessId string `json:"business_id"`
+	OrderId    string `json:"order_id"`
+}
+
+type RespOrderCancel struct {
+	OrderId     string             `json:"order_id"`
+	OrderStatus tables.OrderStatus `json:"order_status"`
+}
+
+func (h *HttpHandle) OrderCancel(ctx *gin.Context) {
+	var (
+		funcName             = "OrderCancel"
+		clientIp, remoteAddr = GetClientIp(ctx)
+		req                  ReqOrderCancel
+		apiResp              http_api.ApiResp
+		err                  error
+	)
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		log.Error("ShouldBindJSON err: ", err.Error(), funcName, clientIp, remoteAddr)
+		apiResp.ApiRespErr(http_api.ApiCodeParamsInvalid, "params invalid")
+		ctx.JSON(http.StatusOK, apiResp)
+		return
+	}
+	log.Info("ApiReq:", funcName, clientIp, remoteAddr, toolib.JsonString(req))
+
+	if err = h.doOrderCancel(&req, &apiResp); err != nil {
+		log.Error("doOrderCancel err:", err.Error(), funcName, clientIp, remoteAddr)
+	}
+
+	ctx.JSON(http.StatusOK, apiResp)
+}
+
+func (h *HttpHandle) doOrderCancel(req *ReqOrderCancel, apiResp *http_api.ApiResp) error {
+	var resp RespOrderCancel
+
+	// check business_id
+	checkBusinessIds(req.BusinessId, apiResp)
+	if apiResp.ErrNo != http_api.ApiCodeSuccess {
+		return nil
+	}
+
+	orderInfo, err := h.DbDao.GetOrderInfo(req.OrderId, req.BusinessId)
+	if err != nil {
+		apiResp.ApiRespErr(http_api.ApiCodeDbError, "Failed to get order info")
+		return fmt.Errorf("GetOrderInfo err: %s", err.Error())
+	} else if orderInfo.Id == 0 {
+		apiResp.ApiRespErr(http_api.ApiCodeOrderNotExist, "Order not exist")
+		return nil
+	}
+	resp.OrderId = orderInfo.OrderId
+	resp.OrderStatus = orderInfo.OrderStatus
+
+	if orderInfo.OrderStatus == tables.OrderStatusCancel {
+		apiResp.ApiRespOK(resp)
+		return nil
+	} else if orderInfo.PayStatus != tables.PayStatusUnpaid {
+		apiResp.ApiRespErr(ApiCodeOrderPaid, "Order has been paid")
+		return nil
+	} else if orderInfo.OrderStatus != tables.OrderStatusNormal {
+		apiResp.ApiRespErr(ApiCodeOrderStatusInvalid, "Order can not be cancelled")
+		return nil
+	}
+
+	// cancel the payment intent first, a paid intent can not be cancelled
+	var paymentInfo tables.TablePaymentInfo
+	if orderInfo.PayTokenId == tables.PayTokenIdStripeUSD {
+		paymentInfo, err = h.DbDao.GetPaymentInfoByOrderId(orderInfo.OrderId)
+		if err != nil {
+			apiResp.ApiRespErr(http_api.ApiCodeDbError, "Failed to get payment info")
+			return fmt.Errorf("GetPaymentInfoByOrderId err: %s", err.Error())
+		}
+		if paymentInfo.Id > 0 && paymentInfo.PayHashStatus == tables.PayHashStatusPending {
+			pi, err := stripe_api.CancelPaymentIntent(paymentInfo.PayHash)
+			if err != nil {
+				apiResp.ApiRespErr(http_api.ApiCodeError500, "Failed to cancel payment intent")
+				return fmt.Errorf("CancelPaymentIntent err: %s", err.Error())
+			} else if pi.Status != stripe.PaymentIntentStatusCanceled {
+				apiResp.ApiRespErr(ApiCodeOrderStatusInvalid, "Order can not be cancelled")
+				return fmt.Errorf("payment intent status: %s[%s]", pi.Status, pi.ID)
+			}
+		}
+	}
+
+	ok, err := h.CN.HandleOrderCancel(orderInfo, paymentInfo)
+	if err != nil {
+		apiResp.ApiRespErr(http_api.ApiCodeDbError, "Failed to cancel order")
+		return fmt.Errorf("HandleOrderCancel err: %s", err.Error())
+	} else if !ok {
+		apiResp.ApiRespErr(ApiCodeOrderPaid, "Order has been paid")
+		return nil
+	}
+	resp.OrderStatus = tables.OrderStatusCancel
+
+	apiResp.ApiRespOK(resp)
+	return nil
+}
@@ -10,9 +10,13 @@ import (
 	"github.com/shopspring/decimal"
 	"net/http"
 	"time"
+	"unipay/business"
+	"unipay/chainswitch"
 	"unipay/config"
+	"unipay/health"
 	"unipay/stripe_api"
 	"unipay/tables"
+	"unipay/txtool"
 )
 
 type ReqOrderCreate struct {
@@ -25,14 +29,19 @@ type ReqOrderCreate struct {
 	PremiumBase       decimal.Decimal   `json:"premium_base"`
 	PremiumAmount     decimal.Decimal   `json:"premium_amount"`
 	MetaData          map[string]string `json:"meta_data"`
+	IdempotencyKey    string            `json:"idempotency_key"`
 }
 
 type RespOrderCreate struct {
-	OrderId               string `json:"order_id"`
-	PaymentAddress        string `json:"payment_address"`
-	ContractAddress       string `json:"contract_address"`
-	StripePaymentIntentId string `json:"stripe_payment_intent_id"`
-	ClientSecret          string `json:"client_secret"`
+	OrderId               string          `json:"order_id"`
+	Amount                decimal.Decimal `json:"amount"`
+	PaymentAddress        string          `json:"payment_address"`
+	ContractAddress       string          `json:"contract_address"`
+	StripePaymentIntentId string          `json:"stripe_payment_intent_id"`
+	ClientSecret          string          `json:"client_secret"`
+	CheckoutUrl           string          `json:"checkout_url"`
+	ChainUnhealthy        bool            `json:"chain_unhealthy"` // created by chain_health.flag while the chain is unhealthy
+	PaymentUriInfo
 }
 
 func (h *HttpHandle) OrderCreate(ctx *gin.Context) {
@@ -74,6 +83,25 @@ func (h *HttpHandle) doOrderCreate(req *ReqOrderCreate, apiResp *http_api.ApiRes
 	if apiResp.ErrNo != http_api.ApiCodeSuccess {
 		return nil
 	}
+	if errMsg := checkMetaData(req.MetaData); errMsg != "" {
+		apiResp.ApiRespErr(http_api.ApiCodeParamsInvalid, errMsg)
+		return nil
+	}
+	businessInfo, _ := business.GetBusiness(req.BusinessId)
+	if e := business.CheckOrderCreate(businessInfo, req.PayTokenId, req.Amount, req.PaymentAddress); e != nil {
+		policyErrToApiResp(e, apiResp)
+		return nil
+	}
+
+	// a retry with the same idempotency_key gets the original order
+	if req.IdempotencyKey != "" {
+		if done, err := h.beginIdempotency(req.BusinessId, req.IdempotencyKey, "order_create", req, apiResp); err != nil {
+			return fmt.Errorf("beginIdempotency err: %s", err.Error())
+		} else if done || apiResp.ErrNo != http_api.ApiCodeSuccess {
+			return nil
+		}
+		defer h.endIdempotency(req.BusinessId, req.IdempotencyKey, apiResp)
+	}
 
 	// create order
 	orderInfo := tables.TableOrderInfo{
@@ -86,7 +114,22 @@ func (h *HttpHandle) doOrderCreate(req *ReqOrderCreate, apiResp *http_api.ApiRes
 		OrderStatus: tables.OrderStatusNormal,
 		Timestamp:   time.Now().UnixMilli(),
 	}
-	orderInfo.InitOrderId()
+	orderInfo.ExpiredAt = business.GetOrderExpiredAt(businessInfo, orderInfo.Timestamp)
+	if len(req.MetaData) > 0 {
+		orderInfo.MetaData = toolib.JsonString(req.MetaData)
+	}
+	if req.IdempotencyKey != "" {
+		orderInfo.InitOrderIdByIdempotencyKey(req.IdempotencyKey)
+		if earlier, err := h.DbDao.GetOrderInfoByOrderId(orderInfo.OrderId); err != nil {
+			apiResp.ApiRespErr(http_api.ApiCodeDbError, "Failed to create order")
+			return fmt.Errorf("GetOrderInfoByOrderId err: %s", err.Error())
+		} else if earlier.Id > 0 {
+			apiResp.ApiRespErr(ApiCodeIdempotencyKeyReused, "The idempotency_key has been used by an earlier order")
+			return nil
+		}
+	} else {
+		orderInfo.InitOrderId()
+	}
 
 	var paymentInfo tables.TablePaymentInfo
 	if orderInfo.Amount.LessThanOrEqual(decimal.Zero) {
@@ -103,6 +146,7 @@ func (h *HttpHandle) doOrderCreate(req *ReqOrderCreate, apiResp *http_api.ApiRes
 			RefundStatus:  tables.RefundStatusDefault,
 		}
 		noticeInfo := tables.TableNoticeInfo{
+			BusinessId:   orderInfo.BusinessId,
 			EventType:    tables.EventTypeOrderPay,
 			PayHash:      paymentInfo.PayHash,
 			NoticeStatus: tables.NoticeStatusDefault,
@@ -113,14 +157,25 @@ func (h *HttpHandle) doOrderCreate(req *ReqOrderCreate, apiResp *http_api.ApiRes
 			apiResp.ApiRespErr(http_api.ApiCodeDbError, "Failed to create order")
 			return fmt.Errorf("CreateOrderInfoWithPaymentInfo err: %s", err.Error())
 		}
+		txtool.Tools.Metrics.OrderCreated().WithLabelValues(string(orderInfo.PayTokenId)).Inc()
 
 		resp.OrderId = orderInfo.OrderId
+		resp.Amount = orderInfo.Amount
 		resp.PaymentAddress = req.PaymentAddress
-		resp.ContractAddress = req.PayTokenId.GetContractAddress(config.Cfg.Server.Net)
+		resp.ContractAddress = req.PayTokenId.GetContractAddress(config.GetCfg().Server.Net)
 		apiResp.ApiRespOK(resp)
 		return nil
 	}
 
+	// max open orders of the payer
+	if openOrders, err := h.DbDao.GetOpenOrderCount(req.BusinessId, orderInfo.PayAddress); err != nil {
+		apiResp.ApiRespErr(http_api.ApiCodeDbError, "Failed to create order")
+		return fmt.Errorf("GetOpenOrderCount err: %s", err.Error())
+	} else if e := business.CheckOpenOrders(businessInfo, openOrders); e != nil {
+		policyErrToApiResp(e, apiResp)
+		return nil
+	}
+
 	// check pay token id
 	paymentAddress, err := config.GetPaymentAddress(req.PayTokenId, req.PaymentAddress)
 	if err != nil {
@@ -130,12 +185,30 @@ func (h *HttpHandle) doOrderCreate(req *ReqOrderCreate, apiResp *http_api.ApiRes
 	orderInfo.PaymentAddress = paymentAddress
 	log.Info("doOrderCreate:", paymentAddress, req.PayTokenId)
 
+	// the kill switch of the chain
+	if chainswitch.IsOrderPaused(req.PayTokenId) {
+		log.Warn("doOrderCreate chain paused:", req.PayTokenId.GetChain(), req.BusinessId)
+		apiResp.ApiRespErr(http_api.ApiCodePaymentMethodDisable, fmt.Sprintf("Payments on %s are temporarily unavailable", req.PayTokenId.GetChain()))
+		return nil
+	}
+
+	// the circuit breaker of the chain
+	if ok, chainHealth := health.IsOrderCreateAllowed(req.PayTokenId); !ok {
+		log.Warn("doOrderCreate chain unhealthy:", chainHealth.Chain, chainHealth.Reason, req.BusinessId)
+		if !config.GetCfg().ChainHealth.Flag {
+			apiResp.ApiRespErr(ApiCodeChainUnhealthy, fmt.Sprintf("Payments on %s are temporarily unavailable", chainHealth.Chain))
+			return nil
+		}
+		resp.ChainUnhealthy = true
+	}
+
+	claimed := false // the unique amount slot, released if the order is not created
 	if req.PayTokenId == tables.PayTokenIdStripeUSD {
-		if !config.Cfg.Chain.Stripe.Switch {
+		if !config.GetCfg().Chain.Stripe.Switch {
 			apiResp.ApiRespErr(http_api.ApiCodePaymentMethodDisable, "This payment method is unavailable")
 			return nil
 		}
-		if req.Amount.IntPart() < 52 {
+		if req.Amount.IntPart() < stripeMinAmount {
 			apiResp.ApiRespErr(http_api.ApiCodeAmountIsTooLow, "Amount not less than 0.52$")
 			return nil
 		}
@@ -144,7 +217,7 @@ func (h *HttpHandle) doOrderCreate(req *ReqOrderCreate, apiResp *http_api.ApiRes
 		orderInfo.PremiumBase = req.PremiumBase
 		orderInfo.PremiumAmount = req.PremiumAmount
 
-		pi, err := stripe_api.CreatePaymentIntent(req.BusinessId, orderInfo.OrderId, req.MetaData, req.Amount.IntPart())
+		pi, err := stripe_api.CreatePaymentIntent(req.BusinessId, orderInfo.OrderId, req.IdempotencyKey, req.MetaData, req.Amount.IntPart())
 		if err != nil {
 			apiResp.ApiRespErr(http_api.ApiCodeError500, "Failed to create a payment intent")
 			return fmt.Errorf("CreatePaymentIntent err: %s", err.Error())
@@ -160,17 +233,83 @@ func (h *HttpHandle) doOrderCreate(req *ReqOrderCreate, apiResp *http_api.ApiRes
 		}
 		resp.StripePaymentIntentId = pi.ID
 		resp.ClientSecret = pi.ClientSecret
+	} else if item, ok := config.GetUniqueAmountToken(req.PayTokenId); ok {
+		if claimed, err = h.claimUniqueAmount(&orderInfo, item, apiResp); err != nil {
+			return fmt.Errorf("claimUniqueAmount err: %s", err.Error())
+		} else if apiResp.ErrNo != http_api.ApiCodeSuccess {
+			return nil
+		}
 	}
 
 	if err := h.DbDao.CreateOrderInfoWithPaymentInfo(orderInfo, paymentInfo); err != nil {
+		if claimed {
+			if err := h.DbDao.ReleaseUniqueAmount(orderInfo.OrderId); err != nil {
+				log.Error("ReleaseUniqueAmount err:", err.Error(), orderInfo.OrderId)
+			}
+		}
 		apiResp.ApiRespErr(http_api.ApiCodeDbError, "Failed to create order")
 		return fmt.Errorf("CreateOrderInfoWithPaymentInfo err: %s", err.Error())
 	}
+	txtool.Tools.Metrics.OrderCreated().WithLabelValues(string(orderInfo.PayTokenId)).Inc()
 
 	resp.OrderId = orderInfo.OrderId
+	resp.Amount = orderInfo.Amount
 	resp.PaymentAddress = req.PaymentAddress
-	resp.ContractAddress = req.PayTokenId.GetContractAddress(config.Cfg.Server.Net)
+	resp.ContractAddress = req.PayTokenId.GetContractAddress(config.GetCfg().Server.Net)
+	if resp.PaymentUriInfo, err = GetPaymentUriInfo(orderInfo, req.PaymentAddress); err != nil {
+		log.Warn("GetPaymentUriInfo err:", err.Error(), orderInfo.OrderId)
+	}
+	resp.CheckoutUrl = config.GetCheckoutUrl(orderInfo.BusinessId, orderInfo.OrderId)
 
 	apiResp.ApiRespOK(resp)
 	return nil
 }
+
+// claimUniqueAmount adds the first free offset to the order amount, so that a memo-less transfer
+// can be matched by payment_address + pay_token_id + amount alone, claimed also for the offset 0
+func (h *HttpHandle) claimUniqueAmount(orderInfo *tables.TableOrderInfo, item config.UniqueAmountToken, apiResp *http_api.ApiResp) (claimed bool, e error) {
+	step := decimal.NewFromInt(int64(item.Step))
+	for i := uint64(0); i < item.MaxCount; i++ {
+		offset := step.Mul(decimal.NewFromInt(int64(i)))
+		ok, err := h.DbDao.ClaimUniqueAmount(tables.TableUniqueAmountInfo{
+			PaymentAddress: orderInfo.PaymentAddress,
+			PayTokenId:     orderInfo.PayTokenId,
+			Amount:         orderInfo.Amount.Add(offset),
+			OrderId:        orderInfo.OrderId,
+			Status:         tables.UniqueAmountStatusOccupied,
+			ExpiredAt:      orderInfo.GetExpiredAt(), // held as long as the order can still be paid
+		})
+		if err != nil {
+			apiResp.ApiRespErr(http_api.ApiCodeDbError, "Failed to create order")
+			return false, fmt.Errorf("ClaimUniqueAmount err: %s", err.Error())
+		} else if ok {
+			orderInfo.Amount = orderInfo.Amount.Add(offset)
+			orderInfo.AmountOffset = offset
+			return true, nil
+		}
+	}
+	apiResp.ApiRespErr(ApiCodeUniqueAmountUnavailable, "Too many open orders with the same amount, please try again later")
+	return false, nil
+}
+
+// checkMetaData returns the reason if the meta_data is over the limits
+func checkMetaData(metaData map[string]string) string {
+	if len(metaData) > tables.OrderMetaDataMaxKeys {
+		return fmt.Sprintf("meta_data at most %d keys", tables.OrderMetaDataMaxKeys)
+	}
+	for k, v := range metaData {
+		if k == "" || len(k) > tables.OrderMetaDataMaxKeyLen {
+			return fmt.Sprintf("meta_data key[%s] invalid", k)
+		}
+		if len(v) > tables.OrderMetaDataMaxValueLen {
+			return fmt.Sprintf("meta_data value of [%s] is too long", k)
+		}
+		if len(v) > tables.OrderMetaIndexMaxLen && config.IsOrderMetaIndexedKey(k) {
+			return fmt.Sprintf("meta_data value of the indexed key [%s] is too long", k)
+		}
+	}
+	if len(toolib.JsonString(metaData)) > tables.OrderMetaDataMaxSize {
+		return fmt.Sprintf("meta_data at most %d bytes", tables.OrderMetaDataMaxSize)
+	}
+	return ""
+}
@@ -0,0 +1,39 @@
+package handle
+
+import (
+	"github.com/dotbitHQ/das-lib/http_api"
+	"github.com/gin-gonic/gin"
+	"github.com/scorpiotzh/toolib"
+	"net/http"
+	"unipay/tables"
+)
+
+type ReqOrderFail struct {
+	BusinessId string `json:"business_id"`
+	OrderId    string `json:"order_id"`
+	Reason     string `json:"reason"`
+}
+
+func (h *HttpHandle) OrderFail(ctx *gin.Context) {
+	var (
+		funcName             = "OrderFail"
+		clientIp, remoteAddr = GetClientIp(ctx)
+		req                  ReqOrderFail
+		apiResp              http_api.ApiResp
+		err                  error
+	)
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		log.Error("ShouldBindJSON err: ", err.Error(), funcName, clientIp, remoteAddr)
+		apiResp.ApiRespErr(http_api.ApiCodeParamsInvalid, "params invalid")
+		ctx.JSON(http.StatusOK, apiResp)
+		return
+	}
+	log.Info("ApiReq:", funcName, clientIp, remoteAddr, toolib.JsonString(req))
+
+	if err = h.doOrderResult(req.BusinessId, req.OrderId, tables.OrderStatusFail, req.Reason, &apiResp); err != nil {
+		log.Error("doOrderResult err:", err.Error(), funcName, clientIp, remoteAddr)
+	}
+
+	ctx.JSON(http.StatusOK, apiResp)
+}
@@ -0,0 +1,90 @@
+package handle
+
+import (
+	"fmt"
+	"github.com/dotbitHQ/das-lib/http_api"
+	"github.com/gin-gonic/gin"
+	"github.com/scorpiotzh/toolib"
+	"net/http"
+	"unipay/tables"
+)
+
+type ReqOrderFulfil struct {
+	BusinessId string `json:"business_id"`
+	OrderId    string `json:"order_id"`
+}
+
+type RespOrderResult struct {
+	OrderId     string             `json:"order_id"`
+	OrderStatus tables.OrderStatus `json:"order_status"`
+}
+
+func (h *HttpHandle) OrderFulfil(ctx *gin.Context) {
+	var (
+		funcName             = "OrderFulfil"
+		clientIp, remoteAddr = GetClientIp(ctx)
+		req                  ReqOrderFulfil
+		apiResp              http_api.ApiResp
+		err                  error
+	)
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		log.Error("ShouldBindJSON err: ", err.Error(), funcName, clientIp, remoteAddr)
+		apiResp.ApiRespErr(http_api.ApiCodeParamsInvalid, "params invalid")
+		ctx.JSON(http.StatusOK, apiResp)
+		return
+	}
+	log.Info("ApiReq:", funcName, clientIp, remoteAddr, toolib.JsonString(req))
+
+	if err = h.doOrderResult(req.BusinessId, req.OrderId, tables.OrderStatusSuccess, "", &apiResp); err != nil {
+		log.Error("doOrderResult err:", err.Error(), funcName, clientIp, remoteAddr)
+	}
+
+	ctx.JSON(http.StatusOK, apiResp)
+}
+
+// doOrderResult is shared by /order/fulfil and /order/fail
+func (h *HttpHandle) doOrderResult(businessId, orderId string, orderStatus tables.OrderStatus, reason string, apiResp *http_api.ApiResp) error {
+	var resp RespOrderResult
+
+	// check business_id
+	checkBusinessIds(businessId, apiResp)
+	if apiResp.ErrNo != http_api.ApiCodeSuccess {
+		return nil
+	}
+
+	orderInfo, err := h.DbDao.GetOrderInfo(orderId, businessId)
+	if err != nil {
+		apiResp.ApiRespErr(http_api.ApiCodeDbError, "Failed to get order info")
+		return fmt.Errorf("GetOrderInfo err: %s", err.Error())
+	} else if orderInfo.Id == 0 {
+		apiResp.ApiRespErr(http_api.ApiCodeOrderNotExist, "Order not exist")
+		return nil
+	}
+	resp.OrderId = orderInfo.OrderId
+	resp.OrderStatus = orderInfo.OrderStatus
+
+	if orderInfo.OrderStatus == orderStatus {
+		apiResp.ApiRespOK(resp)
+		return nil
+	} else if orderInfo.PayStatus != tables.PayStatusPaid {
+		apiResp.ApiRespErr(ApiCodeOrderUnpaid, "Order is unpaid")
+		return nil
+	} else if orderInfo.OrderStatus != tables.OrderStatusNormal {
+		apiResp.ApiRespErr(ApiCodeOrderStatusInvalid, "Order status can not be changed")
+		return nil
+	}
+
+	ok, err := h.CN.HandleOrderResult(orderInfo, orderStatus, reason)
+	if err != nil {
+		apiResp.ApiRespErr(http_api.ApiCodeDbError, "Failed to update order status")
+		return fmt.Errorf("HandleOrderResult err: %s", err.Error())
+	} else if !ok {
+		apiResp.ApiRespErr(ApiCodeOrderStatusInvalid, "Order status can not be changed")
+		return nil
+	}
+	resp.OrderStatus = orderStatus
+
+	apiResp.ApiRespOK(resp)
+	return nil
+}
@@ -17,10 +17,13 @@ type ReqOrderInfo struct {
 }
 
 type RespOrderInfo struct {
-	OrderId         string `json:"order_id"`
-	PaymentAddress  string `json:"payment_address"`
-	ContractAddress string `json:"contract_address"`
-	ClientSecret    string `json:"client_secret"`
+	OrderId         string            `json:"order_id"`
+	PaymentAddress  string            `json:"payment_address"`
+	ContractAddress string            `json:"contract_address"`
+	ClientSecret    string            `json:"client_secret"`
+	CheckoutUrl     string            `json:"checkout_url"`
+	MetaData        map[string]string `json:"meta_data"`
+	PaymentUriInfo
 }
 
 func (h *HttpHandle) OrderInfo(ctx *gin.Context) {
@@ -80,7 +83,13 @@ func (h *HttpHandle) doOrderInfo(req *ReqOrderInfo, apiResp *http_api.ApiResp) e
 
 	resp.OrderId = req.OrderId
 	resp.PaymentAddress = orderInfo.PaymentAddress
-	resp.ContractAddress = orderInfo.PayTokenId.GetContractAddress(config.Cfg.Server.Net)
+	resp.ContractAddress = orderInfo.PayTokenId.GetContractAddress(config.GetCfg().Server.Net)
+	paymentAddress := config.GetPaymentAddressOrigin(orderInfo.PayTokenId, orderInfo.PaymentAddress)
+	if resp.PaymentUriInfo, err = GetPaymentUriInfo(orderInfo, paymentAddress); err != nil {
+		log.Warn("GetPaymentUriInfo err:", err.Error(), orderInfo.OrderId)
+	}
+	resp.CheckoutUrl = config.GetCheckoutUrl(orderInfo.BusinessId, orderInfo.OrderId)
+	resp.MetaData = orderInfo.GetMetaData()
 
 	apiResp.ApiRespOK(resp)
 	return nil
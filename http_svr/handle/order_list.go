@@ -0,0 +1,221 @@
+package handle
+
+import (
+	"fmt"
+	"github.com/dotbitHQ/das-lib/common"
+	"github.com/dotbitHQ/das-lib/core"
+	"github.com/dotbitHQ/das-lib/http_api"
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/gin-gonic/gin"
+	"github.com/scorpiotzh/toolib"
+	"github.com/shopspring/decimal"
+	"net/http"
+	"strconv"
+	"strings"
+	"unipay/config"
+	"unipay/dao"
+	"unipay/tables"
+)
+
+const (
+	OrderListDefaultLimit = 20
+	OrderListMaxLimit     = 100
+)
+
+type ReqOrderList struct {
+	BusinessId   string               `json:"business_id"`
+	PayAddress   string               `json:"pay_address"`
+	CoinType     common.CoinType      `json:"coin_type"` // of pay_address like the key_info of order create, 60 for a 0x address if empty
+	PayTokenId   tables.PayTokenId    `json:"pay_token_id"`
+	PayStatus    *tables.PayStatus    `json:"pay_status"`
+	OrderStatus  *tables.OrderStatus  `json:"order_status"`
+	RefundStatus *tables.RefundStatus `json:"refund_status"` // orders with any payment in the refund status
+	MetaKey      string               `json:"meta_key"`      // one of order_meta.indexed_keys, with meta_value
+	MetaValue    string               `json:"meta_value"`
+	BeginTime    int64                `json:"begin_time"` // ms, inclusive
+	EndTime      int64                `json:"end_time"`   // ms, exclusive
+	Cursor       string               `json:"cursor"`
+	Limit        int                  `json:"limit"`
+}
+
+type RespOrderList struct {
+	OrderList  []OrderListItem `json:"order_list"`
+	NextCursor string          `json:"next_cursor"` // empty if no more
+}
+
+type OrderListItem struct {
+	OrderId        string                `json:"order_id"`
+	PayAddress     string                `json:"pay_address"`
+	AlgorithmId    common.DasAlgorithmId `json:"algorithm_id"`
+	Amount         decimal.Decimal       `json:"amount"`
+	PayTokenId     tables.PayTokenId     `json:"pay_token_id"`
+	PayStatus      tables.PayStatus      `json:"pay_status"`
+	OrderStatus    tables.OrderStatus    `json:"order_status"`
+	PaymentAddress string                `json:"payment_address"`
+	Timestamp      int64                 `json:"timestamp"`
+	MetaData       map[string]string     `json:"meta_data"`
+	PaymentList    []PaymentInfo         `json:"payment_list"`
+}
+
+func (h *HttpHandle) OrderList(ctx *gin.Context) {
+	var (
+		funcName             = "OrderList"
+		clientIp, remoteAddr = GetClientIp(ctx)
+		req                  ReqOrderList
+		apiResp              http_api.ApiResp
+		err                  error
+	)
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		log.Error("ShouldBindJSON err: ", err.Error(), funcName, clientIp, remoteAddr)
+		apiResp.ApiRespErr(http_api.ApiCodeParamsInvalid, "params invalid")
+		ctx.JSON(http.StatusOK, apiResp)
+		return
+	}
+	log.Info("ApiReq:", funcName, clientIp, remoteAddr, toolib.JsonString(req))
+
+	if err = h.doOrderList(&req, &apiResp); err != nil {
+		log.Error("doOrderList err:", err.Error(), funcName, clientIp, remoteAddr)
+	}
+
+	ctx.JSON(http.StatusOK, apiResp)
+}
+
+// formatPayAddress the forms pay_address may be saved in by order create, which keeps the case of an evm address
+func (h *HttpHandle) formatPayAddress(payAddress string, coinType common.CoinType) ([]string, error) {
+	if payAddress == "" {
+		return nil, nil
+	}
+	if coinType == "" {
+		if !strings.HasPrefix(payAddress, "0x") && !strings.HasPrefix(payAddress, "0X") {
+			return []string{payAddress}, nil
+		}
+		coinType = common.CoinTypeEth
+	}
+	addr := core.ChainTypeAddress{
+		Type:    "blockchain",
+		KeyInfo: core.KeyInfo{CoinType: coinType, Key: payAddress},
+	}
+	addrHex, err := addr.FormatChainTypeAddress(h.DasCore.NetType(), true)
+	if err != nil {
+		return nil, fmt.Errorf("FormatChainTypeAddress err: %s", err.Error())
+	}
+	if addrHex.ChainType != common.ChainTypeEth {
+		return []string{addrHex.AddressHex}, nil
+	}
+	list := []string{addrHex.AddressHex}
+	for _, v := range []string{strings.ToLower(addrHex.AddressHex), ethcommon.HexToAddress(addrHex.AddressHex).Hex()} {
+		if v != list[0] && (len(list) < 2 || v != list[1]) {
+			list = append(list, v)
+		}
+	}
+	return list, nil
+}
+
+func (h *HttpHandle) doOrderList(req *ReqOrderList, apiResp *http_api.ApiResp) error {
+	var resp RespOrderList
+	resp.OrderList = make([]OrderListItem, 0)
+
+	// check business_id
+	checkBusinessIds(req.BusinessId, apiResp)
+	if apiResp.ErrNo != http_api.ApiCodeSuccess {
+		return nil
+	}
+
+	payAddressList, err := h.formatPayAddress(req.PayAddress, req.CoinType)
+	if err != nil {
+		apiResp.ApiRespErr(http_api.ApiCodeParamsInvalid, "pay_address invalid")
+		return fmt.Errorf("formatPayAddress err: %s", err.Error())
+	}
+	params := dao.OrderListParams{
+		BusinessId:     req.BusinessId,
+		PayAddressList: payAddressList,
+		PayTokenId:     req.PayTokenId,
+		PayStatus:      req.PayStatus,
+		OrderStatus:    req.OrderStatus,
+		RefundStatus:   req.RefundStatus,
+		MetaKey:        req.MetaKey,
+		MetaValue:      req.MetaValue,
+		BeginTime:      req.BeginTime,
+		EndTime:        req.EndTime,
+		Limit:          req.Limit,
+	}
+	if req.MetaKey != "" && !config.IsOrderMetaIndexedKey(req.MetaKey) {
+		apiResp.ApiRespErr(http_api.ApiCodeParamsInvalid, fmt.Sprintf("meta_key[%s] is not indexed", req.MetaKey))
+		return nil
+	}
+	if req.Cursor != "" {
+		cursor, err := strconv.ParseUint(req.Cursor, 10, 64)
+		if err != nil {
+			apiResp.ApiRespErr(http_api.ApiCodeParamsInvalid, "cursor invalid")
+			return nil
+		}
+		params.Cursor = cursor
+	}
+	if params.Limit <= 0 {
+		params.Limit = OrderListDefaultLimit
+	} else if params.Limit > OrderListMaxLimit {
+		params.Limit = OrderListMaxLimit
+	}
+
+	list, err := h.DbDao.GetOrderList(params)
+	if err != nil {
+		apiResp.ApiRespErr(http_api.ApiCodeDbError, "Failed to get order list")
+		return fmt.Errorf("GetOrderList err: %s", err.Error())
+	}
+	if len(list) == 0 {
+		apiResp.ApiRespOK(resp)
+		return nil
+	}
+
+	// payments of the orders
+	var orderIds []string
+	for _, v := range list {
+		orderIds = append(orderIds, v.OrderId)
+	}
+	paymentList, err := h.DbDao.GetPaymentListByOrderIds(orderIds)
+	if err != nil {
+		apiResp.ApiRespErr(http_api.ApiCodeDbError, "Failed to get payment list")
+		return fmt.Errorf("GetPaymentListByOrderIds err: %s", err.Error())
+	}
+	var paymentMap = make(map[string][]PaymentInfo)
+	for _, v := range paymentList {
+		paymentMap[v.OrderId] = append(paymentMap[v.OrderId], PaymentInfo{
+			OrderId:       v.OrderId,
+			PayHash:       v.PayHash,
+			SourcePayment: v.PayAddress,
+			PayAddress:    v.PayAddress,
+			Amount:        v.Amount,
+			AlgorithmId:   v.AlgorithmId,
+			PayHashStatus: v.PayHashStatus,
+			RefundHash:    v.RefundHash,
+			RefundStatus:  v.RefundStatus,
+		})
+	}
+
+	for _, v := range list {
+		item := OrderListItem{
+			OrderId:        v.OrderId,
+			PayAddress:     v.PayAddress,
+			AlgorithmId:    v.AlgorithmId,
+			Amount:         v.Amount,
+			PayTokenId:     v.PayTokenId,
+			PayStatus:      v.PayStatus,
+			OrderStatus:    v.OrderStatus,
+			PaymentAddress: config.GetPaymentAddressOrigin(v.PayTokenId, v.PaymentAddress),
+			Timestamp:      v.Timestamp,
+			MetaData:       v.GetMetaData(),
+			PaymentList:    paymentMap[v.OrderId],
+		}
+		if item.PaymentList == nil {
+			item.PaymentList = make([]PaymentInfo, 0)
+		}
+		resp.OrderList = append(resp.OrderList, item)
+	}
+	if len(list) == params.Limit {
+		resp.NextCursor = strconv.FormatUint(list[len(list)-1].Id, 10)
+	}
+
+	apiResp.ApiRespOK(resp)
+	return nil
+}
@@ -0,0 +1,115 @@
+package handle
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/dotbitHQ/das-lib/http_api"
+	"github.com/gin-gonic/gin"
+	"github.com/scorpiotzh/toolib"
+	"github.com/skip2/go-qrcode"
+	"net/http"
+	"unipay/config"
+)
+
+const (
+	QrCodeFormatPng = "png"
+	QrCodeFormatSvg = "svg"
+)
+
+type ReqOrderQrCode struct {
+	BusinessId string `json:"business_id" form:"business_id"`
+	OrderId    string `json:"order_id" form:"order_id"`
+	Format     string `json:"format" form:"format"`
+	Size       int    `json:"size" form:"size"`
+}
+
+func (h *HttpHandle) OrderQrCode(ctx *gin.Context) {
+	var (
+		funcName             = "OrderQrCode"
+		clientIp, remoteAddr = GetClientIp(ctx)
+		req                  ReqOrderQrCode
+		apiResp              http_api.ApiResp
+	)
+
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		log.Error("ShouldBindQuery err: ", err.Error(), funcName, clientIp, remoteAddr)
+		apiResp.ApiRespErr(http_api.ApiCodeParamsInvalid, "params invalid")
+		ctx.JSON(http.StatusOK, apiResp)
+		return
+	}
+	log.Info("ApiReq:", funcName, clientIp, remoteAddr, toolib.JsonString(req))
+
+	contentType, data, err := h.doOrderQrCode(&req, &apiResp)
+	if err != nil {
+		log.Error("doOrderQrCode err:", err.Error(), funcName, clientIp, remoteAddr)
+	}
+	if apiResp.ErrNo != http_api.ApiCodeSuccess {
+		ctx.JSON(http.StatusOK, apiResp)
+		return
+	}
+	ctx.Data(http.StatusOK, contentType, data)
+}
+
+func (h *HttpHandle) doOrderQrCode(req *ReqOrderQrCode, apiResp *http_api.ApiResp) (string, []byte, error) {
+	// check business_id
+	checkBusinessIds(req.BusinessId, apiResp)
+	if apiResp.ErrNo != http_api.ApiCodeSuccess {
+		return "", nil, nil
+	}
+	if req.Size <= 0 || req.Size > 1024 {
+		req.Size = 256
+	}
+
+	orderInfo, err := h.DbDao.GetOrderInfo(req.OrderId, req.BusinessId)
+	if err != nil {
+		apiResp.ApiRespErr(http_api.ApiCodeDbError, "Failed to get order info")
+		return "", nil, fmt.Errorf("GetOrderInfo err: %s", err.Error())
+	} else if orderInfo.Id == 0 {
+		apiResp.ApiRespErr(http_api.ApiCodeOrderNotExist, "Order not exist")
+		return "", nil, nil
+	}
+	paymentAddress := config.GetPaymentAddressOrigin(orderInfo.PayTokenId, orderInfo.PaymentAddress)
+	uriInfo, err := GetPaymentUriInfo(orderInfo, paymentAddress)
+	if err != nil {
+		apiResp.ApiRespErr(http_api.ApiCodeParamsInvalid, err.Error())
+		return "", nil, fmt.Errorf("GetPaymentUriInfo err: %s", err.Error())
+	} else if uriInfo.PaymentUri == "" {
+		apiResp.ApiRespErr(http_api.ApiCodeParamsInvalid, "No payment uri for this payment method")
+		return "", nil, nil
+	}
+
+	qr, err := qrcode.New(uriInfo.PaymentUri, qrcode.Medium)
+	if err != nil {
+		apiResp.ApiRespErr(http_api.ApiCodeError500, "Failed to create qr code")
+		return "", nil, fmt.Errorf("qrcode.New err: %s", err.Error())
+	}
+	apiResp.ApiRespOK(nil)
+	switch req.Format {
+	case QrCodeFormatSvg:
+		return "image/svg+xml", qrCodeToSvg(qr, req.Size), nil
+	default:
+		data, err := qr.PNG(req.Size)
+		if err != nil {
+			apiResp.ApiRespErr(http_api.ApiCodeError500, "Failed to create qr code")
+			return "", nil, fmt.Errorf("qr.PNG err: %s", err.Error())
+		}
+		return "image/png", data, nil
+	}
+}
+
+func qrCodeToSvg(qr *qrcode.QRCode, size int) []byte {
+	bitmap := qr.Bitmap()
+	var buf bytes.Buffer
+	buf.WriteString(fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		size, size, len(bitmap), len(bitmap)))
+	buf.WriteString(fmt.Sprintf(`<rect width="%d" height="%d" fill="#ffffff"/><path fill="#000000" d="`, len(bitmap), len(bitmap)))
+	for y, row := range bitmap {
+		for x, v := range row {
+			if v {
+				buf.WriteString(fmt.Sprintf("M%d %dh1v1h-1z", x, y))
+			}
+		}
+	}
+	buf.WriteString(`"/></svg>`)
+	return buf.Bytes()
+}
@@ -6,7 +6,8 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/scorpiotzh/toolib"
 	"net/http"
-	"unipay/config"
+	"unipay/business"
+	"unipay/tables"
 )
 
 type RefundInfo struct {
@@ -15,11 +16,13 @@ type RefundInfo struct {
 }
 
 type ReqOrderRefund struct {
-	BusinessId string       `json:"business_id"`
-	RefundList []RefundInfo `json:"refund_list"`
+	BusinessId     string       `json:"business_id"`
+	RefundList     []RefundInfo `json:"refund_list"`
+	IdempotencyKey string       `json:"idempotency_key"`
 }
 
 type RespOrderRefund struct {
+	PendingApprovalList []string `json:"pending_approval_list"` // pay hashes over the refund auto approval limit
 }
 
 func (h *HttpHandle) OrderRefund(ctx *gin.Context) {
@@ -47,7 +50,7 @@ func (h *HttpHandle) OrderRefund(ctx *gin.Context) {
 }
 
 func (h *HttpHandle) doOrderRefund(req *ReqOrderRefund, apiResp *http_api.ApiResp) error {
-	var resp RespOrderRefund
+	resp := RespOrderRefund{PendingApprovalList: make([]string, 0)}
 
 	// check business_id
 	checkBusinessIds(req.BusinessId, apiResp)
@@ -55,6 +58,15 @@ func (h *HttpHandle) doOrderRefund(req *ReqOrderRefund, apiResp *http_api.ApiRes
 		return nil
 	}
 
+	if req.IdempotencyKey != "" {
+		if done, err := h.beginIdempotency(req.BusinessId, req.IdempotencyKey, "order_refund", req, apiResp); err != nil {
+			return fmt.Errorf("beginIdempotency err: %s", err.Error())
+		} else if done || apiResp.ErrNo != http_api.ApiCodeSuccess {
+			return nil
+		}
+		defer h.endIdempotency(req.BusinessId, req.IdempotencyKey, apiResp)
+	}
+
 	var payHashList []string
 	var refundMap = make(map[string]string)
 	for _, v := range req.RefundList {
@@ -78,6 +90,14 @@ func (h *HttpHandle) doOrderRefund(req *ReqOrderRefund, apiResp *http_api.ApiRes
 		if orderId, ok := refundMap[v.PayHash]; !ok || orderId != v.OrderId {
 			continue
 		}
+		if business.NeedRefundApproval(req.BusinessId, v.PayTokenId, v.Amount) {
+			if err := h.DbDao.UpdatePaymentInfoToPendingApproval(v.PayHash, tables.RefundStatusDefault); err != nil {
+				log.Error("UpdatePaymentInfoToPendingApproval err: %s", err.Error())
+			} else {
+				resp.PendingApprovalList = append(resp.PendingApprovalList, v.PayHash)
+			}
+			continue
+		}
 		if err := h.DbDao.UpdatePaymentInfoToUnRefunded(v.PayHash); err != nil {
 			log.Error("UpdatePaymentInfoToUnRefunded err: %s", err.Error())
 		}
@@ -88,7 +108,7 @@ func (h *HttpHandle) doOrderRefund(req *ReqOrderRefund, apiResp *http_api.ApiRes
 }
 
 func checkBusinessIds(businessId string, apiResp *http_api.ApiResp) {
-	if _, ok := config.Cfg.BusinessIds[businessId]; !ok {
+	if !business.IsBusinessEnabled(businessId) {
 		apiResp.ApiRespErr(http_api.ApiCodeParamsInvalid, fmt.Sprintf("unknow bussiness id[%s]", businessId))
 	}
 }
@@ -0,0 +1,374 @@
+package handle
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/dotbitHQ/das-lib/http_api"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/scorpiotzh/toolib"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unipay/config"
+	"unipay/eventbus"
+	"unipay/tables"
+)
+
+const (
+	orderStreamDefaultTokenTTL    = 300
+	orderStreamDefaultMaxDuration = 1800
+	orderStreamRecheckInterval    = time.Second * 10
+	orderStreamWriteTimeout       = time.Second * 10
+)
+
+type ReqOrderStreamToken struct {
+	BusinessId string `json:"business_id"`
+	OrderId    string `json:"order_id"`
+}
+
+type RespOrderStreamToken struct {
+	Token     string `json:"token"`
+	ExpiredAt int64  `json:"expired_at"` // ms, connect before it
+}
+
+type orderStreamClaims struct {
+	BusinessId string `json:"b"`
+	OrderId    string `json:"o"`
+	ExpiredAt  int64  `json:"e"`
+}
+
+// OrderStreamToken is called by the business backend, the token is handed to the frontend
+func (h *HttpHandle) OrderStreamToken(ctx *gin.Context) {
+	var (
+		funcName             = "OrderStreamToken"
+		clientIp, remoteAddr = GetClientIp(ctx)
+		req                  ReqOrderStreamToken
+		apiResp              http_api.ApiResp
+		err                  error
+	)
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		log.Error("ShouldBindJSON err: ", err.Error(), funcName, clientIp, remoteAddr)
+		apiResp.ApiRespErr(http_api.ApiCodeParamsInvalid, "params invalid")
+		ctx.JSON(http.StatusOK, apiResp)
+		return
+	}
+	log.Info("ApiReq:", funcName, clientIp, remoteAddr, toolib.JsonString(req))
+
+	if err = h.doOrderStreamToken(&req, &apiResp); err != nil {
+		log.Error("doOrderStreamToken err:", err.Error(), funcName, clientIp, remoteAddr)
+	}
+
+	ctx.JSON(http.StatusOK, apiResp)
+}
+
+func (h *HttpHandle) doOrderStreamToken(req *ReqOrderStreamToken, apiResp *http_api.ApiResp) error {
+	var resp RespOrderStreamToken
+
+	// check business_id
+	checkBusinessIds(req.BusinessId, apiResp)
+	if apiResp.ErrNo != http_api.ApiCodeSuccess {
+		return nil
+	}
+
+	orderInfo, err := h.DbDao.GetOrderInfo(req.OrderId, req.BusinessId)
+	if err != nil {
+		apiResp.ApiRespErr(http_api.ApiCodeDbError, "Failed to get order info")
+		return fmt.Errorf("GetOrderInfo err: %s", err.Error())
+	} else if orderInfo.Id == 0 {
+		apiResp.ApiRespErr(http_api.ApiCodeOrderNotExist, "Order not exist")
+		return nil
+	}
+
+	ttl := config.GetCfg().OrderStream.TokenTTL
+	if ttl <= 0 {
+		ttl = orderStreamDefaultTokenTTL
+	}
+	claims := orderStreamClaims{
+		BusinessId: req.BusinessId,
+		OrderId:    req.OrderId,
+		ExpiredAt:  time.Now().Add(time.Duration(ttl) * time.Second).UnixMilli(),
+	}
+	resp.Token = getOrderStreamToken(claims)
+	resp.ExpiredAt = claims.ExpiredAt
+
+	apiResp.ApiRespOK(resp)
+	return nil
+}
+
+// OrderStream Server-Sent Events, an "order" event for every status change
+func (h *HttpHandle) OrderStream(ctx *gin.Context) {
+	var (
+		funcName             = "OrderStream"
+		clientIp, remoteAddr = GetClientIp(ctx)
+		apiResp              http_api.ApiResp
+	)
+
+	claims, err := parseOrderStreamToken(ctx.Query("token"))
+	if err != nil {
+		log.Warn("parseOrderStreamToken err:", err.Error(), funcName, clientIp, remoteAddr)
+		apiResp.ApiRespErr(ApiCodeAuthFailed, "Token invalid")
+		ctx.JSON(http.StatusOK, apiResp)
+		return
+	}
+	log.Info("ApiReq:", funcName, clientIp, remoteAddr, claims.BusinessId, claims.OrderId)
+
+	header := ctx.Writer.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	header.Set("X-Accel-Buffering", "no")
+	ctx.Status(http.StatusOK)
+	ctx.Writer.Flush()
+
+	send := func(event eventbus.OrderEvent) error {
+		ctx.SSEvent("order", event)
+		ctx.Writer.Flush()
+		return nil
+	}
+	keepalive := func() error {
+		if _, err := ctx.Writer.WriteString(": ping\n\n"); err != nil {
+			return err
+		}
+		ctx.Writer.Flush()
+		return nil
+	}
+	if err := h.runOrderStream(ctx.Request.Context(), claims, send, keepalive); err != nil {
+		log.Error("runOrderStream err:", err.Error(), funcName, claims.OrderId)
+	}
+}
+
+var orderStreamUpgrader = websocket.Upgrader{
+	// the token is the auth, browsers on any origin may connect
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// OrderStreamWs the same events as OrderStream, one json text message each
+func (h *HttpHandle) OrderStreamWs(ctx *gin.Context) {
+	var (
+		funcName             = "OrderStreamWs"
+		clientIp, remoteAddr = GetClientIp(ctx)
+		apiResp              http_api.ApiResp
+	)
+
+	claims, err := parseOrderStreamToken(ctx.Query("token"))
+	if err != nil {
+		log.Warn("parseOrderStreamToken err:", err.Error(), funcName, clientIp, remoteAddr)
+		apiResp.ApiRespErr(ApiCodeAuthFailed, "Token invalid")
+		ctx.JSON(http.StatusOK, apiResp)
+		return
+	}
+	log.Info("ApiReq:", funcName, clientIp, remoteAddr, claims.BusinessId, claims.OrderId)
+
+	conn, err := orderStreamUpgrader.Upgrade(ctx.Writer, ctx.Request, nil)
+	if err != nil {
+		log.Error("Upgrade err:", err.Error(), funcName, clientIp, remoteAddr)
+		return
+	}
+	defer conn.Close()
+
+	// the client sends nothing, reading only notices the close
+	wsCtx, cancel := context.WithCancel(ctx.Request.Context())
+	defer cancel()
+	go func() {
+		defer cancel()
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	send := func(event eventbus.OrderEvent) error {
+		_ = conn.SetWriteDeadline(time.Now().Add(orderStreamWriteTimeout))
+		return conn.WriteJSON(event)
+	}
+	keepalive := func() error {
+		return conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(orderStreamWriteTimeout))
+	}
+	if err := h.runOrderStream(wsCtx, claims, send, keepalive); err != nil {
+		log.Error("runOrderStream err:", err.Error(), funcName, claims.OrderId)
+	}
+	_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(orderStreamWriteTimeout))
+}
+
+// runOrderStream sends the current status, then the changes until a final status or max_duration,
+// the db is re-checked for the changes made by other processes
+func (h *HttpHandle) runOrderStream(ctx context.Context, claims orderStreamClaims, send func(eventbus.OrderEvent) error, keepalive func() error) error {
+	sub := eventbus.Subscribe(claims.OrderId)
+	defer sub.Close()
+
+	last, err := h.getOrderStreamEvent(claims)
+	if err != nil {
+		return fmt.Errorf("getOrderStreamEvent err: %s", err.Error())
+	}
+	if err := send(last); err != nil || last.Status.IsFinal() {
+		return err
+	}
+
+	maxDuration := config.GetCfg().OrderStream.MaxDuration
+	if maxDuration <= 0 {
+		maxDuration = orderStreamDefaultMaxDuration
+	}
+	deadline := time.NewTimer(time.Duration(maxDuration) * time.Second)
+	defer deadline.Stop()
+	ticker := time.NewTicker(orderStreamRecheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case event := <-sub.C:
+			if event.Status == last.Status && event.Confirmations == last.Confirmations {
+				continue
+			}
+			last = event
+			if err := send(event); err != nil || event.Status.IsFinal() {
+				return err
+			}
+		case <-ticker.C:
+			event, err := h.getOrderStreamEvent(claims)
+			if err != nil {
+				log.Error("getOrderStreamEvent err:", err.Error(), claims.OrderId)
+			} else if event.Status != last.Status || event.Confirmations != last.Confirmations {
+				last = event
+				if err := send(event); err != nil || event.Status.IsFinal() {
+					return err
+				}
+				continue
+			}
+			if err := keepalive(); err != nil {
+				return nil
+			}
+		case <-deadline.C:
+			return nil
+		case <-ctx.Done():
+			return nil
+		case <-h.Ctx.Done():
+			return nil
+		}
+	}
+}
+
+// getOrderStreamEvent the status of the order in the db
+func (h *HttpHandle) getOrderStreamEvent(claims orderStreamClaims) (eventbus.OrderEvent, error) {
+	event := eventbus.OrderEvent{
+		OrderId:   claims.OrderId,
+		Status:    eventbus.OrderStatusPending,
+		Timestamp: time.Now().UnixMilli(),
+	}
+	orderInfo, err := h.DbDao.GetOrderInfo(claims.OrderId, claims.BusinessId)
+	if err != nil {
+		return event, fmt.Errorf("GetOrderInfo err: %s", err.Error())
+	} else if orderInfo.Id == 0 {
+		return event, fmt.Errorf("order not exist[%s]", claims.OrderId)
+	}
+	paymentList, err := h.DbDao.GetPaymentListByOrderIds([]string{orderInfo.OrderId})
+	if err != nil {
+		return event, fmt.Errorf("GetPaymentListByOrderIds err: %s", err.Error())
+	}
+
+	switch {
+	case orderInfo.OrderStatus == tables.OrderStatusCancel:
+		event.Status = eventbus.OrderStatusCancelled
+	case orderInfo.PayStatus == tables.PayStatusPaid:
+		event.Status = eventbus.OrderStatusPaid
+	case orderInfo.PayStatus == tables.PayStatusDispute:
+		event.Status = eventbus.OrderStatusDisputed
+	case orderInfo.IsExpired():
+		event.Status = eventbus.OrderStatusExpired
+	}
+	for _, v := range paymentList {
+		switch {
+		case v.PayHashStatus == tables.PayHashStatusFailByDispute:
+			event.Status, event.PayHash = eventbus.OrderStatusDisputed, v.PayHash
+		case v.PayHashStatus != tables.PayHashStatusConfirm:
+			continue
+		case v.RefundStatus == tables.RefundStatusRefunded:
+			event.Status, event.PayHash = eventbus.OrderStatusRefunded, v.PayHash
+		case v.RefundStatus == tables.RefundStatusUnRefund || v.RefundStatus == tables.RefundStatusRefunding ||
+			v.RefundStatus == tables.RefundStatusPendingApproval:
+			if event.Status != eventbus.OrderStatusRefunded {
+				event.Status, event.PayHash = eventbus.OrderStatusRefunding, v.PayHash
+			}
+		case event.PayHash == "":
+			event.PayHash = v.PayHash
+			event.Confirmations, event.ConfirmNum = v.Confirmations, v.Confirmations
+		}
+	}
+	if event.Status == eventbus.OrderStatusPending {
+		pendingTx, err := h.DbDao.GetPendingTxByOrderId(orderInfo.OrderId)
+		if err != nil {
+			return event, fmt.Errorf("GetPendingTxByOrderId err: %s", err.Error())
+		} else if pendingTx.Id > 0 {
+			event.Status, event.PayHash = eventbus.OrderStatusSeen, pendingTx.PayHash
+			event.Confirmations, event.ConfirmNum = pendingTx.Confirmations, pendingTx.ConfirmNum
+			if pendingTx.BlockNumber > 0 {
+				event.Status = eventbus.OrderStatusConfirming
+			}
+		}
+	}
+	return event, nil
+}
+
+var (
+	orderStreamSecretOnce sync.Once
+	orderStreamSecret     []byte
+)
+
+// getOrderStreamSecret a random secret works for a single instance only
+func getOrderStreamSecret() []byte {
+	if config.GetCfg().OrderStream.Secret != "" {
+		return []byte(config.GetCfg().OrderStream.Secret)
+	}
+	orderStreamSecretOnce.Do(func() {
+		orderStreamSecret = make([]byte, 32)
+		if _, err := rand.Read(orderStreamSecret); err != nil {
+			log.Error("rand.Read err:", err.Error())
+		}
+	})
+	return orderStreamSecret
+}
+
+// getOrderStreamToken base64url(claims) + "." + hex(hmac_sha256(secret, base64url(claims)))
+func getOrderStreamToken(claims orderStreamClaims) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(toolib.JsonString(claims)))
+	mac := hmac.New(sha256.New, getOrderStreamSecret())
+	mac.Write([]byte(payload))
+	return payload + "." + hex.EncodeToString(mac.Sum(nil))
+}
+
+func parseOrderStreamToken(token string) (claims orderStreamClaims, e error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		e = fmt.Errorf("token format invalid")
+		return
+	}
+	mac := hmac.New(sha256.New, getOrderStreamSecret())
+	mac.Write([]byte(parts[0]))
+	if !hmac.Equal([]byte(hex.EncodeToString(mac.Sum(nil))), []byte(parts[1])) {
+		e = fmt.Errorf("token signature invalid")
+		return
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		e = fmt.Errorf("DecodeString err: %s", err.Error())
+		return
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		e = fmt.Errorf("json.Unmarshal err: %s", err.Error())
+		return
+	}
+	if time.Now().UnixMilli() > claims.ExpiredAt {
+		e = fmt.Errorf("token expired")
+		return
+	}
+	return
+}
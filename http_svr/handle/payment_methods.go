@@ -0,0 +1,132 @@
+package handle
+
+import (
+	"github.com/dotbitHQ/das-lib/http_api"
+	"github.com/gin-gonic/gin"
+	"github.com/scorpiotzh/toolib"
+	"github.com/shopspring/decimal"
+	"net/http"
+	"unipay/business"
+	"unipay/chainswitch"
+	"unipay/config"
+	"unipay/health"
+	"unipay/tables"
+)
+
+type ReqPaymentMethods struct {
+	BusinessId string `json:"business_id"`
+}
+
+type RespPaymentMethods struct {
+	MethodList []PaymentMethod `json:"method_list"`
+}
+
+type PaymentMethod struct {
+	PayTokenId       tables.PayTokenId        `json:"pay_token_id"`
+	Chain            string                   `json:"chain"`
+	Symbol           string                   `json:"symbol"`
+	Decimals         int32                    `json:"decimals"`
+	ContractAddress  string                   `json:"contract_address"`
+	PaymentAddresses []string                 `json:"payment_addresses"` // the payment_address of the order create
+	MinAmount        decimal.Decimal          `json:"min_amount"`        // in the smallest unit, 0 for no limit
+	MaxAmount        decimal.Decimal          `json:"max_amount"`
+	Confirmations    uint64                   `json:"confirmations"` // 0 if no parser of the chain in this process
+	Available        bool                     `json:"available"`     // false while the breaker of the chain is open or the order create is paused
+	Paused           bool                     `json:"paused"`        // the order create is paused by the chain switch
+	Health           health.PublicChainHealth `json:"health"`
+}
+
+// stripeMinAmount 0.52$ in cents
+const stripeMinAmount = 52
+
+// paymentMethodTokens in the order of the response
+var paymentMethodTokens = []tables.PayTokenId{
+	tables.PayTokenIdETH, tables.PayTokenIdErc20USDT,
+	tables.PayTokenIdTRX, tables.PayTokenIdTrc20USDT,
+	tables.PayTokenIdBNB, tables.PayTokenIdBep20USDT,
+	tables.PayTokenIdPOL,
+	tables.PayTokenIdDOGE,
+	tables.PayTokenIdCKB, tables.PayTokenIdDAS, tables.PayTokenIdCkbCCC,
+	tables.PayTokenIdStripeUSD,
+	tables.PayTokenIdDIDPoint,
+}
+
+func (h *HttpHandle) PaymentMethods(ctx *gin.Context) {
+	var (
+		funcName             = "PaymentMethods"
+		clientIp, remoteAddr = GetClientIp(ctx)
+		req                  ReqPaymentMethods
+		apiResp              http_api.ApiResp
+		err                  error
+	)
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		log.Error("ShouldBindJSON err: ", err.Error(), funcName, clientIp, remoteAddr)
+		apiResp.ApiRespErr(http_api.ApiCodeParamsInvalid, "params invalid")
+		ctx.JSON(http.StatusOK, apiResp)
+		return
+	}
+	log.Info("ApiReq:", funcName, clientIp, remoteAddr, toolib.JsonString(req))
+
+	if err = h.doPaymentMethods(&req, &apiResp); err != nil {
+		log.Error("doPaymentMethods err:", err.Error(), funcName, clientIp, remoteAddr)
+	}
+
+	ctx.JSON(http.StatusOK, apiResp)
+}
+
+func (h *HttpHandle) doPaymentMethods(req *ReqPaymentMethods, apiResp *http_api.ApiResp) error {
+	var resp RespPaymentMethods
+	resp.MethodList = make([]PaymentMethod, 0)
+
+	checkBusinessIds(req.BusinessId, apiResp)
+	if apiResp.ErrNo != http_api.ApiCodeSuccess {
+		return nil
+	}
+
+	businessInfo, _ := business.GetBusiness(req.BusinessId)
+	for _, v := range paymentMethodTokens {
+		if !isPaymentMethodConfigured(v) {
+			continue
+		}
+		allowed, limit, addresses := business.GetTokenPolicy(businessInfo, v)
+		if !allowed {
+			continue
+		}
+		available, _ := health.IsOrderCreateAllowed(v)
+		paused := chainswitch.IsOrderPaused(v)
+		chainHealth := health.GetPayTokenHealth(v)
+		method := PaymentMethod{
+			PayTokenId:       v,
+			Chain:            v.GetChain(),
+			Symbol:           v.GetSymbol(),
+			Decimals:         v.GetDecimals(),
+			ContractAddress:  v.GetContractAddress(config.GetCfg().Server.Net),
+			PaymentAddresses: addresses,
+			MinAmount:        limit.Min,
+			MaxAmount:        limit.Max,
+			Confirmations:    chainHealth.ConfirmNum,
+			Available:        available && !paused,
+			Paused:           paused,
+			Health:           chainHealth.ToPublic(),
+		}
+		if v == tables.PayTokenIdStripeUSD && method.MinAmount.LessThan(decimal.NewFromInt(stripeMinAmount)) {
+			method.MinAmount = decimal.NewFromInt(stripeMinAmount)
+		}
+		resp.MethodList = append(resp.MethodList, method)
+	}
+
+	apiResp.ApiRespOK(resp)
+	return nil
+}
+
+// isPaymentMethodConfigured the chain has a receiving address, or the switch for stripe and dp
+func isPaymentMethodConfigured(payTokenId tables.PayTokenId) bool {
+	switch payTokenId {
+	case tables.PayTokenIdStripeUSD:
+		return config.GetCfg().Chain.Stripe.Switch
+	case tables.PayTokenIdDIDPoint:
+		return config.GetCfg().Chain.DP.Switch
+	}
+	return len(config.GetAddrMap(payTokenId)) > 0
+}
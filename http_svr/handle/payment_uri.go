@@ -0,0 +1,93 @@
+package handle
+
+import (
+	"encoding/hex"
+	"fmt"
+	"github.com/dotbitHQ/das-lib/common"
+	"net/url"
+	"unipay/config"
+	"unipay/tables"
+)
+
+type MemoField string
+
+const (
+	MemoFieldInputData  MemoField = "input_data"  // evm tx input
+	MemoFieldTronData   MemoField = "tron_data"   // tron tx raw_data.data
+	MemoFieldOutputData MemoField = "output_data" // ckb outputs_data of the payment output
+	MemoFieldOpReturn   MemoField = "op_return"   // doge OP_RETURN output
+)
+
+type PaymentUriInfo struct {
+	PaymentUri string    `json:"payment_uri"`
+	Memo       string    `json:"memo"`
+	MemoHex    string    `json:"memo_hex"`
+	MemoField  MemoField `json:"memo_field"`
+}
+
+// GetPaymentUriInfo builds the wallet uri and the memo the parser of the chain expects,
+// paymentAddress is the address in AddrMap rather than the formatted one in the order
+func GetPaymentUriInfo(orderInfo tables.TableOrderInfo, paymentAddress string) (info PaymentUriInfo, e error) {
+	net := config.GetCfg().Server.Net
+	payTokenId := orderInfo.PayTokenId
+	amount := orderInfo.Amount
+	decAmount := amount.Shift(-payTokenId.GetDecimals()).String()
+	memoHex := hex.EncodeToString([]byte(orderInfo.OrderId))
+
+	switch payTokenId {
+	case tables.PayTokenIdETH, tables.PayTokenIdBNB, tables.PayTokenIdPOL:
+		info.Memo = orderInfo.OrderId
+		info.MemoHex = "0x" + memoHex
+		info.MemoField = MemoFieldInputData
+		info.PaymentUri = fmt.Sprintf("ethereum:%s@%s?value=%s&data=%s",
+			paymentAddress, getEvmChainId(payTokenId, net), amount.String(), info.MemoHex)
+	case tables.PayTokenIdErc20USDT, tables.PayTokenIdBep20USDT:
+		info.PaymentUri = fmt.Sprintf("ethereum:%s@%s/transfer?address=%s&uint256=%s",
+			payTokenId.GetContractAddress(net), getEvmChainId(payTokenId, net), paymentAddress, amount.String())
+	case tables.PayTokenIdTRX:
+		info.Memo = orderInfo.OrderId
+		info.MemoHex = memoHex
+		info.MemoField = MemoFieldTronData
+		info.PaymentUri = fmt.Sprintf("tron:%s?amount=%s&memo=%s",
+			paymentAddress, decAmount, url.QueryEscape(orderInfo.OrderId))
+	case tables.PayTokenIdTrc20USDT:
+		info.PaymentUri = fmt.Sprintf("tron:%s?amount=%s&contract=%s",
+			paymentAddress, decAmount, payTokenId.GetContractAddress(net))
+	case tables.PayTokenIdDAS, tables.PayTokenIdCKB, tables.PayTokenIdCkbCCC:
+		info.Memo = orderInfo.OrderId
+		info.MemoHex = "0x" + memoHex
+		info.MemoField = MemoFieldOutputData
+		info.PaymentUri = fmt.Sprintf("ckb:%s?amount=%s&data=%s", paymentAddress, decAmount, info.MemoHex)
+	case tables.PayTokenIdDOGE:
+		info.Memo = orderInfo.OrderId
+		info.MemoHex = memoHex
+		info.MemoField = MemoFieldOpReturn
+		info.PaymentUri = fmt.Sprintf("dogecoin:%s?amount=%s&message=%s",
+			paymentAddress, decAmount, url.QueryEscape(orderInfo.OrderId))
+	case tables.PayTokenIdStripeUSD, tables.PayTokenIdDIDPoint:
+		// no wallet uri
+	default:
+		e = fmt.Errorf("unknow pay token id[%s]", payTokenId)
+	}
+	return
+}
+
+func getEvmChainId(payTokenId tables.PayTokenId, net common.DasNetType) common.ChainId {
+	isMainNet := net == common.DasNetTypeMainNet
+	switch payTokenId {
+	case tables.PayTokenIdBNB, tables.PayTokenIdBep20USDT:
+		if isMainNet {
+			return common.ChainIdBscMainNet
+		}
+		return common.ChainIdBscTestNet
+	case tables.PayTokenIdPOL:
+		if isMainNet {
+			return common.ChainIdPolygonMainNet
+		}
+		return common.ChainIdPolygonTestNet
+	}
+	if isMainNet {
+		return common.ChainIdEthMainNet
+	}
+	return common.ChainIdEthTestNet
+}
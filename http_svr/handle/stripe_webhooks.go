@@ -44,7 +44,7 @@ func (h *HttpHandle) doStripeWebhooks(ctx *gin.Context) (httpCode int, e error)
 	log.Info("stripeSignature:", stripeSignature)
 	//log.Info("payload:", string(payload))
 
-	endpointSecret := config.Cfg.Chain.Stripe.EndpointSecret
+	endpointSecret := config.GetCfg().Chain.Stripe.EndpointSecret
 	event, err := webhook.ConstructEvent(payload, stripeSignature, endpointSecret)
 	if err != nil {
 		e = fmt.Errorf("webhook.ConstructEven err: %s", err.Error())
@@ -76,7 +76,7 @@ func (h *HttpHandle) doStripeWebhooks(ctx *gin.Context) (httpCode int, e error)
 				fee = dispute.BalanceTransactions[0].Fee
 			}
 			msg = fmt.Sprintf("Event: %s\nEventID: %s\nDisputeID: %s\nAmount: %.2f\nFee: %.2f\nReason: %s\nPaymentIntentID: %s", event.Type, event.ID, dispute.ID, float64(dispute.Amount)/100, float64(fee)/100, dispute.Reason, dispute.PaymentIntent.ID)
-			notify.SendLarkTextNotifyAtAll(config.Cfg.Notify.LarkErrorKey, "Stripe Dispute", msg)
+			notify.SendAlert(notify.SeverityCritical, notify.CategoryStripe, "Stripe Dispute", msg)
 			msg = ""
 			//
 			pID := dispute.PaymentIntent.ID
@@ -128,7 +128,7 @@ func (h *HttpHandle) doStripeWebhooks(ctx *gin.Context) (httpCode int, e error)
 					Action:      action,
 					Amount:      pi.Amount,
 				}
-				notify.SendStripeNotify(config.Cfg.Notify.StripeKey, si)
+				notify.SendStripeNotify(si)
 			}
 		} else if event.Type == "payment_intent.succeeded" {
 			var pi stripe.PaymentIntent
@@ -175,12 +175,12 @@ func (h *HttpHandle) doStripeWebhooks(ctx *gin.Context) (httpCode int, e error)
 					Action:      action,
 					Amount:      pi.Amount,
 				}
-				notify.SendStripeNotify(config.Cfg.Notify.StripeKey, si)
+				notify.SendStripeNotify(si)
 			}
 			//
-			if config.Cfg.Chain.Stripe.LargeAmount > 0 && pi.Amount > config.Cfg.Chain.Stripe.LargeAmount*100 {
+			if config.GetCfg().Chain.Stripe.LargeAmount > 0 && pi.Amount > config.GetCfg().Chain.Stripe.LargeAmount*100 {
 				msg = fmt.Sprintf("Event: %s\nEventID: %s\nPaymentIntentID: %s\nAmount: %.2f", event.Type, event.ID, pi.ID, float64(pi.Amount)/100)
-				notify.SendLarkErrNotify("Large Amount Order for Stripe", msg)
+				notify.SendAlert(notify.SeverityError, notify.CategoryStripe, "Large Amount Order for Stripe", msg)
 				msg = ""
 			}
 		}
@@ -188,7 +188,7 @@ func (h *HttpHandle) doStripeWebhooks(ctx *gin.Context) (httpCode int, e error)
 		msg = fmt.Sprintf("Event: %s\nEventID: %s", event.Type, event.ID)
 	}
 	if msg != "" {
-		notify.SendLarkTextNotify(config.Cfg.Notify.LarkErrorKey, "Stripe Webhooks", msg)
+		notify.SendAlert(notify.SeverityWarn, notify.CategoryStripe, "Stripe Webhooks", msg)
 	}
 
 	httpCode = http.StatusOK
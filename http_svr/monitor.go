@@ -8,6 +8,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/parnurzeal/gorequest"
 	"net/http"
+	"strings"
 	"time"
 	"unipay/txtool"
 )
@@ -45,7 +46,8 @@ func DoMonitorLog(method string) gin.HandlerFunc {
 		statusCode := ctx.Writer.Status()
 
 		var resp http_api.ApiResp
-		if statusCode == http.StatusOK && blw.body.String() != "" {
+		isJson := strings.Contains(ctx.Writer.Header().Get("Content-Type"), "application/json")
+		if statusCode == http.StatusOK && isJson && blw.body.String() != "" {
 			if err := json.Unmarshal(blw.body.Bytes(), &resp); err != nil {
 				log.Warn("DoMonitorLog Unmarshal err:", method, err)
 				return
@@ -54,7 +56,7 @@ func DoMonitorLog(method string) gin.HandlerFunc {
 				log.Warn("DoMonitorLog:", method, resp.ErrNo, resp.ErrMsg)
 			}
 		}
-		txtool.Tools.Metrics.Api().WithLabelValues(method, fmt.Sprint(statusCode), fmt.Sprint(resp.ErrNo), resp.ErrMsg).Observe(time.Since(startTime).Seconds())
+		txtool.Tools.Metrics.Api().WithLabelValues(method, fmt.Sprint(statusCode), fmt.Sprint(resp.ErrNo)).Observe(time.Since(startTime).Seconds())
 	}
 }
 
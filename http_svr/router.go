@@ -6,11 +6,12 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/scorpiotzh/toolib"
 	"net/http"
+	"unipay/http_svr/handle"
 )
 
 func (h *HttpSvr) initRouter() {
 	h.engine.Use(toolib.MiddlewareCors())
-	v1 := h.engine.Group("v1")
+	v1 := h.engine.Group("v1", DoApiAuth("/v1/version", "/v1/order/stream", "/v1/order/stream/ws"))
 	{
 		// cache
 		//longExpireTime, longDataTime := time.Second*15, time.Minute*10
@@ -23,10 +24,50 @@ func (h *HttpSvr) initRouter() {
 		v1.POST("/version", DoMonitorLog("version"), h.H.Version)
 		v1.POST("/order/info", DoMonitorLog("order_info"), h.H.OrderInfo)
 		v1.POST("/payment/info", DoMonitorLog("payment_info"), h.H.PaymentInfo)
+		v1.POST("/payment/methods", DoMonitorLog("payment_methods"), h.H.PaymentMethods)
+		v1.GET("/order/qrcode", DoMonitorLog("order_qrcode"), h.H.OrderQrCode)
+		v1.POST("/order/list", DoMonitorLog("order_list"), h.H.OrderList)
+		v1.POST("/order/stream/token", DoMonitorLog("order_stream_token"), h.H.OrderStreamToken)
+		v1.GET("/order/stream", h.H.OrderStream) // auth by the stream token
+		v1.GET("/order/stream/ws", h.H.OrderStreamWs)
 
 		// operate
 		v1.POST("/order/create", DoMonitorLog("order_create"), h.H.OrderCreate)
 		v1.POST("/order/refund", DoMonitorLog("order_refund"), h.H.OrderRefund)
+		v1.POST("/order/cancel", DoMonitorLog("order_cancel"), h.H.OrderCancel)
+		v1.POST("/order/fulfil", DoMonitorLog("order_fulfil"), h.H.OrderFulfil)
+		v1.POST("/order/fail", DoMonitorLog("order_fail"), h.H.OrderFail)
+	}
+
+	// admin
+	admin := h.engine.Group("admin/v1", DoAdminAuth())
+	{
+		admin.POST("/business/list", DoMonitorLog("admin_business_list"), h.H.AdminBusinessList)
+		admin.POST("/business/info", DoMonitorLog("admin_business_info"), h.H.AdminBusinessInfo)
+		admin.POST("/business/create", DoMonitorLog("admin_business_create"), h.H.AdminBusinessCreate)
+		admin.POST("/business/update", DoMonitorLog("admin_business_update"), h.H.AdminBusinessUpdate)
+		admin.POST("/business/delete", DoMonitorLog("admin_business_delete"), h.H.AdminBusinessDelete)
+		admin.POST("/business/secret/rotate", DoMonitorLog("admin_business_secret_rotate"), h.H.AdminBusinessSecretRotate)
+		admin.POST("/business/secret/revoke", DoMonitorLog("admin_business_secret_revoke"), h.H.AdminBusinessSecretRevoke)
+		admin.POST("/refund/pending/list", DoMonitorLog("admin_refund_pending_list"), h.H.AdminRefundPendingList)
+		admin.POST("/refund/approve", DoMonitorLog("admin_refund_approve"), h.H.AdminRefundApprove)
+		admin.POST("/notice/list", DoMonitorLog("admin_notice_list"), h.H.AdminNoticeList)
+		admin.POST("/notice/replay", DoMonitorLog("admin_notice_replay"), h.H.AdminNoticeReplay)
+		admin.POST("/wallet/balance", DoMonitorLog("admin_wallet_balance"), h.H.AdminWalletBalance)
+		admin.POST("/chain/health/list", DoMonitorLog("admin_chain_health_list"), h.H.AdminChainHealthList)
+		admin.POST("/chain/switch/list", DoMonitorLog("admin_chain_switch_list"), h.H.AdminChainSwitchList)
+		admin.POST("/chain/switch/update", DoMonitorLog("admin_chain_switch_update"), h.H.AdminChainSwitchUpdate)
+		admin.POST("/chain/switch/log", DoMonitorLog("admin_chain_switch_log"), h.H.AdminChainSwitchLog)
+		admin.POST("/node/list", DoMonitorLog("admin_node_list"), h.H.AdminNodeList)
+	}
+
+	// hosted checkout page
+	h.engine.StaticFS("/checkout-assets", handle.CheckoutAssets())
+	checkout := h.engine.Group("checkout")
+	{
+		checkout.GET("/:business_id/:order_id", h.H.Checkout)
+		checkout.GET("/:business_id/:order_id/status", h.H.CheckoutStatus)
+		checkout.GET("/:business_id/:order_id/qrcode", h.H.CheckoutQrCode)
 	}
 }
 
@@ -0,0 +1,178 @@
+package nodepool
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"github.com/dotbitHQ/das-lib/bitcoin"
+	"github.com/dotbitHQ/das-lib/chain/chain_evm"
+	"github.com/dotbitHQ/das-lib/chain/chain_tron"
+	"github.com/nervosnetwork/ckb-sdk-go/rpc"
+	"unipay/config"
+)
+
+// the clients of a chain are in the order of its endpoints
+
+type EvmPool struct {
+	*Pool
+	clients []*chain_evm.ChainEvm
+}
+
+func NewEvmPool(ctx context.Context, chain string, nodes []config.NodeEndpoint, refundAddFee float64) (*EvmPool, error) {
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("node of %s is empty", chain)
+	}
+	p := EvmPool{}
+	for _, v := range nodes {
+		client, err := chain_evm.NewChainEvm(ctx, v.Url, refundAddFee)
+		if err != nil {
+			return nil, fmt.Errorf("NewChainEvm err: %s [%s]", err.Error(), getEndpointName(v.Url))
+		}
+		p.clients = append(p.clients, client)
+	}
+	p.Pool = newPool(chain, nodes, &p)
+	return &p, nil
+}
+
+func (p *EvmPool) Client() (int, *chain_evm.ChainEvm) {
+	index := p.Pick()
+	return index, p.clients[index]
+}
+
+func (p *EvmPool) GetHead(index int) (uint64, error) {
+	return p.clients[index].BestBlockNumber()
+}
+
+func (p *EvmPool) GetBlockHash(index int, blockNumber uint64) (string, error) {
+	client := p.clients[index]
+	var header struct {
+		Hash string `json:"hash"`
+	}
+	method := fmt.Sprintf(`{"jsonrpc":"2.0","method":"eth_getBlockByNumber","params":["0x%x", false],"id":1}`, blockNumber)
+	if resp, err := client.Request(client.Node, method, &header); err != nil {
+		return "", err
+	} else if resp.Error.Code != 0 {
+		return "", fmt.Errorf("request err: %s [%d]", resp.Error.Message, resp.Error.Code)
+	} else if header.Hash == "" {
+		return "", fmt.Errorf("block %d not found", blockNumber)
+	}
+	return header.Hash, nil
+}
+
+type TronPool struct {
+	*Pool
+	clients []*chain_tron.ChainTron
+}
+
+func NewTronPool(ctx context.Context, chain string, nodes []config.NodeEndpoint) (*TronPool, error) {
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("node of %s is empty", chain)
+	}
+	p := TronPool{}
+	for _, v := range nodes {
+		client, err := chain_tron.NewChainTron(ctx, v.Url)
+		if err != nil {
+			return nil, fmt.Errorf("NewChainTron err: %s [%s]", err.Error(), getEndpointName(v.Url))
+		}
+		p.clients = append(p.clients, client)
+	}
+	p.Pool = newPool(chain, nodes, &p)
+	return &p, nil
+}
+
+func (p *TronPool) Client() (int, *chain_tron.ChainTron) {
+	index := p.Pick()
+	return index, p.clients[index]
+}
+
+func (p *TronPool) GetHead(index int) (uint64, error) {
+	blockNumber, err := p.clients[index].GetBlockNumber()
+	return uint64(blockNumber), err
+}
+
+func (p *TronPool) GetBlockHash(index int, blockNumber uint64) (string, error) {
+	block, err := p.clients[index].GetBlockByNumber(blockNumber)
+	if err != nil {
+		return "", err
+	} else if len(block.Blockid) == 0 {
+		return "", fmt.Errorf("block %d not found", blockNumber)
+	}
+	return hex.EncodeToString(block.Blockid), nil
+}
+
+type CkbPool struct {
+	*Pool
+	ctx     context.Context
+	clients []rpc.Client
+}
+
+func NewCkbPool(ctx context.Context, chain string, nodes []config.NodeEndpoint) (*CkbPool, error) {
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("node of %s is empty", chain)
+	}
+	p := CkbPool{ctx: ctx}
+	for _, v := range nodes {
+		client, err := rpc.DialWithIndexer(v.Url, v.Url)
+		if err != nil {
+			return nil, fmt.Errorf("rpc.DialWithIndexer err: %s [%s]", err.Error(), getEndpointName(v.Url))
+		}
+		p.clients = append(p.clients, client)
+	}
+	p.Pool = newPool(chain, nodes, &p)
+	return &p, nil
+}
+
+func (p *CkbPool) Client() (int, rpc.Client) {
+	index := p.Pick()
+	return index, p.clients[index]
+}
+
+func (p *CkbPool) GetHead(index int) (uint64, error) {
+	return p.clients[index].GetTipBlockNumber(p.ctx)
+}
+
+func (p *CkbPool) GetBlockHash(index int, blockNumber uint64) (string, error) {
+	header, err := p.clients[index].GetHeaderByNumber(p.ctx, blockNumber)
+	if err != nil {
+		return "", err
+	} else if header == nil {
+		return "", fmt.Errorf("block %d not found", blockNumber)
+	}
+	return header.Hash.Hex(), nil
+}
+
+type DogePool struct {
+	*Pool
+	clients []*bitcoin.BaseRequest
+}
+
+// NewDogePool the user and password of the chain are used for the endpoints without their own
+func NewDogePool(chain string, nodes []config.NodeEndpoint, user, password string) (*DogePool, error) {
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("node of %s is empty", chain)
+	}
+	p := DogePool{}
+	for _, v := range nodes {
+		client := bitcoin.BaseRequest{RpcUrl: v.Url, User: v.User, Password: v.Password}
+		if client.User == "" {
+			client.User, client.Password = user, password
+		}
+		p.clients = append(p.clients, &client)
+	}
+	p.Pool = newPool(chain, nodes, &p)
+	return &p, nil
+}
+
+func (p *DogePool) Client() (int, *bitcoin.BaseRequest) {
+	index := p.Pick()
+	return index, p.clients[index]
+}
+
+func (p *DogePool) GetHead(index int) (uint64, error) {
+	data, err := p.clients[index].GetBlockChainInfo()
+	return data.Blocks, err
+}
+
+func (p *DogePool) GetBlockHash(index int, blockNumber uint64) (string, error) {
+	return p.clients[index].GetBlockHash(blockNumber)
+}
@@ -0,0 +1,473 @@
+package nodepool
+
+import (
+	"context"
+	"fmt"
+	"github.com/dotbitHQ/das-lib/http_api/logger"
+	"math/rand"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unipay/config"
+	"unipay/notify"
+	"unipay/txtool"
+)
+
+var log = logger.NewLogger("nodepool", logger.LevelDebug)
+
+type Status string
+
+const (
+	StatusUp   Status = "up"
+	StatusDown Status = "down"
+)
+
+const (
+	defaultProbeInterval = 15
+	defaultMaxHeadLag    = 5
+	defaultStaleSeconds  = 300
+	defaultErrRate       = 0.5
+	defaultDownSeconds   = 60
+
+	// resultWindow the recent calls of the err rate, it is not evaluated below resultMinCount
+	resultWindow   = 20
+	resultMinCount = 5
+)
+
+// Prober the node calls of one endpoint of the pool, by the index of the endpoint
+type Prober interface {
+	GetHead(index int) (uint64, error)
+	GetBlockHash(index int, blockNumber uint64) (string, error)
+}
+
+// Endpoint the name is the host of the url, the keys in the path of the url are not shown
+type Endpoint struct {
+	Name    string  `json:"name"`
+	Weight  int     `json:"weight"`
+	Backup  bool    `json:"backup"`
+	Status  Status  `json:"status"`
+	Reason  string  `json:"reason"`
+	Head    uint64  `json:"head"`
+	HeadAt  int64   `json:"head_at"` // ms, when the head last moved
+	ErrRate float64 `json:"err_rate"`
+	LastErr string  `json:"last_err"`
+	DownAt  int64   `json:"down_at"` // ms
+
+	results [resultWindow]bool // true for an error
+	count   int
+	pos     int
+	okSince time.Time // of the passing probes while down
+}
+
+// Pool the endpoints of the nodes of a chain, the calls go to the up ones by weight,
+// to the backups while no other is up, and to the least failing one if all are down
+type Pool struct {
+	Chain  string
+	prober Prober
+
+	lock      sync.Mutex
+	endpoints []*Endpoint
+	last      int // the last pick, for the failover log
+	cancel    context.CancelFunc
+}
+
+func newPool(chain string, nodes []config.NodeEndpoint, prober Prober) *Pool {
+	p := Pool{Chain: chain, prober: prober}
+	nameMap := make(map[string]int)
+	now := time.Now().UnixMilli()
+	for _, v := range nodes {
+		name := getEndpointName(v.Url)
+		if nameMap[name]++; nameMap[name] > 1 {
+			name = fmt.Sprintf("%s#%d", name, nameMap[name])
+		}
+		weight := v.Weight
+		if weight <= 0 {
+			weight = 1
+		}
+		p.endpoints = append(p.endpoints, &Endpoint{Name: name, Weight: weight, Backup: v.Backup, Status: StatusUp, HeadAt: now})
+	}
+	return &p
+}
+
+func getEndpointName(nodeUrl string) string {
+	if u, err := url.Parse(nodeUrl); err == nil && u.Host != "" {
+		return u.Host
+	}
+	// host:port of tron
+	return strings.Split(nodeUrl, "/")[0]
+}
+
+func getPoolConf() (probeInterval, staleSeconds, downSeconds int64, maxHeadLag uint64, errRate float64) {
+	conf := config.GetCfg().NodePool
+	probeInterval, staleSeconds, downSeconds = conf.ProbeInterval, conf.StaleSeconds, conf.DownSeconds
+	maxHeadLag, errRate = conf.MaxHeadLag, conf.ErrRate
+	if probeInterval <= 0 {
+		probeInterval = defaultProbeInterval
+	}
+	if staleSeconds <= 0 {
+		staleSeconds = defaultStaleSeconds
+	}
+	if downSeconds <= 0 {
+		downSeconds = defaultDownSeconds
+	}
+	if maxHeadLag == 0 {
+		maxHeadLag = defaultMaxHeadLag
+	}
+	if errRate <= 0 {
+		errRate = defaultErrRate
+	}
+	return
+}
+
+// Pick the index of the endpoint for the next calls, report their errors with it
+func (p *Pool) Pick() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	var candidates []int
+	total := 0
+	for _, backup := range []bool{false, true} {
+		for i, e := range p.endpoints {
+			if e.Status == StatusUp && e.Backup == backup {
+				candidates = append(candidates, i)
+				total += e.Weight
+			}
+		}
+		if len(candidates) > 0 {
+			break
+		}
+	}
+
+	index := 0
+	if len(candidates) == 0 {
+		for i, e := range p.endpoints {
+			if e.ErrRate < p.endpoints[index].ErrRate {
+				index = i
+			}
+		}
+	} else {
+		r := rand.Intn(total)
+		for _, i := range candidates {
+			if r -= p.endpoints[i].Weight; r < 0 {
+				index = i
+				break
+			}
+		}
+	}
+	if index != p.last && p.endpoints[p.last].Status == StatusDown {
+		log.Warn("Pick failover:", p.Chain, p.endpoints[p.last].Name, p.endpoints[index].Name)
+	}
+	p.last = index
+	return index
+}
+
+// Report the result of a node call, the endpoint goes down when its err rate is over the threshold
+func (p *Pool) Report(index int, err error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	e := p.endpoints[index]
+	e.addResult(err)
+	if e.Status == StatusUp {
+		_, _, _, _, errRate := getPoolConf()
+		if e.count >= resultMinCount && e.ErrRate >= errRate {
+			p.setDown(index, fmt.Sprintf("err rate %.2f: %s", e.ErrRate, e.LastErr))
+		}
+	}
+}
+
+func (e *Endpoint) addResult(err error) {
+	e.results[e.pos] = err != nil
+	e.pos = (e.pos + 1) % resultWindow
+	if e.count < resultWindow {
+		e.count++
+	}
+	if err != nil {
+		e.LastErr = err.Error()
+	}
+	errCount := 0
+	for i := 0; i < e.count; i++ {
+		if e.results[i] {
+			errCount++
+		}
+	}
+	e.ErrRate = float64(errCount) / float64(e.count)
+}
+
+func (e *Endpoint) resetResults() {
+	e.results = [resultWindow]bool{}
+	e.count, e.pos, e.ErrRate = 0, 0, 0
+}
+
+func (p *Pool) setDown(index int, reason string) {
+	e := p.endpoints[index]
+	e.Status, e.Reason, e.DownAt = StatusDown, reason, time.Now().UnixMilli()
+	e.okSince = time.Time{}
+	txtool.Tools.Metrics.NodeEndpointUp().WithLabelValues(p.Chain, e.Name).Set(0)
+	log.Warn("NodeDown:", p.Chain, e.Name, reason)
+
+	upCount := 0
+	for _, v := range p.endpoints {
+		if v.Status == StatusUp {
+			upCount++
+		}
+	}
+	msg := fmt.Sprintf("- Chain: %s\n- Node: %s\n- Up: %d / %d\n- Reason: %s", p.Chain, e.Name, upCount, len(p.endpoints), reason)
+	if upCount == 0 {
+		notify.SendAlert(notify.SeverityCritical, notify.CategoryNode, "NodeAllDown", msg)
+	} else {
+		notify.SendAlert(notify.SeverityWarn, notify.CategoryNode, "NodeDown", msg)
+	}
+}
+
+func (p *Pool) setUp(index int) {
+	e := p.endpoints[index]
+	log.Info("NodeRecovered:", p.Chain, e.Name, e.Reason)
+	e.Status, e.Reason, e.DownAt = StatusUp, "", 0
+	e.okSince = time.Time{}
+	e.resetResults()
+	txtool.Tools.Metrics.NodeEndpointUp().WithLabelValues(p.Chain, e.Name).Set(1)
+	notify.SendAlert(notify.SeverityInfo, notify.CategoryNode, "NodeRecovered", fmt.Sprintf("- Chain: %s\n- Node: %s", p.Chain, e.Name))
+}
+
+// Run probes the endpoints until the ctx is done or the pool is stopped
+func (p *Pool) Run(ctx context.Context, wg *sync.WaitGroup) {
+	ctx, cancel := context.WithCancel(ctx)
+	p.lock.Lock()
+	p.cancel = cancel
+	p.lock.Unlock()
+	register(p)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer unregister(p)
+		for {
+			p.probe()
+			probeInterval, _, _, _, _ := getPoolConf()
+			select {
+			case <-time.After(time.Duration(probeInterval) * time.Second):
+			case <-ctx.Done():
+				log.Warn("Run done:", p.Chain)
+				return
+			}
+		}
+	}()
+}
+
+// Stop the probes of a pool replaced by the config reload
+func (p *Pool) Stop() {
+	p.lock.Lock()
+	cancel := p.cancel
+	p.lock.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+}
+
+type probeResult struct {
+	head uint64
+	err  error
+}
+
+// probe the heads of all the endpoints, an endpoint is down while it fails, lags behind the best head or its head stays
+func (p *Pool) probe() {
+	resList := make([]probeResult, len(p.endpoints))
+	var wg sync.WaitGroup
+	for i := range p.endpoints {
+		wg.Add(1)
+		go func(index int) {
+			defer wg.Done()
+			resList[index].head, resList[index].err = p.prober.GetHead(index)
+		}(i)
+	}
+	wg.Wait()
+
+	_, staleSeconds, downSeconds, maxHeadLag, errRate := getPoolConf()
+	now := time.Now()
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	var bestHead uint64
+	for i, res := range resList {
+		e := p.endpoints[i]
+		e.addResult(res.err)
+		if res.err == nil && res.head > e.Head {
+			e.Head, e.HeadAt = res.head, now.UnixMilli()
+		}
+		if e.Head > bestHead {
+			bestHead = e.Head
+		}
+	}
+	for i, res := range resList {
+		e := p.endpoints[i]
+		// a single failed probe is counted in the err rate only, a down endpoint comes back on the probes alone
+		reason, soft := "", false
+		if e.Status == StatusUp && e.count >= resultMinCount && e.ErrRate >= errRate {
+			reason = fmt.Sprintf("err rate %.2f: %s", e.ErrRate, e.LastErr)
+		} else if res.err != nil {
+			reason, soft = fmt.Sprintf("probe err: %s", res.err.Error()), true
+		} else if bestHead > e.Head+maxHeadLag {
+			reason = fmt.Sprintf("head %d behind %d", e.Head, bestHead)
+		} else if now.Sub(time.UnixMilli(e.HeadAt)) > time.Duration(staleSeconds)*time.Second {
+			reason = fmt.Sprintf("head %d not moved for %ds", e.Head, staleSeconds)
+		}
+		txtool.Tools.Metrics.NodeEndpointHead().WithLabelValues(p.Chain, e.Name).Set(float64(e.Head))
+
+		switch {
+		case reason != "" && e.Status == StatusUp:
+			if !soft {
+				p.setDown(i, reason)
+			}
+		case reason != "":
+			e.Reason, e.okSince = reason, time.Time{}
+		case e.Status == StatusDown:
+			if e.okSince.IsZero() {
+				e.okSince = now
+			} else if now.Sub(e.okSince) >= time.Duration(downSeconds)*time.Second {
+				p.setUp(i)
+			}
+		default:
+			txtool.Tools.Metrics.NodeEndpointUp().WithLabelValues(p.Chain, e.Name).Set(1)
+		}
+	}
+}
+
+// CheckBlockHash compares the hash of the block at the parse height with the other up endpoints at that height,
+// the endpoints of the minority go down, without a majority the block is not parsed and is tried again next round
+func (p *Pool) CheckBlockHash(index int, blockNumber uint64, hash string) error {
+	if !config.GetCfg().NodePool.HashCheck {
+		return nil
+	}
+	var others []int
+	p.lock.Lock()
+	for i, e := range p.endpoints {
+		if i != index && e.Status == StatusUp && e.Head >= blockNumber {
+			others = append(others, i)
+		}
+	}
+	p.lock.Unlock()
+	if len(others) == 0 {
+		return nil
+	}
+
+	hashList := make([]string, len(others))
+	var wg sync.WaitGroup
+	for i, v := range others {
+		wg.Add(1)
+		go func(i, v int) {
+			defer wg.Done()
+			h, err := p.prober.GetBlockHash(v, blockNumber)
+			p.Report(v, err)
+			if err != nil {
+				log.Warn("GetBlockHash err:", p.Chain, blockNumber, err.Error())
+				return
+			}
+			hashList[i] = formatHash(h)
+		}(i, v)
+	}
+	wg.Wait()
+
+	hash = formatHash(hash)
+	voteMap := map[string][]int{hash: {index}}
+	total := 1
+	for i, h := range hashList {
+		if h != "" {
+			voteMap[h] = append(voteMap[h], others[i])
+			total++
+		}
+	}
+	if len(voteMap) == 1 {
+		return nil
+	}
+
+	majority := ""
+	for h, v := range voteMap {
+		if len(v)*2 > total {
+			majority = h
+		}
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	var detail []string
+	for h, v := range voteMap {
+		for _, i := range v {
+			detail = append(detail, fmt.Sprintf("%s: %s", p.endpoints[i].Name, h))
+			if majority != "" && h != majority && p.endpoints[i].Status == StatusUp {
+				p.setDown(i, fmt.Sprintf("block hash at %d not agreed", blockNumber))
+			}
+		}
+	}
+	sort.Strings(detail)
+	if majority == hash {
+		return nil
+	}
+	msg := fmt.Sprintf("- Chain: %s\n- Block: %d\n%s", p.Chain, blockNumber, strings.Join(detail, "\n"))
+	if majority == "" {
+		notify.SendAlert(notify.SeverityCritical, notify.CategoryNode, "NodeHashDisagree", msg)
+	}
+	return fmt.Errorf("block hash at %d not agreed: %s", blockNumber, strings.Join(detail, ", "))
+}
+
+func formatHash(hash string) string {
+	return strings.TrimPrefix(strings.ToLower(hash), "0x")
+}
+
+// GetEndpointList a copy of the state of the endpoints
+func (p *Pool) GetEndpointList() []Endpoint {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	list := make([]Endpoint, 0, len(p.endpoints))
+	for _, v := range p.endpoints {
+		list = append(list, *v)
+	}
+	return list
+}
+
+var (
+	poolLock sync.Mutex
+	poolMap  = make(map[string]*Pool)
+)
+
+func register(p *Pool) {
+	poolLock.Lock()
+	defer poolLock.Unlock()
+	poolMap[p.Chain] = p
+}
+
+// unregister only the registered pool of the chain, the new pool of a reload may be in its place already
+func unregister(p *Pool) {
+	poolLock.Lock()
+	defer poolLock.Unlock()
+	if poolMap[p.Chain] != p {
+		return
+	}
+	delete(poolMap, p.Chain)
+	for _, e := range p.endpoints {
+		txtool.Tools.Metrics.NodeEndpointUp().DeleteLabelValues(p.Chain, e.Name)
+		txtool.Tools.Metrics.NodeEndpointHead().DeleteLabelValues(p.Chain, e.Name)
+	}
+}
+
+type PoolInfo struct {
+	Chain        string     `json:"chain"`
+	EndpointList []Endpoint `json:"endpoint_list"`
+}
+
+// GetPoolList the running pools of this process
+func GetPoolList() []PoolInfo {
+	poolLock.Lock()
+	var pools []*Pool
+	for _, v := range poolMap {
+		pools = append(pools, v)
+	}
+	poolLock.Unlock()
+	sort.Slice(pools, func(i, j int) bool { return pools[i].Chain < pools[j].Chain })
+
+	list := make([]PoolInfo, 0, len(pools))
+	for _, v := range pools {
+		list = append(list, PoolInfo{Chain: v.Chain, EndpointList: v.GetEndpointList()})
+	}
+	return list
+}
@@ -0,0 +1,275 @@
+package nodepool
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+	"unipay/config"
+	"unipay/txtool"
+)
+
+const testCfg = `
+server:
+  net: 1
+chain:
+  ckb:
+    node: "http://127.0.0.1:8114"
+node_pool:
+  max_head_lag: 5
+  err_rate: 0.5
+  down_seconds: 60
+  hash_check: true
+`
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "unipay")
+	if err != nil {
+		panic(err)
+	}
+	configFilePath := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(configFilePath, []byte(testCfg), 0600); err != nil {
+		panic(err)
+	}
+	if err := config.InitCfg(configFilePath); err != nil {
+		panic(err)
+	}
+	txtool.Init()
+	code := m.Run()
+	_ = os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// testProber the heads and the block hashes of the endpoints by index
+type testProber struct {
+	lock     sync.Mutex
+	heads    []uint64
+	errs     []error
+	hashList []string
+}
+
+func (t *testProber) GetHead(index int) (uint64, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.heads[index], t.errs[index]
+}
+
+func (t *testProber) GetBlockHash(index int, blockNumber uint64) (string, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.hashList[index], t.errs[index]
+}
+
+func newTestPool(nodes ...config.NodeEndpoint) (*Pool, *testProber) {
+	prober := &testProber{
+		heads:    make([]uint64, len(nodes)),
+		errs:     make([]error, len(nodes)),
+		hashList: make([]string, len(nodes)),
+	}
+	return newPool("TEST", nodes, prober), prober
+}
+
+func getPickCount(p *Pool, n int) map[int]int {
+	countMap := make(map[int]int)
+	for i := 0; i < n; i++ {
+		countMap[p.Pick()]++
+	}
+	return countMap
+}
+
+func TestPoolPick(t *testing.T) {
+	p, _ := newTestPool(
+		config.NodeEndpoint{Url: "http://a.node:8545", Weight: 3},
+		config.NodeEndpoint{Url: "http://b.node:8545"},
+		config.NodeEndpoint{Url: "http://c.node:8545", Weight: 10, Backup: true},
+	)
+	if name := p.endpoints[0].Name; name != "a.node:8545" {
+		t.Fatal("name:", name)
+	}
+
+	// by weight, the backup is not used while the others are up
+	countMap := getPickCount(p, 4000)
+	if countMap[2] != 0 {
+		t.Fatal("backup picked:", countMap)
+	}
+	if rate := float64(countMap[0]) / 4000; rate < 0.7 || rate > 0.8 {
+		t.Fatal("weight:", countMap)
+	}
+
+	p.setDown(0, "test")
+	if countMap = getPickCount(p, 100); countMap[1] != 100 {
+		t.Fatal("one up:", countMap)
+	}
+
+	p.setDown(1, "test")
+	if countMap = getPickCount(p, 100); countMap[2] != 100 {
+		t.Fatal("backup:", countMap)
+	}
+
+	// all down, the least failing one
+	p.setDown(2, "test")
+	for i := 0; i < resultMinCount; i++ {
+		p.Report(0, fmt.Errorf("err"))
+		p.Report(2, fmt.Errorf("err"))
+	}
+	if countMap = getPickCount(p, 100); countMap[1] != 100 {
+		t.Fatal("all down:", countMap)
+	}
+}
+
+func TestPoolReport(t *testing.T) {
+	p, _ := newTestPool(
+		config.NodeEndpoint{Url: "http://a.node:8545"},
+		config.NodeEndpoint{Url: "http://b.node:8545"},
+	)
+
+	// not evaluated below resultMinCount
+	for i := 0; i < resultMinCount-1; i++ {
+		p.Report(0, fmt.Errorf("err"))
+	}
+	if p.endpoints[0].Status != StatusUp {
+		t.Fatal("down below the min count")
+	}
+	p.Report(0, fmt.Errorf("err"))
+	if e := p.endpoints[0]; e.Status != StatusDown || e.ErrRate != 1 {
+		t.Fatal("not down:", e.Status, e.ErrRate)
+	}
+
+	// 2 of 5 is under the err rate, 3 of 6 is not
+	for _, err := range []error{nil, nil, nil, fmt.Errorf("err"), fmt.Errorf("err")} {
+		p.Report(1, err)
+	}
+	if e := p.endpoints[1]; e.Status != StatusUp || e.ErrRate != 0.4 {
+		t.Fatal("down under the err rate:", e.Status, e.ErrRate)
+	}
+	p.Report(1, fmt.Errorf("err"))
+	if e := p.endpoints[1]; e.Status != StatusDown || e.ErrRate != 0.5 {
+		t.Fatal("not down at the err rate:", e.Status, e.ErrRate)
+	}
+
+	// the window keeps the recent calls only
+	e := &Endpoint{}
+	for i := 0; i < resultWindow; i++ {
+		e.addResult(fmt.Errorf("err"))
+	}
+	for i := 0; i < resultWindow/2; i++ {
+		e.addResult(nil)
+	}
+	if e.count != resultWindow || e.ErrRate != 0.5 {
+		t.Fatal("window:", e.count, e.ErrRate)
+	}
+}
+
+func TestPoolRecovery(t *testing.T) {
+	p, prober := newTestPool(
+		config.NodeEndpoint{Url: "http://a.node:8545"},
+		config.NodeEndpoint{Url: "http://b.node:8545"},
+	)
+	prober.heads[0], prober.heads[1] = 100, 100
+	p.probe()
+
+	for i := 0; i < resultMinCount; i++ {
+		p.Report(0, fmt.Errorf("err"))
+	}
+	if p.endpoints[0].Status != StatusDown {
+		t.Fatal("not down")
+	}
+
+	// a down endpoint stays down until the probes pass for down_seconds
+	prober.heads[0], prober.heads[1] = 101, 101
+	p.probe()
+	e := p.endpoints[0]
+	if e.Status != StatusDown || e.okSince.IsZero() {
+		t.Fatal("first passing probe:", e.Status, e.okSince)
+	}
+
+	// a failed probe starts it over
+	prober.errs[0] = fmt.Errorf("probe err")
+	p.probe()
+	if e.Status != StatusDown || !e.okSince.IsZero() {
+		t.Fatal("failed probe:", e.Status, e.okSince)
+	}
+
+	prober.errs[0] = nil
+	p.probe()
+	e.okSince = e.okSince.Add(-time.Second * 60)
+	p.probe()
+	if e.Status != StatusUp || e.ErrRate != 0 || e.count != 0 {
+		t.Fatal("not recovered:", e.Status, e.ErrRate, e.count)
+	}
+
+	// a single failed probe of an up endpoint only counts in the err rate
+	prober.errs[1] = fmt.Errorf("probe err")
+	p.probe()
+	if p.endpoints[1].Status != StatusUp {
+		t.Fatal("down on a single failed probe")
+	}
+	prober.errs[1] = nil
+
+	// lagging behind the best head
+	prober.heads[0], prober.heads[1] = 120, 110
+	p.probe()
+	if p.endpoints[1].Status != StatusDown || p.endpoints[0].Status != StatusUp {
+		t.Fatal("lag:", p.endpoints[0].Status, p.endpoints[1].Status)
+	}
+}
+
+func newTestHashPool(hashList ...string) *Pool {
+	var nodes []config.NodeEndpoint
+	for i := range hashList {
+		nodes = append(nodes, config.NodeEndpoint{Url: fmt.Sprintf("http://%d.node:8545", i)})
+	}
+	p, prober := newTestPool(nodes...)
+	for i, e := range p.endpoints {
+		e.Head = 100
+		prober.hashList[i] = hashList[i]
+	}
+	return p
+}
+
+func TestPoolCheckBlockHash(t *testing.T) {
+	// all agree, the case and the 0x prefix do not matter
+	p := newTestHashPool("0xAA", "aa", "0xaa")
+	if err := p.CheckBlockHash(0, 100, "0xAA"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the majority agrees with the caller, the minority goes down
+	p = newTestHashPool("0xaa", "0xaa", "0xbb")
+	if err := p.CheckBlockHash(0, 100, "0xaa"); err != nil {
+		t.Fatal(err)
+	}
+	if p.endpoints[2].Status != StatusDown || p.endpoints[0].Status != StatusUp || p.endpoints[1].Status != StatusUp {
+		t.Fatal("majority:", p.GetEndpointList())
+	}
+
+	// the caller is the minority, the block is not parsed
+	p = newTestHashPool("0xbb", "0xaa", "0xaa")
+	if err := p.CheckBlockHash(0, 100, "0xbb"); err == nil {
+		t.Fatal("minority not rejected")
+	}
+	if p.endpoints[0].Status != StatusDown || p.endpoints[1].Status != StatusUp || p.endpoints[2].Status != StatusUp {
+		t.Fatal("minority:", p.GetEndpointList())
+	}
+
+	// no majority, nothing goes down
+	p = newTestHashPool("0xaa", "0xbb")
+	if err := p.CheckBlockHash(0, 100, "0xaa"); err == nil {
+		t.Fatal("no majority not rejected")
+	}
+	for _, e := range p.endpoints {
+		if e.Status != StatusUp {
+			t.Fatal("no majority:", p.GetEndpointList())
+		}
+	}
+
+	// the endpoints below the height and the failing ones have no vote
+	p = newTestHashPool("0xaa", "0xbb", "0xbb")
+	p.endpoints[1].Head = 99
+	p.prober.(*testProber).errs[2] = fmt.Errorf("err")
+	if err := p.CheckBlockHash(0, 100, "0xaa"); err != nil {
+		t.Fatal(err)
+	}
+}
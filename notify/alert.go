@@ -0,0 +1,279 @@
+package notify
+
+import (
+	"fmt"
+	"sync"
+	"time"
+	"unipay/config"
+	"unipay/txtool"
+)
+
+type Severity string
+
+const (
+	SeverityInfo     Severity = "info"
+	SeverityWarn     Severity = "warn"
+	SeverityError    Severity = "error"
+	SeverityCritical Severity = "critical"
+)
+
+type Category string
+
+const (
+	CategoryParser   Category = "parser"
+	CategoryRefund   Category = "refund"
+	CategoryCallback Category = "callback"
+	CategoryOrder    Category = "order"
+	CategoryStripe   Category = "stripe"
+	CategoryBalance  Category = "balance"
+	CategoryNode     Category = "node"
+	CategoryConfig   Category = "config"
+)
+
+// Alert the Title is a metric label, keep it a constant, the details go to the Text
+type Alert struct {
+	Severity  Severity `json:"severity"`
+	Category  Category `json:"category"`
+	Title     string   `json:"title"`
+	Text      string   `json:"text"`
+	Timestamp int64    `json:"timestamp"`
+}
+
+type Notifier interface {
+	Name() string
+	Notify(alert Alert) error
+}
+
+const (
+	alertDefaultDedupWindow = 300
+	alertDefaultRateLimit   = 30
+	alertQueueSize          = 256
+)
+
+type alertRoute struct {
+	notifier   Notifier
+	severities map[Severity]struct{}
+	categories map[Category]struct{}
+	// rate limit in a fixed minute window
+	windowStart int64
+	windowCount int
+}
+
+func (a *alertRoute) match(alert Alert) bool {
+	if _, ok := a.severities[alert.Severity]; len(a.severities) > 0 && !ok {
+		return false
+	}
+	if _, ok := a.categories[alert.Category]; len(a.categories) > 0 && !ok {
+		return false
+	}
+	return true
+}
+
+type alertJob struct {
+	notifier Notifier
+	alert    Alert
+}
+
+type alertRouter struct {
+	lock       sync.Mutex
+	routes     []*alertRoute
+	dedup      map[string]alertDedup
+	queue      chan alertJob
+	dedupAfter time.Duration
+	rateLimit  int
+}
+
+type alertDedup struct {
+	sentAt     time.Time
+	suppressed int
+}
+
+var (
+	alertRouterOnce sync.Once
+	router          *alertRouter
+)
+
+func getAlertRouter() *alertRouter {
+	alertRouterOnce.Do(func() {
+		router = newAlertRouter()
+		go router.run()
+	})
+	return router
+}
+
+func newAlertRouter() *alertRouter {
+	r := &alertRouter{
+		dedup: make(map[string]alertDedup),
+		queue: make(chan alertJob, alertQueueSize),
+	}
+	r.initRoutes(config.GetCfg())
+	return r
+}
+
+// initRoutes the caller holds the lock once the router is in use
+func (r *alertRouter) initRoutes(cfg *config.CfgServer) {
+	r.dedupAfter = time.Duration(cfg.Notify.DedupWindow) * time.Second
+	r.rateLimit = cfg.Notify.RateLimit
+	if r.dedupAfter <= 0 {
+		r.dedupAfter = alertDefaultDedupWindow * time.Second
+	}
+	if r.rateLimit <= 0 {
+		r.rateLimit = alertDefaultRateLimit
+	}
+	confList := cfg.Notify.Notifiers
+	if len(confList) == 0 {
+		confList = getDefaultNotifierConfList(cfg)
+	}
+	r.routes = nil
+	for _, v := range confList {
+		notifier, err := NewNotifier(v)
+		if err != nil {
+			log.Error("NewNotifier err:", v.Name, err.Error())
+			continue
+		}
+		route := alertRoute{
+			notifier:   notifier,
+			severities: make(map[Severity]struct{}),
+			categories: make(map[Category]struct{}),
+		}
+		for _, s := range v.Severities {
+			route.severities[Severity(s)] = struct{}{}
+		}
+		for _, c := range v.Categories {
+			route.categories[Category(c)] = struct{}{}
+		}
+		r.routes = append(r.routes, &route)
+	}
+}
+
+// SubscribeConfig rebuilds the notifiers on a config reload, and alerts a rejected one
+func SubscribeConfig() {
+	config.Subscribe("notify", func(oldCfg, newCfg *config.CfgServer) error {
+		if fmt.Sprintf("%+v", oldCfg.Notify) == fmt.Sprintf("%+v", newCfg.Notify) {
+			return nil
+		}
+		r := getAlertRouter()
+		r.lock.Lock()
+		r.initRoutes(newCfg)
+		r.lock.Unlock()
+		log.Info("SubscribeConfig: notifiers rebuilt")
+		return nil
+	})
+	config.OnReloadFailed(func(err error) {
+		SendAlert(SeverityCritical, CategoryConfig, "ConfigReloadRejected", err.Error())
+	})
+}
+
+// getDefaultNotifierConfList the routes of the lark keys before the notifiers config,
+// every warn, error and critical alert reaches lark_error, the balance ones lark_das_info
+func getDefaultNotifierConfList(cfg *config.CfgServer) []config.NotifierConf {
+	var list []config.NotifierConf
+	if key := cfg.Notify.LarkErrorKey; key != "" {
+		list = append(list, config.NotifierConf{
+			Name:       "lark_error",
+			Type:       NotifierTypeLark,
+			Key:        key,
+			Severities: []string{string(SeverityWarn), string(SeverityError), string(SeverityCritical)},
+			Categories: []string{
+				string(CategoryParser), string(CategoryRefund), string(CategoryCallback), string(CategoryOrder),
+				string(CategoryStripe), string(CategoryNode), string(CategoryConfig),
+			},
+		})
+	}
+	if key := cfg.Notify.LarkDasInfoKey; key != "" {
+		list = append(list, config.NotifierConf{
+			Name:       "lark_das_info",
+			Type:       NotifierTypeLark,
+			Key:        key,
+			Severities: []string{string(SeverityInfo), string(SeverityError), string(SeverityCritical)},
+			Categories: []string{string(CategoryBalance)},
+		})
+	}
+	if key := cfg.Notify.StripeKey; key != "" {
+		list = append(list, config.NotifierConf{
+			Name:       "lark_stripe",
+			Type:       NotifierTypeLark,
+			Key:        key,
+			Severities: []string{string(SeverityInfo)},
+			Categories: []string{string(CategoryStripe)},
+		})
+	}
+	return list
+}
+
+// SendAlert never blocks, the alert is counted in the notify metric,
+// then sent to the matching notifiers unless it is a duplicate in the dedup window or over the rate limit
+func SendAlert(severity Severity, category Category, title, text string) {
+	if title == "" || text == "" {
+		return
+	}
+	txtool.Tools.Metrics.ErrNotify().WithLabelValues(string(severity), string(category), title).Inc()
+	getAlertRouter().send(Alert{
+		Severity:  severity,
+		Category:  category,
+		Title:     title,
+		Text:      text,
+		Timestamp: time.Now().UnixMilli(),
+	})
+}
+
+func (r *alertRouter) send(alert Alert) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	var routes []*alertRoute
+	for _, v := range r.routes {
+		if v.match(alert) {
+			routes = append(routes, v)
+		}
+	}
+	if len(routes) == 0 {
+		return
+	}
+
+	now := time.Now()
+	key := fmt.Sprintf("%s|%s|%s|%s", alert.Severity, alert.Category, alert.Title, alert.Text)
+	if d, ok := r.dedup[key]; ok && now.Sub(d.sentAt) < r.dedupAfter {
+		d.suppressed++
+		r.dedup[key] = d
+		txtool.Tools.Metrics.NotifyDropped().WithLabelValues("", "dedup").Inc()
+		return
+	} else if ok && d.suppressed > 0 {
+		alert.Text = fmt.Sprintf("%s\n(%d duplicates suppressed)", alert.Text, d.suppressed)
+	}
+	r.dedup[key] = alertDedup{sentAt: now}
+	if len(r.dedup) > 1000 {
+		for k, v := range r.dedup {
+			if now.Sub(v.sentAt) >= r.dedupAfter {
+				delete(r.dedup, k)
+			}
+		}
+	}
+
+	minute := now.Unix() / 60
+	for _, v := range routes {
+		if v.windowStart != minute {
+			v.windowStart, v.windowCount = minute, 0
+		}
+		if v.windowCount >= r.rateLimit {
+			txtool.Tools.Metrics.NotifyDropped().WithLabelValues(v.notifier.Name(), "rate_limit").Inc()
+			continue
+		}
+		v.windowCount++
+		select {
+		case r.queue <- alertJob{notifier: v.notifier, alert: alert}:
+		default:
+			log.Warn("SendAlert queue full:", v.notifier.Name(), alert.Title)
+			txtool.Tools.Metrics.NotifyDropped().WithLabelValues(v.notifier.Name(), "queue_full").Inc()
+		}
+	}
+}
+
+func (r *alertRouter) run() {
+	for job := range r.queue {
+		if err := job.notifier.Notify(job.alert); err != nil {
+			log.Error("Notify err:", job.notifier.Name(), job.alert.Title, err.Error())
+			txtool.Tools.Metrics.NotifyDropped().WithLabelValues(job.notifier.Name(), "send_err").Inc()
+		}
+	}
+}
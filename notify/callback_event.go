@@ -0,0 +1,167 @@
+package notify
+
+import (
+	"fmt"
+	"github.com/dotbitHQ/das-lib/common"
+	"github.com/shopspring/decimal"
+	"unipay/tables"
+)
+
+// reqCallbackNoticeV2 the body for the businesses with callback_version 2
+type reqCallbackNoticeV2 struct {
+	BusinessId string                 `json:"business_id"`
+	Version    tables.CallbackVersion `json:"version"`
+	EventList  []EventInfoV2          `json:"event_list"`
+}
+
+// EventInfoV2 keeps the v1 fields, so a business can switch before reading the new ones
+type EventInfoV2 struct {
+	EventInfo
+	EventId    string        `json:"event_id"`    // the same on every retry and replay
+	OccurredAt int64         `json:"occurred_at"` // ms
+	Order      EventOrder    `json:"order"`
+	Payment    *EventPayment `json:"payment"` // null if the event has no payment
+	Refund     EventRefund   `json:"refund"`
+}
+
+type EventOrder struct {
+	OrderId        string                `json:"order_id"`
+	PayAddress     string                `json:"pay_address"`
+	AlgorithmId    common.DasAlgorithmId `json:"algorithm_id"`
+	Amount         decimal.Decimal       `json:"amount"`
+	PayTokenId     tables.PayTokenId     `json:"pay_token_id"`
+	Symbol         string                `json:"symbol"`
+	Decimals       int32                 `json:"decimals"`
+	PayStatus      tables.PayStatus      `json:"pay_status"`
+	OrderStatus    tables.OrderStatus    `json:"order_status"`
+	PaymentAddress string                `json:"payment_address"`
+	PremiumAmount  decimal.Decimal       `json:"premium_amount"`
+	Timestamp      int64                 `json:"timestamp"`
+	ExpiredAt      int64                 `json:"expired_at"`
+	MetaData       map[string]string     `json:"meta_data"`
+}
+
+type EventPayment struct {
+	PayHash       string                `json:"pay_hash"`
+	PayAddress    string                `json:"pay_address"`
+	AlgorithmId   common.DasAlgorithmId `json:"algorithm_id"`
+	Amount        decimal.Decimal       `json:"amount"`
+	PayTokenId    tables.PayTokenId     `json:"pay_token_id"`
+	Symbol        string                `json:"symbol"`
+	Decimals      int32                 `json:"decimals"`
+	Chain         string                `json:"chain"`
+	PayHashStatus tables.PayHashStatus  `json:"pay_hash_status"`
+	BlockNumber   uint64                `json:"block_number"`  // 0 for stripe
+	BlockHash     string                `json:"block_hash"`    // empty if unknown
+	Confirmations uint64                `json:"confirmations"` // when the payment was accepted
+	Timestamp     int64                 `json:"timestamp"`
+}
+
+// EventRefund the refund of the payment, and the totals of all the payments of the order
+type EventRefund struct {
+	RefundStatus    tables.RefundStatus `json:"refund_status"`
+	RefundHash      string              `json:"refund_hash"`
+	RefundAmount    decimal.Decimal     `json:"refund_amount"`
+	PaidAmount      decimal.Decimal     `json:"paid_amount"`
+	RefundingAmount decimal.Decimal     `json:"refunding_amount"` // UnRefunded, Refunding and PendingApproval
+	RefundedAmount  decimal.Decimal     `json:"refunded_amount"`
+}
+
+// getCallbackReq the body by the callback_version of the business
+func (c *CallbackNotice) getCallbackReq(businessInfo tables.TableBusinessInfo, list []EventInfo) (interface{}, error) {
+	if businessInfo.GetCallbackVersion() < tables.CallbackVersion2 {
+		return reqCallbackNotice{
+			BusinessId: businessInfo.BusinessId,
+			EventList:  list,
+		}, nil
+	}
+	req := reqCallbackNoticeV2{
+		BusinessId: businessInfo.BusinessId,
+		Version:    tables.CallbackVersion2,
+	}
+	for _, v := range list {
+		event, err := c.getEventInfoV2(v)
+		if err != nil {
+			return nil, fmt.Errorf("getEventInfoV2 err: %s", err.Error())
+		}
+		req.EventList = append(req.EventList, event)
+	}
+	return req, nil
+}
+
+func (c *CallbackNotice) getEventInfoV2(eventInfo EventInfo) (EventInfoV2, error) {
+	orderInfo, paymentInfo := eventInfo.order, eventInfo.payment
+	event := EventInfoV2{
+		EventInfo:  eventInfo,
+		EventId:    eventInfo.notice.NoticeId,
+		OccurredAt: eventInfo.notice.Timestamp,
+		Order: EventOrder{
+			OrderId:        orderInfo.OrderId,
+			PayAddress:     orderInfo.PayAddress,
+			AlgorithmId:    orderInfo.AlgorithmId,
+			Amount:         orderInfo.Amount,
+			PayTokenId:     orderInfo.PayTokenId,
+			Symbol:         orderInfo.PayTokenId.GetSymbol(),
+			Decimals:       orderInfo.PayTokenId.GetDecimals(),
+			PayStatus:      orderInfo.PayStatus,
+			OrderStatus:    orderInfo.OrderStatus,
+			PaymentAddress: orderInfo.PaymentAddress,
+			PremiumAmount:  orderInfo.PremiumAmount,
+			Timestamp:      orderInfo.Timestamp,
+			ExpiredAt:      orderInfo.GetExpiredAt(),
+			MetaData:       orderInfo.GetMetaData(),
+		},
+		Refund: EventRefund{
+			RefundStatus: paymentInfo.RefundStatus,
+			RefundHash:   paymentInfo.RefundHash,
+		},
+	}
+	if paymentInfo.PayHash != "" {
+		event.Payment = &EventPayment{
+			PayHash:       paymentInfo.PayHash,
+			PayAddress:    paymentInfo.PayAddress,
+			AlgorithmId:   paymentInfo.AlgorithmId,
+			Amount:        paymentInfo.Amount,
+			PayTokenId:    paymentInfo.PayTokenId,
+			Symbol:        paymentInfo.PayTokenId.GetSymbol(),
+			Decimals:      paymentInfo.PayTokenId.GetDecimals(),
+			Chain:         paymentInfo.PayTokenId.GetChain(),
+			PayHashStatus: paymentInfo.PayHashStatus,
+			BlockNumber:   paymentInfo.BlockNumber,
+			BlockHash:     paymentInfo.BlockHash,
+			Confirmations: paymentInfo.Confirmations,
+			Timestamp:     paymentInfo.Timestamp,
+		}
+		if paymentInfo.RefundStatus != tables.RefundStatusDefault && paymentInfo.RefundStatus != tables.RefundStatusRefuseToRefund {
+			event.Refund.RefundAmount = paymentInfo.Amount
+		}
+	}
+
+	// the payment of the event may not be saved yet
+	list, err := c.DbDao.GetPaymentListByOrderIds([]string{orderInfo.OrderId})
+	if err != nil {
+		return event, fmt.Errorf("GetPaymentListByOrderIds err: %s", err.Error())
+	}
+	found := false
+	for i, v := range list {
+		if v.PayHash == paymentInfo.PayHash {
+			list[i], found = paymentInfo, true
+		}
+	}
+	if !found && paymentInfo.PayHash != "" {
+		list = append(list, paymentInfo)
+	}
+	for _, v := range list {
+		if v.PayHashStatus != tables.PayHashStatusConfirm {
+			continue
+		}
+		event.Refund.PaidAmount = event.Refund.PaidAmount.Add(v.Amount)
+		switch v.RefundStatus {
+		case tables.RefundStatusUnRefund, tables.RefundStatusRefunding, tables.RefundStatusPendingApproval:
+			event.Refund.RefundingAmount = event.Refund.RefundingAmount.Add(v.Amount)
+		case tables.RefundStatusRefunded:
+			event.Refund.RefundedAmount = event.Refund.RefundedAmount.Add(v.Amount)
+		}
+	}
+	return event, nil
+}
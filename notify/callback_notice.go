@@ -1,13 +1,19 @@
 package notify
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/dotbitHQ/das-lib/common"
 	"github.com/parnurzeal/gorequest"
+	"strings"
 	"time"
+	"unipay/business"
 	"unipay/config"
 	"unipay/dao"
+	"unipay/eventbus"
 	"unipay/tables"
+	"unipay/txtool"
+	"unipay/webhookverify"
 )
 
 type CallbackNotice struct {
@@ -16,6 +22,7 @@ type CallbackNotice struct {
 
 func (c *CallbackNotice) HandlePaymentToFailByDispute(paymentInfo tables.TablePaymentInfo, orderInfo tables.TableOrderInfo) error {
 	noticeInfo := tables.TableNoticeInfo{
+		BusinessId:   orderInfo.BusinessId,
 		EventType:    tables.EventTypePaymentDispute,
 		PayHash:      paymentInfo.PayHash,
 		NoticeCount:  0,
@@ -27,9 +34,10 @@ func (c *CallbackNotice) HandlePaymentToFailByDispute(paymentInfo tables.TablePa
 	paymentInfo.PayHashStatus = tables.PayHashStatusFailByDispute
 	orderInfo.OrderStatus = tables.OrderStatusFail
 
-	if err := c.callbackNotice(noticeInfo, paymentInfo, orderInfo); err != nil {
+	if httpStatus, err := c.callbackNotice(noticeInfo, paymentInfo, orderInfo); err != nil {
 		log.Error("callbackNotice err: ", err.Error(), noticeInfo.NoticeId)
-		SendLarkErrNotify("callbackNotice", err.Error()+noticeInfo.NoticeId)
+		SendAlert(SeverityError, CategoryCallback, "callbackNotice", err.Error()+noticeInfo.NoticeId)
+		setNoticeFailed(&noticeInfo, httpStatus, err)
 	} else {
 		noticeInfo.NoticeStatus = tables.NoticeStatusOK
 	}
@@ -37,12 +45,18 @@ func (c *CallbackNotice) HandlePaymentToFailByDispute(paymentInfo tables.TablePa
 	if err := c.DbDao.UpdatePayHashStatusToFailByDispute(paymentInfo, noticeInfo); err != nil {
 		return fmt.Errorf("UpdatePayHashStatusToFailByDispute err: %s[%s]", err.Error(), paymentInfo.PayHash)
 	}
+	eventbus.Publish(eventbus.OrderEvent{
+		OrderId: orderInfo.OrderId,
+		Status:  eventbus.OrderStatusDisputed,
+		PayHash: paymentInfo.PayHash,
+	})
 	return nil
 }
 
 func (c *CallbackNotice) HandlePayment(paymentInfo tables.TablePaymentInfo, orderInfo tables.TableOrderInfo) error {
 	paymentInfo.PayHashStatus = tables.PayHashStatusConfirm
 	noticeInfo := tables.TableNoticeInfo{
+		BusinessId:   orderInfo.BusinessId,
 		EventType:    tables.EventTypeOrderPay,
 		PayHash:      paymentInfo.PayHash,
 		NoticeCount:  0,
@@ -52,45 +66,164 @@ func (c *CallbackNotice) HandlePayment(paymentInfo tables.TablePaymentInfo, orde
 	noticeInfo.InitNoticeId()
 
 	orderInfo.PayStatus = tables.PayStatusPaid
-	if err := c.callbackNotice(noticeInfo, paymentInfo, orderInfo); err != nil {
+	setNoticeFirstAttempt(&noticeInfo)
+	if ok, err := c.DbDao.UpdatePaymentStatus(paymentInfo, noticeInfo); err != nil {
+		return fmt.Errorf("UpdatePaymentStatus err: %s", err.Error())
+	} else if !ok {
+		// cancelled after the parser loaded the order
+		return c.HandleLatePayment(paymentInfo, orderInfo)
+	}
+	amount, _ := paymentInfo.Amount.Shift(-paymentInfo.PayTokenId.GetDecimals()).Float64()
+	txtool.Tools.Metrics.OrderPaid().WithLabelValues(string(orderInfo.PayTokenId)).Inc()
+	txtool.Tools.Metrics.PaymentAmount().WithLabelValues(string(paymentInfo.PayTokenId)).Add(amount)
+	eventbus.Publish(eventbus.OrderEvent{
+		OrderId:       orderInfo.OrderId,
+		Status:        eventbus.OrderStatusPaid,
+		PayHash:       paymentInfo.PayHash,
+		Confirmations: paymentInfo.Confirmations,
+		ConfirmNum:    paymentInfo.Confirmations,
+	})
+
+	// after the commit, the order results in the response need the order paid
+	if httpStatus, err := c.callbackNotice(noticeInfo, paymentInfo, orderInfo); err != nil {
 		log.Error("callbackNotice err: ", err.Error(), noticeInfo.NoticeId)
-		SendLarkErrNotify("callbackNotice", err.Error()+noticeInfo.NoticeId)
-	} else {
-		noticeInfo.NoticeStatus = tables.NoticeStatusOK
+		SendAlert(SeverityError, CategoryCallback, "callbackNotice", err.Error()+noticeInfo.NoticeId)
+		c.HandleNoticeFailed(noticeInfo, httpStatus, err)
+	} else if err := c.DbDao.UpdateNoticeStatusToOKByNoticeId(noticeInfo.NoticeId); err != nil {
+		log.Error("UpdateNoticeStatusToOKByNoticeId err: ", err.Error(), noticeInfo.NoticeId)
 	}
+	return nil
+}
 
-	if err := c.DbDao.UpdatePaymentStatus(paymentInfo, noticeInfo); err != nil {
-		return fmt.Errorf("UpdatePaymentStatus err: %s", err.Error())
+// HandleLatePayment a payment of a cancelled or expired order is not credited but refunded
+func (c *CallbackNotice) HandleLatePayment(paymentInfo tables.TablePaymentInfo, orderInfo tables.TableOrderInfo) error {
+	log.Warn("HandleLatePayment:", orderInfo.BusinessId, orderInfo.OrderId, orderInfo.OrderStatus, paymentInfo.PayHash)
+	if err := c.DbDao.CreateLatePayment(paymentInfo); err != nil {
+		return fmt.Errorf("CreateLatePayment err: %s", err.Error())
 	}
+	eventbus.Publish(eventbus.OrderEvent{
+		OrderId:       orderInfo.OrderId,
+		Status:        eventbus.OrderStatusRefunding,
+		PayHash:       paymentInfo.PayHash,
+		Confirmations: paymentInfo.Confirmations,
+		ConfirmNum:    paymentInfo.Confirmations,
+	})
+	SendAlert(SeverityError, CategoryOrder, "LatePayment", fmt.Sprintf("%s\n%s\n%s\n%s", orderInfo.BusinessId, orderInfo.OrderId, paymentInfo.PayHash, orderInfo.MetaData))
 	return nil
 }
 
-func (c *CallbackNotice) callbackNotice(notice tables.TableNoticeInfo, paymentInfo tables.TablePaymentInfo, orderInfo tables.TableOrderInfo) error {
+// HandleOrderCancel cancels the order and notices the business,
+// paymentInfo is empty for crypto orders without payment
+func (c *CallbackNotice) HandleOrderCancel(orderInfo tables.TableOrderInfo, paymentInfo tables.TablePaymentInfo) (bool, error) {
+	noticeInfo := tables.TableNoticeInfo{
+		BusinessId:   orderInfo.BusinessId,
+		EventType:    tables.EventTypeOrderCancelled,
+		PayHash:      paymentInfo.PayHash,
+		OrderId:      orderInfo.OrderId,
+		NoticeCount:  0,
+		NoticeStatus: tables.NoticeStatusDefault,
+		Timestamp:    time.Now().UnixMilli(),
+	}
+	noticeInfo.InitNoticeId()
+	setNoticeFirstAttempt(&noticeInfo)
+
+	ok, err := c.DbDao.UpdateOrderToCancel(orderInfo.OrderId, noticeInfo)
+	if err != nil {
+		return false, fmt.Errorf("UpdateOrderToCancel err: %s[%s]", err.Error(), orderInfo.OrderId)
+	} else if !ok {
+		return false, nil
+	}
+	eventbus.Publish(eventbus.OrderEvent{
+		OrderId: orderInfo.OrderId,
+		Status:  eventbus.OrderStatusCancelled,
+		PayHash: paymentInfo.PayHash,
+	})
+
+	orderInfo.OrderStatus = tables.OrderStatusCancel
+	if paymentInfo.PayHash != "" {
+		paymentInfo.PayHashStatus = tables.PayHashStatusFail
+	}
+	if httpStatus, err := c.callbackNotice(noticeInfo, paymentInfo, orderInfo); err != nil {
+		// RepeatCallbackNotice will retry
+		log.Error("callbackNotice err: ", err.Error(), noticeInfo.NoticeId)
+		c.HandleNoticeFailed(noticeInfo, httpStatus, err)
+		return true, nil
+	}
+	if err := c.DbDao.UpdateNoticeStatusToOKByNoticeId(noticeInfo.NoticeId); err != nil {
+		log.Error("UpdateNoticeStatusToOKByNoticeId err: ", err.Error(), noticeInfo.NoticeId)
+	}
+	return true, nil
+}
+
+// HandleOrderResult sets a paid order to success or fail as reported by the business,
+// returns false if the order is not a paid normal order
+func (c *CallbackNotice) HandleOrderResult(orderInfo tables.TableOrderInfo, orderStatus tables.OrderStatus, reason string) (bool, error) {
+	if orderStatus != tables.OrderStatusSuccess && orderStatus != tables.OrderStatusFail {
+		return false, fmt.Errorf("invalid order status[%d]", orderStatus)
+	}
+	refund := orderStatus == tables.OrderStatusFail && config.GetCfg().Server.OrderFailAutoRefund
+	ok, err := c.DbDao.UpdateOrderResult(orderInfo.OrderId, orderStatus, refund)
+	if err != nil {
+		return false, fmt.Errorf("UpdateOrderResult err: %s[%s]", err.Error(), orderInfo.OrderId)
+	}
+	if ok && orderStatus == tables.OrderStatusFail {
+		log.Warn("HandleOrderResult order fail:", orderInfo.BusinessId, orderInfo.OrderId, reason, refund)
+		if refund {
+			eventbus.Publish(eventbus.OrderEvent{OrderId: orderInfo.OrderId, Status: eventbus.OrderStatusRefunding})
+		}
+	}
+	return ok, nil
+}
+
+// handleCallbackResp applies the order results returned in the callback response
+func (c *CallbackNotice) handleCallbackResp(businessId string, resp *respCallbackNotice) {
+	for _, v := range resp.OrderList {
+		orderInfo, err := c.DbDao.GetOrderInfo(v.OrderId, businessId)
+		if err != nil {
+			log.Error("GetOrderInfo err: ", err.Error(), v.OrderId)
+			continue
+		} else if orderInfo.Id == 0 || orderInfo.OrderStatus == v.OrderStatus {
+			continue
+		}
+		if ok, err := c.HandleOrderResult(orderInfo, v.OrderStatus, v.Reason); err != nil {
+			log.Error("HandleOrderResult err: ", err.Error(), v.OrderId)
+		} else if !ok {
+			log.Warn("handleCallbackResp order not paid or not normal:", businessId, v.OrderId, orderInfo.PayStatus, orderInfo.OrderStatus, v.OrderStatus)
+		}
+	}
+}
+
+// callbackNotice returns the http status of the failed request, 0 if there is no response
+func (c *CallbackNotice) callbackNotice(notice tables.TableNoticeInfo, paymentInfo tables.TablePaymentInfo, orderInfo tables.TableOrderInfo) (int, error) {
 	// get callback url
-	callbackUrl, ok := config.Cfg.BusinessIds[orderInfo.BusinessId]
+	businessInfo, ok := business.GetBusiness(orderInfo.BusinessId)
 	if !ok {
-		return fmt.Errorf("not exist business id[%s]", orderInfo.BusinessId)
+		return 0, fmt.Errorf("not exist business id[%s]", orderInfo.BusinessId)
 	}
 
 	// send notice
-	req := reqCallbackNotice{
-		BusinessId: orderInfo.BusinessId,
-		EventList: []EventInfo{{
-			EventType:    notice.EventType,
-			OrderId:      orderInfo.OrderId,
-			PayStatus:    orderInfo.PayStatus,
-			PayHash:      paymentInfo.PayHash,
-			PayAddress:   paymentInfo.PayAddress,
-			AlgorithmId:  paymentInfo.AlgorithmId,
-			RefundStatus: paymentInfo.RefundStatus,
-			RefundHash:   paymentInfo.RefundHash,
-		}},
+	req, err := c.getCallbackReq(businessInfo, []EventInfo{{
+		EventType:    notice.EventType,
+		OrderId:      orderInfo.OrderId,
+		PayStatus:    orderInfo.PayStatus,
+		PayHash:      paymentInfo.PayHash,
+		PayAddress:   paymentInfo.PayAddress,
+		AlgorithmId:  paymentInfo.AlgorithmId,
+		RefundStatus: paymentInfo.RefundStatus,
+		RefundHash:   paymentInfo.RefundHash,
+		notice:       notice,
+		order:        orderInfo,
+		payment:      paymentInfo,
+	}})
+	if err != nil {
+		return 0, fmt.Errorf("getCallbackReq err: %s", err.Error())
 	}
 	resp := &respCallbackNotice{}
-	if err := doNoticeReq(callbackUrl, req, resp); err != nil {
-		return fmt.Errorf("doNoticeReq err: %s", err.Error())
+	if httpStatus, err := doBusinessNoticeReq(businessInfo, req, resp); err != nil {
+		return httpStatus, fmt.Errorf("doBusinessNoticeReq err: %s", err.Error())
 	}
-	return nil
+	c.handleCallbackResp(orderInfo.BusinessId, resp)
+	return 0, nil
 }
 
 func (c *CallbackNotice) RepeatCallbackNotice(eventMap map[string][]EventInfo) error {
@@ -100,24 +233,28 @@ func (c *CallbackNotice) RepeatCallbackNotice(eventMap map[string][]EventInfo) e
 
 	// callback
 	for k, list := range eventMap {
-		req := reqCallbackNotice{
-			BusinessId: k,
-			EventList:  list,
-		}
-		callbackUrl, ok := config.Cfg.BusinessIds[k]
+		businessInfo, ok := business.GetBusiness(k)
 		if !ok {
 			log.Error("BusinessId not exist:", k)
+			for _, v := range list {
+				c.HandleNoticeFailed(v.notice, 0, fmt.Errorf("not exist business id[%s]", k))
+			}
+			continue
+		}
+		req, err := c.getCallbackReq(businessInfo, list)
+		if err != nil {
+			log.Error("getCallbackReq err:", err.Error(), k)
+			for _, v := range list {
+				c.HandleNoticeFailed(v.notice, 0, err)
+			}
 			continue
 		}
 		resp := &respCallbackNotice{}
-		if err := doNoticeReq(callbackUrl, req, resp); err != nil {
-			log.Error("doNoticeReq err:", err.Error())
-			SendLarkErrNotify("doNoticeReq", err.Error())
+		if httpStatus, err := doBusinessNoticeReq(businessInfo, req, resp); err != nil {
+			log.Error("doBusinessNoticeReq err:", err.Error())
+			SendAlert(SeverityError, CategoryCallback, "doBusinessNoticeReq", err.Error())
 			for _, v := range list {
-				noticeCount := v.NoticeCount + 1
-				if err := c.DbDao.UpdateNoticeCount(v.NoticeId, noticeCount); err != nil {
-					log.Error("UpdateNoticeCount err: ", err.Error(), v.NoticeId)
-				}
+				c.HandleNoticeFailed(v.notice, httpStatus, err)
 			}
 			continue
 		}
@@ -129,58 +266,84 @@ func (c *CallbackNotice) RepeatCallbackNotice(eventMap map[string][]EventInfo) e
 		if err := c.DbDao.UpdateNoticeStatusToOK(ids); err != nil {
 			log.Error("UpdateNoticeStatusToOK err:", err.Error(), ids)
 		}
+		c.handleCallbackResp(k, resp)
 	}
 
 	return nil
 }
 
-func (c *CallbackNotice) GetEventInfo(notice tables.TableNoticeInfo) (businessId string, eventInfo EventInfo, e error) {
-	// check timestamp
-	timestamp := notice.Timestamp
-	switch notice.NoticeCount {
-	case 0: // 30s
-		timestamp += 30 * 1e3
-	case 1: // 1min
-		timestamp += 60 * 1e3
-	case 2: // 5 min
-		timestamp += 300 * 1e3
-	case 3: // 1 h
-		timestamp += 3600 * 1e3
-	case 4: // 12 h
-		timestamp += 43200 * 1e3
-	default:
-		SendLarkErrNotify("UpdateNoticeStatusToFail", notice.PayHash)
-		if err := c.DbDao.UpdateNoticeStatusToFail(notice.Id); err != nil {
-			e = fmt.Errorf("UpdateNoticeStatusToFail err: %s", err.Error())
-			return
-		}
-		return
+// HandleNoticeFailed schedules the next attempt by the callback_retry of the business,
+// the notice goes to the dead letter after the last one
+func (c *CallbackNotice) HandleNoticeFailed(notice tables.TableNoticeInfo, httpStatus int, err error) {
+	setNoticeFailed(&notice, httpStatus, err)
+	if notice.NoticeStatus == tables.NoticeStatusFail {
+		log.Warn("HandleNoticeFailed dead letter:", notice.BusinessId, notice.NoticeId, notice.NoticeCount)
+		SendAlert(SeverityError, CategoryCallback, "CallbackDeadLetter", fmt.Sprintf("%s\n%s\n%s %s\n%s", notice.BusinessId, notice.NoticeId, notice.EventType, notice.PayHash, notice.LastError))
+	}
+	if err := c.DbDao.UpdateNoticeAttempt(notice); err != nil {
+		log.Error("UpdateNoticeAttempt err: ", err.Error(), notice.NoticeId)
 	}
-	nowT := time.Now().UnixMilli()
-	if nowT < timestamp {
-		log.Info("callbackNotice NoticeCount:", notice.NoticeCount, nowT, timestamp-nowT)
+}
+
+// DefaultCallbackRetry seconds between the attempts when the business has no callback_retry
+var DefaultCallbackRetry = []int64{30, 60, 300, 3600, 43200}
+
+func getNoticeSchedule(businessId string) []int64 {
+	if businessInfo, ok := business.GetBusiness(businessId); ok && len(businessInfo.GetCallbackRetry()) > 0 {
+		return businessInfo.GetCallbackRetry()
+	}
+	return DefaultCallbackRetry
+}
+
+func setNoticeFailed(notice *tables.TableNoticeInfo, httpStatus int, err error) {
+	schedule := getNoticeSchedule(notice.BusinessId)
+	notice.NoticeCount++
+	notice.LastHttpStatus = httpStatus
+	notice.LastError = err.Error()
+	if len(notice.LastError) > 1024 {
+		notice.LastError = notice.LastError[:1024]
+	}
+	if notice.NoticeCount > len(schedule) {
+		notice.NoticeStatus = tables.NoticeStatusFail
+		notice.NextAttemptAt = 0
 		return
 	}
+	notice.NextAttemptAt = time.Now().UnixMilli() + schedule[notice.NoticeCount-1]*1e3
+}
+
+// setNoticeFirstAttempt for a notice sent right after its insert, the timer only picks it up as a retry
+func setNoticeFirstAttempt(notice *tables.TableNoticeInfo) {
+	notice.NextAttemptAt = time.Now().UnixMilli() + getNoticeSchedule(notice.BusinessId)[0]*1e3
+}
 
+// GetEventInfo the notice is due, see DbDao.GetDueNoticeList
+func (c *CallbackNotice) GetEventInfo(notice tables.TableNoticeInfo) (businessId string, eventInfo EventInfo, e error) {
 	// get payment info
-	paymentInfo, err := c.DbDao.GetPaymentInfoByPayHash(notice.PayHash)
-	if err != nil {
-		e = fmt.Errorf("GetPaymentInfoByPayHash err: %s", err.Error())
-		return
-	} else if paymentInfo.Id == 0 {
-		e = fmt.Errorf("payment not exist[%s]", notice.PayHash)
-		return
+	var paymentInfo tables.TablePaymentInfo
+	var err error
+	orderId := notice.OrderId
+	if notice.PayHash != "" {
+		paymentInfo, err = c.DbDao.GetPaymentInfoByPayHash(notice.PayHash)
+		if err != nil {
+			e = fmt.Errorf("GetPaymentInfoByPayHash err: %s", err.Error())
+			return
+		} else if paymentInfo.Id == 0 {
+			e = fmt.Errorf("payment not exist[%s]", notice.PayHash)
+			return
+		}
+		orderId = paymentInfo.OrderId
 	}
 
 	// get order info
-	orderInfo, err := c.DbDao.GetOrderInfoByOrderId(paymentInfo.OrderId)
+	orderInfo, err := c.DbDao.GetOrderInfoByOrderId(orderId)
 	if err != nil {
 		e = fmt.Errorf("GetOrderInfoByOrderId err: %s", err.Error())
 		return
 	} else if orderInfo.Id == 0 {
-		e = fmt.Errorf("order not exist[%s]", paymentInfo.OrderId)
+		e = fmt.Errorf("order not exist[%s]", orderId)
 		return
 	}
+	notice.BusinessId = orderInfo.BusinessId // empty in the rows before the queue
 
 	eventInfo = EventInfo{
 		EventType:    notice.EventType,
@@ -193,6 +356,9 @@ func (c *CallbackNotice) GetEventInfo(notice tables.TableNoticeInfo) (businessId
 		RefundHash:   paymentInfo.RefundHash,
 		NoticeId:     notice.Id,
 		NoticeCount:  notice.NoticeCount,
+		notice:       notice,
+		order:        orderInfo,
+		payment:      paymentInfo,
 	}
 	businessId = orderInfo.BusinessId
 	return
@@ -213,8 +379,20 @@ type EventInfo struct {
 	RefundHash   string                `json:"refund_hash"`
 	NoticeId     uint64                `json:"notice_id"`
 	NoticeCount  int                   `json:"notice_count"`
+
+	notice  tables.TableNoticeInfo
+	order   tables.TableOrderInfo
+	payment tables.TablePaymentInfo
 }
 type respCallbackNotice struct {
+	OrderList []CallbackOrderResult `json:"order_list"` // optional
+}
+
+// CallbackOrderResult the business may return the fulfilment result of the orders in the callback response
+type CallbackOrderResult struct {
+	OrderId     string             `json:"order_id"`
+	OrderStatus tables.OrderStatus `json:"order_status"` // 1-Success 2-Fail
+	Reason      string             `json:"reason"`
 }
 
 type apiResp struct {
@@ -223,19 +401,81 @@ type apiResp struct {
 	Data   interface{} `json:"data"`
 }
 
-func doNoticeReq(url string, req, data interface{}) error {
+// doBusinessNoticeReq tries the backup callback url when the main one fails,
+// returns the http status of the last failed request
+func doBusinessNoticeReq(businessInfo tables.TableBusinessInfo, req, data interface{}) (int, error) {
+	urls := businessInfo.GetCallbackUrls()
+	if len(urls) == 0 {
+		return 0, fmt.Errorf("no callback url of business id[%s]", businessInfo.BusinessId)
+	}
+	var errs []string
+	var httpStatus int
+	for _, url := range urls {
+		begin := time.Now()
+		status, err := doNoticeReq(businessInfo, url, req, data)
+		if err == nil {
+			txtool.Tools.Metrics.CallbackDuration().WithLabelValues(businessInfo.BusinessId, "ok").Observe(time.Since(begin).Seconds())
+			return 0, nil
+		}
+		txtool.Tools.Metrics.CallbackDuration().WithLabelValues(businessInfo.BusinessId, "fail").Observe(time.Since(begin).Seconds())
+		httpStatus = status
+		errs = append(errs, err.Error())
+	}
+	txtool.Tools.Metrics.CallbackFailed().WithLabelValues(businessInfo.BusinessId).Inc()
+	return httpStatus, fmt.Errorf("%s", strings.Join(errs, "; "))
+}
+
+// doNoticeReq a single attempt, the retries are scheduled by the notice queue
+func doNoticeReq(businessInfo tables.TableBusinessInfo, url string, req, data interface{}) (int, error) {
 	var resp apiResp
 	resp.Data = &data
 
-	_, _, errs := gorequest.New().Post(url).
-		Timeout(time.Second*10).
-		Retry(3, time.Second).
-		SendStruct(&req).EndStruct(&resp)
+	body, err := json.Marshal(req)
+	if err != nil {
+		return 0, fmt.Errorf("json.Marshal err: %s", err.Error())
+	}
+	request := gorequest.New().Post(url).
+		Timeout(time.Second * 10).
+		Type(gorequest.TypeJSON)
+	if err := signNoticeReq(request, businessInfo, body); err != nil {
+		return 0, fmt.Errorf("signNoticeReq err: %s", err.Error())
+	}
+	request.BounceToRawString = true // send the signed bytes as they are
+	res, _, errs := request.Send(string(body)).EndStruct(&resp)
+	httpStatus := 0
+	if res != nil {
+		httpStatus = res.StatusCode
+	}
+	if httpStatus != 0 && (httpStatus < 200 || httpStatus >= 300) {
+		return httpStatus, fmt.Errorf("http status %d", httpStatus)
+	}
 	if len(errs) > 0 {
-		return fmt.Errorf("%v", errs)
+		return httpStatus, fmt.Errorf("%v", errs)
 	}
 	if resp.ErrNo != 0 {
-		return fmt.Errorf("%d - %s", resp.ErrNo, resp.ErrMsg)
+		return httpStatus, fmt.Errorf("%d - %s", resp.ErrNo, resp.ErrMsg)
+	}
+	return 0, nil
+}
+
+// signNoticeReq the signature covers the exact body bytes
+func signNoticeReq(request *gorequest.SuperAgent, businessInfo tables.TableBusinessInfo, body []byte) error {
+	// the hmac secrets of the config are only the bootstrap of t_business_info
+	_, privateKey, keyId, err := config.GetCallbackSignKey(businessInfo.BusinessId)
+	if err != nil {
+		return err
+	}
+	secrets := business.GetCallbackSecrets(businessInfo.BusinessId)
+	timestamp := time.Now().Unix()
+	if len(secrets) > 0 {
+		request.Set(webhookverify.HeaderSignature, webhookverify.Sign(secrets, timestamp, body))
+	}
+	if privateKey != nil {
+		jws, err := webhookverify.SignJws(privateKey, keyId, timestamp, body)
+		if err != nil {
+			return fmt.Errorf("SignJws err: %s", err.Error())
+		}
+		request.Set(webhookverify.HeaderJws, jws)
 	}
 	return nil
 }
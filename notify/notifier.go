@@ -0,0 +1,176 @@
+package notify
+
+import (
+	"fmt"
+	"github.com/parnurzeal/gorequest"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+	"unipay/config"
+)
+
+const (
+	NotifierTypeLark     = "lark"
+	NotifierTypeSlack    = "slack"
+	NotifierTypeTelegram = "telegram"
+	NotifierTypeSmtp     = "smtp"
+	NotifierTypeWebhook  = "webhook"
+
+	TelegramNotifyUrl = "https://api.telegram.org/bot%s/sendMessage"
+)
+
+func NewNotifier(conf config.NotifierConf) (Notifier, error) {
+	name := conf.Name
+	if name == "" {
+		name = conf.Type
+	}
+	switch conf.Type {
+	case NotifierTypeLark:
+		if conf.Key == "" {
+			return nil, fmt.Errorf("key is empty")
+		}
+		return &LarkNotifier{name: name, Key: conf.Key}, nil
+	case NotifierTypeSlack:
+		if conf.Url == "" {
+			return nil, fmt.Errorf("url is empty")
+		}
+		return &SlackNotifier{name: name, Url: conf.Url}, nil
+	case NotifierTypeTelegram:
+		if conf.BotToken == "" || conf.ChatId == "" {
+			return nil, fmt.Errorf("bot_token or chat_id is empty")
+		}
+		return &TelegramNotifier{name: name, BotToken: conf.BotToken, ChatId: conf.ChatId}, nil
+	case NotifierTypeSmtp:
+		if conf.SmtpAddr == "" || conf.From == "" || len(conf.To) == 0 {
+			return nil, fmt.Errorf("smtp_addr, from or to is empty")
+		}
+		return &SmtpNotifier{name: name, Addr: conf.SmtpAddr, User: conf.User, Password: conf.Password, From: conf.From, To: conf.To}, nil
+	case NotifierTypeWebhook:
+		if conf.Url == "" {
+			return nil, fmt.Errorf("url is empty")
+		}
+		return &WebhookNotifier{name: name, Url: conf.Url}, nil
+	}
+	return nil, fmt.Errorf("unknown notifier type[%s]", conf.Type)
+}
+
+func getAlertTitle(alert Alert) string {
+	if alert.Severity == SeverityInfo {
+		return alert.Title
+	}
+	return fmt.Sprintf("[%s] %s", alert.Severity, alert.Title)
+}
+
+func postNotify(url string, data interface{}) error {
+	resp, body, errs := gorequest.New().Post(url).Timeout(time.Second*10).
+		Retry(3, 2*time.Second, http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable).
+		SendStruct(data).End()
+	if len(errs) > 0 {
+		return fmt.Errorf("req err: %v", errs)
+	} else if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("http code: %d, %s", resp.StatusCode, body)
+	}
+	log.Info("postNotify:", body)
+	return nil
+}
+
+// LarkNotifier critical alerts mention everyone
+type LarkNotifier struct {
+	name string
+	Key  string
+}
+
+func (l *LarkNotifier) Name() string {
+	return l.name
+}
+
+func (l *LarkNotifier) Notify(alert Alert) error {
+	return sendLarkNotify(l.Key, getAlertTitle(alert), alert.Text, alert.Severity == SeverityCritical)
+}
+
+// SlackNotifier an incoming webhook
+type SlackNotifier struct {
+	name string
+	Url  string
+}
+
+func (s *SlackNotifier) Name() string {
+	return s.name
+}
+
+func (s *SlackNotifier) Notify(alert Alert) error {
+	text := fmt.Sprintf("*UNIPAY: %s*\n%s", getAlertTitle(alert), alert.Text)
+	if alert.Severity == SeverityCritical {
+		text = "<!channel> " + text
+	}
+	return postNotify(s.Url, map[string]string{"text": text})
+}
+
+type TelegramNotifier struct {
+	name     string
+	BotToken string
+	ChatId   string
+}
+
+func (t *TelegramNotifier) Name() string {
+	return t.name
+}
+
+func (t *TelegramNotifier) Notify(alert Alert) error {
+	return postNotify(fmt.Sprintf(TelegramNotifyUrl, t.BotToken), map[string]string{
+		"chat_id": t.ChatId,
+		"text":    fmt.Sprintf("UNIPAY: %s\n%s", getAlertTitle(alert), alert.Text),
+	})
+}
+
+// SmtpNotifier plain auth if the user is not empty
+type SmtpNotifier struct {
+	name     string
+	Addr     string
+	User     string
+	Password string
+	From     string
+	To       []string
+}
+
+func (s *SmtpNotifier) Name() string {
+	return s.name
+}
+
+func (s *SmtpNotifier) Notify(alert Alert) error {
+	var auth smtp.Auth
+	if s.User != "" {
+		host, _, err := net.SplitHostPort(s.Addr)
+		if err != nil {
+			return fmt.Errorf("SplitHostPort err: %s", err.Error())
+		}
+		auth = smtp.PlainAuth("", s.User, s.Password, host)
+	}
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: UNIPAY: %s\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s\r\n",
+		s.From, strings.Join(s.To, ","), getAlertTitle(alert), alert.Text)
+	if err := smtp.SendMail(s.Addr, auth, s.From, s.To, []byte(msg)); err != nil {
+		return fmt.Errorf("SendMail err: %s", err.Error())
+	}
+	return nil
+}
+
+// WebhookNotifier posts the alert as json
+type WebhookNotifier struct {
+	name string
+	Url  string
+}
+
+type reqWebhookNotify struct {
+	Name string `json:"name"`
+	Alert
+}
+
+func (w *WebhookNotifier) Name() string {
+	return w.name
+}
+
+func (w *WebhookNotifier) Notify(alert Alert) error {
+	return postNotify(w.Url, reqWebhookNotify{Name: w.name, Alert: alert})
+}
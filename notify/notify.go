@@ -3,9 +3,6 @@ package notify
 import (
 	"fmt"
 	"github.com/dotbitHQ/das-lib/http_api/logger"
-	"github.com/parnurzeal/gorequest"
-	"time"
-	"unipay/txtool"
 )
 
 var log = logger.NewLogger("notify", logger.LevelDebug)
@@ -34,10 +31,8 @@ type MsgData struct {
 	} `json:"content"`
 }
 
-func SendLarkTextNotify(key, title, text string) {
-	if key == "" || text == "" {
-		return
-	}
+// sendLarkNotify atAll mentions everyone in the group
+func sendLarkNotify(key, title, text string, atAll bool) error {
 	var data MsgData
 	data.Email = ""
 	data.MsgType = "post"
@@ -51,53 +46,17 @@ func SendLarkTextNotify(key, title, text string) {
 			},
 		},
 	}
-	url := fmt.Sprintf(LarkNotifyUrl, key)
-	_, body, errs := gorequest.New().Post(url).Timeout(time.Second*10).Retry(5, 5*time.Second).SendStruct(&data).End()
-	if len(errs) > 0 {
-		log.Error("SendLarkTextNotify req err:", errs)
-	} else {
-		log.Info("SendLarkTextNotify req:", body)
-	}
-}
-
-func SendLarkErrNotify(title, text string) {
-	if title == "" || text == "" {
-		return
-	}
-	txtool.Tools.Metrics.ErrNotify().WithLabelValues(title, text).Inc()
-}
-
-func SendLarkTextNotifyAtAll(key, title, text string) {
-	if key == "" || text == "" {
-		return
-	}
-	var data MsgData
-	data.Email = ""
-	data.MsgType = "post"
-	data.Content.Post.ZhCn.Title = fmt.Sprintf("UNIPAY: %s", title)
-	data.Content.Post.ZhCn.Content = [][]MsgContent{
-		{
-			MsgContent{
-				Tag:      "text",
-				UnEscape: false,
-				Text:     text,
-			},
-		},
-		{
-			MsgContent{
+	if atAll {
+		data.Content.Post.ZhCn.Content = append(data.Content.Post.ZhCn.Content, []MsgContent{
+			{
 				Tag:      "at",
 				UserId:   "all",
 				UserName: "所有人",
 			},
-		},
+		})
 	}
 	url := fmt.Sprintf(LarkNotifyUrl, key)
-	_, body, errs := gorequest.New().Post(url).Timeout(time.Second*10).Retry(5, 5*time.Second).SendStruct(&data).End()
-	if len(errs) > 0 {
-		log.Error("SendLarkTextNotifyAtAll req err:", errs)
-	} else {
-		log.Info("SendLarkTextNotifyAtAll req:", body)
-	}
+	return postNotify(url, &data)
 }
 
 type StripeInfo struct {
@@ -110,7 +69,7 @@ type StripeInfo struct {
 	Amount      int64
 }
 
-func SendStripeNotify(key string, si StripeInfo) {
+func SendStripeNotify(si StripeInfo) {
 	msg := fmt.Sprintf(`> OrderId: %s
 > PID: %s
 > Product Info: %s
@@ -118,7 +77,5 @@ func SendStripeNotify(key string, si StripeInfo) {
 > Address: %s
 > Action: %s
 > Amount: %.2f`, si.OrderId, si.PID, si.ProductInfo, si.AlgorithmId, si.Address, si.Action, float64(si.Amount)/100)
-	go func() {
-		SendLarkTextNotify(key, "Stripe Payment", msg)
-	}()
+	SendAlert(SeverityInfo, CategoryStripe, "Stripe Payment", msg)
 }
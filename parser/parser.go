@@ -3,15 +3,17 @@ package parser
 import (
 	"context"
 	"fmt"
-	"github.com/dotbitHQ/das-lib/bitcoin"
-	"github.com/dotbitHQ/das-lib/chain/chain_evm"
-	"github.com/dotbitHQ/das-lib/chain/chain_tron"
 	"github.com/dotbitHQ/das-lib/common"
 	"github.com/dotbitHQ/das-lib/core"
-	"github.com/nervosnetwork/ckb-sdk-go/rpc"
+	"github.com/dotbitHQ/das-lib/http_api/logger"
+	"reflect"
+	"strings"
 	"sync"
+	"time"
 	"unipay/config"
 	"unipay/dao"
+	"unipay/health"
+	"unipay/nodepool"
 	"unipay/notify"
 	"unipay/parser/parser_bitcoin"
 	"unipay/parser/parser_ckb"
@@ -22,20 +24,36 @@ import (
 	"unipay/tables"
 )
 
+var log = logger.NewLogger("parser", logger.LevelDebug)
+
 type ToolParser struct {
 	ctx     context.Context
 	wg      *sync.WaitGroup
 	dbDao   *dao.DbDao
 	dasCore *core.DasCore
 
+	lock            sync.Mutex
 	parserCommonMap map[tables.ParserType]*parser_common.ParserCommon
+	runningMap      map[tables.ParserType]*runningParser
 
 	cn *notify.CallbackNotice
 }
 
+// runningParser the Parser and the MempoolWatcher of a chain
+type runningParser struct {
+	cancel context.CancelFunc
+	wg     *sync.WaitGroup
+}
+
+var parserTypeList = []tables.ParserType{
+	tables.ParserTypeETH, tables.ParserTypeBSC, tables.ParserTypePOLYGON, tables.ParserTypeTRON,
+	tables.ParserTypeCKB, tables.ParserTypeDoge, tables.ParserTypeDP,
+}
+
 func NewToolParser(ctx context.Context, wg *sync.WaitGroup, dbDao *dao.DbDao, cn *notify.CallbackNotice, dasCore *core.DasCore) (*ToolParser, error) {
 	tp := ToolParser{
 		parserCommonMap: make(map[tables.ParserType]*parser_common.ParserCommon),
+		runningMap:      make(map[tables.ParserType]*runningParser),
 		ctx:             ctx,
 		wg:              wg,
 		dbDao:           dbDao,
@@ -43,38 +61,56 @@ func NewToolParser(ctx context.Context, wg *sync.WaitGroup, dbDao *dao.DbDao, cn
 		dasCore:         dasCore,
 	}
 
-	if err := tp.initParserEth(); err != nil {
-		return nil, fmt.Errorf("initParserEth err: %s", err.Error())
-	}
-	if err := tp.initParserBsc(); err != nil {
-		return nil, fmt.Errorf("initParserBsc err: %s", err.Error())
-	}
-	if err := tp.initParserPolygon(); err != nil {
-		return nil, fmt.Errorf("initParserPolygon err: %s", err.Error())
-	}
-	if err := tp.initParserTron(); err != nil {
-		return nil, fmt.Errorf("initParserTron err: %s", err.Error())
-	}
-	if err := tp.initParserCkb(); err != nil {
-		return nil, fmt.Errorf("initParserCkb err: %s", err.Error())
-	}
-	if err := tp.initParserDoge(); err != nil {
-		return nil, fmt.Errorf("initParserDoge err: %s", err.Error())
-	}
-	if err := tp.initParserDP(); err != nil {
-		return nil, fmt.Errorf("initParserDP err: %s", err.Error())
+	for _, v := range parserTypeList {
+		if err := tp.initParser(v); err != nil {
+			return nil, err
+		}
 	}
 
 	return &tp, nil
 }
 
+func (t *ToolParser) initParser(parserType tables.ParserType) error {
+	switch parserType {
+	case tables.ParserTypeETH:
+		if err := t.initParserEth(); err != nil {
+			return fmt.Errorf("initParserEth err: %s", err.Error())
+		}
+	case tables.ParserTypeBSC:
+		if err := t.initParserBsc(); err != nil {
+			return fmt.Errorf("initParserBsc err: %s", err.Error())
+		}
+	case tables.ParserTypePOLYGON:
+		if err := t.initParserPolygon(); err != nil {
+			return fmt.Errorf("initParserPolygon err: %s", err.Error())
+		}
+	case tables.ParserTypeTRON:
+		if err := t.initParserTron(); err != nil {
+			return fmt.Errorf("initParserTron err: %s", err.Error())
+		}
+	case tables.ParserTypeCKB:
+		if err := t.initParserCkb(); err != nil {
+			return fmt.Errorf("initParserCkb err: %s", err.Error())
+		}
+	case tables.ParserTypeDoge:
+		if err := t.initParserDoge(); err != nil {
+			return fmt.Errorf("initParserDoge err: %s", err.Error())
+		}
+	case tables.ParserTypeDP:
+		if err := t.initParserDP(); err != nil {
+			return fmt.Errorf("initParserDP err: %s", err.Error())
+		}
+	}
+	return nil
+}
+
 func (t *ToolParser) initParserEth() error {
-	if !config.Cfg.Chain.Eth.Switch {
+	if !config.GetCfg().Chain.Eth.Switch {
 		return nil
 	}
-	chainEvm, err := chain_evm.NewChainEvm(t.ctx, config.Cfg.Chain.Eth.Node, config.Cfg.Chain.Eth.RefundAddFee)
+	pool, err := nodepool.NewEvmPool(t.ctx, tables.PayTokenIdETH.GetChain(), config.GetNodeList(config.GetCfg().Chain.Eth.Node, config.GetCfg().Chain.Eth.Nodes), config.GetCfg().Chain.Eth.RefundAddFee)
 	if err != nil {
-		return fmt.Errorf("chain_evm.NewChainEvm eth err: %s", err.Error())
+		return fmt.Errorf("NewEvmPool eth err: %s", err.Error())
 	}
 	t.parserCommonMap[tables.ParserTypeETH] = &parser_common.ParserCommon{
 		PC: &parser_common.ParserCore{
@@ -85,27 +121,31 @@ func (t *ToolParser) initParserEth() error {
 			ParserType:         tables.ParserTypeETH,
 			PayTokenId:         tables.PayTokenIdETH,
 			ContractPayTokenId: tables.PayTokenIdErc20USDT,
-			ContractAddress:    tables.PayTokenIdErc20USDT.GetContractAddress(config.Cfg.Server.Net),
+			ContractAddress:    tables.PayTokenIdErc20USDT.GetContractAddress(config.GetCfg().Server.Net),
 			CurrentBlockNumber: 0,
 			ConcurrencyNum:     5,
 			ConfirmNum:         2,
-			Switch:             config.Cfg.Chain.Eth.Switch,
-			AddrMap:            config.FormatAddrMap(tables.ParserTypeETH, config.Cfg.Chain.Eth.AddrMap),
+			PrefetchNum:        10,
+			PollInterval:       time.Second * 4,
+			Switch:             config.GetCfg().Chain.Eth.Switch,
+			Mempool:            config.GetCfg().Chain.Eth.Mempool,
+			AddrMap:            config.FormatAddrMap(tables.ParserTypeETH, config.GetCfg().Chain.Eth.AddrMap),
 		},
 		PA: &parser_evm.ParserEvm{
-			ChainEvm: chainEvm,
+			Pool: pool,
+			Ws:   config.GetCfg().Chain.Eth.Ws,
 		},
 	}
 	return nil
 }
 
 func (t *ToolParser) initParserBsc() error {
-	if !config.Cfg.Chain.Bsc.Switch {
+	if !config.GetCfg().Chain.Bsc.Switch {
 		return nil
 	}
-	chainEvm, err := chain_evm.NewChainEvm(t.ctx, config.Cfg.Chain.Bsc.Node, config.Cfg.Chain.Bsc.RefundAddFee)
+	pool, err := nodepool.NewEvmPool(t.ctx, tables.PayTokenIdBNB.GetChain(), config.GetNodeList(config.GetCfg().Chain.Bsc.Node, config.GetCfg().Chain.Bsc.Nodes), config.GetCfg().Chain.Bsc.RefundAddFee)
 	if err != nil {
-		return fmt.Errorf("chain_evm.NewChainEvm bsc err: %s", err.Error())
+		return fmt.Errorf("NewEvmPool bsc err: %s", err.Error())
 	}
 	t.parserCommonMap[tables.ParserTypeBSC] = &parser_common.ParserCommon{
 		PC: &parser_common.ParserCore{
@@ -116,15 +156,19 @@ func (t *ToolParser) initParserBsc() error {
 			ParserType:         tables.ParserTypeBSC,
 			PayTokenId:         tables.PayTokenIdBNB,
 			ContractPayTokenId: tables.PayTokenIdBep20USDT,
-			ContractAddress:    tables.PayTokenIdBep20USDT.GetContractAddress(config.Cfg.Server.Net),
+			ContractAddress:    tables.PayTokenIdBep20USDT.GetContractAddress(config.GetCfg().Server.Net),
 			CurrentBlockNumber: 0,
 			ConcurrencyNum:     10,
 			ConfirmNum:         10,
-			Switch:             config.Cfg.Chain.Bsc.Switch,
-			AddrMap:            config.FormatAddrMap(tables.ParserTypeBSC, config.Cfg.Chain.Bsc.AddrMap),
+			PrefetchNum:        20,
+			PollInterval:       time.Second * 3,
+			Switch:             config.GetCfg().Chain.Bsc.Switch,
+			Mempool:            config.GetCfg().Chain.Bsc.Mempool,
+			AddrMap:            config.FormatAddrMap(tables.ParserTypeBSC, config.GetCfg().Chain.Bsc.AddrMap),
 		},
 		PA: &parser_evm.ParserEvm{
-			ChainEvm: chainEvm,
+			Pool: pool,
+			Ws:   config.GetCfg().Chain.Bsc.Ws,
 		},
 	}
 
@@ -132,12 +176,12 @@ func (t *ToolParser) initParserBsc() error {
 }
 
 func (t *ToolParser) initParserPolygon() error {
-	if !config.Cfg.Chain.Polygon.Switch {
+	if !config.GetCfg().Chain.Polygon.Switch {
 		return nil
 	}
-	chainEvm, err := chain_evm.NewChainEvm(t.ctx, config.Cfg.Chain.Polygon.Node, config.Cfg.Chain.Polygon.RefundAddFee)
+	pool, err := nodepool.NewEvmPool(t.ctx, tables.PayTokenIdPOL.GetChain(), config.GetNodeList(config.GetCfg().Chain.Polygon.Node, config.GetCfg().Chain.Polygon.Nodes), config.GetCfg().Chain.Polygon.RefundAddFee)
 	if err != nil {
-		return fmt.Errorf("chain_evm.NewChainEvm bsc err: %s", err.Error())
+		return fmt.Errorf("NewEvmPool polygon err: %s", err.Error())
 	}
 	t.parserCommonMap[tables.ParserTypePOLYGON] = &parser_common.ParserCommon{
 		PC: &parser_common.ParserCore{
@@ -150,25 +194,29 @@ func (t *ToolParser) initParserPolygon() error {
 			CurrentBlockNumber: 0,
 			ConcurrencyNum:     10,
 			ConfirmNum:         10,
-			Switch:             config.Cfg.Chain.Polygon.Switch,
-			AddrMap:            config.FormatAddrMap(tables.ParserTypePOLYGON, config.Cfg.Chain.Polygon.AddrMap),
+			PrefetchNum:        20,
+			PollInterval:       time.Second * 2,
+			Switch:             config.GetCfg().Chain.Polygon.Switch,
+			Mempool:            config.GetCfg().Chain.Polygon.Mempool,
+			AddrMap:            config.FormatAddrMap(tables.ParserTypePOLYGON, config.GetCfg().Chain.Polygon.AddrMap),
 		},
 		PA: &parser_evm.ParserEvm{
-			ChainEvm: chainEvm,
+			Pool: pool,
+			Ws:   config.GetCfg().Chain.Polygon.Ws,
 		},
 	}
 	return nil
 }
 
 func (t *ToolParser) initParserTron() error {
-	if !config.Cfg.Chain.Tron.Switch {
+	if !config.GetCfg().Chain.Tron.Switch {
 		return nil
 	}
-	chainTron, err := chain_tron.NewChainTron(t.ctx, config.Cfg.Chain.Tron.Node)
+	pool, err := nodepool.NewTronPool(t.ctx, tables.PayTokenIdTRX.GetChain(), config.GetNodeList(config.GetCfg().Chain.Tron.Node, config.GetCfg().Chain.Tron.Nodes))
 	if err != nil {
-		return fmt.Errorf("chain_ckb.NewChainTron tron err: %s", err.Error())
+		return fmt.Errorf("NewTronPool tron err: %s", err.Error())
 	}
-	contractAddress := tables.PayTokenIdTrc20USDT.GetContractAddress(config.Cfg.Server.Net)
+	contractAddress := tables.PayTokenIdTrc20USDT.GetContractAddress(config.GetCfg().Server.Net)
 	if contractAddress, err = common.TronBase58ToHex(contractAddress); err != nil {
 		return fmt.Errorf("TronBase58ToHex err: %s", err.Error())
 	}
@@ -185,22 +233,24 @@ func (t *ToolParser) initParserTron() error {
 			CurrentBlockNumber: 0,
 			ConcurrencyNum:     10,
 			ConfirmNum:         10,
-			Switch:             config.Cfg.Chain.Tron.Switch,
-			AddrMap:            config.FormatAddrMap(tables.ParserTypeTRON, config.Cfg.Chain.Tron.AddrMap),
+			PrefetchNum:        20,
+			PollInterval:       time.Second * 3,
+			Switch:             config.GetCfg().Chain.Tron.Switch,
+			AddrMap:            config.FormatAddrMap(tables.ParserTypeTRON, config.GetCfg().Chain.Tron.AddrMap),
 		},
-		PA: &parser_tron.ParserTron{ChainTron: chainTron},
+		PA: &parser_tron.ParserTron{Pool: pool},
 	}
 
 	return nil
 }
 
 func (t *ToolParser) initParserCkb() error {
-	if !config.Cfg.Chain.Ckb.Switch {
+	if !config.GetCfg().Chain.Ckb.Switch {
 		return nil
 	}
-	rpcClient, err := rpc.DialWithIndexer(config.Cfg.Chain.Ckb.Node, config.Cfg.Chain.Ckb.Node)
+	pool, err := nodepool.NewCkbPool(t.ctx, tables.PayTokenIdCKB.GetChain(), config.GetNodeList(config.GetCfg().Chain.Ckb.Node, config.GetCfg().Chain.Ckb.Nodes))
 	if err != nil {
-		return fmt.Errorf("rpc.DialWithIndexer err:%s", err.Error())
+		return fmt.Errorf("NewCkbPool err:%s", err.Error())
 	}
 	t.parserCommonMap[tables.ParserTypeCKB] = &parser_common.ParserCommon{
 		PC: &parser_common.ParserCore{
@@ -213,19 +263,22 @@ func (t *ToolParser) initParserCkb() error {
 			CurrentBlockNumber: 0,
 			ConcurrencyNum:     10,
 			ConfirmNum:         3,
-			Switch:             config.Cfg.Chain.Ckb.Switch,
-			AddrMap:            config.FormatAddrMap(tables.ParserTypeCKB, config.Cfg.Chain.Ckb.AddrMap),
+			PrefetchNum:        20,
+			PollInterval:       time.Second * 4,
+			Switch:             config.GetCfg().Chain.Ckb.Switch,
+			Mempool:            config.GetCfg().Chain.Ckb.Mempool,
+			AddrMap:            config.FormatAddrMap(tables.ParserTypeCKB, config.GetCfg().Chain.Ckb.AddrMap),
 		},
 		PA: &parser_ckb.ParserCkb{
-			Ctx:    t.ctx,
-			Client: rpcClient,
+			Ctx:  t.ctx,
+			Pool: pool,
 		},
 	}
 	return nil
 }
 
 func (t *ToolParser) initParserDP() error {
-	if !config.Cfg.Chain.DP.Switch {
+	if !config.GetCfg().Chain.DP.Switch {
 		return nil
 	}
 	t.parserCommonMap[tables.ParserTypeDP] = &parser_common.ParserCommon{
@@ -239,7 +292,7 @@ func (t *ToolParser) initParserDP() error {
 			CurrentBlockNumber: 0,
 			ConcurrencyNum:     10,
 			ConfirmNum:         3,
-			Switch:             config.Cfg.Chain.DP.Switch,
+			Switch:             config.GetCfg().Chain.DP.Switch,
 			AddrMap:            nil,
 		},
 		PA: &parser_dp.ParserDP{
@@ -251,14 +304,13 @@ func (t *ToolParser) initParserDP() error {
 }
 
 func (t *ToolParser) initParserDoge() error {
-	if !config.Cfg.Chain.Doge.Switch {
+	if !config.GetCfg().Chain.Doge.Switch {
 		return nil
 	}
-	nodeRpc := bitcoin.BaseRequest{
-		RpcUrl:   config.Cfg.Chain.Doge.Node,
-		User:     config.Cfg.Chain.Doge.User,
-		Password: config.Cfg.Chain.Doge.Password,
-		Proxy:    "",
+	doge := config.GetCfg().Chain.Doge
+	pool, err := nodepool.NewDogePool(tables.PayTokenIdDOGE.GetChain(), config.GetNodeList(doge.Node, doge.Nodes), doge.User, doge.Password)
+	if err != nil {
+		return fmt.Errorf("NewDogePool err: %s", err.Error())
 	}
 	t.parserCommonMap[tables.ParserTypeDoge] = &parser_common.ParserCommon{
 		PC: &parser_common.ParserCore{
@@ -271,18 +323,112 @@ func (t *ToolParser) initParserDoge() error {
 			CurrentBlockNumber: 0,
 			ConcurrencyNum:     3,
 			ConfirmNum:         3,
-			Switch:             config.Cfg.Chain.Doge.Switch,
-			AddrMap:            config.Cfg.Chain.Doge.AddrMap,
+			PrefetchNum:        5,
+			PollInterval:       time.Second * 15,
+			Switch:             config.GetCfg().Chain.Doge.Switch,
+			Mempool:            config.GetCfg().Chain.Doge.Mempool,
+			AddrMap:            config.GetCfg().Chain.Doge.AddrMap,
 		},
-		PA: &parser_bitcoin.ParserBitcoin{NodeRpc: &nodeRpc},
+		PA: &parser_bitcoin.ParserBitcoin{Pool: pool},
 	}
 	return nil
 }
 
 func (t *ToolParser) RunParser() {
-	for _, v := range t.parserCommonMap {
+	t.lock.Lock()
+	for k, v := range t.parserCommonMap {
 		if v.PC.Switch {
-			go v.Parser()
+			t.startParser(k, v)
+		}
+	}
+	t.lock.Unlock()
+	config.Subscribe("parser", t.reloadParser)
+}
+
+// startParser on a context of its own, so that the config reload can restart the one chain
+func (t *ToolParser) startParser(parserType tables.ParserType, pc *parser_common.ParserCommon) {
+	ctx, cancel := context.WithCancel(t.ctx)
+	wg := &sync.WaitGroup{}
+	pc.PC.Ctx, pc.PC.Wg = ctx, wg
+	t.runningMap[parserType] = &runningParser{cancel: cancel, wg: wg}
+
+	wg.Add(2)
+	go pc.Parser()
+	go pc.MempoolWatcher()
+
+	t.wg.Add(1)
+	go func() {
+		wg.Wait()
+		t.wg.Done()
+	}()
+}
+
+// stopParser waits for the current round of parsing, the next start goes on from the saved block
+func (t *ToolParser) stopParser(parserType tables.ParserType) {
+	item, ok := t.runningMap[parserType]
+	if !ok {
+		return
+	}
+	item.cancel()
+	item.wg.Wait()
+	delete(t.runningMap, parserType)
+	health.ReportStop(parserType)
+}
+
+// reloadParser restarts the parsers with a changed switch or node, the addr map is replaced in place
+func (t *ToolParser) reloadParser(oldCfg, newCfg *config.CfgServer) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	var errList []string
+	for _, parserType := range parserTypeList {
+		oldKey, oldAddrMap := getParserConf(oldCfg, parserType)
+		newKey, newAddrMap := getParserConf(newCfg, parserType)
+		if oldKey == newKey {
+			if pc, ok := t.parserCommonMap[parserType]; ok && !reflect.DeepEqual(oldAddrMap, newAddrMap) {
+				log.Info("reloadParser addr map:", parserType.ToString(), len(newAddrMap))
+				pc.PC.SetAddrMap(config.FormatAddrMap(parserType, newAddrMap))
+			}
+			continue
+		}
+
+		log.Warn("reloadParser restart:", parserType.ToString())
+		t.stopParser(parserType)
+		delete(t.parserCommonMap, parserType)
+		if err := t.initParser(parserType); err != nil {
+			errList = append(errList, fmt.Sprintf("%s: %s", parserType.ToString(), err.Error()))
+			continue
+		}
+		if pc, ok := t.parserCommonMap[parserType]; ok && pc.PC.Switch {
+			t.startParser(parserType, pc)
 		}
 	}
+	if len(errList) > 0 {
+		err := fmt.Errorf("%s", strings.Join(errList, "; "))
+		notify.SendAlert(notify.SeverityCritical, notify.CategoryParser, "ParserReload", err.Error())
+		return err
+	}
+	return nil
+}
+
+// getParserConf the parser restarts when the key changes
+func getParserConf(cfg *config.CfgServer, parserType tables.ParserType) (key string, addrMap map[string]string) {
+	ch := cfg.Chain
+	switch parserType {
+	case tables.ParserTypeETH:
+		return fmt.Sprintf("%t|%v|%s|%t", ch.Eth.Switch, config.GetNodeList(ch.Eth.Node, ch.Eth.Nodes), ch.Eth.Ws, ch.Eth.Mempool), ch.Eth.AddrMap
+	case tables.ParserTypeBSC:
+		return fmt.Sprintf("%t|%v|%s|%t", ch.Bsc.Switch, config.GetNodeList(ch.Bsc.Node, ch.Bsc.Nodes), ch.Bsc.Ws, ch.Bsc.Mempool), ch.Bsc.AddrMap
+	case tables.ParserTypePOLYGON:
+		return fmt.Sprintf("%t|%v|%s|%t", ch.Polygon.Switch, config.GetNodeList(ch.Polygon.Node, ch.Polygon.Nodes), ch.Polygon.Ws, ch.Polygon.Mempool), ch.Polygon.AddrMap
+	case tables.ParserTypeTRON:
+		return fmt.Sprintf("%t|%v", ch.Tron.Switch, config.GetNodeList(ch.Tron.Node, ch.Tron.Nodes)), ch.Tron.AddrMap
+	case tables.ParserTypeCKB:
+		return fmt.Sprintf("%t|%v|%t", ch.Ckb.Switch, config.GetNodeList(ch.Ckb.Node, ch.Ckb.Nodes), ch.Ckb.Mempool), ch.Ckb.AddrMap
+	case tables.ParserTypeDoge:
+		return fmt.Sprintf("%t|%v|%s|%s|%t", ch.Doge.Switch, config.GetNodeList(ch.Doge.Node, ch.Doge.Nodes), ch.Doge.User, ch.Doge.Password, ch.Doge.Mempool), ch.Doge.AddrMap
+	case tables.ParserTypeDP:
+		return fmt.Sprintf("%t", ch.DP.Switch), nil
+	}
+	return "", nil
 }
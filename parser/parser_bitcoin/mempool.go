@@ -0,0 +1,82 @@
+package parser_bitcoin
+
+import (
+	"fmt"
+	"github.com/dotbitHQ/das-lib/bitcoin"
+	"github.com/shopspring/decimal"
+	"unipay/parser/parser_common"
+	"unipay/tables"
+)
+
+const rpcMethodGetRawMempool bitcoin.RpcMethod = "getrawmempool"
+
+// GetMempoolTxList only the txs not checked in the former rounds are requested
+func (p *ParserBitcoin) GetMempoolTxList(pc *parser_common.ParserCore) ([]parser_common.MempoolTx, error) {
+	var hashList []string
+	index, nodeRpc := p.Pool.Client()
+	err := nodeRpc.Request(rpcMethodGetRawMempool, []interface{}{false}, &hashList)
+	p.Pool.Report(index, err)
+	if err != nil {
+		return nil, fmt.Errorf("req getrawmempool err: %s", err.Error())
+	}
+	mainNetParams, err := p.getMainNetParams(pc)
+	if err != nil {
+		return nil, fmt.Errorf("getMainNetParams err: %s", err.Error())
+	}
+
+	var list []parser_common.MempoolTx
+	for _, hash := range p.mempoolCache.Filter(hashList) {
+		data, err := nodeRpc.GetRawTransaction(hash)
+		if err != nil {
+			return list, fmt.Errorf("req GetRawTransaction err: %s", err.Error())
+		}
+		p.mempoolCache.Add(hash)
+
+		isMyTx, value, receiptAddr := false, float64(0), ""
+		for _, vOut := range data.Vout {
+			for _, receiptAddr = range vOut.ScriptPubKey.Addresses {
+				if _, ok := pc.GetAddrMap()[receiptAddr]; ok {
+					isMyTx = true
+					value = vOut.Value
+					break
+				}
+			}
+			if isMyTx {
+				break
+			}
+		}
+		if !isMyTx || len(data.Vin) == 0 {
+			continue
+		}
+		_, addrPayload, err := bitcoin.VinScriptSigToAddress(data.Vin[0].ScriptSig, mainNetParams)
+		if err != nil {
+			log.Warn("VinScriptSigToAddress err:", pc.ParserType, hash, err.Error())
+			continue
+		}
+		list = append(list, parser_common.MempoolTx{
+			TxHash:      data.Txid,
+			FromAddr:    addrPayload,
+			ReceiptAddr: receiptAddr,
+			OrderId:     getOrderIdByOpReturn(data),
+			PayTokenIds: []tables.PayTokenId{pc.PayTokenId},
+			Amount:      decimal.NewFromFloat(value).Mul(decimal.NewFromInt(1e8)),
+		})
+	}
+	return list, nil
+}
+
+func (p *ParserBitcoin) GetTxBlockNumber(txHash string) (uint64, error) {
+	index, nodeRpc := p.Pool.Client()
+	data, err := nodeRpc.GetRawTransaction(txHash)
+	p.Pool.Report(index, err)
+	if err != nil {
+		return 0, fmt.Errorf("req GetRawTransaction err: %s", err.Error())
+	} else if data.BlockHash == "" {
+		return 0, nil
+	}
+	block, err := nodeRpc.GetBlock(data.BlockHash)
+	if err != nil {
+		return 0, fmt.Errorf("req GetBlock err: %s", err.Error())
+	}
+	return block.Height, nil
+}
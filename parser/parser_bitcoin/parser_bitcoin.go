@@ -13,6 +13,7 @@ import (
 	"strings"
 	"sync"
 	"unipay/config"
+	"unipay/nodepool"
 	"unipay/notify"
 	"unipay/parser/parser_common"
 	"unipay/tables"
@@ -21,29 +22,38 @@ import (
 var log = logger.NewLogger("parser_bitcoin", logger.LevelDebug)
 
 type ParserBitcoin struct {
-	NodeRpc *bitcoin.BaseRequest
+	Pool         *nodepool.DogePool
+	mempoolCache parser_common.MempoolCache
 }
 
 func (p *ParserBitcoin) GetLatestBlockNumber() (uint64, error) {
-	data, err := p.NodeRpc.GetBlockChainInfo()
+	index, nodeRpc := p.Pool.Client()
+	data, err := nodeRpc.GetBlockChainInfo()
+	p.Pool.Report(index, err)
 	if err != nil {
 		return 0, fmt.Errorf("GetBlockChainInfo err: %s", err.Error())
 	}
 	return data.Blocks, nil
 }
+
+// Init the probes of the nodes stop with the parser
 func (p *ParserBitcoin) Init(pc *parser_common.ParserCore) error {
+	p.Pool.Run(pc.Ctx, pc.Wg)
 	return nil
 }
 func (p *ParserBitcoin) SingleParsing(pc *parser_common.ParserCore) error {
 	parserType, currentBlockNumber := pc.ParserType, pc.CurrentBlockNumber
 	log.Debug("SingleParsing:", parserType, currentBlockNumber)
 
-	hash, err := p.NodeRpc.GetBlockHash(currentBlockNumber)
+	index, nodeRpc := p.Pool.Client()
+	hash, err := nodeRpc.GetBlockHash(currentBlockNumber)
+	p.Pool.Report(index, err)
 	if err != nil {
 		return fmt.Errorf("req GetBlockHash err: %s", err.Error())
 	}
 
-	block, err := p.NodeRpc.GetBlock(hash)
+	block, err := nodeRpc.GetBlock(hash)
+	p.Pool.Report(index, err)
 	if err != nil {
 		return fmt.Errorf("req GetBlock err: %s", err.Error())
 	}
@@ -51,13 +61,16 @@ func (p *ParserBitcoin) SingleParsing(pc *parser_common.ParserCore) error {
 	blockHash := block.Hash
 	parentHash := block.PreviousBlockHash
 	log.Debug("SingleParsing:", parserType, blockHash, parentHash)
+	if err := p.Pool.CheckBlockHash(index, currentBlockNumber, blockHash); err != nil {
+		return fmt.Errorf("CheckBlockHash err: %s", err.Error())
+	}
 
 	if isFork, err := pc.HandleFork(blockHash, parentHash); err != nil {
 		return fmt.Errorf("HandleFork err: %s", err.Error())
 	} else if isFork {
 		return nil
 	}
-	if err := p.parsingBlockData2(&block, pc); err != nil {
+	if err := p.parsingBlockData2(nodeRpc, &block, pc); err != nil {
 		return fmt.Errorf("parsingBlockData2 err: %s", err.Error())
 	} else {
 		if err := pc.HandleSingleParsingOK(blockHash, parentHash); err != nil {
@@ -77,16 +90,20 @@ func (p *ParserBitcoin) ConcurrentParsing(pc *parser_common.ParserCore) error {
 	blockLock := &sync.Mutex{}
 	blockGroup := &errgroup.Group{}
 
+	// the blocks of a round from one node
+	nodeIndex, nodeRpc := p.Pool.Client()
 	for i := uint64(0); i < concurrencyNum; i++ {
 		bn := currentBlockNumber + i
 		index := i
 		blockGroup.Go(func() error {
-			blockHash, err := p.NodeRpc.GetBlockHash(bn)
+			blockHash, err := nodeRpc.GetBlockHash(bn)
+			p.Pool.Report(nodeIndex, err)
 			if err != nil {
 				return fmt.Errorf("req GetBlockHash err: %s", err.Error())
 			}
 
-			block, err := p.NodeRpc.GetBlock(blockHash)
+			block, err := nodeRpc.GetBlock(blockHash)
+			p.Pool.Report(nodeIndex, err)
 			if err != nil {
 				return fmt.Errorf("req GetBlock err: %s", err.Error())
 			}
@@ -110,6 +127,10 @@ func (p *ParserBitcoin) ConcurrentParsing(pc *parser_common.ParserCore) error {
 	if err := blockGroup.Wait(); err != nil {
 		return fmt.Errorf("errGroup.Wait()1 err: %s", err.Error())
 	}
+	lastBlock := blockList[concurrencyNum-1]
+	if err := p.Pool.CheckBlockHash(nodeIndex, lastBlock.BlockNumber, lastBlock.BlockHash); err != nil {
+		return fmt.Errorf("CheckBlockHash err: %s", err.Error())
+	}
 
 	for i := range blocks {
 		blockCh <- blocks[i]
@@ -118,7 +139,7 @@ func (p *ParserBitcoin) ConcurrentParsing(pc *parser_common.ParserCore) error {
 
 	blockGroup.Go(func() error {
 		for v := range blockCh {
-			if err := p.parsingBlockData2(&v, pc); err != nil {
+			if err := p.parsingBlockData2(nodeRpc, &v, pc); err != nil {
 				return fmt.Errorf("parsingBlockData2 err: %s", err.Error())
 			}
 		}
@@ -144,7 +165,7 @@ func (p *ParserBitcoin) getMainNetParams(pc *parser_common.ParserCore) (chaincfg
 	return chaincfg.MainNetParams, fmt.Errorf("unknow MainNetParams ParserType[%d]", pc.ParserType)
 }
 
-func (p *ParserBitcoin) parsingBlockData2(block *bitcoin.BlockInfo, pc *parser_common.ParserCore) error {
+func (p *ParserBitcoin) parsingBlockData2(nodeRpc *bitcoin.BaseRequest, block *bitcoin.BlockInfo, pc *parser_common.ParserCore) error {
 	parserType := pc.ParserType
 	if block == nil {
 		return fmt.Errorf("block is nil")
@@ -157,13 +178,13 @@ func (p *ParserBitcoin) parsingBlockData2(block *bitcoin.BlockInfo, pc *parser_c
 	dataGroup := &errgroup.Group{}
 
 	txChanNum := 5
-	if config.Cfg.Chain.Doge.TxChanNum > 0 {
-		txChanNum = config.Cfg.Chain.Doge.TxChanNum
+	if config.GetCfg().Chain.Doge.TxChanNum > 0 {
+		txChanNum = config.GetCfg().Chain.Doge.TxChanNum
 	}
 	for i := 0; i < txChanNum; i++ {
 		dataGroup.Go(func() error {
 			for index := range indexCh {
-				data, err := p.NodeRpc.GetRawTransaction(block.Tx[index])
+				data, err := nodeRpc.GetRawTransaction(block.Tx[index])
 				if err != nil {
 					return fmt.Errorf("req GetRawTransaction err: %s", err.Error())
 				}
@@ -188,7 +209,7 @@ func (p *ParserBitcoin) parsingBlockData2(block *bitcoin.BlockInfo, pc *parser_c
 		isMyTx, value, receiptAddr := false, float64(0), ""
 		for _, vOut := range data.Vout {
 			for _, receiptAddr = range vOut.ScriptPubKey.Addresses {
-				if _, ok := pc.AddrMap[receiptAddr]; ok {
+				if _, ok := pc.GetAddrMap()[receiptAddr]; ok {
 					isMyTx = true
 					value = vOut.Value
 					break
@@ -214,12 +235,12 @@ func (p *ParserBitcoin) parsingBlockData2(block *bitcoin.BlockInfo, pc *parser_c
 				return fmt.Errorf("VinScriptSigToAddress err: %s", err.Error())
 			}
 
-			if ok, err := p.dealWithOpReturn(pc, data, decValue, addrPayload, receiptAddr); err != nil {
+			if ok, err := p.dealWithOpReturn(pc, block, data, decValue, addrPayload, receiptAddr); err != nil {
 				return fmt.Errorf("dealWithOpReturn err: %s", err.Error())
 			} else if ok {
 				continue
 			}
-			if err = p.dealWithHashAndAmount(pc, data, decValue, addrPayload, receiptAddr); err != nil {
+			if err = p.dealWithHashAndAmount(pc, block, data, decValue, addrPayload, receiptAddr); err != nil {
 				return fmt.Errorf("dealWithHashAndAmount err: %s", err.Error())
 			}
 		}
@@ -227,7 +248,7 @@ func (p *ParserBitcoin) parsingBlockData2(block *bitcoin.BlockInfo, pc *parser_c
 	return nil
 }
 
-func (p *ParserBitcoin) parsingBlockData(block *bitcoin.BlockInfo, pc *parser_common.ParserCore) error {
+func (p *ParserBitcoin) parsingBlockData(nodeRpc *bitcoin.BaseRequest, block *bitcoin.BlockInfo, pc *parser_common.ParserCore) error {
 	parserType := pc.ParserType
 	if block == nil {
 		return fmt.Errorf("block is nil")
@@ -237,7 +258,7 @@ func (p *ParserBitcoin) parsingBlockData(block *bitcoin.BlockInfo, pc *parser_co
 		//t1 := time.Now()
 		//log.Info("parsingBlockData: t1", t1.String(), i)
 		// get tx info
-		data, err := p.NodeRpc.GetRawTransaction(v)
+		data, err := nodeRpc.GetRawTransaction(v)
 		if err != nil {
 			return fmt.Errorf("req GetRawTransaction err: %s", err.Error())
 		}
@@ -246,7 +267,7 @@ func (p *ParserBitcoin) parsingBlockData(block *bitcoin.BlockInfo, pc *parser_co
 		isMyTx, value, receiptAddr := false, float64(0), ""
 		for _, vOut := range data.Vout {
 			for _, receiptAddr = range vOut.ScriptPubKey.Addresses {
-				if _, ok := pc.AddrMap[receiptAddr]; ok {
+				if _, ok := pc.GetAddrMap()[receiptAddr]; ok {
 					isMyTx = true
 					value = vOut.Value
 					break
@@ -272,12 +293,12 @@ func (p *ParserBitcoin) parsingBlockData(block *bitcoin.BlockInfo, pc *parser_co
 				return fmt.Errorf("VinScriptSigToAddress err: %s", err.Error())
 			}
 
-			if ok, err := p.dealWithOpReturn(pc, data, decValue, addrPayload, receiptAddr); err != nil {
+			if ok, err := p.dealWithOpReturn(pc, block, data, decValue, addrPayload, receiptAddr); err != nil {
 				return fmt.Errorf("dealWithOpReturn err: %s", err.Error())
 			} else if ok {
 				continue
 			}
-			if err = p.dealWithHashAndAmount(pc, data, decValue, addrPayload, receiptAddr); err != nil {
+			if err = p.dealWithHashAndAmount(pc, block, data, decValue, addrPayload, receiptAddr); err != nil {
 				return fmt.Errorf("dealWithHashAndAmount err: %s", err.Error())
 			}
 		}
@@ -286,7 +307,7 @@ func (p *ParserBitcoin) parsingBlockData(block *bitcoin.BlockInfo, pc *parser_co
 	return nil
 }
 
-func (p *ParserBitcoin) dealWithOpReturn(pc *parser_common.ParserCore, data btcjson.TxRawResult, decValue decimal.Decimal, addrPayload, receiptAddr string) (bool, error) {
+func getOrderIdByOpReturn(data btcjson.TxRawResult) string {
 	var orderId string
 	for _, vOut := range data.Vout {
 		switch vOut.ScriptPubKey.Type {
@@ -300,6 +321,11 @@ func (p *ParserBitcoin) dealWithOpReturn(pc *parser_common.ParserCore, data btcj
 			break
 		}
 	}
+	return orderId
+}
+
+func (p *ParserBitcoin) dealWithOpReturn(pc *parser_common.ParserCore, block *bitcoin.BlockInfo, data btcjson.TxRawResult, decValue decimal.Decimal, addrPayload, receiptAddr string) (bool, error) {
+	orderId := getOrderIdByOpReturn(data)
 	log.Info("dealWithOpReturn:", orderId, addrPayload)
 	if orderId == "" {
 		return false, nil
@@ -322,32 +348,32 @@ func (p *ParserBitcoin) dealWithOpReturn(pc *parser_common.ParserCore, data btcj
 		return false, nil
 	}
 	// update payment info
-	if err = pc.DoPayment(order, data.Txid, addrPayload, pc.ParserType.ToAlgorithmId()); err != nil {
+	if err = pc.DoPayment(order, data.Txid, addrPayload, pc.ParserType.ToAlgorithmId(), block.Height, block.Hash); err != nil {
 		return false, fmt.Errorf("pc.DoPayment err: %s", err.Error())
 	}
 
 	return true, nil
 }
 
-func (p *ParserBitcoin) dealWithHashAndAmount(pc *parser_common.ParserCore, data btcjson.TxRawResult, decValue decimal.Decimal, addrPayload, receiptAddr string) error {
+func (p *ParserBitcoin) dealWithHashAndAmount(pc *parser_common.ParserCore, block *bitcoin.BlockInfo, data btcjson.TxRawResult, decValue decimal.Decimal, addrPayload, receiptAddr string) error {
 	var order tables.TableOrderInfo
 	var err error
 
 	decValue = decValue.Mul(decimal.NewFromInt(1e8))
-	order, err = pc.DbDao.GetOrderByAddrWithAmountAndAddr(addrPayload, receiptAddr, pc.PayTokenId, decValue)
+	order, err = pc.GetOrderByAmount(addrPayload, receiptAddr, pc.PayTokenId, decValue)
 	if err != nil {
-		return fmt.Errorf("GetOrderByAddrWithAmountAndAddr err: %s", err.Error())
+		return fmt.Errorf("GetOrderByAmount err: %s", err.Error())
 	}
 	log.Info("dealWithHashAndAmount:", data.Txid, order.OrderId)
 	if order.Id > 0 {
-		if err = pc.DoPayment(order, data.Txid, addrPayload, pc.ParserType.ToAlgorithmId()); err != nil {
+		if err = pc.DoPayment(order, data.Txid, addrPayload, pc.ParserType.ToAlgorithmId(), block.Height, block.Hash); err != nil {
 			return fmt.Errorf("pc.DoPayment err: %s", err.Error())
 		}
 	} else {
 		//pc.CreatePaymentForMismatch("", data.Txid, addrPayload, decValue, pc.PayTokenId)
 		msg := `hash: %s
 addrPayload: %s`
-		notify.SendLarkTextNotify(config.Cfg.Notify.LarkErrorKey, "dealWithHashAndAmount", fmt.Sprintf(msg, data.Txid, addrPayload))
+		notify.SendAlert(notify.SeverityWarn, notify.CategoryParser, "dealWithHashAndAmount", fmt.Sprintf(msg, data.Txid, addrPayload))
 	}
 	return nil
 }
@@ -0,0 +1,43 @@
+package parser_bitcoin
+
+import (
+	"fmt"
+	"github.com/dotbitHQ/das-lib/bitcoin"
+	"unipay/parser/parser_common"
+)
+
+// fetchedBlock the inputs of the block are parsed from the node it came from
+type fetchedBlock struct {
+	nodeRpc *bitcoin.BaseRequest
+	block   bitcoin.BlockInfo
+}
+
+func (p *ParserBitcoin) FetchBlock(pc *parser_common.ParserCore, blockNumber uint64, check bool) (*parser_common.Block, error) {
+	index, nodeRpc := p.Pool.Client()
+	hash, err := nodeRpc.GetBlockHash(blockNumber)
+	p.Pool.Report(index, err)
+	if err != nil {
+		return nil, fmt.Errorf("req GetBlockHash err: %s [%d]", err.Error(), blockNumber)
+	}
+	block, err := nodeRpc.GetBlock(hash)
+	p.Pool.Report(index, err)
+	if err != nil {
+		return nil, fmt.Errorf("req GetBlock err: %s [%d]", err.Error(), blockNumber)
+	}
+	if check {
+		if err := p.Pool.CheckBlockHash(index, blockNumber, block.Hash); err != nil {
+			return nil, fmt.Errorf("CheckBlockHash err: %s", err.Error())
+		}
+	}
+	return &parser_common.Block{
+		BlockNumber: blockNumber,
+		BlockHash:   block.Hash,
+		ParentHash:  block.PreviousBlockHash,
+		Data:        fetchedBlock{nodeRpc: nodeRpc, block: block},
+	}, nil
+}
+
+func (p *ParserBitcoin) ParseBlock(pc *parser_common.ParserCore, block *parser_common.Block) error {
+	data := block.Data.(fetchedBlock)
+	return p.parsingBlockData2(data.nodeRpc, &data.block, pc)
+}
@@ -0,0 +1,91 @@
+package parser_ckb
+
+import (
+	"fmt"
+	"github.com/dotbitHQ/das-lib/common"
+	"github.com/nervosnetwork/ckb-sdk-go/address"
+	"github.com/nervosnetwork/ckb-sdk-go/types"
+	"github.com/shopspring/decimal"
+	"strconv"
+	"unipay/config"
+	"unipay/parser/parser_common"
+	"unipay/tables"
+)
+
+// GetMempoolTxList the pending and proposed txs of the tx pool, only the ones not checked in the former rounds
+func (p *ParserCkb) GetMempoolTxList(pc *parser_common.ParserCore) ([]parser_common.MempoolTx, error) {
+	index, client := p.Pool.Client()
+	pool, err := client.GetRawTxPool(p.Ctx)
+	p.Pool.Report(index, err)
+	if err != nil {
+		return nil, fmt.Errorf("GetRawTxPool err: %s", err.Error())
+	}
+	var hashList []string
+	for _, v := range append(pool.Pending, pool.Proposed...) {
+		if v != nil {
+			hashList = append(hashList, v.Hex())
+		}
+	}
+	mode := address.Mainnet
+	if config.GetCfg().Server.Net != common.DasNetTypeMainNet {
+		mode = address.Testnet
+	}
+
+	var list []parser_common.MempoolTx
+	for _, hash := range p.mempoolCache.Filter(hashList) {
+		res, err := client.GetTransaction(p.Ctx, types.HexToHash(hash))
+		if err != nil {
+			return list, fmt.Errorf("GetTransaction err: %s", err.Error())
+		}
+		p.mempoolCache.Add(hash)
+		if res == nil || res.Transaction == nil {
+			continue
+		}
+		tx := res.Transaction
+		for i, v := range tx.Outputs {
+			addrArgs := common.Bytes2Hex(v.Lock.Args)
+			if _, ok := pc.GetAddrMap()[addrArgs]; !ok || i >= len(tx.OutputsData) {
+				continue
+			}
+			orderId := string(tx.OutputsData[i])
+			if orderId == "" || len(tx.Inputs) == 0 {
+				continue
+			}
+			txInputs, err := client.GetTransaction(p.Ctx, tx.Inputs[0].PreviousOutput.TxHash)
+			if err != nil {
+				return list, fmt.Errorf("GetTransaction err: %s", err.Error())
+			}
+			fromAddr, err := common.ConvertScriptToAddress(mode, txInputs.Transaction.Outputs[tx.Inputs[0].PreviousOutput.Index].Lock)
+			if err != nil {
+				return list, fmt.Errorf("common.ConvertScriptToAddress err: %s", err.Error())
+			}
+			capacity, _ := decimal.NewFromString(strconv.FormatUint(v.Capacity, 10))
+			list = append(list, parser_common.MempoolTx{
+				TxHash:      hash,
+				FromAddr:    fromAddr,
+				ReceiptAddr: addrArgs,
+				OrderId:     orderId,
+				PayTokenIds: []tables.PayTokenId{tables.PayTokenIdCKB, tables.PayTokenIdDAS, tables.PayTokenIdCkbCCC},
+				Amount:      capacity,
+			})
+			break
+		}
+	}
+	return list, nil
+}
+
+func (p *ParserCkb) GetTxBlockNumber(txHash string) (uint64, error) {
+	index, client := p.Pool.Client()
+	res, err := client.GetTransaction(p.Ctx, types.HexToHash(txHash))
+	p.Pool.Report(index, err)
+	if err != nil {
+		return 0, fmt.Errorf("GetTransaction err: %s", err.Error())
+	} else if res == nil || res.TxStatus == nil || res.TxStatus.BlockHash == nil {
+		return 0, nil
+	}
+	header, err := client.GetHeader(p.Ctx, *res.TxStatus.BlockHash)
+	if err != nil {
+		return 0, fmt.Errorf("GetHeader err: %s", err.Error())
+	}
+	return header.Number, nil
+}
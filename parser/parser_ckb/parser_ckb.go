@@ -14,6 +14,7 @@ import (
 	"strconv"
 	"sync"
 	"unipay/config"
+	"unipay/nodepool"
 	"unipay/parser/parser_common"
 	"unipay/tables"
 )
@@ -21,15 +22,21 @@ import (
 var log = logger.NewLogger("parser_ckb", logger.LevelDebug)
 
 type ParserCkb struct {
-	Ctx    context.Context
-	Client rpc.Client
+	Ctx          context.Context
+	Pool         *nodepool.CkbPool
+	mempoolCache parser_common.MempoolCache
 }
 
+// Init the probes of the nodes stop with the parser
 func (p *ParserCkb) Init(pc *parser_common.ParserCore) error {
+	p.Pool.Run(pc.Ctx, pc.Wg)
 	return nil
 }
 func (p *ParserCkb) GetLatestBlockNumber() (uint64, error) {
-	if blockNumber, err := p.Client.GetTipBlockNumber(p.Ctx); err != nil {
+	index, client := p.Pool.Client()
+	blockNumber, err := client.GetTipBlockNumber(p.Ctx)
+	p.Pool.Report(index, err)
+	if err != nil {
 		return 0, fmt.Errorf("GetTipBlockNumber err: %s", err.Error())
 	} else {
 		return blockNumber, nil
@@ -39,7 +46,9 @@ func (p *ParserCkb) SingleParsing(pc *parser_common.ParserCore) error {
 	parserType, currentBlockNumber := pc.ParserType, pc.CurrentBlockNumber
 	log.Debug("SingleParsing:", parserType, currentBlockNumber)
 
-	block, err := p.Client.GetBlockByNumber(p.Ctx, currentBlockNumber)
+	index, client := p.Pool.Client()
+	block, err := client.GetBlockByNumber(p.Ctx, currentBlockNumber)
+	p.Pool.Report(index, err)
 	if err != nil {
 		return fmt.Errorf("GetBlockByNumber err: %s", err.Error())
 	}
@@ -47,6 +56,9 @@ func (p *ParserCkb) SingleParsing(pc *parser_common.ParserCore) error {
 	blockHash := block.Header.Hash.Hex()
 	parentHash := block.Header.ParentHash.Hex()
 	log.Debug("SingleParsing:", parserType, blockHash, parentHash)
+	if err := p.Pool.CheckBlockHash(index, currentBlockNumber, blockHash); err != nil {
+		return fmt.Errorf("CheckBlockHash err: %s", err.Error())
+	}
 
 	if isFork, err := pc.HandleFork(blockHash, parentHash); err != nil {
 		return fmt.Errorf("HandleFork err: %s", err.Error())
@@ -54,7 +66,7 @@ func (p *ParserCkb) SingleParsing(pc *parser_common.ParserCore) error {
 		return nil
 	}
 
-	if err := p.parsingBlockData(block, pc); err != nil {
+	if err := p.parsingBlockData(client, block, pc); err != nil {
 		return fmt.Errorf("parsingBlockData err: %s", err.Error())
 	} else {
 		if err := pc.HandleSingleParsingOK(blockHash, parentHash); err != nil {
@@ -74,11 +86,14 @@ func (p *ParserCkb) ConcurrentParsing(pc *parser_common.ParserCore) error {
 	blockLock := &sync.Mutex{}
 	blockGroup := &errgroup.Group{}
 
+	// the blocks of a round from one node
+	nodeIndex, client := p.Pool.Client()
 	for i := uint64(0); i < concurrencyNum; i++ {
 		bn := currentBlockNumber + i
 		index := i
 		blockGroup.Go(func() error {
-			block, err := p.Client.GetBlockByNumber(p.Ctx, bn)
+			block, err := client.GetBlockByNumber(p.Ctx, bn)
+			p.Pool.Report(nodeIndex, err)
 			if err != nil {
 				return fmt.Errorf("GetBlockByNumber err:%s [%d]", err.Error(), bn)
 			}
@@ -101,6 +116,10 @@ func (p *ParserCkb) ConcurrentParsing(pc *parser_common.ParserCore) error {
 	if err := blockGroup.Wait(); err != nil {
 		return fmt.Errorf("errGroup.Wait()1 err: %s", err.Error())
 	}
+	lastBlock := blockList[concurrencyNum-1]
+	if err := p.Pool.CheckBlockHash(nodeIndex, lastBlock.BlockNumber, lastBlock.BlockHash); err != nil {
+		return fmt.Errorf("CheckBlockHash err: %s", err.Error())
+	}
 
 	for i := range blocks {
 		blockCh <- blocks[i]
@@ -109,7 +128,7 @@ func (p *ParserCkb) ConcurrentParsing(pc *parser_common.ParserCore) error {
 
 	blockGroup.Go(func() error {
 		for v := range blockCh {
-			if err := p.parsingBlockData(v, pc); err != nil {
+			if err := p.parsingBlockData(client, v, pc); err != nil {
 				return fmt.Errorf("parsingBlockData err: %s", err.Error())
 			}
 		}
@@ -127,16 +146,16 @@ func (p *ParserCkb) ConcurrentParsing(pc *parser_common.ParserCore) error {
 	return nil
 }
 
-func (p *ParserCkb) parsingBlockData(block *types.Block, pc *parser_common.ParserCore) error {
+func (p *ParserCkb) parsingBlockData(client rpc.Client, block *types.Block, pc *parser_common.ParserCore) error {
 	parserType := pc.ParserType
 	if block == nil {
 		return fmt.Errorf("block is nil")
 	}
-	log.Info("parsingBlockData:", toolib.JsonString(pc.AddrMap))
+	log.Info("parsingBlockData:", toolib.JsonString(pc.GetAddrMap()))
 	for _, tx := range block.Transactions {
 		for i, v := range tx.Outputs {
 			addrArgs := common.Bytes2Hex(v.Lock.Args)
-			_, ok := pc.AddrMap[addrArgs]
+			_, ok := pc.GetAddrMap()[addrArgs]
 			if !ok {
 				continue
 			}
@@ -145,12 +164,12 @@ func (p *ParserCkb) parsingBlockData(block *types.Block, pc *parser_common.Parse
 				continue
 			}
 			log.Info("parsingBlockData:", orderId, tx.Hash.Hex())
-			txInputs, err := p.Client.GetTransaction(p.Ctx, tx.Inputs[0].PreviousOutput.TxHash)
+			txInputs, err := client.GetTransaction(p.Ctx, tx.Inputs[0].PreviousOutput.TxHash)
 			if err != nil {
 				return fmt.Errorf("GetTransaction err:%s", err.Error())
 			}
 			mode := address.Mainnet
-			if config.Cfg.Server.Net != common.DasNetTypeMainNet {
+			if config.GetCfg().Server.Net != common.DasNetTypeMainNet {
 				mode = address.Testnet
 			}
 			fromAddr, err := common.ConvertScriptToAddress(mode, txInputs.Transaction.Outputs[tx.Inputs[0].PreviousOutput.Index].Lock)
@@ -180,7 +199,7 @@ func (p *ParserCkb) parsingBlockData(block *types.Block, pc *parser_common.Parse
 				continue
 			}
 			// change the status to confirm
-			if err = pc.DoPayment(order, tx.Hash.Hex(), fromAddr, pc.ParserType.ToAlgorithmId()); err != nil {
+			if err = pc.DoPayment(order, tx.Hash.Hex(), fromAddr, pc.ParserType.ToAlgorithmId(), block.Header.Number, block.Header.Hash.Hex()); err != nil {
 				return fmt.Errorf("pc.DoPayment err: %s", err.Error())
 			}
 			break
@@ -0,0 +1,42 @@
+package parser_ckb
+
+import (
+	"fmt"
+	"github.com/nervosnetwork/ckb-sdk-go/rpc"
+	"github.com/nervosnetwork/ckb-sdk-go/types"
+	"unipay/parser/parser_common"
+)
+
+// fetchedBlock the inputs of the block are parsed from the node it came from
+type fetchedBlock struct {
+	client rpc.Client
+	block  *types.Block
+}
+
+func (p *ParserCkb) FetchBlock(pc *parser_common.ParserCore, blockNumber uint64, check bool) (*parser_common.Block, error) {
+	index, client := p.Pool.Client()
+	block, err := client.GetBlockByNumber(p.Ctx, blockNumber)
+	p.Pool.Report(index, err)
+	if err != nil {
+		return nil, fmt.Errorf("GetBlockByNumber err: %s [%d]", err.Error(), blockNumber)
+	} else if block == nil || block.Header == nil {
+		return nil, fmt.Errorf("GetBlockByNumber data is nil: [%d]", blockNumber)
+	}
+	blockHash := block.Header.Hash.Hex()
+	if check {
+		if err := p.Pool.CheckBlockHash(index, blockNumber, blockHash); err != nil {
+			return nil, fmt.Errorf("CheckBlockHash err: %s", err.Error())
+		}
+	}
+	return &parser_common.Block{
+		BlockNumber: blockNumber,
+		BlockHash:   blockHash,
+		ParentHash:  block.Header.ParentHash.Hex(),
+		Data:        fetchedBlock{client: client, block: block},
+	}, nil
+}
+
+func (p *ParserCkb) ParseBlock(pc *parser_common.ParserCore, block *parser_common.Block) error {
+	data := block.Data.(fetchedBlock)
+	return p.parsingBlockData(data.client, data.block, pc)
+}
@@ -0,0 +1,215 @@
+package parser_common
+
+import (
+	"fmt"
+	"github.com/shopspring/decimal"
+	"time"
+	"unipay/chainswitch"
+	"unipay/eventbus"
+	"unipay/tables"
+)
+
+// MempoolTx a transfer to one of the AddrMap which the parser has not reached yet
+type MempoolTx struct {
+	TxHash      string
+	FromAddr    string
+	ReceiptAddr string
+	OrderId     string              // from the memo, empty to match the order by the amount
+	PayTokenIds []tables.PayTokenId // the accepted pay token of the order, the first one to match by the amount
+	Amount      decimal.Decimal
+}
+
+// MempoolApi the optional api of a ParserApi, for the MempoolWatcher
+type MempoolApi interface {
+	GetMempoolTxList(*ParserCore) ([]MempoolTx, error)
+	// GetTxBlockNumber 0 if the tx is not in a block yet
+	GetTxBlockNumber(txHash string) (uint64, error)
+}
+
+const (
+	mempoolInterval      = time.Second * 10
+	mempoolPendingLimit  = 200
+	mempoolDropAfter     = time.Hour * 3
+	mempoolCheckMaxCount = 500
+)
+
+// MempoolWatcher records the matched txs of the mempool and publishes the seen and confirming events,
+// the order is only credited by the parser at the confirm num, the caller adds PC.Wg like the Parser
+func (p *ParserCommon) MempoolWatcher() {
+	defer p.PC.Wg.Done()
+	api, ok := p.PA.(MempoolApi)
+	if !ok || !p.PC.Mempool {
+		return
+	}
+	parserType := p.PC.ParserType
+	ticker := time.NewTicker(mempoolInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if chainswitch.IsParsePaused(parserType) {
+				continue
+			}
+			if err := p.doMempoolTxList(api); err != nil {
+				log.Error("doMempoolTxList err:", parserType, err.Error())
+			}
+			if err := p.doPendingTxConfirmations(api); err != nil {
+				log.Error("doPendingTxConfirmations err:", parserType, err.Error())
+			}
+		case <-p.PC.Ctx.Done():
+			log.Warn("MempoolWatcher done", parserType)
+			return
+		}
+	}
+}
+
+func (p *ParserCommon) doMempoolTxList(api MempoolApi) error {
+	list, err := api.GetMempoolTxList(p.PC)
+	if err != nil {
+		return fmt.Errorf("GetMempoolTxList err: %s", err.Error())
+	}
+	for _, v := range list {
+		order, err := p.PC.getMempoolTxOrder(v)
+		if err != nil {
+			return fmt.Errorf("getMempoolTxOrder err: %s", err.Error())
+		} else if order.Id == 0 {
+			continue
+		}
+		ok, err := p.PC.DbDao.CreatePendingTx(tables.TablePendingTxInfo{
+			PayHash:    v.TxHash,
+			OrderId:    order.OrderId,
+			ParserType: p.PC.ParserType,
+			PayAddress: v.FromAddr,
+			Amount:     v.Amount,
+			PayTokenId: order.PayTokenId,
+			ConfirmNum: p.PC.ConfirmNum,
+			Status:     tables.PendingTxStatusSeen,
+			Timestamp:  time.Now().UnixMilli(),
+		})
+		if err != nil {
+			return fmt.Errorf("CreatePendingTx err: %s", err.Error())
+		} else if !ok {
+			continue
+		}
+		log.Info("doMempoolTxList:", p.PC.ParserType, order.OrderId, v.TxHash)
+		eventbus.Publish(eventbus.OrderEvent{
+			OrderId:    order.OrderId,
+			Status:     eventbus.OrderStatusSeen,
+			PayHash:    v.TxHash,
+			ConfirmNum: p.PC.ConfirmNum,
+		})
+	}
+	return nil
+}
+
+// getMempoolTxOrder matches the order the same way as the parsers, only an unpaid order
+func (p *ParserCore) getMempoolTxOrder(tx MempoolTx) (order tables.TableOrderInfo, err error) {
+	if len(tx.PayTokenIds) == 0 {
+		return
+	}
+	if tx.OrderId != "" {
+		order, err = p.DbDao.GetOrderInfoByOrderIdWithAddr(tx.OrderId, tx.ReceiptAddr)
+		if err != nil {
+			return order, fmt.Errorf("GetOrderInfoByOrderIdWithAddr err: %s", err.Error())
+		}
+	} else {
+		order, err = p.GetOrderByAmount(tx.FromAddr, tx.ReceiptAddr, tx.PayTokenIds[0], tx.Amount)
+		if err != nil {
+			return order, fmt.Errorf("GetOrderByAmount err: %s", err.Error())
+		}
+	}
+	tokenOk := false
+	for _, v := range tx.PayTokenIds {
+		if order.PayTokenId == v {
+			tokenOk = true
+		}
+	}
+	if order.Id == 0 || !tokenOk || tx.Amount.Cmp(order.Amount) == -1 ||
+		order.PayStatus != tables.PayStatusUnpaid || order.OrderStatus != tables.OrderStatusNormal || order.IsExpired() {
+		return tables.TableOrderInfo{}, nil
+	}
+	return order, nil
+}
+
+func (p *ParserCommon) doPendingTxConfirmations(api MempoolApi) error {
+	list, err := p.PC.DbDao.GetPendingTxList(p.PC.ParserType, tables.PendingTxStatusSeen, mempoolPendingLimit)
+	if err != nil {
+		return fmt.Errorf("GetPendingTxList err: %s", err.Error())
+	} else if len(list) == 0 {
+		return nil
+	}
+	latestBlockNumber, err := p.PA.GetLatestBlockNumber()
+	if err != nil {
+		return fmt.Errorf("GetLatestBlockNumber err: %s", err.Error())
+	}
+	for _, v := range list {
+		// the parser did not credit it in time, e.g. it was replaced or never mined
+		if time.Since(time.UnixMilli(v.Timestamp)) > mempoolDropAfter {
+			log.Warn("doPendingTxConfirmations dropped:", p.PC.ParserType, v.OrderId, v.PayHash)
+			if err := p.PC.DbDao.UpdatePendingTxStatus(v.PayHash, tables.PendingTxStatusSeen, tables.PendingTxStatusDropped); err != nil {
+				return fmt.Errorf("UpdatePendingTxStatus err: %s", err.Error())
+			}
+			eventbus.Publish(eventbus.OrderEvent{
+				OrderId: v.OrderId,
+				Status:  eventbus.OrderStatusPending,
+			})
+			continue
+		}
+		blockNumber, err := api.GetTxBlockNumber(v.PayHash)
+		if err != nil {
+			log.Error("GetTxBlockNumber err:", p.PC.ParserType, v.PayHash, err.Error())
+			continue
+		}
+		confirmations := uint64(0)
+		if blockNumber > 0 && latestBlockNumber >= blockNumber {
+			confirmations = latestBlockNumber - blockNumber + 1
+		}
+		if blockNumber == v.BlockNumber && confirmations == v.Confirmations {
+			continue
+		}
+		if err := p.PC.DbDao.UpdatePendingTxConfirmations(v.PayHash, blockNumber, confirmations); err != nil {
+			return fmt.Errorf("UpdatePendingTxConfirmations err: %s", err.Error())
+		}
+		event := eventbus.OrderEvent{
+			OrderId:       v.OrderId,
+			Status:        eventbus.OrderStatusConfirming,
+			PayHash:       v.PayHash,
+			Confirmations: confirmations,
+			ConfirmNum:    p.PC.ConfirmNum,
+		}
+		if blockNumber == 0 {
+			// back to the mempool by a fork
+			event.Status = eventbus.OrderStatusSeen
+		}
+		eventbus.Publish(event)
+	}
+	return nil
+}
+
+// MempoolCache the tx hashes already checked, for the chains without the full txs in the mempool api
+type MempoolCache struct {
+	checked map[string]struct{}
+}
+
+// Filter the hashes not checked yet, at most mempoolCheckMaxCount a round, the ones out of the mempool are forgotten
+func (m *MempoolCache) Filter(hashList []string) []string {
+	checked := make(map[string]struct{})
+	var list []string
+	for _, v := range hashList {
+		if _, ok := m.checked[v]; ok {
+			checked[v] = struct{}{}
+		} else if len(list) < mempoolCheckMaxCount {
+			list = append(list, v)
+		}
+	}
+	m.checked = checked
+	return list
+}
+
+func (m *MempoolCache) Add(hash string) {
+	if m.checked == nil {
+		m.checked = make(map[string]struct{})
+	}
+	m.checked[hash] = struct{}{}
+}
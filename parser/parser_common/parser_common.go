@@ -6,7 +6,10 @@ import (
 	"strings"
 	"sync/atomic"
 	"time"
+	"unipay/chainswitch"
+	"unipay/health"
 	"unipay/notify"
+	"unipay/txtool"
 )
 
 var log = logger.NewLogger("parser_common", logger.LevelDebug)
@@ -38,7 +41,12 @@ func (p *ParserCommon) initCurrentBlockNumber() error {
 	return nil
 }
 
+// parsePausedInterval the delay to resume after the chain switch
+const parsePausedInterval = time.Second * 10
+
+// Parser the caller adds PC.Wg before it starts, so the stop of a parser can wait for it
 func (p *ParserCommon) Parser() {
+	defer p.PC.Wg.Done()
 	if err := p.PA.Init(p.PC); err != nil {
 		log.Error("Parser Init err: %s", err.Error())
 		return
@@ -52,11 +60,28 @@ func (p *ParserCommon) Parser() {
 	confirmNum := p.PC.ConfirmNum
 
 	atomic.AddUint64(&p.PC.CurrentBlockNumber, 1)
-	p.PC.Wg.Add(1)
+	health.ReportStart(parserType, confirmNum)
+	if api, ok := p.PA.(PipelineApi); ok && p.PC.PrefetchNum > 0 {
+		p.pipelineParser(api)
+		return
+	}
 	for {
 		select {
 		default:
+			if chainswitch.IsParsePaused(parserType) {
+				health.ReportPaused(parserType)
+				log.Debug("Parser paused:", parserType, p.PC.CurrentBlockNumber)
+				time.Sleep(parsePausedInterval)
+				continue
+			}
 			latestBlockNumber, err := p.PA.GetLatestBlockNumber()
+			if err == nil {
+				p.setBlockMetrics(latestBlockNumber)
+				health.ReportHead(parserType, atomic.LoadUint64(&p.PC.CurrentBlockNumber), latestBlockNumber, confirmNum)
+			} else {
+				health.ReportNodeErr(parserType, err)
+			}
+			beginBlockNumber := p.PC.CurrentBlockNumber
 			if err != nil {
 				log.Error("GetLatestBlockNumber err: ", err.Error())
 				time.Sleep(time.Second * 30)
@@ -65,25 +90,19 @@ func (p *ParserCommon) Parser() {
 				nowTime := time.Now()
 				if err := p.PA.ConcurrentParsing(p.PC); err != nil {
 					log.Error("ConcurrentParsing err:", parserType, err.Error(), p.PC.CurrentBlockNumber)
-					if !strings.Contains(err.Error(), "data is nil") &&
-						!strings.Contains(err.Error(), "HTTP status code received from server: 503") &&
-						!strings.Contains(err.Error(), "BlockHeader is nil") {
-						notify.SendLarkErrNotify(fmt.Sprintf("Parser %d", parserType), err.Error())
-					}
+					p.sendParsingAlert(err)
 				}
 				log.Debug("ConcurrentParsing time:", parserType, time.Since(nowTime).Seconds())
+				p.addParsedBlockMetrics(beginBlockNumber, nowTime)
 				time.Sleep(time.Second * 5)
 			} else if p.PC.CurrentBlockNumber < (latestBlockNumber - confirmNum) {
 				nowTime := time.Now()
 				if err := p.PA.SingleParsing(p.PC); err != nil {
 					log.Error("SingleParsing err:", parserType, err.Error(), p.PC.CurrentBlockNumber)
-					if !strings.Contains(err.Error(), "data is nil") &&
-						!strings.Contains(err.Error(), "HTTP status code received from server: 503") &&
-						!strings.Contains(err.Error(), "BlockHeader is nil") {
-						notify.SendLarkErrNotify(fmt.Sprintf("Parser %d", parserType), err.Error())
-					}
+					p.sendParsingAlert(err)
 				}
 				log.Debug("Parsing time:", parserType, time.Since(nowTime).Seconds())
+				p.addParsedBlockMetrics(beginBlockNumber, nowTime)
 				time.Sleep(time.Second * 30)
 			} else {
 				log.Debug("Parser:", parserType, p.PC.CurrentBlockNumber, latestBlockNumber)
@@ -91,8 +110,42 @@ func (p *ParserCommon) Parser() {
 			}
 		case <-p.PC.Ctx.Done():
 			log.Warn("Parser done", parserType)
-			p.PC.Wg.Done()
 			return
 		}
 	}
 }
+
+// sendParsingAlert not for the blocks the node does not have yet
+func (p *ParserCommon) sendParsingAlert(err error) {
+	if !strings.Contains(err.Error(), "data is nil") &&
+		!strings.Contains(err.Error(), "HTTP status code received from server: 503") &&
+		!strings.Contains(err.Error(), "BlockHeader is nil") {
+		notify.SendAlert(notify.SeverityError, notify.CategoryParser, fmt.Sprintf("Parser %d", p.PC.ParserType), err.Error())
+	}
+}
+
+func (p *ParserCommon) setBlockMetrics(latestBlockNumber uint64) {
+	parserType := p.PC.ParserType.ToString()
+	currentBlockNumber := atomic.LoadUint64(&p.PC.CurrentBlockNumber)
+	lag := float64(0)
+	if latestBlockNumber > currentBlockNumber {
+		lag = float64(latestBlockNumber - currentBlockNumber)
+	}
+	txtool.Tools.Metrics.ParserCurrentBlock().WithLabelValues(parserType).Set(float64(currentBlockNumber))
+	txtool.Tools.Metrics.ParserChainHead().WithLabelValues(parserType).Set(float64(latestBlockNumber))
+	txtool.Tools.Metrics.ParserLag().WithLabelValues(parserType).Set(lag)
+}
+
+// addParsedBlockMetrics a fork rolls the current block back, that round counts nothing
+func (p *ParserCommon) addParsedBlockMetrics(beginBlockNumber uint64, beginTime time.Time) {
+	currentBlockNumber := atomic.LoadUint64(&p.PC.CurrentBlockNumber)
+	if currentBlockNumber <= beginBlockNumber {
+		return
+	}
+	parserType := p.PC.ParserType.ToString()
+	blocks := float64(currentBlockNumber - beginBlockNumber)
+	txtool.Tools.Metrics.ParserBlocks().WithLabelValues(parserType).Add(blocks)
+	if seconds := time.Since(beginTime).Seconds(); seconds > 0 {
+		txtool.Tools.Metrics.ParserBlocksPerSecond().WithLabelValues(parserType).Set(blocks / seconds)
+	}
+}
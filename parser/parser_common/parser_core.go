@@ -8,9 +8,11 @@ import (
 	"sync"
 	"sync/atomic"
 	"time"
+	"unipay/config"
 	"unipay/dao"
 	"unipay/notify"
 	"unipay/tables"
+	"unipay/txtool"
 )
 
 type ParserCore struct {
@@ -25,8 +27,43 @@ type ParserCore struct {
 	CurrentBlockNumber uint64
 	ConcurrencyNum     uint64
 	ConfirmNum         uint64
+	PrefetchNum        uint64        // the blocks fetched ahead by the pipeline if the ParserApi is a PipelineApi, 0 for the rounds
+	PollInterval       time.Duration // of the pipeline, a new head of a HeadApi wakes it earlier
 	Switch             bool
+	Mempool            bool // run the MempoolWatcher if the ParserApi is a MempoolApi
 	AddrMap            map[string]string
+
+	addrLock sync.RWMutex
+	blockDao blockDao // the DbDao if nil
+}
+
+// blockDao the block records of the fork check
+type blockDao interface {
+	FindBlockInfoByBlockNumber(parserType tables.ParserType, blockNumber uint64) (tables.TableBlockParserInfo, error)
+	DeleteBlockInfoByBlockNumber(parserType tables.ParserType, blockNumber uint64) error
+	CreateBlockInfo(blockInfo tables.TableBlockParserInfo) error
+	CreateBlockInfoList(list []tables.TableBlockParserInfo) error
+	DeleteBlockInfo(parserType tables.ParserType, blockNumber uint64) error
+}
+
+func (p *ParserCore) getBlockDao() blockDao {
+	if p.blockDao != nil {
+		return p.blockDao
+	}
+	return p.DbDao
+}
+
+// GetAddrMap the receiving addresses, replaced by the config reload while the parser runs
+func (p *ParserCore) GetAddrMap() map[string]string {
+	p.addrLock.RLock()
+	defer p.addrLock.RUnlock()
+	return p.AddrMap
+}
+
+func (p *ParserCore) SetAddrMap(addrMap map[string]string) {
+	p.addrLock.Lock()
+	defer p.addrLock.Unlock()
+	p.AddrMap = addrMap
 }
 
 func (p *ParserCore) CreatePaymentForMismatch(orderId, payHash, payAddress string, amount decimal.Decimal, payTokenId tables.PayTokenId) {
@@ -46,7 +83,8 @@ func (p *ParserCore) CreatePaymentForMismatch(orderId, payHash, payAddress strin
 	}
 }
 
-func (p *ParserCore) DoPayment(order tables.TableOrderInfo, txId, fromHex string, algorithmId common.DasAlgorithmId) error {
+// DoPayment blockNumber and blockHash of the tx go to the callback, the hash is empty if the chain has none
+func (p *ParserCore) DoPayment(order tables.TableOrderInfo, txId, fromHex string, algorithmId common.DasAlgorithmId, blockNumber uint64, blockHash string) error {
 	paymentInfo := tables.TablePaymentInfo{
 		PayHash:       txId,
 		OrderId:       order.OrderId,
@@ -57,6 +95,22 @@ func (p *ParserCore) DoPayment(order tables.TableOrderInfo, txId, fromHex string
 		PayTokenId:    order.PayTokenId,
 		PayHashStatus: tables.PayHashStatusConfirm,
 		RefundStatus:  tables.RefundStatusDefault,
+		BlockNumber:   blockNumber,
+		BlockHash:     blockHash,
+		Confirmations: p.ConfirmNum,
+	}
+	if p.Mempool {
+		if err := p.DbDao.UpdatePendingTxStatus(txId, tables.PendingTxStatusSeen, tables.PendingTxStatusCredited); err != nil {
+			log.Error("UpdatePendingTxStatus err:", p.ParserType, txId, err.Error())
+		}
+	}
+	if order.OrderStatus == tables.OrderStatusCancel || (order.ExpiredAt > 0 && order.IsExpired()) {
+		// late payment of a cancelled order or after the ttl of the business, refund it
+		log.Warn("DoPayment order cancelled or expired:", p.ParserType, order.OrderId, order.OrderStatus, txId)
+		if err := p.CN.HandleLatePayment(paymentInfo, order); err != nil {
+			return fmt.Errorf("HandleLatePayment err: %s", err.Error())
+		}
+		return nil
 	}
 	if err := p.CN.HandlePayment(paymentInfo, order); err != nil {
 		return fmt.Errorf("HandlePayment err: %s", err.Error())
@@ -64,14 +118,36 @@ func (p *ParserCore) DoPayment(order tables.TableOrderInfo, txId, fromHex string
 	return nil
 }
 
+// GetOrderByAmount matches a memo-less transfer by sender and amount first,
+// then by the unique amount slot when the unique amount mode is on for the token
+func (p *ParserCore) GetOrderByAmount(fromAddr, receiptAddr string, payTokenId tables.PayTokenId, amount decimal.Decimal) (tables.TableOrderInfo, error) {
+	order, err := p.DbDao.GetOrderByAddrWithAmountAndAddr(fromAddr, receiptAddr, payTokenId, amount)
+	if err != nil {
+		return order, fmt.Errorf("GetOrderByAddrWithAmountAndAddr err: %s", err.Error())
+	} else if order.Id > 0 {
+		return order, nil
+	}
+	if _, ok := config.GetUniqueAmountToken(payTokenId); !ok {
+		return order, nil
+	}
+	order, err = p.DbDao.GetOrderByUniqueAmount(receiptAddr, payTokenId, amount)
+	if err != nil {
+		return order, fmt.Errorf("GetOrderByUniqueAmount err: %s", err.Error())
+	} else if order.Id > 0 {
+		log.Info("GetOrderByUniqueAmount:", p.ParserType, order.OrderId, fromAddr, amount.String())
+	}
+	return order, nil
+}
+
 func (p *ParserCore) HandleFork(blockHash, parentHash string) (bool, error) {
-	block, err := p.DbDao.FindBlockInfoByBlockNumber(p.ParserType, p.CurrentBlockNumber-1)
+	block, err := p.getBlockDao().FindBlockInfoByBlockNumber(p.ParserType, p.CurrentBlockNumber-1)
 	if err != nil {
 		return false, err
 	}
 	if block.Id > 0 && block.BlockHash != parentHash {
 		log.Warn("DoCheckFork is true:", p.ParserType, p.CurrentBlockNumber, blockHash, parentHash, block.BlockHash)
-		if err := p.DbDao.DeleteBlockInfoByBlockNumber(p.ParserType, p.CurrentBlockNumber-1); err != nil {
+		txtool.Tools.Metrics.ParserFork().WithLabelValues(p.ParserType.ToString()).Inc()
+		if err := p.getBlockDao().DeleteBlockInfoByBlockNumber(p.ParserType, p.CurrentBlockNumber-1); err != nil {
 			return false, fmt.Errorf("DeleteBlockInfoByBlockNumber err: %s", err.Error())
 		}
 		atomic.AddUint64(&p.CurrentBlockNumber, ^uint64(0))
@@ -87,24 +163,24 @@ func (p *ParserCore) HandleSingleParsingOK(blockHash, parentHash string) error {
 		BlockHash:   blockHash,
 		ParentHash:  parentHash,
 	}
-	if err := p.DbDao.CreateBlockInfo(blockInfo); err != nil {
+	if err := p.getBlockDao().CreateBlockInfo(blockInfo); err != nil {
 		return fmt.Errorf("CreateBlockInfo err: %s", err.Error())
 	} else {
 		atomic.AddUint64(&p.CurrentBlockNumber, 1)
 	}
-	if err := p.DbDao.DeleteBlockInfo(p.ParserType, p.CurrentBlockNumber-20); err != nil {
+	if err := p.getBlockDao().DeleteBlockInfo(p.ParserType, p.CurrentBlockNumber-20); err != nil {
 		log.Error("DeleteBlockInfo1 err:", p.ParserType, err.Error(), p.CurrentBlockNumber)
 	}
 	return nil
 }
 
 func (p *ParserCore) HandleConcurrentParsingOK(blockList []tables.TableBlockParserInfo) error {
-	if err := p.DbDao.CreateBlockInfoList(blockList); err != nil {
+	if err := p.getBlockDao().CreateBlockInfoList(blockList); err != nil {
 		return fmt.Errorf("CreateBlockInfoList err:%s", err.Error())
 	} else {
 		atomic.AddUint64(&p.CurrentBlockNumber, p.ConcurrencyNum)
 	}
-	if err := p.DbDao.DeleteBlockInfo(p.ParserType, p.CurrentBlockNumber-20); err != nil {
+	if err := p.getBlockDao().DeleteBlockInfo(p.ParserType, p.CurrentBlockNumber-20); err != nil {
 		log.Error("DeleteBlockInfo2 err:", p.ParserType, err.Error(), p.CurrentBlockNumber)
 	}
 	return nil
@@ -0,0 +1,215 @@
+package parser_common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unipay/chainswitch"
+	"unipay/health"
+)
+
+// Block a block fetched ahead by the pipeline, Data is the block of the chain for its ParseBlock
+type Block struct {
+	BlockNumber uint64
+	BlockHash   string
+	ParentHash  string
+	Data        interface{}
+}
+
+// PipelineApi the optional api of a ParserApi, the Parser fetches up to PrefetchNum blocks ahead
+// while the earlier ones are parsed, and commits the blocks one by one in order
+type PipelineApi interface {
+	// FetchBlock is called concurrently, check for the block hash agreement of the nodes
+	FetchBlock(pc *ParserCore, blockNumber uint64, check bool) (*Block, error)
+	ParseBlock(pc *ParserCore, block *Block) error
+}
+
+// HeadApi the optional api of a ParserApi, a new head wakes the pipeline before its PollInterval
+type HeadApi interface {
+	// SubscribeHead blocks until the subscription fails or the ctx is done,
+	// ErrHeadUnsupported if the chain has no subscription configured
+	SubscribeHead(ctx context.Context, onHead func(blockNumber uint64)) error
+}
+
+var ErrHeadUnsupported = errors.New("head subscription unsupported")
+
+const (
+	defaultPollInterval = time.Second * 30
+	headRetryInterval   = time.Second * 10
+	// pipelineMaxBlocks of a run, the head and the health are reported between the runs of a catch-up
+	pipelineMaxBlocks = 500
+)
+
+// isParsePaused replaced by the tests
+var isParsePaused = chainswitch.IsParsePaused
+
+type fetchResult struct {
+	block *Block
+	err   error
+	done  chan struct{}
+}
+
+// pipelineParser the loop of the Parser for a PipelineApi, it parses all the blocks under the confirm num on every wake up
+func (p *ParserCommon) pipelineParser(api PipelineApi) {
+	parserType, confirmNum := p.PC.ParserType, p.PC.ConfirmNum
+	pollInterval := p.PC.PollInterval
+	if pollInterval <= 0 {
+		pollInterval = defaultPollInterval
+	}
+	wake := make(chan struct{}, 1)
+	if headApi, ok := p.PA.(HeadApi); ok {
+		p.PC.Wg.Add(1)
+		go p.headWatcher(headApi, wake)
+	}
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		wait := true
+		if isParsePaused(parserType) {
+			health.ReportPaused(parserType)
+			log.Debug("Parser paused:", parserType, p.PC.CurrentBlockNumber)
+		} else if latestBlockNumber, err := p.PA.GetLatestBlockNumber(); err != nil {
+			health.ReportNodeErr(parserType, err)
+			log.Error("GetLatestBlockNumber err: ", err.Error())
+		} else {
+			p.setBlockMetrics(latestBlockNumber)
+			health.ReportHead(parserType, atomic.LoadUint64(&p.PC.CurrentBlockNumber), latestBlockNumber, confirmNum)
+			if latestBlockNumber > confirmNum && p.PC.CurrentBlockNumber < latestBlockNumber-confirmNum {
+				beginBlockNumber, nowTime := p.PC.CurrentBlockNumber, time.Now()
+				err := p.pipelineParsing(api, latestBlockNumber-confirmNum)
+				log.Debug("pipelineParsing time:", parserType, beginBlockNumber, p.PC.CurrentBlockNumber, time.Since(nowTime).Seconds())
+				p.addParsedBlockMetrics(beginBlockNumber, nowTime)
+				if err != nil {
+					log.Error("pipelineParsing err:", parserType, err.Error(), p.PC.CurrentBlockNumber)
+					p.sendParsingAlert(err)
+				} else {
+					// the head moved on while the blocks were parsed
+					wait = false
+				}
+			} else {
+				log.Debug("Parser:", parserType, p.PC.CurrentBlockNumber, latestBlockNumber)
+			}
+		}
+		if !wait && p.PC.Ctx.Err() == nil {
+			continue
+		}
+		select {
+		case <-wake:
+		case <-ticker.C:
+		case <-p.PC.Ctx.Done():
+			log.Warn("Parser done", parserType)
+			return
+		}
+	}
+}
+
+// headWatcher subscribes again after a failure, the poll interval covers the meantime
+func (p *ParserCommon) headWatcher(api HeadApi, wake chan struct{}) {
+	defer p.PC.Wg.Done()
+	parserType := p.PC.ParserType
+	onHead := func(blockNumber uint64) {
+		log.Debug("headWatcher:", parserType, blockNumber)
+		select {
+		case wake <- struct{}{}:
+		default:
+		}
+	}
+	for {
+		err := api.SubscribeHead(p.PC.Ctx, onHead)
+		if err == ErrHeadUnsupported {
+			return
+		} else if err != nil && p.PC.Ctx.Err() == nil {
+			log.Warn("SubscribeHead err:", parserType, err.Error())
+		}
+		select {
+		case <-time.After(headRetryInterval):
+		case <-p.PC.Ctx.Done():
+			log.Warn("headWatcher done", parserType)
+			return
+		}
+	}
+}
+
+// pipelineParsing fetches the blocks before endBlockNumber PrefetchNum ahead, and parses and commits them in order,
+// it returns at a fork once HandleFork has rolled the current block back
+func (p *ParserCommon) pipelineParsing(api PipelineApi, endBlockNumber uint64) error {
+	pc := p.PC
+	beginBlockNumber := pc.CurrentBlockNumber
+	if endBlockNumber > beginBlockNumber+pipelineMaxBlocks {
+		endBlockNumber = beginBlockNumber + pipelineMaxBlocks
+	}
+	// the agreement of the nodes every ConcurrencyNum blocks and at the last one, like the rounds
+	checkNum := pc.ConcurrencyNum
+	if checkNum == 0 {
+		checkNum = 1
+	}
+
+	ctx, cancel := context.WithCancel(pc.Ctx)
+	fetchWg := &sync.WaitGroup{}
+	defer func() {
+		cancel()
+		fetchWg.Wait()
+	}()
+
+	// a fetch starts once its result is queued, so at most PrefetchNum blocks wait for the parsing
+	resultCh := make(chan *fetchResult, pc.PrefetchNum)
+	fetchWg.Add(1)
+	go func() {
+		defer fetchWg.Done()
+		defer close(resultCh)
+		for bn := beginBlockNumber; bn < endBlockNumber; bn++ {
+			r := &fetchResult{done: make(chan struct{})}
+			select {
+			case resultCh <- r:
+			case <-ctx.Done():
+				return
+			}
+			check := bn == endBlockNumber-1 || (bn-beginBlockNumber+1)%checkNum == 0
+			fetchWg.Add(1)
+			go func(blockNumber uint64) {
+				defer fetchWg.Done()
+				defer close(r.done)
+				r.block, r.err = api.FetchBlock(pc, blockNumber, check)
+			}(bn)
+		}
+	}()
+
+	prevHash := ""
+	for r := range resultCh {
+		select {
+		case <-r.done:
+		case <-ctx.Done():
+			return nil
+		}
+		// the blocks fetched already are left for the next run
+		if ctx.Err() != nil || isParsePaused(pc.ParserType) {
+			return nil
+		} else if r.err != nil {
+			return fmt.Errorf("FetchBlock err: %s", r.err.Error())
+		}
+		block := r.block
+		if block.BlockNumber != pc.CurrentBlockNumber {
+			return fmt.Errorf("block %d out of order, current %d", block.BlockNumber, pc.CurrentBlockNumber)
+		}
+		// the parent of a prefetched block is the block committed right before it, only a mismatch needs the db
+		if prevHash == "" || block.ParentHash != prevHash {
+			if isFork, err := pc.HandleFork(block.BlockHash, block.ParentHash); err != nil {
+				return fmt.Errorf("HandleFork err: %s", err.Error())
+			} else if isFork {
+				return nil
+			}
+		}
+		if err := api.ParseBlock(pc, block); err != nil {
+			return fmt.Errorf("ParseBlock err: %s [%d]", err.Error(), block.BlockNumber)
+		}
+		if err := pc.HandleSingleParsingOK(block.BlockHash, block.ParentHash); err != nil {
+			return fmt.Errorf("HandleSingleParsingOK err: %s", err.Error())
+		}
+		prevHash = block.BlockHash
+	}
+	return nil
+}
@@ -0,0 +1,304 @@
+package parser_common
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"unipay/tables"
+	"unipay/txtool"
+)
+
+func TestMain(m *testing.M) {
+	txtool.Init()
+	os.Exit(m.Run())
+}
+
+func getTestHash(blockNumber uint64) string {
+	return fmt.Sprintf("0x%064x", blockNumber)
+}
+
+// testBlockDao the block records in memory
+type testBlockDao struct {
+	lock      sync.Mutex
+	blocks    map[uint64]tables.TableBlockParserInfo
+	findCount int
+	deleted   []uint64
+}
+
+func newTestBlockDao(currentBlockNumber uint64) *testBlockDao {
+	d := &testBlockDao{blocks: make(map[uint64]tables.TableBlockParserInfo)}
+	_ = d.CreateBlockInfo(tables.TableBlockParserInfo{
+		BlockNumber: currentBlockNumber - 1,
+		BlockHash:   getTestHash(currentBlockNumber - 1),
+		ParentHash:  getTestHash(currentBlockNumber - 2),
+	})
+	return d
+}
+
+func (d *testBlockDao) FindBlockInfoByBlockNumber(parserType tables.ParserType, blockNumber uint64) (tables.TableBlockParserInfo, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.findCount++
+	return d.blocks[blockNumber], nil
+}
+
+func (d *testBlockDao) DeleteBlockInfoByBlockNumber(parserType tables.ParserType, blockNumber uint64) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	delete(d.blocks, blockNumber)
+	d.deleted = append(d.deleted, blockNumber)
+	return nil
+}
+
+func (d *testBlockDao) CreateBlockInfo(blockInfo tables.TableBlockParserInfo) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	blockInfo.Id = blockInfo.BlockNumber + 1
+	d.blocks[blockInfo.BlockNumber] = blockInfo
+	return nil
+}
+
+func (d *testBlockDao) CreateBlockInfoList(list []tables.TableBlockParserInfo) error {
+	for _, v := range list {
+		_ = d.CreateBlockInfo(v)
+	}
+	return nil
+}
+
+func (d *testBlockDao) DeleteBlockInfo(parserType tables.ParserType, blockNumber uint64) error {
+	return nil
+}
+
+// testPipelineApi the blocks of a chain without forks unless changed by the test
+type testPipelineApi struct {
+	lock       sync.Mutex
+	parentMap  map[uint64]string // the parent hash of a block on another branch
+	errMap     map[uint64]error
+	checkList  []uint64
+	parsedList []uint64
+	maxAhead   uint64
+	parseDelay time.Duration
+	onParse    func(blockNumber uint64)
+}
+
+func (a *testPipelineApi) FetchBlock(pc *ParserCore, blockNumber uint64, check bool) (*Block, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if ahead := blockNumber - atomic.LoadUint64(&pc.CurrentBlockNumber); ahead > a.maxAhead {
+		a.maxAhead = ahead
+	}
+	if check {
+		a.checkList = append(a.checkList, blockNumber)
+	}
+	if err := a.errMap[blockNumber]; err != nil {
+		return nil, err
+	}
+	parentHash := getTestHash(blockNumber - 1)
+	if v, ok := a.parentMap[blockNumber]; ok {
+		parentHash = v
+	}
+	return &Block{BlockNumber: blockNumber, BlockHash: getTestHash(blockNumber), ParentHash: parentHash}, nil
+}
+
+func (a *testPipelineApi) ParseBlock(pc *ParserCore, block *Block) error {
+	time.Sleep(a.parseDelay)
+	a.lock.Lock()
+	a.parsedList = append(a.parsedList, block.BlockNumber)
+	a.lock.Unlock()
+	if a.onParse != nil {
+		a.onParse(block.BlockNumber)
+	}
+	return nil
+}
+
+func newTestParser(ctx context.Context, dao *testBlockDao, currentBlockNumber uint64) *ParserCommon {
+	return &ParserCommon{PC: &ParserCore{
+		Ctx:                ctx,
+		ParserType:         tables.ParserTypeETH,
+		CurrentBlockNumber: currentBlockNumber,
+		ConcurrencyNum:     4,
+		PrefetchNum:        3,
+		blockDao:           dao,
+	}}
+}
+
+func getBlockNumberList(begin, end uint64) []uint64 {
+	var list []uint64
+	for i := begin; i < end; i++ {
+		list = append(list, i)
+	}
+	return list
+}
+
+func TestPipelineParsing(t *testing.T) {
+	dao := newTestBlockDao(100)
+	p := newTestParser(context.Background(), dao, 100)
+	api := &testPipelineApi{}
+
+	if err := p.pipelineParsing(api, 110); err != nil {
+		t.Fatal(err)
+	}
+	if p.PC.CurrentBlockNumber != 110 {
+		t.Fatal("current:", p.PC.CurrentBlockNumber)
+	}
+	if fmt.Sprint(api.parsedList) != fmt.Sprint(getBlockNumberList(100, 110)) {
+		t.Fatal("parsed:", api.parsedList)
+	}
+	// the parent of the first block is checked in the db, the others against the block before
+	if dao.findCount != 1 {
+		t.Fatal("HandleFork:", dao.findCount)
+	}
+	for _, v := range getBlockNumberList(100, 110) {
+		if dao.blocks[v].BlockHash != getTestHash(v) {
+			t.Fatal("block not committed:", v)
+		}
+	}
+	// every ConcurrencyNum blocks and the last one, the fetches run concurrently
+	sort.Slice(api.checkList, func(i, j int) bool { return api.checkList[i] < api.checkList[j] })
+	if fmt.Sprint(api.checkList) != fmt.Sprint([]uint64{103, 107, 109}) {
+		t.Fatal("check:", api.checkList)
+	}
+}
+
+func TestPipelineParsingMaxBlocks(t *testing.T) {
+	dao := newTestBlockDao(100)
+	p := newTestParser(context.Background(), dao, 100)
+	api := &testPipelineApi{}
+
+	if err := p.pipelineParsing(api, 100+pipelineMaxBlocks+10); err != nil {
+		t.Fatal(err)
+	}
+	if p.PC.CurrentBlockNumber != 100+pipelineMaxBlocks {
+		t.Fatal("current:", p.PC.CurrentBlockNumber)
+	}
+}
+
+func TestPipelineParsingFork(t *testing.T) {
+	dao := newTestBlockDao(100)
+	p := newTestParser(context.Background(), dao, 100)
+	// 105 is on another branch, 104 is rolled back
+	api := &testPipelineApi{parentMap: map[uint64]string{105: "0xother"}}
+
+	if err := p.pipelineParsing(api, 110); err != nil {
+		t.Fatal(err)
+	}
+	if p.PC.CurrentBlockNumber != 104 {
+		t.Fatal("current:", p.PC.CurrentBlockNumber)
+	}
+	if fmt.Sprint(api.parsedList) != fmt.Sprint(getBlockNumberList(100, 105)) {
+		t.Fatal("parsed:", api.parsedList)
+	}
+	if fmt.Sprint(dao.deleted) != "[104]" {
+		t.Fatal("deleted:", dao.deleted)
+	}
+	if _, ok := dao.blocks[104]; ok {
+		t.Fatal("104 not rolled back")
+	}
+	if dao.findCount != 2 {
+		t.Fatal("HandleFork:", dao.findCount)
+	}
+
+	// the next run goes on from 104 on the new branch
+	api = &testPipelineApi{}
+	dao.blocks[103] = tables.TableBlockParserInfo{Id: 1, BlockNumber: 103, BlockHash: getTestHash(103)}
+	if err := p.pipelineParsing(api, 110); err != nil {
+		t.Fatal(err)
+	}
+	if p.PC.CurrentBlockNumber != 110 {
+		t.Fatal("current after the fork:", p.PC.CurrentBlockNumber)
+	}
+}
+
+func TestPipelineParsingFetchErr(t *testing.T) {
+	dao := newTestBlockDao(100)
+	p := newTestParser(context.Background(), dao, 100)
+	api := &testPipelineApi{errMap: map[uint64]error{104: fmt.Errorf("node err")}}
+
+	err := p.pipelineParsing(api, 110)
+	if err == nil || !strings.Contains(err.Error(), "FetchBlock err") {
+		t.Fatal(err)
+	}
+	// the blocks before are committed, 104 is fetched again next run
+	if p.PC.CurrentBlockNumber != 104 {
+		t.Fatal("current:", p.PC.CurrentBlockNumber)
+	}
+	if fmt.Sprint(api.parsedList) != fmt.Sprint(getBlockNumberList(100, 104)) {
+		t.Fatal("parsed:", api.parsedList)
+	}
+}
+
+func TestPipelineParsingPause(t *testing.T) {
+	var paused int32
+	defer func(fn func(tables.ParserType) bool) {
+		isParsePaused = fn
+	}(isParsePaused)
+	isParsePaused = func(parserType tables.ParserType) bool {
+		return atomic.LoadInt32(&paused) == 1
+	}
+
+	dao := newTestBlockDao(100)
+	p := newTestParser(context.Background(), dao, 100)
+	api := &testPipelineApi{onParse: func(blockNumber uint64) {
+		if blockNumber == 102 {
+			atomic.StoreInt32(&paused, 1)
+		}
+	}}
+
+	if err := p.pipelineParsing(api, 110); err != nil {
+		t.Fatal(err)
+	}
+	if p.PC.CurrentBlockNumber != 103 {
+		t.Fatal("current:", p.PC.CurrentBlockNumber)
+	}
+}
+
+func TestPipelineParsingCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	dao := newTestBlockDao(100)
+	p := newTestParser(ctx, dao, 100)
+	api := &testPipelineApi{onParse: func(blockNumber uint64) {
+		if blockNumber == 102 {
+			cancel()
+		}
+	}}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- p.pipelineParsing(api, 110)
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("pipelineParsing not returned after the cancel")
+	}
+	if p.PC.CurrentBlockNumber != 103 {
+		t.Fatal("current:", p.PC.CurrentBlockNumber)
+	}
+}
+
+func TestPipelineParsingPrefetch(t *testing.T) {
+	dao := newTestBlockDao(100)
+	p := newTestParser(context.Background(), dao, 100)
+	api := &testPipelineApi{parseDelay: time.Millisecond}
+
+	if err := p.pipelineParsing(api, 140); err != nil {
+		t.Fatal(err)
+	}
+	if p.PC.CurrentBlockNumber != 140 {
+		t.Fatal("current:", p.PC.CurrentBlockNumber)
+	}
+	// at most PrefetchNum blocks are fetched ahead of the one being parsed
+	if api.maxAhead > p.PC.PrefetchNum || api.maxAhead < 2 {
+		t.Fatal("max ahead:", api.maxAhead, p.PC.PrefetchNum)
+	}
+}
@@ -56,7 +56,7 @@ func (p *ParserDP) ActionTransferDP(req FuncTransactionHandleReq, pc *parser_com
 		return
 	}
 	var txDPInfoOfSvr core.TxDPInfo
-	transferWhitelist, err := address.Parse(config.Cfg.Chain.DP.TransferWhitelist)
+	transferWhitelist, err := address.Parse(config.GetCfg().Chain.DP.TransferWhitelist)
 	if err != nil {
 		resp.Err = fmt.Errorf("address.Parse err: %s", err.Error())
 		return
@@ -110,7 +110,7 @@ func (p *ParserDP) ActionTransferDP(req FuncTransactionHandleReq, pc *parser_com
 		return
 	}
 	mode := address.Mainnet
-	if config.Cfg.Server.Net != common.DasNetTypeMainNet {
+	if config.GetCfg().Server.Net != common.DasNetTypeMainNet {
 		mode = address.Testnet
 	}
 	fromAddr, err := common.ConvertScriptToAddress(mode, userLock)
@@ -141,7 +141,7 @@ func (p *ParserDP) ActionTransferDP(req FuncTransactionHandleReq, pc *parser_com
 	}
 
 	// change the status to confirm
-	if err = pc.DoPayment(order, req.TxHash, fromAddr, parserType.ToAlgorithmId()); err != nil {
+	if err = pc.DoPayment(order, req.TxHash, fromAddr, parserType.ToAlgorithmId(), req.BlockNumber, req.BlockHash); err != nil {
 		resp.Err = fmt.Errorf("pc.DoPayment err: %s", err.Error())
 		return
 	}
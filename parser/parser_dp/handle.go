@@ -14,6 +14,7 @@ type FuncTransactionHandleReq struct {
 	Tx             *types.Transaction
 	TxHash         string
 	BlockNumber    uint64
+	BlockHash      string
 	BlockTimestamp int64
 	Action         common.DasAction
 }
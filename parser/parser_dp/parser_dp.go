@@ -148,6 +148,7 @@ func (p *ParserDP) parsingBlockData(block *types.Block, pc *parser_common.Parser
 					Tx:             tx,
 					TxHash:         txHash,
 					BlockNumber:    blockNumber,
+					BlockHash:      block.Header.Hash.Hex(),
 					BlockTimestamp: int64(blockTimestamp),
 					Action:         builder.Action,
 				}, pc)
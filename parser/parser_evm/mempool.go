@@ -0,0 +1,90 @@
+package parser_evm
+
+import (
+	"fmt"
+	"github.com/dotbitHQ/das-lib/chain/chain_evm"
+	dascommon "github.com/dotbitHQ/das-lib/common"
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/shopspring/decimal"
+	"math/big"
+	"strings"
+	"unipay/parser/parser_common"
+	"unipay/tables"
+)
+
+// GetMempoolTxList the txs of the pending block, some nodes return the latest block for it,
+// those txs are seen with the first confirmation
+func (p *ParserEvm) GetMempoolTxList(pc *parser_common.ParserCore) ([]parser_common.MempoolTx, error) {
+	var block chain_evm.Block
+	method := `{"jsonrpc":"2.0","method":"eth_getBlockByNumber","params":["pending", true],"id":1}`
+	index, chainEvm := p.Pool.Client()
+	resp, err := chainEvm.Request(chainEvm.Node, method, &block)
+	p.Pool.Report(index, err)
+	if err != nil {
+		return nil, fmt.Errorf("Request err: %s", err.Error())
+	} else if resp.Error.Code != 0 {
+		return nil, fmt.Errorf("request err: %s [%d]", resp.Error.Message, resp.Error.Code)
+	}
+
+	contractUSDT := strings.ToLower(pc.ContractAddress)
+	var list []parser_common.MempoolTx
+	for _, tx := range block.Transactions {
+		addrTo := strings.ToLower(ethcommon.HexToAddress(tx.To).Hex())
+		switch addrTo {
+		default:
+			if _, ok := pc.GetAddrMap()[addrTo]; !ok {
+				continue
+			}
+			orderId := string(ethcommon.FromHex(tx.Input))
+			if orderId == "" {
+				continue
+			}
+			list = append(list, parser_common.MempoolTx{
+				TxHash:      tx.Hash,
+				FromAddr:    ethcommon.HexToAddress(tx.From).Hex(),
+				ReceiptAddr: addrTo,
+				OrderId:     orderId,
+				PayTokenIds: []tables.PayTokenId{pc.PayTokenId},
+				Amount:      decimal.NewFromBigInt(chain_evm.BigIntFromHex(tx.Value), 0),
+			})
+		case contractUSDT:
+			if len(tx.Input) != 138 || !strings.Contains(tx.Input, "a9059cbb0000") {
+				continue
+			}
+			addrReceipt := "0x" + strings.ToLower(tx.Input[34:74])
+			if _, ok := pc.GetAddrMap()[addrReceipt]; !ok {
+				continue
+			}
+			list = append(list, parser_common.MempoolTx{
+				TxHash:      tx.Hash,
+				FromAddr:    tx.From,
+				ReceiptAddr: addrReceipt,
+				PayTokenIds: []tables.PayTokenId{pc.ContractPayTokenId},
+				Amount:      decimal.NewFromBigInt(new(big.Int).SetBytes(dascommon.Hex2Bytes(tx.Input)[36:]), 0),
+			})
+		}
+	}
+	return list, nil
+}
+
+func (p *ParserEvm) GetTxBlockNumber(txHash string) (uint64, error) {
+	var tx *chain_evm.Transaction
+	method := fmt.Sprintf(`{"jsonrpc":"2.0","method":"eth_getTransactionByHash","params":["%s"],"id":1}`, txHash)
+	index, chainEvm := p.Pool.Client()
+	resp, err := chainEvm.Request(chainEvm.Node, method, &tx)
+	p.Pool.Report(index, err)
+	if err != nil {
+		return 0, fmt.Errorf("Request err: %s", err.Error())
+	} else if resp.Error.Code != 0 {
+		return 0, fmt.Errorf("request err: %s [%d]", resp.Error.Message, resp.Error.Code)
+	}
+	if tx == nil || tx.BlockNumber == "" {
+		return 0, nil
+	}
+	blockNumber, err := hexutil.DecodeUint64(tx.BlockNumber)
+	if err != nil {
+		return 0, fmt.Errorf("DecodeUint64 err: %s", err.Error())
+	}
+	return blockNumber, nil
+}
@@ -6,12 +6,14 @@ import (
 	dascommon "github.com/dotbitHQ/das-lib/common"
 	"github.com/dotbitHQ/das-lib/http_api/logger"
 	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/scorpiotzh/toolib"
 	"github.com/shopspring/decimal"
 	"golang.org/x/sync/errgroup"
 	"math/big"
 	"strings"
 	"sync"
+	"unipay/nodepool"
 	"unipay/parser/parser_common"
 	"unipay/tables"
 )
@@ -19,14 +21,19 @@ import (
 var log = logger.NewLogger("parser_evm", logger.LevelDebug)
 
 type ParserEvm struct {
-	ChainEvm *chain_evm.ChainEvm
+	Pool *nodepool.EvmPool
+	Ws   string
 }
 
+// Init the probes of the nodes stop with the parser
 func (p *ParserEvm) Init(pc *parser_common.ParserCore) error {
+	p.Pool.Run(pc.Ctx, pc.Wg)
 	return nil
 }
 func (p *ParserEvm) GetLatestBlockNumber() (uint64, error) {
-	currentBlockNumber, err := p.ChainEvm.BestBlockNumber()
+	index, chainEvm := p.Pool.Client()
+	currentBlockNumber, err := chainEvm.BestBlockNumber()
+	p.Pool.Report(index, err)
 	if err != nil {
 		return 0, fmt.Errorf("BestBlockNumber err: %s", err.Error())
 	}
@@ -36,7 +43,9 @@ func (p *ParserEvm) SingleParsing(pc *parser_common.ParserCore) error {
 	parserType, currentBlockNumber := pc.ParserType, pc.CurrentBlockNumber
 	log.Debug("SingleParsing:", parserType, currentBlockNumber)
 
-	block, err := p.ChainEvm.GetBlockByNumber(currentBlockNumber)
+	index, chainEvm := p.Pool.Client()
+	block, err := chainEvm.GetBlockByNumber(currentBlockNumber)
+	p.Pool.Report(index, err)
 	if err != nil {
 		return fmt.Errorf("GetBlockByNumber err: %s", err.Error())
 	}
@@ -44,6 +53,9 @@ func (p *ParserEvm) SingleParsing(pc *parser_common.ParserCore) error {
 		log.Info("GetBlockByNumber:", currentBlockNumber, toolib.JsonString(&block))
 		return fmt.Errorf("GetBlockByNumber data is nil: [%d]", currentBlockNumber)
 	}
+	if err := p.Pool.CheckBlockHash(index, currentBlockNumber, block.Hash); err != nil {
+		return fmt.Errorf("CheckBlockHash err: %s", err.Error())
+	}
 
 	blockHash := block.Hash
 	parentHash := block.ParentHash
@@ -75,11 +87,14 @@ func (p *ParserEvm) ConcurrentParsing(pc *parser_common.ParserCore) error {
 	blockLock := &sync.Mutex{}
 	blockGroup := &errgroup.Group{}
 
+	// the blocks of a round from one node
+	nodeIndex, chainEvm := p.Pool.Client()
 	for i := uint64(0); i < concurrencyNum; i++ {
 		bn := currentBlockNumber + i
 		index := i
 		blockGroup.Go(func() error {
-			block, err := p.ChainEvm.GetBlockByNumber(bn)
+			block, err := chainEvm.GetBlockByNumber(bn)
+			p.Pool.Report(nodeIndex, err)
 			if err != nil {
 				return fmt.Errorf("GetBlockByNumber err:%s [%d]", err.Error(), bn)
 			}
@@ -106,6 +121,10 @@ func (p *ParserEvm) ConcurrentParsing(pc *parser_common.ParserCore) error {
 	if err := blockGroup.Wait(); err != nil {
 		return fmt.Errorf("errGroup.Wait()1 err: %s", err.Error())
 	}
+	lastBlock := blockList[concurrencyNum-1]
+	if err := p.Pool.CheckBlockHash(nodeIndex, lastBlock.BlockNumber, lastBlock.BlockHash); err != nil {
+		return fmt.Errorf("CheckBlockHash err: %s", err.Error())
+	}
 
 	for i := range blocks {
 		blockCh <- blocks[i]
@@ -139,11 +158,12 @@ func (p *ParserEvm) parsingBlockData(block *chain_evm.Block, pc *parser_common.P
 	if block == nil {
 		return fmt.Errorf("block is nil")
 	}
+	blockNumber, _ := hexutil.DecodeUint64(block.Number)
 	for _, tx := range block.Transactions {
 		addrTo := strings.ToLower(ethcommon.HexToAddress(tx.To).Hex())
 		switch addrTo {
 		default:
-			if _, ok := pc.AddrMap[addrTo]; !ok {
+			if _, ok := pc.GetAddrMap()[addrTo]; !ok {
 				continue
 			}
 			orderId := string(ethcommon.FromHex(tx.Input))
@@ -172,7 +192,7 @@ func (p *ParserEvm) parsingBlockData(block *chain_evm.Block, pc *parser_common.P
 				pc.CreatePaymentForMismatch(order.OrderId, tx.Hash, ethcommon.HexToAddress(tx.From).Hex(), decValue, pc.PayTokenId)
 				continue
 			}
-			if err = pc.DoPayment(order, tx.Hash, ethcommon.HexToAddress(tx.From).Hex(), pc.ParserType.ToAlgorithmId()); err != nil {
+			if err = pc.DoPayment(order, tx.Hash, ethcommon.HexToAddress(tx.From).Hex(), pc.ParserType.ToAlgorithmId(), blockNumber, block.Hash); err != nil {
 				return fmt.Errorf("pc.DoPayment err: %s", err.Error())
 			}
 		case contractUSDT:
@@ -181,14 +201,14 @@ func (p *ParserEvm) parsingBlockData(block *chain_evm.Block, pc *parser_common.P
 				continue
 			}
 			addrReceipt := "0x" + strings.ToLower(tx.Input[34:74])
-			if _, ok := pc.AddrMap[addrReceipt]; !ok {
+			if _, ok := pc.GetAddrMap()[addrReceipt]; !ok {
 				continue
 			}
 			amount := decimal.NewFromBigInt(new(big.Int).SetBytes(dascommon.Hex2Bytes(tx.Input)[36:]), 0)
 			log.Info("parsingBlockData:", contractPayTokenId, tx.From, amount.String(), tx.Hash)
-			order, err := pc.DbDao.GetOrderByAddrWithAmountAndAddr(tx.From, addrReceipt, contractPayTokenId, amount)
+			order, err := pc.GetOrderByAmount(tx.From, addrReceipt, contractPayTokenId, amount)
 			if err != nil {
-				return fmt.Errorf("GetOrderByAddrWithAmountAndAddr err: %s", err.Error())
+				return fmt.Errorf("GetOrderByAmount err: %s", err.Error())
 			} else if order.Id == 0 {
 				log.Warn("order not exist:", contractPayTokenId, tx.From, amount, tx.Hash)
 				//pc.CreatePaymentForMismatch("", tx.Hash, ethcommon.HexToAddress(tx.From).Hex(), amount, contractPayTokenId)
@@ -199,7 +219,7 @@ func (p *ParserEvm) parsingBlockData(block *chain_evm.Block, pc *parser_common.P
 				continue
 			}
 
-			if err = pc.DoPayment(order, tx.Hash, ethcommon.HexToAddress(tx.From).Hex(), pc.ParserType.ToAlgorithmId()); err != nil {
+			if err = pc.DoPayment(order, tx.Hash, ethcommon.HexToAddress(tx.From).Hex(), pc.ParserType.ToAlgorithmId(), blockNumber, block.Hash); err != nil {
 				return fmt.Errorf("pc.DoPayment err: %s", err.Error())
 			}
 		}
@@ -0,0 +1,72 @@
+package parser_evm
+
+import (
+	"context"
+	"fmt"
+	"github.com/dotbitHQ/das-lib/chain/chain_evm"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/scorpiotzh/toolib"
+	"unipay/parser/parser_common"
+)
+
+func (p *ParserEvm) FetchBlock(pc *parser_common.ParserCore, blockNumber uint64, check bool) (*parser_common.Block, error) {
+	index, chainEvm := p.Pool.Client()
+	block, err := chainEvm.GetBlockByNumber(blockNumber)
+	p.Pool.Report(index, err)
+	if err != nil {
+		return nil, fmt.Errorf("GetBlockByNumber err: %s [%d]", err.Error(), blockNumber)
+	}
+	if block.Hash == "" || block.ParentHash == "" {
+		log.Warn("GetBlockByNumber:", blockNumber, toolib.JsonString(&block))
+		return nil, fmt.Errorf("GetBlockByNumber data is nil: [%d]", blockNumber)
+	}
+	if check {
+		if err := p.Pool.CheckBlockHash(index, blockNumber, block.Hash); err != nil {
+			return nil, fmt.Errorf("CheckBlockHash err: %s", err.Error())
+		}
+	}
+	return &parser_common.Block{
+		BlockNumber: blockNumber,
+		BlockHash:   block.Hash,
+		ParentHash:  block.ParentHash,
+		Data:        block,
+	}, nil
+}
+
+func (p *ParserEvm) ParseBlock(pc *parser_common.ParserCore, block *parser_common.Block) error {
+	return p.parsingBlockData(block.Data.(*chain_evm.Block), pc)
+}
+
+type newHead struct {
+	Number hexutil.Uint64 `json:"number"`
+}
+
+// SubscribeHead the heads only wake the parser up, the blocks still come from the pool
+func (p *ParserEvm) SubscribeHead(ctx context.Context, onHead func(blockNumber uint64)) error {
+	if p.Ws == "" {
+		return parser_common.ErrHeadUnsupported
+	}
+	client, err := rpc.DialContext(ctx, p.Ws)
+	if err != nil {
+		return fmt.Errorf("rpc.DialContext err: %s", err.Error())
+	}
+	defer client.Close()
+
+	headCh := make(chan newHead, 16)
+	sub, err := client.EthSubscribe(ctx, headCh, "newHeads")
+	if err != nil {
+		return fmt.Errorf("EthSubscribe err: %s", err.Error())
+	}
+	defer sub.Unsubscribe()
+	for {
+		select {
+		case head := <-headCh:
+			onHead(uint64(head.Number))
+		case err := <-sub.Err():
+			return fmt.Errorf("subscription err: %v", err)
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"math/big"
 	"strings"
 	"sync"
+	"unipay/nodepool"
 	"unipay/parser/parser_common"
 	"unipay/tables"
 )
@@ -21,14 +22,18 @@ import (
 var log = logger.NewLogger("parser_tron", logger.LevelDebug)
 
 type ParserTron struct {
-	ChainTron *chain_tron.ChainTron
+	Pool *nodepool.TronPool
 }
 
+// Init the probes of the nodes stop with the parser
 func (p *ParserTron) Init(pc *parser_common.ParserCore) error {
+	p.Pool.Run(pc.Ctx, pc.Wg)
 	return nil
 }
 func (p *ParserTron) GetLatestBlockNumber() (uint64, error) {
-	currentBlockNumber, err := p.ChainTron.GetBlockNumber()
+	index, chainTron := p.Pool.Client()
+	currentBlockNumber, err := chainTron.GetBlockNumber()
+	p.Pool.Report(index, err)
 	if err != nil {
 		return 0, fmt.Errorf("GetBlockNumber err: %s", err.Error())
 	}
@@ -38,7 +43,9 @@ func (p *ParserTron) SingleParsing(pc *parser_common.ParserCore) error {
 	parserType, currentBlockNumber := pc.ParserType, pc.CurrentBlockNumber
 	log.Debug("SingleParsing:", parserType, currentBlockNumber)
 
-	block, err := p.ChainTron.GetBlockByNumber(currentBlockNumber)
+	index, chainTron := p.Pool.Client()
+	block, err := chainTron.GetBlockByNumber(currentBlockNumber)
+	p.Pool.Report(index, err)
 	if err != nil {
 		return fmt.Errorf("GetBlockByNumber err: %s", err.Error())
 	}
@@ -51,6 +58,9 @@ func (p *ParserTron) SingleParsing(pc *parser_common.ParserCore) error {
 	blockHash := hex.EncodeToString(block.Blockid)
 	parentHash := hex.EncodeToString(block.BlockHeader.RawData.ParentHash)
 	log.Debug("SingleParsing:", parserType, blockHash, parentHash)
+	if err := p.Pool.CheckBlockHash(index, currentBlockNumber, blockHash); err != nil {
+		return fmt.Errorf("CheckBlockHash err: %s", err.Error())
+	}
 
 	if isFork, err := pc.HandleFork(blockHash, parentHash); err != nil {
 		return fmt.Errorf("HandleFork err: %s", err.Error())
@@ -78,11 +88,14 @@ func (p *ParserTron) ConcurrentParsing(pc *parser_common.ParserCore) error {
 	blockLock := &sync.Mutex{}
 	blockGroup := &errgroup.Group{}
 
+	// the blocks of a round from one node
+	nodeIndex, chainTron := p.Pool.Client()
 	for i := uint64(0); i < concurrencyNum; i++ {
 		bn := currentBlockNumber + i
 		index := i
 		blockGroup.Go(func() error {
-			block, err := p.ChainTron.GetBlockByNumber(bn)
+			block, err := chainTron.GetBlockByNumber(bn)
+			p.Pool.Report(nodeIndex, err)
 			if err != nil {
 				return fmt.Errorf("GetBlockByNumber err:%s [%d]", err.Error(), bn)
 			}
@@ -111,6 +124,10 @@ func (p *ParserTron) ConcurrentParsing(pc *parser_common.ParserCore) error {
 	if err := blockGroup.Wait(); err != nil {
 		return fmt.Errorf("errGroup.Wait()1 err: %s", err.Error())
 	}
+	lastBlock := blockList[concurrencyNum-1]
+	if err := p.Pool.CheckBlockHash(nodeIndex, lastBlock.BlockNumber, lastBlock.BlockHash); err != nil {
+		return fmt.Errorf("CheckBlockHash err: %s", err.Error())
+	}
 
 	for i := range blocks {
 		blockCh <- blocks[i]
@@ -157,7 +174,7 @@ func (p *ParserTron) parsingBlockData(block *api.BlockExtention, pc *parser_comm
 			}
 			orderId := chain_tron.GetMemo(tx.Transaction.RawData.Data)
 			fromAddr, toAddr := hex.EncodeToString(instance.OwnerAddress), hex.EncodeToString(instance.ToAddress)
-			if _, ok := pc.AddrMap[toAddr]; !ok {
+			if _, ok := pc.GetAddrMap()[toAddr]; !ok {
 				continue
 			}
 			log.Info("parsingBlockData:", parserType, orderId, hex.EncodeToString(tx.Txid))
@@ -187,7 +204,7 @@ func (p *ParserTron) parsingBlockData(block *api.BlockExtention, pc *parser_comm
 				continue
 			}
 			// change the status to confirm
-			if err = pc.DoPayment(order, hex.EncodeToString(tx.Txid), fromAddr, pc.ParserType.ToAlgorithmId()); err != nil {
+			if err = pc.DoPayment(order, hex.EncodeToString(tx.Txid), fromAddr, pc.ParserType.ToAlgorithmId(), uint64(block.BlockHeader.RawData.Number), hex.EncodeToString(block.Blockid)); err != nil {
 				return fmt.Errorf("pc.DoPayment err: %s", err.Error())
 			}
 		//case core.Transaction_Contract_TransferAssetContract:
@@ -210,12 +227,12 @@ func (p *ParserTron) parsingBlockData(block *api.BlockExtention, pc *parser_comm
 
 			log.Info("parsingBlockData:", fromHex, contractHex, toHex, amount.String())
 
-			if _, ok := pc.AddrMap[toHex]; !ok {
+			if _, ok := pc.GetAddrMap()[toHex]; !ok {
 				continue
 			}
-			order, err := pc.DbDao.GetOrderByAddrWithAmountAndAddr(fromHex, toHex, contractPayTokenId, amount)
+			order, err := pc.GetOrderByAmount(fromHex, toHex, contractPayTokenId, amount)
 			if err != nil {
-				return fmt.Errorf("GetOrderByAddrWithAmountAndAddr err: %s", err.Error())
+				return fmt.Errorf("GetOrderByAmount err: %s", err.Error())
 			} else if order.Id == 0 {
 				log.Warn("order not exist:", contractPayTokenId, fromHex, amount)
 				//pc.CreatePaymentForMismatch("", hex.EncodeToString(tx.Txid), fromHex, amount, contractPayTokenId)
@@ -226,7 +243,7 @@ func (p *ParserTron) parsingBlockData(block *api.BlockExtention, pc *parser_comm
 				//pc.CreatePaymentForMismatch(common.DasAlgorithmIdTron, order.OrderId, hex.EncodeToString(tx.Txid), fromHex, amount, contractPayTokenId)
 				continue
 			}
-			if err = pc.DoPayment(order, hex.EncodeToString(tx.Txid), fromHex, pc.ParserType.ToAlgorithmId()); err != nil {
+			if err = pc.DoPayment(order, hex.EncodeToString(tx.Txid), fromHex, pc.ParserType.ToAlgorithmId(), uint64(block.BlockHeader.RawData.Number), hex.EncodeToString(block.Blockid)); err != nil {
 				return fmt.Errorf("pc.DoPayment err: %s", err.Error())
 			}
 		}
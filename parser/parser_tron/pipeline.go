@@ -0,0 +1,38 @@
+package parser_tron
+
+import (
+	"encoding/hex"
+	"fmt"
+	"github.com/fbsobreira/gotron-sdk/pkg/proto/api"
+	"unipay/parser/parser_common"
+)
+
+func (p *ParserTron) FetchBlock(pc *parser_common.ParserCore, blockNumber uint64, check bool) (*parser_common.Block, error) {
+	index, chainTron := p.Pool.Client()
+	block, err := chainTron.GetBlockByNumber(blockNumber)
+	p.Pool.Report(index, err)
+	if err != nil {
+		return nil, fmt.Errorf("GetBlockByNumber err: %s [%d]", err.Error(), blockNumber)
+	}
+	if block.BlockHeader == nil {
+		return nil, fmt.Errorf("block.BlockHeader is nil[%d]", blockNumber)
+	} else if block.BlockHeader.RawData == nil {
+		return nil, fmt.Errorf("block.BlockHeader.RawData is nil[%d]", blockNumber)
+	}
+	blockHash := hex.EncodeToString(block.Blockid)
+	if check {
+		if err := p.Pool.CheckBlockHash(index, blockNumber, blockHash); err != nil {
+			return nil, fmt.Errorf("CheckBlockHash err: %s", err.Error())
+		}
+	}
+	return &parser_common.Block{
+		BlockNumber: blockNumber,
+		BlockHash:   blockHash,
+		ParentHash:  hex.EncodeToString(block.BlockHeader.RawData.ParentHash),
+		Data:        block,
+	}, nil
+}
+
+func (p *ParserTron) ParseBlock(pc *parser_common.ParserCore, block *parser_common.Block) error {
+	return p.parsingBlockData(block.Data.(*api.BlockExtention), pc)
+}
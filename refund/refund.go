@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 	"github.com/dotbitHQ/das-lib/bitcoin"
-	"github.com/dotbitHQ/das-lib/chain/chain_evm"
 	"github.com/dotbitHQ/das-lib/chain/chain_tron"
 	"github.com/dotbitHQ/das-lib/core"
 	"github.com/dotbitHQ/das-lib/http_api/logger"
@@ -14,6 +13,9 @@ import (
 	"time"
 	"unipay/config"
 	"unipay/dao"
+	"unipay/nodepool"
+	"unipay/notify"
+	"unipay/tables"
 )
 
 var (
@@ -26,11 +28,14 @@ type ToolRefund struct {
 	DbDao   *dao.DbDao
 	DasCore *core.DasCore
 
+	lock             sync.Mutex // a round of refund, or the rebuild of the clients by the config reload
 	remoteSignClient *remote_sign.RemoteSignClient
-	chainDoge        *bitcoin.TxTool
-	chainEth         *chain_evm.ChainEvm
-	chainBsc         *chain_evm.ChainEvm
-	chainPolygon     *chain_evm.ChainEvm
+	poolDoge         *nodepool.DogePool
+	poolEth          *nodepool.EvmPool
+	poolBsc          *nodepool.EvmPool
+	poolPolygon      *nodepool.EvmPool
+	poolTron         *nodepool.TronPool
+	chainDoge        *bitcoin.TxTool // of the current round, see pickClients
 	chainTron        *chain_tron.ChainTron
 
 	cron *cron.Cron
@@ -38,79 +43,114 @@ type ToolRefund struct {
 
 func (t *ToolRefund) InitRefundInfo() error {
 	// remote sign client
-	if config.Cfg.Server.RemoteSignApiUrl != "" {
-		remoteSignClient, err := remote_sign.NewRemoteSignClient(t.Ctx, config.Cfg.Server.RemoteSignApiUrl)
+	if config.GetCfg().Server.RemoteSignApiUrl != "" {
+		remoteSignClient, err := remote_sign.NewRemoteSignClient(t.Ctx, config.GetCfg().Server.RemoteSignApiUrl)
 		if err != nil {
 			return fmt.Errorf("NewRemoteSignClient err: %s", err.Error())
 		}
 		t.remoteSignClient = remoteSignClient
 	}
+	ch := config.GetCfg().Chain
 	// doge
-	if config.Cfg.Chain.Doge.Refund {
-		t.chainDoge = &bitcoin.TxTool{
-			RpcClient: &bitcoin.BaseRequest{
-				RpcUrl:   config.Cfg.Chain.Doge.Node,
-				User:     config.Cfg.Chain.Doge.User,
-				Password: config.Cfg.Chain.Doge.Password,
-				Proxy:    "",
-			},
-			Ctx:              t.Ctx,
-			RemoteSignClient: nil,
-			DustLimit:        bitcoin.DustLimitDoge,
-			Params:           bitcoin.GetDogeMainNetParams(),
-		}
-		if t.remoteSignClient != nil {
-			t.chainDoge.RemoteSignClient = t.remoteSignClient.Client()
+	if ch.Doge.Refund {
+		pool, err := nodepool.NewDogePool(tables.PayTokenIdDOGE.GetChain(), config.GetNodeList(ch.Doge.Node, ch.Doge.Nodes), ch.Doge.User, ch.Doge.Password)
+		if err != nil {
+			return fmt.Errorf("NewDogePool err: %s", err.Error())
 		}
+		t.poolDoge = pool
 	}
 
 	// eth
-	if config.Cfg.Chain.Eth.Refund {
-		chainEth, err := chain_evm.NewChainEvm(t.Ctx, config.Cfg.Chain.Eth.Node, config.Cfg.Chain.Eth.RefundAddFee)
+	if ch.Eth.Refund {
+		pool, err := nodepool.NewEvmPool(t.Ctx, tables.PayTokenIdETH.GetChain(), config.GetNodeList(ch.Eth.Node, ch.Eth.Nodes), ch.Eth.RefundAddFee)
 		if err != nil {
-			return fmt.Errorf("NewChainEvm eth err: %s", err.Error())
+			return fmt.Errorf("NewEvmPool eth err: %s", err.Error())
 		}
-		t.chainEth = chainEth
+		t.poolEth = pool
 	}
 
 	// bsc
-	if config.Cfg.Chain.Bsc.Refund {
-		chainBsc, err := chain_evm.NewChainEvm(t.Ctx, config.Cfg.Chain.Bsc.Node, config.Cfg.Chain.Bsc.RefundAddFee)
+	if ch.Bsc.Refund {
+		pool, err := nodepool.NewEvmPool(t.Ctx, tables.PayTokenIdBNB.GetChain(), config.GetNodeList(ch.Bsc.Node, ch.Bsc.Nodes), ch.Bsc.RefundAddFee)
 		if err != nil {
-			return fmt.Errorf("NewChainEvm bsc err: %s", err.Error())
+			return fmt.Errorf("NewEvmPool bsc err: %s", err.Error())
 		}
-		t.chainBsc = chainBsc
+		t.poolBsc = pool
 	}
 
 	// polygon
-	if config.Cfg.Chain.Polygon.Refund {
-		chainPolygon, err := chain_evm.NewChainEvm(t.Ctx, config.Cfg.Chain.Polygon.Node, config.Cfg.Chain.Polygon.RefundAddFee)
+	if ch.Polygon.Refund {
+		pool, err := nodepool.NewEvmPool(t.Ctx, tables.PayTokenIdPOL.GetChain(), config.GetNodeList(ch.Polygon.Node, ch.Polygon.Nodes), ch.Polygon.RefundAddFee)
 		if err != nil {
-			return fmt.Errorf("NewChainEvm polygon err: %s", err.Error())
+			return fmt.Errorf("NewEvmPool polygon err: %s", err.Error())
 		}
-		t.chainPolygon = chainPolygon
+		t.poolPolygon = pool
 	}
 
 	// tron
-	if config.Cfg.Chain.Tron.Refund {
-		chainTron, err := chain_tron.NewChainTron(t.Ctx, config.Cfg.Chain.Tron.Node)
+	if ch.Tron.Refund {
+		pool, err := nodepool.NewTronPool(t.Ctx, tables.PayTokenIdTRX.GetChain(), config.GetNodeList(ch.Tron.Node, ch.Tron.Nodes))
 		if err != nil {
-			return fmt.Errorf("chain_ckb.NewChainTron tron err: %s", err.Error())
+			return fmt.Errorf("NewTronPool tron err: %s", err.Error())
 		}
-		t.chainTron = chainTron
+		t.poolTron = pool
 	}
 
+	for _, v := range t.getPoolList() {
+		v.Run(t.Ctx, t.Wg)
+	}
 	return nil
 }
 
+func (t *ToolRefund) getPoolList() []*nodepool.Pool {
+	var list []*nodepool.Pool
+	if t.poolDoge != nil {
+		list = append(list, t.poolDoge.Pool)
+	}
+	if t.poolEth != nil {
+		list = append(list, t.poolEth.Pool)
+	}
+	if t.poolBsc != nil {
+		list = append(list, t.poolBsc.Pool)
+	}
+	if t.poolPolygon != nil {
+		list = append(list, t.poolPolygon.Pool)
+	}
+	if t.poolTron != nil {
+		list = append(list, t.poolTron.Pool)
+	}
+	return list
+}
+
+// pickClients the tron and doge clients of a round from the up nodes, the evm ones are picked with the nonces
+func (t *ToolRefund) pickClients() {
+	t.chainDoge, t.chainTron = nil, nil
+	if t.poolDoge != nil {
+		_, nodeRpc := t.poolDoge.Client()
+		t.chainDoge = &bitcoin.TxTool{
+			RpcClient:        nodeRpc,
+			Ctx:              t.Ctx,
+			RemoteSignClient: nil,
+			DustLimit:        bitcoin.DustLimitDoge,
+			Params:           bitcoin.GetDogeMainNetParams(),
+		}
+		if t.remoteSignClient != nil {
+			t.chainDoge.RemoteSignClient = t.remoteSignClient.Client()
+		}
+	}
+	if t.poolTron != nil {
+		_, t.chainTron = t.poolTron.Client()
+	}
+}
+
 func (t *ToolRefund) RunRefund() error {
-	if config.Cfg.Server.CronSpec == "" {
+	if config.GetCfg().Server.CronSpec == "" {
 		return nil
 	}
-	log.Debug("DoOrderRefund:", config.Cfg.Server.CronSpec)
+	log.Debug("DoOrderRefund:", config.GetCfg().Server.CronSpec)
 
 	t.cron = cron.New(cron.WithSeconds())
-	_, err := t.cron.AddFunc(config.Cfg.Server.CronSpec, func() {
+	_, err := t.cron.AddFunc(config.GetCfg().Server.CronSpec, func() {
 		log.Debug("doRefund start ...")
 		if err := t.doRefund(); err != nil {
 			log.Error("doRefund err: ", err.Error())
@@ -140,3 +180,46 @@ func (t *ToolRefund) RunRefundOnce() {
 		}
 	}()
 }
+
+// ReloadRefund rebuilds the chain clients between two rounds of refund when their config changes,
+// the addr maps are read at each round
+func (t *ToolRefund) ReloadRefund(oldCfg, newCfg *config.CfgServer) error {
+	if getRefundClientConf(oldCfg) != getRefundClientConf(newCfg) {
+		tmp := ToolRefund{Ctx: t.Ctx, Wg: t.Wg, DbDao: t.DbDao, DasCore: t.DasCore}
+		if err := tmp.InitRefundInfo(); err != nil {
+			notify.SendAlert(notify.SeverityCritical, notify.CategoryRefund, "RefundReload", err.Error())
+			return fmt.Errorf("InitRefundInfo err: %s", err.Error())
+		}
+		t.lock.Lock()
+		oldPoolList := t.getPoolList()
+		t.remoteSignClient, t.poolDoge = tmp.remoteSignClient, tmp.poolDoge
+		t.poolEth, t.poolBsc, t.poolPolygon, t.poolTron = tmp.poolEth, tmp.poolBsc, tmp.poolPolygon, tmp.poolTron
+		t.lock.Unlock()
+		for _, v := range oldPoolList {
+			v.Stop()
+		}
+		log.Info("ReloadRefund: chain clients rebuilt")
+	}
+	if oldCfg.Server.CronSpec != newCfg.Server.CronSpec {
+		if t.cron != nil {
+			t.cron.Stop()
+		}
+		if err := t.RunRefund(); err != nil {
+			return fmt.Errorf("RunRefund err: %s", err.Error())
+		}
+		log.Info("ReloadRefund: cron spec", newCfg.Server.CronSpec)
+	}
+	return nil
+}
+
+// getRefundClientConf the clients of InitRefundInfo are rebuilt when it changes
+func getRefundClientConf(cfg *config.CfgServer) string {
+	ch := cfg.Chain
+	return fmt.Sprintf("%s|%t|%v|%s|%s|%t|%v|%v|%t|%v|%v|%t|%v|%v|%t|%v",
+		cfg.Server.RemoteSignApiUrl,
+		ch.Doge.Refund, config.GetNodeList(ch.Doge.Node, ch.Doge.Nodes), ch.Doge.User, ch.Doge.Password,
+		ch.Eth.Refund, config.GetNodeList(ch.Eth.Node, ch.Eth.Nodes), ch.Eth.RefundAddFee,
+		ch.Bsc.Refund, config.GetNodeList(ch.Bsc.Node, ch.Bsc.Nodes), ch.Bsc.RefundAddFee,
+		ch.Polygon.Refund, config.GetNodeList(ch.Polygon.Node, ch.Polygon.Nodes), ch.Polygon.RefundAddFee,
+		ch.Tron.Refund, config.GetNodeList(ch.Tron.Node, ch.Tron.Nodes))
+}
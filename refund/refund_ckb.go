@@ -17,7 +17,7 @@ import (
 )
 
 func (t *ToolRefund) doRefundCkb(paymentAddress, private string, list []tables.ViewRefundPaymentInfo) error {
-	if !config.Cfg.Chain.Ckb.Refund {
+	if !config.GetCfg().Chain.Ckb.Refund {
 		return fmt.Errorf("ckb refund flag is false")
 	}
 	if len(list) == 0 {
@@ -127,7 +127,7 @@ func (t *ToolRefund) doRefundCkb(paymentAddress, private string, list []tables.V
 	if _, err = txBuilder.SendTransaction(); err != nil {
 		if err1 := t.DbDao.UpdatePaymentListToUnRefunded(payHashList); err1 != nil {
 			log.Info("UpdatePaymentListToUnRefunded err: ", err1.Error(), payHashList)
-			notify.SendLarkErrNotify("doRefundCKB", fmt.Sprintf("%s\n%s", strings.Join(payHashList, ","), err1.Error()))
+			notify.SendAlert(notify.SeverityError, notify.CategoryRefund, "doRefundCKB", fmt.Sprintf("%s\n%s", strings.Join(payHashList, ","), err1.Error()))
 		}
 		return fmt.Errorf("SendTransaction err: %s", err.Error())
 	}
@@ -8,12 +8,14 @@ import (
 	"strings"
 	"time"
 	"unipay/config"
+	"unipay/eventbus"
 	"unipay/notify"
 	"unipay/tables"
+	"unipay/txtool"
 )
 
 func (t *ToolRefund) doRefundDoge(paymentAddress, private string, list []tables.ViewRefundPaymentInfo) error {
-	if !config.Cfg.Chain.Doge.Refund {
+	if !config.GetCfg().Chain.Doge.Refund {
 		return fmt.Errorf("doge refund flag is false")
 	}
 	if t.chainDoge == nil {
@@ -62,9 +64,9 @@ func (t *ToolRefund) doRefundDoge(paymentAddress, private string, list []tables.
 			return fmt.Errorf("LocalSignTx err: %s", err.Error())
 		}
 		signTx = tx
-	} else if config.Cfg.Server.RemoteSignApiUrl != "" {
+	} else if config.GetCfg().Server.RemoteSignApiUrl != "" {
 		log.Info("doRefundDoge remote sign")
-		signTx, err = remote_sign.SignTxForDOGE(config.Cfg.Server.RemoteSignApiUrl, paymentAddress, tx)
+		signTx, err = remote_sign.SignTxForDOGE(config.GetCfg().Server.RemoteSignApiUrl, paymentAddress, tx)
 		if err != nil {
 			return fmt.Errorf("remote_sign.SignTxForDOGE err: %s", err.Error())
 		}
@@ -85,7 +87,7 @@ func (t *ToolRefund) doRefundDoge(paymentAddress, private string, list []tables.
 	if _, err = t.chainDoge.SendTx(signTx); err != nil {
 		if err = t.DbDao.UpdatePaymentListToUnRefunded(payHashList); err != nil {
 			log.Info("UpdatePaymentListToUnRefunded err: ", err.Error(), payHashList)
-			notify.SendLarkErrNotify("doRefundDoge", fmt.Sprintf("%s\n%s", strings.Join(payHashList, ","), err.Error()))
+			notify.SendAlert(notify.SeverityError, notify.CategoryRefund, "doRefundDoge", fmt.Sprintf("%s\n%s", strings.Join(payHashList, ","), err.Error()))
 		}
 		return fmt.Errorf("SendTx err: %s", err.Error())
 	}
@@ -102,6 +104,7 @@ func (t *ToolRefund) addCallbackNotice(list []tables.ViewRefundPaymentInfo) erro
 	var noticeList []tables.TableNoticeInfo
 	for _, v := range list {
 		notice := tables.TableNoticeInfo{
+			BusinessId:   v.BusinessId,
 			EventType:    tables.EventTypeOrderRefund,
 			PayHash:      v.PayHash,
 			NoticeCount:  0,
@@ -115,5 +118,9 @@ func (t *ToolRefund) addCallbackNotice(list []tables.ViewRefundPaymentInfo) erro
 	if err := t.DbDao.CreateNoticeList(noticeList); err != nil {
 		return fmt.Errorf("CreateNoticeList erR: %s", err.Error())
 	}
+	for _, v := range list {
+		eventbus.Publish(eventbus.OrderEvent{OrderId: v.OrderId, Status: eventbus.OrderStatusRefunded, PayHash: v.PayHash})
+		txtool.Tools.Metrics.Refund().WithLabelValues(string(v.PayTokenId), "refunded").Inc()
+	}
 	return nil
 }
@@ -16,7 +16,7 @@ import (
 )
 
 func (t *ToolRefund) doRefundDP(list []tables.ViewRefundPaymentInfo) error {
-	if !config.Cfg.Chain.DP.Refund {
+	if !config.GetCfg().Chain.DP.Refund {
 		return fmt.Errorf("dp refund flag is false")
 	}
 	if len(list) == 0 {
@@ -24,10 +24,10 @@ func (t *ToolRefund) doRefundDP(list []tables.ViewRefundPaymentInfo) error {
 	}
 	log.Info("doRefundDP:", len(list))
 	chainId := int64(5)
-	if config.Cfg.Server.Net == common.DasNetTypeMainNet {
+	if config.GetCfg().Server.Net == common.DasNetTypeMainNet {
 		chainId = 1
 	}
-	fromLock, err := address.Parse(config.Cfg.Chain.DP.TransferWhitelist)
+	fromLock, err := address.Parse(config.GetCfg().Chain.DP.TransferWhitelist)
 	if err != nil {
 		return fmt.Errorf("address.Parse err: %s", err.Error())
 	}
@@ -35,8 +35,8 @@ func (t *ToolRefund) doRefundDP(list []tables.ViewRefundPaymentInfo) error {
 	if err != nil {
 		return fmt.Errorf("ScriptToHex err: %s", err.Error())
 	}
-	refundUrl := fmt.Sprintf("%s/v1/dp/refund", config.Cfg.Chain.DP.RefundUrl)
-	sendTxUrl := fmt.Sprintf("%s/v1/tx/send", config.Cfg.Chain.DP.RefundUrl)
+	refundUrl := fmt.Sprintf("%s/v1/dp/refund", config.GetCfg().Chain.DP.RefundUrl)
+	sendTxUrl := fmt.Sprintf("%s/v1/tx/send", config.GetCfg().Chain.DP.RefundUrl)
 	for i, v := range list {
 
 		req := ReqRefundDP{
@@ -60,14 +60,14 @@ func (t *ToolRefund) doRefundDP(list []tables.ViewRefundPaymentInfo) error {
 			if s.SignType != common.DasAlgorithmIdEth712 {
 				continue
 			}
-			if config.Cfg.Chain.DP.TransferWhitelistPrivate != "" {
-				sig, err := sign.DoEIP712Sign(chainId, s.SignMsg, config.Cfg.Chain.DP.TransferWhitelistPrivate, data.MMJson)
+			if config.GetCfg().Chain.DP.TransferWhitelistPrivate != "" {
+				sig, err := sign.DoEIP712Sign(chainId, s.SignMsg, config.GetCfg().Chain.DP.TransferWhitelistPrivate, data.MMJson)
 				if err != nil {
 					return fmt.Errorf("DoEIP712Sign err: %s", err.Error())
 				}
 				data.SignList[index].SignMsg = sig
-			} else if config.Cfg.Server.RemoteSignApiUrl != "" {
-				sig, err := remote_sign.SignTxFor712(config.Cfg.Server.RemoteSignApiUrl, fromAddr.AddressHex, s.SignMsg, chainId, data.MMJson)
+			} else if config.GetCfg().Server.RemoteSignApiUrl != "" {
+				sig, err := remote_sign.SignTxFor712(config.GetCfg().Server.RemoteSignApiUrl, fromAddr.AddressHex, s.SignMsg, chainId, data.MMJson)
 				if err != nil {
 					return fmt.Errorf("SignTxFor712 err: %s", err.Error())
 				}
@@ -89,14 +89,14 @@ func (t *ToolRefund) doRefundDP(list []tables.ViewRefundPaymentInfo) error {
 		if err != nil {
 			if er := t.DbDao.UpdateSinglePaymentToUnRefunded(v.PayHash); er != nil {
 				log.Info("UpdateSinglePaymentToUnRefunded err: ", er.Error(), v.PayHash)
-				notify.SendLarkErrNotify("UpdateSinglePaymentToUnRefunded", fmt.Sprintf("%s\n%s", v.PayHash, er.Error()))
+				notify.SendAlert(notify.SeverityError, notify.CategoryRefund, "UpdateSinglePaymentToUnRefunded", fmt.Sprintf("%s\n%s", v.PayHash, er.Error()))
 			}
 			return fmt.Errorf("http_api.SendReqV2 err: %s", err.Error())
 		}
 		if resp2.ErrNo != http_api.ApiCodeSuccess {
 			if er := t.DbDao.UpdateSinglePaymentToUnRefunded(v.PayAddress); er != nil {
 				log.Info("UpdateSinglePaymentToUnRefunded err: ", er.Error(), v.PayHash)
-				notify.SendLarkErrNotify("UpdateSinglePaymentToUnRefunded", fmt.Sprintf("%s\n%s", v.PayHash, er.Error()))
+				notify.SendAlert(notify.SeverityError, notify.CategoryRefund, "UpdateSinglePaymentToUnRefunded", fmt.Sprintf("%s\n%s", v.PayHash, er.Error()))
 			}
 			return fmt.Errorf("req failed: [%d]%s", resp2.ErrNo, resp2.ErrMsg)
 		}
@@ -65,7 +65,7 @@ func (t *ToolRefund) refundEvm(p refundEvmParam) (ok bool, e error) {
 			e = fmt.Errorf("chain_evm.PackMessage err: %s", err.Error())
 			return
 		}
-		contract := p.info.PayTokenId.GetContractAddress(config.Cfg.Server.Net)
+		contract := p.info.PayTokenId.GetContractAddress(config.GetCfg().Server.Net)
 		gasPrice, gasLimit, err = p.chainEvm.EstimateGas(fromAddr, contract, decimal.Zero, data, addFee)
 		if err != nil {
 			e = fmt.Errorf("p.chainEvm.EstimateGas err: %s", err.Error())
@@ -109,14 +109,14 @@ func (t *ToolRefund) refundEvm(p refundEvmParam) (ok bool, e error) {
 			e = fmt.Errorf("SignWithPrivateKey err:%s", err.Error())
 			return
 		}
-	} else if config.Cfg.Server.RemoteSignApiUrl != "" {
+	} else if config.GetCfg().Server.RemoteSignApiUrl != "" {
 		log.Info("refundEvm remote sign")
 		chainID, err := p.chainEvm.Client.ChainID(context.Background())
 		if err != nil {
 			e = fmt.Errorf("p.chainEvm.Client.ChainID err: %s", err.Error())
 			return
 		}
-		tx, err = remote_sign.SignTxForEVM(config.Cfg.Server.RemoteSignApiUrl, fromAddr, chainID.Int64(), tx)
+		tx, err = remote_sign.SignTxForEVM(config.GetCfg().Server.RemoteSignApiUrl, fromAddr, chainID.Int64(), tx)
 		if err != nil {
 			e = fmt.Errorf("remote_sign.SignTxForEVM err: %s", err.Error())
 			return
@@ -146,7 +146,7 @@ func (t *ToolRefund) refundEvm(p refundEvmParam) (ok bool, e error) {
 		e = fmt.Errorf("SendTx err: %s", err.Error())
 		if err = t.DbDao.UpdateSinglePaymentToUnRefunded(payHash); err != nil {
 			log.Info("UpdateSinglePaymentToUnRefunded err: ", err.Error(), payHash)
-			notify.SendLarkErrNotify("UpdateSinglePaymentToUnRefunded", fmt.Sprintf("%s\n%s", payHash, err.Error()))
+			notify.SendAlert(notify.SeverityError, notify.CategoryRefund, "UpdateSinglePaymentToUnRefunded", fmt.Sprintf("%s\n%s", payHash, err.Error()))
 		}
 		return
 	}
@@ -4,12 +4,19 @@ import (
 	"fmt"
 	"github.com/dotbitHQ/das-lib/chain/chain_evm"
 	"strings"
+	"unipay/business"
+	"unipay/chainswitch"
 	"unipay/config"
 	"unipay/notify"
 	"unipay/tables"
+	"unipay/txtool"
 )
 
 func (t *ToolRefund) doRefund() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.pickClients()
+
 	// get refund list
 	list, err := t.DbDao.GetViewRefundListWithin3d()
 	if err != nil {
@@ -23,6 +30,21 @@ func (t *ToolRefund) doRefund() error {
 		if v.PayHashStatus != tables.PayHashStatusConfirm && v.RefundStatus != tables.RefundStatusUnRefund {
 			continue
 		}
+		// left unrefunded until the chain switch resumes
+		if chainswitch.IsRefundPaused(v.PayTokenId) {
+			log.Warn("doRefund paused:", v.PayTokenId.GetChain(), v.OrderId, v.PayHash)
+			continue
+		}
+		// the automatic refunds of late payments and failed orders are held here too
+		if v.RefundApprovedAt == 0 && business.NeedRefundApproval(v.BusinessId, v.PayTokenId, v.Amount) {
+			log.Warn("doRefund pending approval:", v.BusinessId, v.OrderId, v.PayHash, v.Amount.String())
+			if err := t.DbDao.UpdatePaymentInfoToPendingApproval(v.PayHash, tables.RefundStatusUnRefund); err != nil {
+				log.Error("UpdatePaymentInfoToPendingApproval err:", err.Error(), v.PayHash)
+			} else {
+				notify.SendAlert(notify.SeverityWarn, notify.CategoryRefund, "RefundPendingApproval", fmt.Sprintf("%s\n%s\n%s\n%s %s\n%s", v.BusinessId, v.OrderId, v.PayHash, v.Amount.String(), v.PayTokenId, v.MetaData))
+			}
+			continue
+		}
 		var parserType tables.ParserType
 		switch v.PayTokenId {
 		case tables.PayTokenIdCKB, tables.PayTokenIdDAS, tables.PayTokenIdCkbCCC:
@@ -53,12 +75,12 @@ func (t *ToolRefund) doRefund() error {
 
 	// do refund
 	var parserTypeAddrMap = make(map[tables.ParserType]map[string]string)
-	parserTypeAddrMap[tables.ParserTypeCKB] = config.Cfg.Chain.Ckb.AddrMap
-	parserTypeAddrMap[tables.ParserTypeDoge] = config.Cfg.Chain.Doge.AddrMap
-	parserTypeAddrMap[tables.ParserTypeTRON] = config.Cfg.Chain.Tron.AddrMap
-	parserTypeAddrMap[tables.ParserTypeETH] = config.Cfg.Chain.Eth.AddrMap
-	parserTypeAddrMap[tables.ParserTypeBSC] = config.Cfg.Chain.Bsc.AddrMap
-	parserTypeAddrMap[tables.ParserTypePOLYGON] = config.Cfg.Chain.Polygon.AddrMap
+	parserTypeAddrMap[tables.ParserTypeCKB] = config.GetCfg().Chain.Ckb.AddrMap
+	parserTypeAddrMap[tables.ParserTypeDoge] = config.GetCfg().Chain.Doge.AddrMap
+	parserTypeAddrMap[tables.ParserTypeTRON] = config.GetCfg().Chain.Tron.AddrMap
+	parserTypeAddrMap[tables.ParserTypeETH] = config.GetCfg().Chain.Eth.AddrMap
+	parserTypeAddrMap[tables.ParserTypeBSC] = config.GetCfg().Chain.Bsc.AddrMap
+	parserTypeAddrMap[tables.ParserTypePOLYGON] = config.GetCfg().Chain.Polygon.AddrMap
 	parserTypeEvmMap, err := t.getParserTypeEvmMap()
 	if err != nil {
 		return fmt.Errorf("getParserTypeEvmMap err: %s", err.Error())
@@ -71,6 +93,7 @@ func (t *ToolRefund) doRefund() error {
 			if !ok {
 				continue
 			}
+			err = nil // only ckb and doge set it
 			switch parserType {
 			case tables.ParserTypeCKB:
 				err = t.doRefundCkb(paymentAddress, private, refundList)
@@ -105,19 +128,22 @@ func (t *ToolRefund) doRefund() error {
 			}
 			if err != nil {
 				log.Error("doRefund err: ", parserType, paymentAddress, err.Error())
-				notify.SendLarkErrNotify("doRefund", err.Error())
+				for _, v := range refundList {
+					txtool.Tools.Metrics.Refund().WithLabelValues(string(v.PayTokenId), "failed").Inc()
+				}
+				notify.SendAlert(notify.SeverityError, notify.CategoryRefund, "doRefund", err.Error())
 			}
 		}
 	}
 	// stripe
 	if err = t.doRefundStripe(stripeList); err != nil {
 		log.Error("doRefundStripe err: ", err.Error())
-		notify.SendLarkErrNotify("doRefundStripe", err.Error())
+		notify.SendAlert(notify.SeverityError, notify.CategoryRefund, "doRefundStripe", err.Error())
 	}
 	// dp
 	if err = t.doRefundDP(dpList); err != nil {
 		log.Error("doRefundDP err: %s", err.Error())
-		notify.SendLarkErrNotify("doRefundDP", err.Error())
+		notify.SendAlert(notify.SeverityError, notify.CategoryRefund, "doRefundDP", err.Error())
 	}
 
 	return nil
@@ -130,18 +156,21 @@ type parserTypeEvm struct {
 	nonceMap map[string]uint64
 }
 
+// getParserTypeEvmMap the client of a chain is picked from the up nodes for the round, the nonces and the txs go to the same node
 func (t *ToolRefund) getParserTypeEvmMap() (map[tables.ParserType]parserTypeEvm, error) {
 	var parserTypeEvmMap = make(map[tables.ParserType]parserTypeEvm)
 	// eth
 	parserTypeETH := parserTypeEvm{
-		addFee:   config.Cfg.Chain.Eth.RefundAddFee,
-		refund:   config.Cfg.Chain.Eth.Refund,
-		chainEvm: t.chainEth,
+		addFee:   config.GetCfg().Chain.Eth.RefundAddFee,
+		refund:   config.GetCfg().Chain.Eth.Refund,
 		nonceMap: make(map[string]uint64),
 	}
-	if t.chainEth != nil {
-		for k, _ := range config.Cfg.Chain.Eth.AddrMap {
-			nonce, err := t.chainEth.NonceAt(k)
+	if t.poolEth != nil {
+		index, chainEvm := t.poolEth.Client()
+		parserTypeETH.chainEvm = chainEvm
+		for k, _ := range config.GetCfg().Chain.Eth.AddrMap {
+			nonce, err := chainEvm.NonceAt(k)
+			t.poolEth.Report(index, err)
 			if err != nil {
 				return nil, fmt.Errorf("NonceAt eth err: %s", err.Error())
 			}
@@ -159,14 +188,16 @@ func (t *ToolRefund) getParserTypeEvmMap() (map[tables.ParserType]parserTypeEvm,
 
 	// bsc
 	parserTypeBSC := parserTypeEvm{
-		addFee:   config.Cfg.Chain.Bsc.RefundAddFee,
-		refund:   config.Cfg.Chain.Bsc.Refund,
-		chainEvm: t.chainBsc,
+		addFee:   config.GetCfg().Chain.Bsc.RefundAddFee,
+		refund:   config.GetCfg().Chain.Bsc.Refund,
 		nonceMap: make(map[string]uint64),
 	}
-	if t.chainBsc != nil {
-		for k, _ := range config.Cfg.Chain.Bsc.AddrMap {
-			nonce, err := t.chainBsc.NonceAt(k)
+	if t.poolBsc != nil {
+		index, chainEvm := t.poolBsc.Client()
+		parserTypeBSC.chainEvm = chainEvm
+		for k, _ := range config.GetCfg().Chain.Bsc.AddrMap {
+			nonce, err := chainEvm.NonceAt(k)
+			t.poolBsc.Report(index, err)
 			if err != nil {
 				return nil, fmt.Errorf("NonceAt bsc err: %s", err.Error())
 			}
@@ -184,14 +215,16 @@ func (t *ToolRefund) getParserTypeEvmMap() (map[tables.ParserType]parserTypeEvm,
 
 	// polygon
 	parserTypePolygon := parserTypeEvm{
-		addFee:   config.Cfg.Chain.Polygon.RefundAddFee,
-		refund:   config.Cfg.Chain.Polygon.Refund,
-		chainEvm: t.chainPolygon,
+		addFee:   config.GetCfg().Chain.Polygon.RefundAddFee,
+		refund:   config.GetCfg().Chain.Polygon.Refund,
 		nonceMap: make(map[string]uint64),
 	}
-	if t.chainPolygon != nil {
-		for k, _ := range config.Cfg.Chain.Polygon.AddrMap {
-			nonce, err := t.chainPolygon.NonceAt(k)
+	if t.poolPolygon != nil {
+		index, chainEvm := t.poolPolygon.Client()
+		parserTypePolygon.chainEvm = chainEvm
+		for k, _ := range config.GetCfg().Chain.Polygon.AddrMap {
+			nonce, err := chainEvm.NonceAt(k)
+			t.poolPolygon.Report(index, err)
 			if err != nil {
 				return nil, fmt.Errorf("NonceAt polygon err: %s", err.Error())
 			}
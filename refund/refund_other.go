@@ -4,9 +4,11 @@ import (
 	"fmt"
 	"unipay/notify"
 	"unipay/tables"
+	"unipay/txtool"
 )
 
 func sendRefundNotify(id uint64, payTokenId tables.PayTokenId, orderId, err string) {
 	msg := fmt.Sprintf("ID: %d\nPayTokenId: %s\nOrderId: %s\nErr: %s", id, payTokenId, orderId, err)
-	notify.SendLarkErrNotify("sendRefundNotify", msg)
+	txtool.Tools.Metrics.Refund().WithLabelValues(string(payTokenId), "failed").Inc()
+	notify.SendAlert(notify.SeverityError, notify.CategoryRefund, "sendRefundNotify", msg)
 }
@@ -10,7 +10,7 @@ import (
 )
 
 func (t *ToolRefund) doRefundStripe(list []tables.ViewRefundPaymentInfo) error {
-	if !config.Cfg.Chain.Stripe.Refund {
+	if !config.GetCfg().Chain.Stripe.Refund {
 		return nil
 	}
 	if len(list) == 0 {
@@ -13,7 +13,7 @@ import (
 )
 
 func (t *ToolRefund) refundTron(paymentAddress, private string, info tables.ViewRefundPaymentInfo) error {
-	if !config.Cfg.Chain.Tron.Refund {
+	if !config.GetCfg().Chain.Tron.Refund {
 		return fmt.Errorf("tron refund flag is false")
 	}
 	if t.chainTron == nil {
@@ -39,7 +39,7 @@ func (t *ToolRefund) refundTron(paymentAddress, private string, info tables.View
 		//	return nil
 		//}
 
-		contractHex := payTokenId.GetContractAddress(config.Cfg.Server.Net)
+		contractHex := payTokenId.GetContractAddress(config.GetCfg().Server.Net)
 		if contractHex, err = common.TronBase58ToHex(contractHex); err != nil {
 			return fmt.Errorf("TronBase58ToHex err: %s", err.Error())
 		}
@@ -63,7 +63,7 @@ func (t *ToolRefund) refundTron(paymentAddress, private string, info tables.View
 		if err != nil {
 			return fmt.Errorf("AddSign err:%s", err.Error())
 		}
-	} else if config.Cfg.Server.RemoteSignApiUrl != "" {
+	} else if config.GetCfg().Server.RemoteSignApiUrl != "" {
 		log.Info("refundTron remote sign")
 		hash, err := chain_tron.GetTxHash(tx)
 		if err != nil {
@@ -73,7 +73,7 @@ func (t *ToolRefund) refundTron(paymentAddress, private string, info tables.View
 		if err != nil {
 			return fmt.Errorf("common.TronHexToBase58 err: %s", err.Error())
 		}
-		signData, err := remote_sign.SignTxForTRON(config.Cfg.Server.RemoteSignApiUrl, fromAddr, hash)
+		signData, err := remote_sign.SignTxForTRON(config.GetCfg().Server.RemoteSignApiUrl, fromAddr, hash)
 		if err != nil {
 			return fmt.Errorf("remote_sign.SignTxForTRON err: %s", err.Error())
 		}
@@ -99,7 +99,7 @@ func (t *ToolRefund) refundTron(paymentAddress, private string, info tables.View
 	if err = t.chainTron.SendTransaction(tx.Transaction); err != nil {
 		if er := t.DbDao.UpdateSinglePaymentToUnRefunded(payHash); er != nil {
 			log.Info("UpdateSinglePaymentToUnRefunded err: ", er.Error(), payHash)
-			notify.SendLarkErrNotify("UpdateSinglePaymentToUnRefunded", fmt.Sprintf("%s\n%s", payHash, er.Error()))
+			notify.SendAlert(notify.SeverityError, notify.CategoryRefund, "UpdateSinglePaymentToUnRefunded", fmt.Sprintf("%s\n%s", payHash, er.Error()))
 		}
 		return fmt.Errorf("SendTx err: %s", err.Error())
 	}
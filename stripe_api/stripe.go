@@ -9,7 +9,8 @@ import (
 	"github.com/stripe/stripe-go/v74/refund"
 )
 
-func CreatePaymentIntent(businessId, orderId string, metadata map[string]string, amount int64) (*stripe.PaymentIntent, error) {
+// CreatePaymentIntent a retry with the same idempotencyKey gets the intent of the first request from stripe
+func CreatePaymentIntent(businessId, orderId, idempotencyKey string, metadata map[string]string, amount int64) (*stripe.PaymentIntent, error) {
 	params := &stripe.PaymentIntentParams{
 		Amount:             stripe.Int64(amount),
 		PaymentMethodTypes: stripe.StringSlice([]string{string(stripe.ChargePaymentMethodDetailsTypeCard)}),
@@ -26,6 +27,9 @@ func CreatePaymentIntent(businessId, orderId string, metadata map[string]string,
 	}
 	params.Metadata["business_id"] = businessId
 	params.Metadata["order_id"] = orderId
+	if idempotencyKey != "" {
+		params.IdempotencyKey = stripe.String(businessId + "_" + idempotencyKey)
+	}
 
 	pi, err := paymentintent.New(params)
 	if err != nil {
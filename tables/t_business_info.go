@@ -0,0 +1,144 @@
+package tables
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type TableBusinessInfo struct {
+	Id                uint64          `json:"id" gorm:"column:id; primaryKey; type:bigint(20) UNSIGNED NOT NULL AUTO_INCREMENT COMMENT '';"`
+	BusinessId        string          `json:"business_id" gorm:"column:business_id; uniqueIndex:uk_business_id; type:varchar(255) NOT NULL DEFAULT '' COMMENT '';"`
+	Name              string          `json:"name" gorm:"column:name; type:varchar(255) NOT NULL DEFAULT '' COMMENT '';"`
+	CallbackUrl       string          `json:"callback_url" gorm:"column:callback_url; type:varchar(1024) NOT NULL DEFAULT '' COMMENT '';"`
+	CallbackUrlBackup string          `json:"callback_url_backup" gorm:"column:callback_url_backup; type:varchar(1024) NOT NULL DEFAULT '' COMMENT 'tried when callback_url fails';"`
+	ApiSecrets        string          `json:"api_secrets" gorm:"column:api_secrets; type:varchar(1024) NOT NULL DEFAULT '' COMMENT 'encrypted, at most 2, see business.EncryptSecrets';"`
+	CallbackSecrets   string          `json:"callback_secrets" gorm:"column:callback_secrets; type:varchar(1024) NOT NULL DEFAULT '' COMMENT 'encrypted, at most 2, see business.EncryptSecrets';"`
+	EnabledTokens     string          `json:"enabled_tokens" gorm:"column:enabled_tokens; type:varchar(1024) NOT NULL DEFAULT '' COMMENT 'comma separated pay token ids, empty for all';"`
+	DefaultExpiry     int64           `json:"default_expiry" gorm:"column:default_expiry; type:bigint(20) NOT NULL DEFAULT '0' COMMENT 'order ttl in seconds, 0 for 3 days';"`
+	Contact           string          `json:"contact" gorm:"column:contact; type:varchar(255) NOT NULL DEFAULT '' COMMENT '';"`
+	CallbackRetry     string          `json:"callback_retry" gorm:"column:callback_retry; type:varchar(255) NOT NULL DEFAULT '' COMMENT 'comma separated seconds between the callback attempts, empty for the default';"`
+	Policy            string          `json:"policy" gorm:"column:policy; type:text COMMENT 'json of BusinessPolicy';"`
+	CallbackVersion   CallbackVersion `json:"callback_version" gorm:"column:callback_version; type:smallint(6) NOT NULL DEFAULT '0' COMMENT '0,1-v1 2-v2';"`
+	Status            BusinessStatus  `json:"status" gorm:"column:status; type:smallint(6) NOT NULL DEFAULT '0' COMMENT '0-Enabled 1-Disabled';"`
+	CreatedAt         time.Time       `json:"created_at" gorm:"column:created_at; type:timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '';"`
+	UpdatedAt         time.Time       `json:"updated_at" gorm:"column:updated_at; type:timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '';"`
+}
+
+const (
+	TableNameBusinessInfo = "t_business_info"
+)
+
+func (t *TableBusinessInfo) TableName() string {
+	return TableNameBusinessInfo
+}
+
+type BusinessStatus int
+
+const (
+	BusinessStatusEnabled  BusinessStatus = 0
+	BusinessStatusDisabled BusinessStatus = 1
+)
+
+// CallbackVersion the schema of the callback body, v2 is opt-in
+type CallbackVersion int
+
+const (
+	CallbackVersion1 CallbackVersion = 1
+	CallbackVersion2 CallbackVersion = 2
+)
+
+func (t *TableBusinessInfo) GetCallbackVersion() CallbackVersion {
+	if t.CallbackVersion < CallbackVersion1 {
+		return CallbackVersion1
+	}
+	return t.CallbackVersion
+}
+
+func (t *TableBusinessInfo) GetCallbackUrls() []string {
+	var list []string
+	for _, v := range []string{t.CallbackUrl, t.CallbackUrlBackup} {
+		if v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// GetCallbackRetry the invalid items are skipped
+func (t *TableBusinessInfo) GetCallbackRetry() []int64 {
+	var list []int64
+	for _, v := range splitList(t.CallbackRetry, 0) {
+		if i, err := strconv.ParseInt(v, 10, 64); err == nil && i > 0 {
+			list = append(list, i)
+		}
+	}
+	return list
+}
+
+// GetEnabledTokens empty for all the tokens
+func (t *TableBusinessInfo) GetEnabledTokens() []PayTokenId {
+	var list []PayTokenId
+	for _, v := range splitList(t.EnabledTokens, 0) {
+		list = append(list, PayTokenId(v))
+	}
+	return list
+}
+
+func (t *TableBusinessInfo) IsTokenEnabled(payTokenId PayTokenId) bool {
+	list := t.GetEnabledTokens()
+	if len(list) == 0 {
+		return true
+	}
+	for _, v := range list {
+		if v == payTokenId {
+			return true
+		}
+	}
+	return false
+}
+
+// BusinessPolicy the amounts are in the smallest unit of the token, the same as the order amount,
+// empty fields are not limited
+type BusinessPolicy struct {
+	Chains            []string                         `json:"chains"` // see PayTokenId.GetChain
+	AmountLimits      map[PayTokenId]PolicyAmountLimit `json:"amount_limits"`
+	PaymentAddresses  map[PayTokenId][]string          `json:"payment_addresses"`   // a subset of addr_map
+	MaxOpenOrders     int64                            `json:"max_open_orders"`     // unpaid orders per payer
+	RefundAutoApprove map[PayTokenId]decimal.Decimal   `json:"refund_auto_approve"` // larger refunds wait for approval
+}
+
+type PolicyAmountLimit struct {
+	Min decimal.Decimal `json:"min"`
+	Max decimal.Decimal `json:"max"`
+}
+
+func (t *TableBusinessInfo) GetPolicy() (policy BusinessPolicy, e error) {
+	if t.Policy == "" {
+		return
+	}
+	if e = json.Unmarshal([]byte(t.Policy), &policy); e != nil {
+		e = fmt.Errorf("policy of business[%s] invalid: %s", t.BusinessId, e.Error())
+	}
+	return
+}
+
+func JoinList(list []string) string {
+	return strings.Join(list, ",")
+}
+
+func splitList(s string, limit int) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	if limit > 0 && len(list) > limit {
+		list = list[:limit]
+	}
+	return list
+}
@@ -0,0 +1,55 @@
+package tables
+
+import (
+	"time"
+)
+
+// TableChainSwitchInfo the runtime kill switches of a chain, a chain without a row runs as configured
+type TableChainSwitchInfo struct {
+	Id           uint64    `json:"id" gorm:"column:id; primaryKey; type:bigint(20) UNSIGNED NOT NULL AUTO_INCREMENT COMMENT '';"`
+	Chain        string    `json:"chain" gorm:"column:chain; uniqueIndex:uk_chain; type:varchar(255) NOT NULL DEFAULT '' COMMENT 'see PayTokenId.GetChain';"`
+	ParsePaused  bool      `json:"parse_paused" gorm:"column:parse_paused; type:tinyint(1) NOT NULL DEFAULT '0' COMMENT '';"`
+	OrderPaused  bool      `json:"order_paused" gorm:"column:order_paused; type:tinyint(1) NOT NULL DEFAULT '0' COMMENT '';"`
+	RefundPaused bool      `json:"refund_paused" gorm:"column:refund_paused; type:tinyint(1) NOT NULL DEFAULT '0' COMMENT '';"`
+	Operator     string    `json:"operator" gorm:"column:operator; type:varchar(255) NOT NULL DEFAULT '' COMMENT 'of the last toggle';"`
+	Reason       string    `json:"reason" gorm:"column:reason; type:varchar(1024) NOT NULL DEFAULT '' COMMENT 'of the last toggle';"`
+	CreatedAt    time.Time `json:"created_at" gorm:"column:created_at; type:timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '';"`
+	UpdatedAt    time.Time `json:"updated_at" gorm:"column:updated_at; type:timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '';"`
+}
+
+const (
+	TableNameChainSwitchInfo = "t_chain_switch_info"
+)
+
+func (t *TableChainSwitchInfo) TableName() string {
+	return TableNameChainSwitchInfo
+}
+
+type ChainSwitchTarget string
+
+const (
+	ChainSwitchTargetParse  ChainSwitchTarget = "parse"
+	ChainSwitchTargetOrder  ChainSwitchTarget = "order"
+	ChainSwitchTargetRefund ChainSwitchTarget = "refund"
+)
+
+// TableChainSwitchLog the audit log, a row per toggled target
+type TableChainSwitchLog struct {
+	Id        uint64            `json:"id" gorm:"column:id; primaryKey; type:bigint(20) UNSIGNED NOT NULL AUTO_INCREMENT COMMENT '';"`
+	Chain     string            `json:"chain" gorm:"column:chain; index:k_chain; type:varchar(255) NOT NULL DEFAULT '' COMMENT '';"`
+	Target    ChainSwitchTarget `json:"target" gorm:"column:target; type:varchar(255) NOT NULL DEFAULT '' COMMENT 'parse, order, refund';"`
+	Paused    bool              `json:"paused" gorm:"column:paused; type:tinyint(1) NOT NULL DEFAULT '0' COMMENT 'the new state';"`
+	Operator  string            `json:"operator" gorm:"column:operator; type:varchar(255) NOT NULL DEFAULT '' COMMENT '';"`
+	Reason    string            `json:"reason" gorm:"column:reason; type:varchar(1024) NOT NULL DEFAULT '' COMMENT '';"`
+	ClientIp  string            `json:"client_ip" gorm:"column:client_ip; type:varchar(255) NOT NULL DEFAULT '' COMMENT '';"`
+	Timestamp int64             `json:"timestamp" gorm:"column:timestamp; type:bigint(20) NOT NULL DEFAULT '0' COMMENT '';"`
+	CreatedAt time.Time         `json:"created_at" gorm:"column:created_at; type:timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '';"`
+}
+
+const (
+	TableNameChainSwitchLog = "t_chain_switch_log"
+)
+
+func (t *TableChainSwitchLog) TableName() string {
+	return TableNameChainSwitchLog
+}
@@ -0,0 +1,35 @@
+package tables
+
+import (
+	"time"
+)
+
+type TableIdempotencyInfo struct {
+	Id             uint64            `json:"id" gorm:"column:id; primaryKey; type:bigint(20) UNSIGNED NOT NULL AUTO_INCREMENT COMMENT '';"`
+	BusinessId     string            `json:"business_id" gorm:"column:business_id; uniqueIndex:uk_business_id_key; type:varchar(255) NOT NULL DEFAULT '' COMMENT '';"`
+	IdempotencyKey string            `json:"idempotency_key" gorm:"column:idempotency_key; uniqueIndex:uk_business_id_key; type:varchar(255) NOT NULL DEFAULT '' COMMENT '';"`
+	Api            string            `json:"api" gorm:"column:api; type:varchar(255) NOT NULL DEFAULT '' COMMENT 'order_create, order_refund';"`
+	RequestHash    string            `json:"request_hash" gorm:"column:request_hash; type:varchar(255) NOT NULL DEFAULT '' COMMENT '';"`
+	Status         IdempotencyStatus `json:"status" gorm:"column:status; type:smallint(6) NOT NULL DEFAULT '0' COMMENT '0-Processing 1-Done';"`
+	Response       string            `json:"response" gorm:"column:response; type:mediumtext NOT NULL COMMENT 'the original api response';"`
+	CreatedAt      time.Time         `json:"created_at" gorm:"column:created_at; type:timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '';"`
+	UpdatedAt      time.Time         `json:"updated_at" gorm:"column:updated_at; type:timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '';"`
+}
+
+const (
+	TableNameIdempotencyInfo = "t_idempotency_info"
+)
+
+func (t *TableIdempotencyInfo) TableName() string {
+	return TableNameIdempotencyInfo
+}
+
+type IdempotencyStatus int
+
+const (
+	IdempotencyStatusProcessing IdempotencyStatus = 0
+	IdempotencyStatusDone       IdempotencyStatus = 1
+)
+
+// IdempotencyProcessingTimeout a request still processing after this is considered lost and can be retried
+const IdempotencyProcessingTimeout = time.Minute * 5
@@ -7,15 +7,20 @@ import (
 )
 
 type TableNoticeInfo struct {
-	Id           uint64       `json:"id" gorm:"column:id; primaryKey; type:bigint(20) UNSIGNED NOT NULL AUTO_INCREMENT COMMENT '';"`
-	NoticeId     string       `json:"notice_id" gorm:"column:notice_id; uniqueIndex:uk_notice_id; type:varchar(255) NOT NULL DEFAULT '' COMMENT '';"`
-	EventType    EventType    `json:"event_type" gorm:"column:event_type; type:varchar(255) NOT NULL DEFAULT '' COMMENT 'ORDER.PAY, ORDER.REFUND';"`
-	PayHash      string       `json:"pay_hash" gorm:"column:pay_hash; index:k_pay_hash; type:varchar(255) NOT NULL DEFAULT '' COMMENT '';"`
-	NoticeCount  int          `json:"notice_count" gorm:"column:notice_count; type:smallint(6) NOT NULL DEFAULT '0' COMMENT '';"`
-	NoticeStatus NoticeStatus `json:"notice_status" gorm:"column:notice_status; type:smallint(6) NOT NULL DEFAULT '0' COMMENT '0-Default 1-OK 2-Fail';"`
-	Timestamp    int64        `json:"timestamp" gorm:"column:timestamp; index:k_timestamp; type:bigint(20) NOT NULL DEFAULT '0' COMMENT '';"`
-	CreatedAt    time.Time    `json:"created_at" gorm:"column:created_at; type:timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '';"`
-	UpdatedAt    time.Time    `json:"updated_at" gorm:"column:updated_at; type:timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '';"`
+	Id             uint64       `json:"id" gorm:"column:id; primaryKey; type:bigint(20) UNSIGNED NOT NULL AUTO_INCREMENT COMMENT '';"`
+	NoticeId       string       `json:"notice_id" gorm:"column:notice_id; uniqueIndex:uk_notice_id; type:varchar(255) NOT NULL DEFAULT '' COMMENT '';"`
+	BusinessId     string       `json:"business_id" gorm:"column:business_id; index:k_business_id_timestamp,priority:1; type:varchar(255) NOT NULL DEFAULT '' COMMENT '';"`
+	EventType      EventType    `json:"event_type" gorm:"column:event_type; type:varchar(255) NOT NULL DEFAULT '' COMMENT 'ORDER.PAY, ORDER.REFUND, ORDER.CANCELLED';"`
+	PayHash        string       `json:"pay_hash" gorm:"column:pay_hash; index:k_pay_hash; type:varchar(255) NOT NULL DEFAULT '' COMMENT '';"`
+	OrderId        string       `json:"order_id" gorm:"column:order_id; index:k_order_id; type:varchar(255) NOT NULL DEFAULT '' COMMENT 'for events without payment';"`
+	NoticeCount    int          `json:"notice_count" gorm:"column:notice_count; type:smallint(6) NOT NULL DEFAULT '0' COMMENT 'failed attempts';"`
+	NoticeStatus   NoticeStatus `json:"notice_status" gorm:"column:notice_status; index:k_notice_status_next_attempt_at,priority:1; type:smallint(6) NOT NULL DEFAULT '0' COMMENT '0-Default 1-OK 2-Fail(dead letter)';"`
+	NextAttemptAt  int64        `json:"next_attempt_at" gorm:"column:next_attempt_at; index:k_notice_status_next_attempt_at,priority:2; type:bigint(20) NOT NULL DEFAULT '0' COMMENT '0 for right away';"`
+	LastError      string       `json:"last_error" gorm:"column:last_error; type:varchar(1024) NOT NULL DEFAULT '' COMMENT '';"`
+	LastHttpStatus int          `json:"last_http_status" gorm:"column:last_http_status; type:int(11) NOT NULL DEFAULT '0' COMMENT '0 if no response';"`
+	Timestamp      int64        `json:"timestamp" gorm:"column:timestamp; index:k_timestamp; index:k_business_id_timestamp,priority:2; type:bigint(20) NOT NULL DEFAULT '0' COMMENT '';"`
+	CreatedAt      time.Time    `json:"created_at" gorm:"column:created_at; type:timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '';"`
+	UpdatedAt      time.Time    `json:"updated_at" gorm:"column:updated_at; type:timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '';"`
 }
 
 const (
@@ -27,7 +32,7 @@ func (t *TableNoticeInfo) TableName() string {
 }
 
 func (t *TableNoticeInfo) InitNoticeId() {
-	noticeId := fmt.Sprintf("%s%s%d", t.EventType, t.PayHash, t.Timestamp)
+	noticeId := fmt.Sprintf("%s%s%s%d", t.EventType, t.PayHash, t.OrderId, t.Timestamp)
 	t.NoticeId = fmt.Sprintf("%x", md5.Sum([]byte(noticeId)))
 }
 
@@ -37,6 +42,7 @@ const (
 	EventTypeOrderPay       EventType = "ORDER.PAY"
 	EventTypeOrderRefund    EventType = "ORDER.REFUND"
 	EventTypePaymentDispute EventType = "PAYMENT.DISPUTE"
+	EventTypeOrderCancelled EventType = "ORDER.CANCELLED"
 )
 
 type NoticeStatus int
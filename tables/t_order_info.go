@@ -2,6 +2,8 @@ package tables
 
 import (
 	"crypto/md5"
+	"crypto/rand"
+	"encoding/json"
 	"fmt"
 	"github.com/dotbitHQ/das-lib/common"
 	"github.com/shopspring/decimal"
@@ -11,18 +13,21 @@ import (
 type TableOrderInfo struct {
 	Id                uint64                `json:"id" gorm:"column:id; primaryKey; type:bigint(20) UNSIGNED NOT NULL AUTO_INCREMENT COMMENT '';"`
 	OrderId           string                `json:"order_id" gorm:"column:order_id; uniqueIndex:uk_order_id; type:varchar(255) NOT NULL DEFAULT '' COMMENT '';"`
-	BusinessId        string                `json:"business_id" gorm:"column:business_id; type:varchar(255) NOT NULL DEFAULT '' COMMENT '';"`
-	PayAddress        string                `json:"pay_address" gorm:"column:pay_address; index:k_pay_address; type:varchar(255) NOT NULL DEFAULT '' COMMENT '';"`
+	BusinessId        string                `json:"business_id" gorm:"column:business_id; index:k_business_id_timestamp,priority:1; index:k_business_id_pay_address,priority:1; index:k_business_id_token_status,priority:1; type:varchar(255) NOT NULL DEFAULT '' COMMENT '';"`
+	PayAddress        string                `json:"pay_address" gorm:"column:pay_address; index:k_pay_address; index:k_business_id_pay_address,priority:2; type:varchar(255) NOT NULL DEFAULT '' COMMENT '';"`
 	AlgorithmId       common.DasAlgorithmId `json:"algorithm_id" gorm:"column:algorithm_id; type:smallint(6) NOT NULL DEFAULT '0' COMMENT '3,5-EVM 4-TRON 7-DOGE';"`
 	Amount            decimal.Decimal       `json:"amount" gorm:"column:amount; type:decimal(60,0) NOT NULL DEFAULT '0' COMMENT '';"`
-	PayTokenId        PayTokenId            `json:"pay_token_id" gorm:"column:pay_token_id; type:varchar(255) NOT NULL DEFAULT '' COMMENT '';"`
-	PayStatus         PayStatus             `json:"pay_status" gorm:"column:pay_status; type:smallint(6) NOT NULL DEFAULT '0' COMMENT '0-Unpaid 1-Paid';"`
-	OrderStatus       OrderStatus           `json:"order_status" gorm:"column:order_status; type:smallint(6) NOT NULL DEFAULT '0' COMMENT '0-Normal 1-Cancel';"`
-	Timestamp         int64                 `json:"timestamp" gorm:"column:timestamp; index:k_timestamp; type:bigint(20) NOT NULL DEFAULT '0' COMMENT '';"`
+	PayTokenId        PayTokenId            `json:"pay_token_id" gorm:"column:pay_token_id; index:k_business_id_token_status,priority:2; type:varchar(255) NOT NULL DEFAULT '' COMMENT '';"`
+	PayStatus         PayStatus             `json:"pay_status" gorm:"column:pay_status; index:k_business_id_token_status,priority:3; type:smallint(6) NOT NULL DEFAULT '0' COMMENT '0-Unpaid 1-Paid';"`
+	OrderStatus       OrderStatus           `json:"order_status" gorm:"column:order_status; type:smallint(6) NOT NULL DEFAULT '0' COMMENT '0-Normal 1-Success 2-Fail 3-Cancel';"`
+	Timestamp         int64                 `json:"timestamp" gorm:"column:timestamp; index:k_timestamp; index:k_business_id_timestamp,priority:2; index:k_business_id_pay_address,priority:3; index:k_business_id_token_status,priority:4; type:bigint(20) NOT NULL DEFAULT '0' COMMENT '';"`
 	PaymentAddress    string                `json:"payment_address" gorm:"column:payment_address; type:varchar(255) NOT NULL DEFAULT '' COMMENT '';"`
 	PremiumPercentage decimal.Decimal       `json:"premium_percentage" gorm:"column:premium_percentage; type:decimal(20,10) NOT NULL DEFAULT '0' COMMENT '';"`
 	PremiumBase       decimal.Decimal       `json:"premium_base" gorm:"column:premium_base; type:decimal(20,10) NOT NULL DEFAULT '0' COMMENT '';"`
 	PremiumAmount     decimal.Decimal       `json:"premium_amount" gorm:"column:premium_amount; type:decimal(60,0) NOT NULL DEFAULT '0' COMMENT '';"`
+	AmountOffset      decimal.Decimal       `json:"amount_offset" gorm:"column:amount_offset; type:decimal(60,0) NOT NULL DEFAULT '0' COMMENT 'unique amount offset';"`
+	ExpiredAt         int64                 `json:"expired_at" gorm:"column:expired_at; type:bigint(20) NOT NULL DEFAULT '0' COMMENT '0 for 3 days after timestamp';"`
+	MetaData          string                `json:"meta_data" gorm:"column:meta_data; type:text COMMENT 'json of the meta_data of order create';"`
 	CreatedAt         time.Time             `json:"created_at" gorm:"column:created_at; type:timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '';"`
 	UpdatedAt         time.Time             `json:"updated_at" gorm:"column:updated_at; type:timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '';"`
 }
@@ -35,6 +40,38 @@ func (t *TableOrderInfo) TableName() string {
 	return TableNameOrderInfo
 }
 
+// GetExpiredAt an order can be paid within 3 days, see GetEfficientOrderTimestamp,
+// or before expired_at if the business has a shorter ttl
+func (t *TableOrderInfo) GetExpiredAt() int64 {
+	if t.ExpiredAt > 0 {
+		return t.ExpiredAt
+	}
+	return t.Timestamp + MaxOrderTTL.Milliseconds()
+}
+
+func (t *TableOrderInfo) IsExpired() bool {
+	return time.Now().UnixMilli() > t.GetExpiredAt()
+}
+
+const MaxOrderTTL = time.Hour * 24 * 3
+
+const (
+	OrderMetaDataMaxKeys     = 20
+	OrderMetaDataMaxKeyLen   = 64
+	OrderMetaDataMaxValueLen = 1024
+	OrderMetaDataMaxSize     = 4096 // bytes of the json
+	OrderMetaIndexMaxLen     = 255  // of an indexed value
+)
+
+// GetMetaData empty if the order has none
+func (t *TableOrderInfo) GetMetaData() map[string]string {
+	m := make(map[string]string)
+	if t.MetaData != "" {
+		_ = json.Unmarshal([]byte(t.MetaData), &m)
+	}
+	return m
+}
+
 func GetEfficientOrderTimestamp() int64 {
 	return time.Now().Add(-time.Hour * 24 * 3).UnixMilli()
 }
@@ -84,6 +121,90 @@ func (p PayTokenId) GetContractAddress(net common.DasNetType) string {
 	return contract
 }
 
+func (p PayTokenId) GetDecimals() int32 {
+	switch p {
+	case PayTokenIdETH, PayTokenIdBNB, PayTokenIdMATIC, PayTokenIdPOL, PayTokenIdBep20USDT:
+		return 18
+	case PayTokenIdErc20USDT, PayTokenIdTRX, PayTokenIdTrc20USDT, PayTokenIdDIDPoint:
+		return 6
+	case PayTokenIdDOGE, PayTokenIdDAS, PayTokenIdCKB, PayTokenIdCkbCCC:
+		return 8
+	case PayTokenIdStripeUSD:
+		return 2
+	}
+	return 0
+}
+
+// GetChain the chain names used in the business policy
+func (p PayTokenId) GetChain() string {
+	switch p {
+	case PayTokenIdETH, PayTokenIdErc20USDT:
+		return "ETH"
+	case PayTokenIdTRX, PayTokenIdTrc20USDT:
+		return "TRON"
+	case PayTokenIdBNB, PayTokenIdBep20USDT:
+		return "BSC"
+	case PayTokenIdMATIC, PayTokenIdPOL:
+		return "POLYGON"
+	case PayTokenIdDOGE:
+		return "DOGE"
+	case PayTokenIdDAS, PayTokenIdCKB, PayTokenIdCkbCCC:
+		return "CKB"
+	case PayTokenIdStripeUSD:
+		return "STRIPE"
+	case PayTokenIdDIDPoint:
+		return "DP"
+	}
+	return ""
+}
+
+// ToParserType the parser of the chain, false for stripe and the internal tokens
+func (p PayTokenId) ToParserType() (ParserType, bool) {
+	switch p {
+	case PayTokenIdETH, PayTokenIdErc20USDT:
+		return ParserTypeETH, true
+	case PayTokenIdTRX, PayTokenIdTrc20USDT:
+		return ParserTypeTRON, true
+	case PayTokenIdBNB, PayTokenIdBep20USDT:
+		return ParserTypeBSC, true
+	case PayTokenIdMATIC, PayTokenIdPOL:
+		return ParserTypePOLYGON, true
+	case PayTokenIdDOGE:
+		return ParserTypeDoge, true
+	case PayTokenIdDAS, PayTokenIdCKB, PayTokenIdCkbCCC:
+		return ParserTypeCKB, true
+	case PayTokenIdDIDPoint:
+		return ParserTypeDP, true
+	}
+	return 0, false
+}
+
+func (p PayTokenId) GetSymbol() string {
+	switch p {
+	case PayTokenIdETH:
+		return "ETH"
+	case PayTokenIdErc20USDT, PayTokenIdTrc20USDT, PayTokenIdBep20USDT:
+		return "USDT"
+	case PayTokenIdTRX:
+		return "TRX"
+	case PayTokenIdBNB:
+		return "BNB"
+	case PayTokenIdMATIC:
+		return "MATIC"
+	case PayTokenIdPOL:
+		return "POL"
+	case PayTokenIdDOGE:
+		return "DOGE"
+	case PayTokenIdDAS, PayTokenIdCKB, PayTokenIdCkbCCC:
+		return "CKB"
+	case PayTokenIdStripeUSD:
+		return "USD"
+	case PayTokenIdDIDPoint:
+		return "DP"
+	}
+	return ""
+}
+
 type PayStatus int
 
 const (
@@ -98,9 +219,20 @@ const (
 	OrderStatusNormal  OrderStatus = 0
 	OrderStatusSuccess OrderStatus = 1
 	OrderStatusFail    OrderStatus = 2
+	OrderStatusCancel  OrderStatus = 3
 )
 
+// InitOrderId the random part keeps the orders of the same payer and amount in the same millisecond apart
 func (t *TableOrderInfo) InitOrderId() {
-	orderId := fmt.Sprintf("%s%s%s%s%d", t.BusinessId, t.PayAddress, t.PayTokenId, t.Amount.String(), t.Timestamp)
+	nonce := make([]byte, 8)
+	_, _ = rand.Read(nonce)
+	orderId := fmt.Sprintf("%s%s%s%s%d%x", t.BusinessId, t.PayAddress, t.PayTokenId, t.Amount.String(), t.Timestamp, nonce)
+	t.OrderId = fmt.Sprintf("%x", md5.Sum([]byte(orderId)))
+}
+
+// InitOrderIdByIdempotencyKey a retry of a failed create gets the same order id, so its stripe request is the same too,
+// a key reused after its idempotency record is gone gets the id of the earlier order
+func (t *TableOrderInfo) InitOrderIdByIdempotencyKey(idempotencyKey string) {
+	orderId := fmt.Sprintf("%s%s%s%s%s", t.BusinessId, t.PayAddress, t.PayTokenId, t.Amount.String(), idempotencyKey)
 	t.OrderId = fmt.Sprintf("%x", md5.Sum([]byte(orderId)))
 }
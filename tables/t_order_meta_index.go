@@ -0,0 +1,43 @@
+package tables
+
+import "time"
+
+// TableOrderMetaIndex the indexed keys of the order meta_data, see config order_meta.indexed_keys
+type TableOrderMetaIndex struct {
+	Id         uint64    `json:"id" gorm:"column:id; primaryKey; type:bigint(20) UNSIGNED NOT NULL AUTO_INCREMENT COMMENT '';"`
+	OrderId    string    `json:"order_id" gorm:"column:order_id; uniqueIndex:uk_order_id_meta_key,priority:1; type:varchar(255) NOT NULL DEFAULT '' COMMENT '';"`
+	BusinessId string    `json:"business_id" gorm:"column:business_id; index:k_business_id_meta,priority:1; type:varchar(255) NOT NULL DEFAULT '' COMMENT '';"`
+	MetaKey    string    `json:"meta_key" gorm:"column:meta_key; uniqueIndex:uk_order_id_meta_key,priority:2; index:k_business_id_meta,priority:2; type:varchar(64) NOT NULL DEFAULT '' COMMENT '';"`
+	MetaValue  string    `json:"meta_value" gorm:"column:meta_value; index:k_business_id_meta,priority:3; type:varchar(255) NOT NULL DEFAULT '' COMMENT '';"`
+	Timestamp  int64     `json:"timestamp" gorm:"column:timestamp; index:k_business_id_meta,priority:4; type:bigint(20) NOT NULL DEFAULT '0' COMMENT 'of the order';"`
+	CreatedAt  time.Time `json:"created_at" gorm:"column:created_at; type:timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '';"`
+	UpdatedAt  time.Time `json:"updated_at" gorm:"column:updated_at; type:timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '';"`
+}
+
+const (
+	TableNameOrderMetaIndex = "t_order_meta_index"
+)
+
+func (t *TableOrderMetaIndex) TableName() string {
+	return TableNameOrderMetaIndex
+}
+
+// GetMetaIndexList the values of the keys in the meta_data of the order
+func (t *TableOrderInfo) GetMetaIndexList(keys []string) []TableOrderMetaIndex {
+	var list []TableOrderMetaIndex
+	metaData := t.GetMetaData()
+	for _, k := range keys {
+		v, ok := metaData[k]
+		if !ok || v == "" || len(v) > OrderMetaIndexMaxLen {
+			continue
+		}
+		list = append(list, TableOrderMetaIndex{
+			OrderId:    t.OrderId,
+			BusinessId: t.BusinessId,
+			MetaKey:    k,
+			MetaValue:  v,
+			Timestamp:  t.Timestamp,
+		})
+	}
+	return list
+}
@@ -7,21 +7,25 @@ import (
 )
 
 type TablePaymentInfo struct {
-	Id            uint64                `json:"id" gorm:"column:id; primaryKey; type:bigint(20) UNSIGNED NOT NULL AUTO_INCREMENT COMMENT '';"`
-	PayHash       string                `json:"pay_hash" gorm:"column:pay_hash; uniqueIndex:uk_pay_hash; type:varchar(255) NOT NULL DEFAULT '' COMMENT '';"`
-	OrderId       string                `json:"order_id" gorm:"column:order_id; index:k_order_id; type:varchar(255) NOT NULL DEFAULT '' COMMENT '';"`
-	PayAddress    string                `json:"pay_address" gorm:"column:pay_address; index:k_pay_address; type:varchar(255) NOT NULL DEFAULT '' COMMENT '';"`
-	AlgorithmId   common.DasAlgorithmId `json:"algorithm_id" gorm:"column:algorithm_id; type:smallint(6) NOT NULL DEFAULT '0' COMMENT '3,5-EVM 4-TRON 7-DOGE';"`
-	Timestamp     int64                 `json:"timestamp" gorm:"column:timestamp; index:k_timestamp; type:bigint(20) NOT NULL DEFAULT '0' COMMENT '';"`
-	Amount        decimal.Decimal       `json:"amount" gorm:"column:amount; type:decimal(60,0) NOT NULL DEFAULT '0' COMMENT '';"` // diff from order
-	PayTokenId    PayTokenId            `json:"pay_token_id" gorm:"column:pay_token_id; type:varchar(255) NOT NULL DEFAULT '' COMMENT '';"`
-	PayHashStatus PayHashStatus         `json:"pay_hash_status" gorm:"column:pay_hash_status; type:smallint(6) NOT NULL DEFAULT '0' COMMENT '0-Pending 1-Confirm 2-Fail';"`
-	RefundStatus  RefundStatus          `json:"refund_status" gorm:"column:refund_status; type:smallint(6) NOT NULL DEFAULT '0' COMMENT '0-Default 1-UnRefunded 2-Refunded';"`
-	RefundHash    string                `json:"refund_hash" gorm:"column:refund_hash; type:varchar(255) NOT NULL DEFAULT '' COMMENT '';"`
-	RefundNonce   uint64                `json:"refund_nonce" gorm:"column:refund_nonce; index:k_refund_nonce; type:int(11) NOT NULL DEFAULT '0' COMMENT '';"`
-	RefundFrom    string                `json:"refund_from" gorm:"column:refund_from; type:varchar(255) NOT NULL DEFAULT '' COMMENT '';"`
-	CreatedAt     time.Time             `json:"created_at" gorm:"column:created_at; type:timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '';"`
-	UpdatedAt     time.Time             `json:"updated_at" gorm:"column:updated_at; type:timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '';"`
+	Id               uint64                `json:"id" gorm:"column:id; primaryKey; type:bigint(20) UNSIGNED NOT NULL AUTO_INCREMENT COMMENT '';"`
+	PayHash          string                `json:"pay_hash" gorm:"column:pay_hash; uniqueIndex:uk_pay_hash; type:varchar(255) NOT NULL DEFAULT '' COMMENT '';"`
+	OrderId          string                `json:"order_id" gorm:"column:order_id; index:k_order_id; index:k_order_id_refund_status,priority:1; type:varchar(255) NOT NULL DEFAULT '' COMMENT '';"`
+	PayAddress       string                `json:"pay_address" gorm:"column:pay_address; index:k_pay_address; type:varchar(255) NOT NULL DEFAULT '' COMMENT '';"`
+	AlgorithmId      common.DasAlgorithmId `json:"algorithm_id" gorm:"column:algorithm_id; type:smallint(6) NOT NULL DEFAULT '0' COMMENT '3,5-EVM 4-TRON 7-DOGE';"`
+	Timestamp        int64                 `json:"timestamp" gorm:"column:timestamp; index:k_timestamp; type:bigint(20) NOT NULL DEFAULT '0' COMMENT '';"`
+	Amount           decimal.Decimal       `json:"amount" gorm:"column:amount; type:decimal(60,0) NOT NULL DEFAULT '0' COMMENT '';"` // diff from order
+	PayTokenId       PayTokenId            `json:"pay_token_id" gorm:"column:pay_token_id; type:varchar(255) NOT NULL DEFAULT '' COMMENT '';"`
+	PayHashStatus    PayHashStatus         `json:"pay_hash_status" gorm:"column:pay_hash_status; type:smallint(6) NOT NULL DEFAULT '0' COMMENT '0-Pending 1-Confirm 2-Fail';"`
+	RefundStatus     RefundStatus          `json:"refund_status" gorm:"column:refund_status; index:k_order_id_refund_status,priority:2; type:smallint(6) NOT NULL DEFAULT '0' COMMENT '0-Default 1-UnRefunded 2-Refunding 3-Refunded 4-RefuseToRefund 5-PendingApproval';"`
+	RefundHash       string                `json:"refund_hash" gorm:"column:refund_hash; type:varchar(255) NOT NULL DEFAULT '' COMMENT '';"`
+	RefundNonce      uint64                `json:"refund_nonce" gorm:"column:refund_nonce; index:k_refund_nonce; type:int(11) NOT NULL DEFAULT '0' COMMENT '';"`
+	RefundFrom       string                `json:"refund_from" gorm:"column:refund_from; type:varchar(255) NOT NULL DEFAULT '' COMMENT '';"`
+	RefundApprovedAt int64                 `json:"refund_approved_at" gorm:"column:refund_approved_at; type:bigint(20) NOT NULL DEFAULT '0' COMMENT 'approved by admin over the auto approval limit';"`
+	BlockNumber      uint64                `json:"block_number" gorm:"column:block_number; type:bigint(20) UNSIGNED NOT NULL DEFAULT '0' COMMENT '';"`
+	BlockHash        string                `json:"block_hash" gorm:"column:block_hash; type:varchar(255) NOT NULL DEFAULT '' COMMENT '';"`
+	Confirmations    uint64                `json:"confirmations" gorm:"column:confirmations; type:int(11) NOT NULL DEFAULT '0' COMMENT 'confirm num of the parser when accepted';"`
+	CreatedAt        time.Time             `json:"created_at" gorm:"column:created_at; type:timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '';"`
+	UpdatedAt        time.Time             `json:"updated_at" gorm:"column:updated_at; type:timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '';"`
 }
 
 const (
@@ -48,11 +52,12 @@ const (
 type RefundStatus int
 
 const (
-	RefundStatusDefault        RefundStatus = 0
-	RefundStatusUnRefund       RefundStatus = 1
-	RefundStatusRefunding      RefundStatus = 2
-	RefundStatusRefunded       RefundStatus = 3
-	RefundStatusRefuseToRefund RefundStatus = 4
+	RefundStatusDefault         RefundStatus = 0
+	RefundStatusUnRefund        RefundStatus = 1
+	RefundStatusRefunding       RefundStatus = 2
+	RefundStatusRefunded        RefundStatus = 3
+	RefundStatusRefuseToRefund  RefundStatus = 4
+	RefundStatusPendingApproval RefundStatus = 5 // over the refund_auto_approve of the business policy
 )
 
 type ViewRefundPaymentInfo struct {
@@ -63,13 +68,15 @@ type ViewRefundPaymentInfo struct {
 	PayAddress  string                `json:"pay_address" gorm:"column:pay_address;"`
 	AlgorithmId common.DasAlgorithmId `json:"algorithm_id" gorm:"column:algorithm_id;"`
 	//Timestamp      int64                 `json:"timestamp" gorm:"column:timestamp;"`
-	Amount        decimal.Decimal `json:"amount" gorm:"column:amount;"` // diff from order
-	PayTokenId    PayTokenId      `json:"pay_token_id" gorm:"column:pay_token_id;"`
-	PayHashStatus PayHashStatus   `json:"pay_hash_status" gorm:"column:pay_hash_status;"`
-	RefundStatus  RefundStatus    `json:"refund_status" gorm:"column:refund_status;"`
+	Amount           decimal.Decimal `json:"amount" gorm:"column:amount;"` // diff from order
+	PayTokenId       PayTokenId      `json:"pay_token_id" gorm:"column:pay_token_id;"`
+	PayHashStatus    PayHashStatus   `json:"pay_hash_status" gorm:"column:pay_hash_status;"`
+	RefundStatus     RefundStatus    `json:"refund_status" gorm:"column:refund_status;"`
+	RefundApprovedAt int64           `json:"refund_approved_at" gorm:"column:refund_approved_at;"`
 	//RefundHash     string                `json:"refund_hash" gorm:"column:refund_hash;"`
 	//RefundNonce    uint64                `json:"refund_nonce" gorm:"column:refund_nonce;"`
 	PaymentAddress    string          `json:"payment_address" gorm:"column:payment_address;"`
 	PremiumPercentage decimal.Decimal `json:"premium_percentage" gorm:"column:premium_percentage;"`
 	PremiumBase       decimal.Decimal `json:"premium_base" gorm:"column:premium_base;"`
+	MetaData          string          `json:"meta_data" gorm:"column:meta_data;"`
 }
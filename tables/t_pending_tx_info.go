@@ -0,0 +1,41 @@
+package tables
+
+import (
+	"github.com/shopspring/decimal"
+	"time"
+)
+
+// TablePendingTxInfo a payment seen by the mempool watcher, never credits the order,
+// the parser still does that when the tx reaches the confirm num
+type TablePendingTxInfo struct {
+	Id            uint64          `json:"id" gorm:"column:id; primaryKey; type:bigint(20) UNSIGNED NOT NULL AUTO_INCREMENT COMMENT '';"`
+	PayHash       string          `json:"pay_hash" gorm:"column:pay_hash; uniqueIndex:uk_pay_hash; type:varchar(255) NOT NULL DEFAULT '' COMMENT '';"`
+	OrderId       string          `json:"order_id" gorm:"column:order_id; index:k_order_id; type:varchar(255) NOT NULL DEFAULT '' COMMENT '';"`
+	ParserType    ParserType      `json:"parser_type" gorm:"column:parser_type; index:k_parser_type_status,priority:1; type:smallint(6) NOT NULL DEFAULT '0' COMMENT '';"`
+	PayAddress    string          `json:"pay_address" gorm:"column:pay_address; type:varchar(255) NOT NULL DEFAULT '' COMMENT '';"`
+	Amount        decimal.Decimal `json:"amount" gorm:"column:amount; type:decimal(60,0) NOT NULL DEFAULT '0' COMMENT '';"`
+	PayTokenId    PayTokenId      `json:"pay_token_id" gorm:"column:pay_token_id; type:varchar(255) NOT NULL DEFAULT '' COMMENT '';"`
+	BlockNumber   uint64          `json:"block_number" gorm:"column:block_number; type:bigint(20) UNSIGNED NOT NULL DEFAULT '0' COMMENT '0-in the mempool';"`
+	Confirmations uint64          `json:"confirmations" gorm:"column:confirmations; type:bigint(20) UNSIGNED NOT NULL DEFAULT '0' COMMENT '';"`
+	ConfirmNum    uint64          `json:"confirm_num" gorm:"column:confirm_num; type:bigint(20) UNSIGNED NOT NULL DEFAULT '0' COMMENT 'of the parser';"`
+	Status        PendingTxStatus `json:"status" gorm:"column:status; index:k_parser_type_status,priority:2; type:smallint(6) NOT NULL DEFAULT '0' COMMENT '0-Seen 1-Credited 2-Dropped';"`
+	Timestamp     int64           `json:"timestamp" gorm:"column:timestamp; type:bigint(20) NOT NULL DEFAULT '0' COMMENT 'first seen';"`
+	CreatedAt     time.Time       `json:"created_at" gorm:"column:created_at; type:timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '';"`
+	UpdatedAt     time.Time       `json:"updated_at" gorm:"column:updated_at; type:timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '';"`
+}
+
+const (
+	TableNamePendingTxInfo = "t_pending_tx_info"
+)
+
+func (t *TablePendingTxInfo) TableName() string {
+	return TableNamePendingTxInfo
+}
+
+type PendingTxStatus int
+
+const (
+	PendingTxStatusSeen     PendingTxStatus = 0
+	PendingTxStatusCredited PendingTxStatus = 1 // the parser did the payment
+	PendingTxStatusDropped  PendingTxStatus = 2 // left the mempool without a block
+)
@@ -0,0 +1,34 @@
+package tables
+
+import (
+	"github.com/shopspring/decimal"
+	"time"
+)
+
+type TableUniqueAmountInfo struct {
+	Id             uint64             `json:"id" gorm:"column:id; primaryKey; type:bigint(20) UNSIGNED NOT NULL AUTO_INCREMENT COMMENT '';"`
+	PaymentAddress string             `json:"payment_address" gorm:"column:payment_address; uniqueIndex:uk_address_token_amount; type:varchar(255) NOT NULL DEFAULT '' COMMENT '';"`
+	PayTokenId     PayTokenId         `json:"pay_token_id" gorm:"column:pay_token_id; uniqueIndex:uk_address_token_amount; type:varchar(255) NOT NULL DEFAULT '' COMMENT '';"`
+	Amount         decimal.Decimal    `json:"amount" gorm:"column:amount; uniqueIndex:uk_address_token_amount; type:decimal(60,0) NOT NULL DEFAULT '0' COMMENT '';"`
+	OrderId        string             `json:"order_id" gorm:"column:order_id; index:k_order_id; type:varchar(255) NOT NULL DEFAULT '' COMMENT '';"`
+	Status         UniqueAmountStatus `json:"status" gorm:"column:status; type:smallint(6) NOT NULL DEFAULT '0' COMMENT '0-Occupied 1-Released';"`
+	CollisionCount int                `json:"collision_count" gorm:"column:collision_count; type:int(11) NOT NULL DEFAULT '0' COMMENT '';"`
+	ExpiredAt      int64              `json:"expired_at" gorm:"column:expired_at; type:bigint(20) NOT NULL DEFAULT '0' COMMENT '';"`
+	CreatedAt      time.Time          `json:"created_at" gorm:"column:created_at; type:timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '';"`
+	UpdatedAt      time.Time          `json:"updated_at" gorm:"column:updated_at; type:timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '';"`
+}
+
+const (
+	TableNameUniqueAmountInfo = "t_unique_amount_info"
+)
+
+func (t *TableUniqueAmountInfo) TableName() string {
+	return TableNameUniqueAmountInfo
+}
+
+type UniqueAmountStatus int
+
+const (
+	UniqueAmountStatusOccupied UniqueAmountStatus = 0
+	UniqueAmountStatusReleased UniqueAmountStatus = 1
+)
@@ -0,0 +1,49 @@
+package timer
+
+import (
+	"fmt"
+	"time"
+	"unipay/tables"
+	"unipay/txtool"
+)
+
+// RunMetrics the metrics read from the db, the refund queue is worked by cmd/refund
+func (t *ToolTimer) RunMetrics() {
+	tickerMetrics := time.NewTicker(time.Second * 30)
+	t.Wg.Add(1)
+	go func() {
+		for {
+			select {
+			case <-tickerMetrics.C:
+				if err := t.doRefundQueueMetrics(); err != nil {
+					log.Error("doRefundQueueMetrics err: ", err.Error())
+				}
+			case <-t.Ctx.Done():
+				log.Warn("RunMetrics done")
+				t.Wg.Done()
+				return
+			}
+		}
+	}()
+}
+
+var refundQueueLabels = map[tables.RefundStatus]string{
+	tables.RefundStatusUnRefund:        "un_refund",
+	tables.RefundStatusRefunding:       "refunding",
+	tables.RefundStatusPendingApproval: "pending_approval",
+}
+
+func (t *ToolTimer) doRefundQueueMetrics() error {
+	list, err := t.DbDao.GetRefundQueueCount()
+	if err != nil {
+		return fmt.Errorf("GetRefundQueueCount err: %s", err.Error())
+	}
+	countMap := make(map[tables.RefundStatus]int64)
+	for _, v := range list {
+		countMap[v.RefundStatus] = v.Count
+	}
+	for status, label := range refundQueueLabels {
+		txtool.Tools.Metrics.RefundQueue().WithLabelValues(label).Set(float64(countMap[status]))
+	}
+	return nil
+}
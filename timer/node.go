@@ -3,7 +3,6 @@ package timer
 import (
 	"fmt"
 	"time"
-	"unipay/config"
 	"unipay/notify"
 	"unipay/tables"
 )
@@ -21,7 +20,7 @@ func (t *ToolTimer) RunCheckNode() {
 			case <-tickerNode.C:
 				if err := t.doCheckNode(); err != nil {
 					log.Error("doCheckNode err: ", err.Error())
-					notify.SendLarkErrNotify("doCheckNode", err.Error())
+					notify.SendAlert(notify.SeverityError, notify.CategoryNode, "doCheckNode", err.Error())
 				}
 			case <-t.Ctx.Done():
 				log.Warn("RunCallbackNotice done")
@@ -41,7 +40,7 @@ func (t *ToolTimer) doCheckNode() error {
 		if bn, ok := nodeMap[v.ParserType]; ok {
 			if v.BlockNumber <= bn {
 				msg := fmt.Sprintf("ParserType(%s), ParserBlockNumber[%d]", v.ParserType.ToString(), v.BlockNumber)
-				notify.SendLarkTextNotify(config.Cfg.Notify.LarkErrorKey, "doCheckNode", msg)
+				notify.SendAlert(notify.SeverityWarn, notify.CategoryNode, "doCheckNode", msg)
 			}
 		}
 		nodeMap[v.ParserType] = v.BlockNumber
@@ -12,10 +12,10 @@ func (t *ToolTimer) doCallbackNotice() error {
 	log.Debug("doCallbackNotice start")
 	defer log.Debug("doCallbackNotice end")
 
-	// get 24h un notify list
-	list, err := t.DbDao.Get24HUnNotifyList()
+	// get the due notices, the rest are picked up in the next rounds
+	list, err := t.DbDao.GetDueNoticeList(1000)
 	if err != nil {
-		return fmt.Errorf("Get24HUnNotifyList err: %s", err.Error())
+		return fmt.Errorf("GetDueNoticeList err: %s", err.Error())
 	}
 	if len(list) == 0 {
 		return nil
@@ -33,6 +33,7 @@ func (t *ToolTimer) doCallbackNotice() error {
 				businessId, eventInfo, er := t.CN.GetEventInfo(notice)
 				if er != nil {
 					log.Error("GetEventInfo err: ", er.Error(), notice.PayHash)
+					t.CN.HandleNoticeFailed(notice, 0, er)
 					continue
 				}
 				if businessId != "" && eventInfo.OrderId != "" {
@@ -15,7 +15,7 @@ func (t *ToolTimer) RunCheckRefundNum() {
 			case <-tickerCheck.C:
 				if err := t.checkRefundNum(); err != nil {
 					log.Error("checkRefundNum err: ", err.Error())
-					notify.SendLarkErrNotify("checkRefundNum", err.Error())
+					notify.SendAlert(notify.SeverityError, notify.CategoryRefund, "checkRefundNum", err.Error())
 				}
 			case <-t.Ctx.Done():
 				log.Warn("RunCheckRefundNum done")
@@ -36,6 +36,6 @@ func (t *ToolTimer) checkRefundNum() error {
 	}
 	log.Info("checkRefundNum:", countRefund)
 	msg := fmt.Sprintf("> count: %d", countRefund)
-	notify.SendLarkErrNotify("UnRefunded Txs", msg)
+	notify.SendAlert(notify.SeverityWarn, notify.CategoryRefund, "UnRefunded Txs", msg)
 	return nil
 }
@@ -18,7 +18,7 @@ func (t *ToolTimer) RunCheckStripeStatus() {
 			case <-tickerStripe.C:
 				if err := t.checkStripeStatus(); err != nil {
 					log.Error("checkStripeStatus err: ", err.Error())
-					notify.SendLarkErrNotify("checkStripeStatus", err.Error())
+					notify.SendAlert(notify.SeverityError, notify.CategoryStripe, "checkStripeStatus", err.Error())
 				}
 			case <-t.Ctx.Done():
 				log.Warn("RunCheckStripeStatus done")
@@ -31,7 +31,7 @@ func (t *ToolTimer) RunCallbackNotice() {
 			case <-tickerCallback.C:
 				if err := t.doCallbackNotice(); err != nil {
 					log.Error("doCallbackNotice err: ", err.Error())
-					notify.SendLarkErrNotify("doCallbackNotice", err.Error())
+					notify.SendAlert(notify.SeverityError, notify.CategoryCallback, "doCallbackNotice", err.Error())
 				}
 			case <-t.Ctx.Done():
 				log.Warn("RunCallbackNotice done")
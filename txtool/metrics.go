@@ -0,0 +1,169 @@
+package txtool
+
+import (
+	"database/sql"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// the labels are bounded: parser types, pay token ids, business ids and fixed results
+
+func (m *Metric) counterVec(p **prometheus.CounterVec, name, help string, labels ...string) *prometheus.CounterVec {
+	m.l.Lock()
+	defer m.l.Unlock()
+	if *p == nil {
+		*p = prometheus.NewCounterVec(prometheus.CounterOpts{Name: name, Help: help}, labels)
+		PromRegister.MustRegister(*p)
+	}
+	return *p
+}
+
+func (m *Metric) gaugeVec(p **prometheus.GaugeVec, name, help string, labels ...string) *prometheus.GaugeVec {
+	m.l.Lock()
+	defer m.l.Unlock()
+	if *p == nil {
+		*p = prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: name, Help: help}, labels)
+		PromRegister.MustRegister(*p)
+	}
+	return *p
+}
+
+func (m *Metric) ParserCurrentBlock() *prometheus.GaugeVec {
+	return m.gaugeVec(&m.parserCurrentBlock, "parser_current_block", "the next block to parse", "parser")
+}
+
+func (m *Metric) ParserChainHead() *prometheus.GaugeVec {
+	return m.gaugeVec(&m.parserChainHead, "parser_chain_head", "the latest block of the node", "parser")
+}
+
+func (m *Metric) ParserLag() *prometheus.GaugeVec {
+	return m.gaugeVec(&m.parserLag, "parser_lag", "chain head minus current block, the confirm num included", "parser")
+}
+
+func (m *Metric) ParserBlocks() *prometheus.CounterVec {
+	return m.counterVec(&m.parserBlocks, "parser_blocks_total", "parsed blocks", "parser")
+}
+
+func (m *Metric) ParserBlocksPerSecond() *prometheus.GaugeVec {
+	return m.gaugeVec(&m.parserBlocksPerSecond, "parser_blocks_per_second", "of the last parsing round", "parser")
+}
+
+func (m *Metric) ParserFork() *prometheus.CounterVec {
+	return m.counterVec(&m.parserFork, "parser_fork_total", "forks rolled back", "parser")
+}
+
+func (m *Metric) OrderCreated() *prometheus.CounterVec {
+	return m.counterVec(&m.orderCreated, "order_created_total", "created orders", "pay_token_id")
+}
+
+func (m *Metric) OrderPaid() *prometheus.CounterVec {
+	return m.counterVec(&m.orderPaid, "order_paid_total", "paid orders", "pay_token_id")
+}
+
+func (m *Metric) PaymentAmount() *prometheus.CounterVec {
+	return m.counterVec(&m.paymentAmount, "payment_amount_total", "paid amount in the token unit, not the smallest unit", "pay_token_id")
+}
+
+func (m *Metric) RefundQueue() *prometheus.GaugeVec {
+	return m.gaugeVec(&m.refundQueue, "refund_queue", "payments waiting for the refund", "refund_status")
+}
+
+// Refund result: refunded failed
+func (m *Metric) Refund() *prometheus.CounterVec {
+	return m.counterVec(&m.refund, "refund_total", "refund outcomes", "pay_token_id", "result")
+}
+
+// CallbackDuration status: ok fail
+func (m *Metric) CallbackDuration() *prometheus.HistogramVec {
+	m.l.Lock()
+	defer m.l.Unlock()
+	if m.callbackDuration == nil {
+		m.callbackDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "callback_duration_seconds",
+			Help:    "callback requests to the businesses",
+			Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+		}, []string{"business_id", "status"})
+		PromRegister.MustRegister(m.callbackDuration)
+	}
+	return m.callbackDuration
+}
+
+func (m *Metric) CallbackFailed() *prometheus.CounterVec {
+	return m.counterVec(&m.callbackFailed, "callback_failed_total", "failed callback requests", "business_id")
+}
+
+// the wallet labels are bounded by the addr_map of the config
+
+func (m *Metric) WalletBalance() *prometheus.GaugeVec {
+	return m.gaugeVec(&m.walletBalance, "wallet_balance", "balance of the refund wallet in the token unit", "pay_token_id", "address")
+}
+
+func (m *Metric) WalletRefundNeed() *prometheus.GaugeVec {
+	return m.gaugeVec(&m.walletRefundNeed, "wallet_refund_need", "projected need of the refund queue in the token unit, the fees included", "pay_token_id", "address")
+}
+
+// WalletTronResource resource: energy bandwidth
+func (m *Metric) WalletTronResource() *prometheus.GaugeVec {
+	return m.gaugeVec(&m.walletTronResource, "wallet_tron_resource", "available resource of the tron refund wallet", "address", "resource")
+}
+
+func (m *Metric) WalletUtxoCount() *prometheus.GaugeVec {
+	return m.gaugeVec(&m.walletUtxoCount, "wallet_utxo_count", "spendable utxos of the doge refund wallet", "address")
+}
+
+// ChainHealthy 1 healthy, 0 the breaker of the order create is open
+func (m *Metric) ChainHealthy() *prometheus.GaugeVec {
+	return m.gaugeVec(&m.chainHealthy, "chain_healthy", "health of the chain from the parser lag and the node errors", "parser")
+}
+
+// the endpoint labels are bounded by the nodes of the config
+
+// NodeEndpointUp 1 up, 0 down and only picked if all the endpoints of the chain are down
+func (m *Metric) NodeEndpointUp() *prometheus.GaugeVec {
+	return m.gaugeVec(&m.nodeEndpointUp, "node_endpoint_up", "health of the node endpoint from the probes and the call errors", "chain", "endpoint")
+}
+
+func (m *Metric) NodeEndpointHead() *prometheus.GaugeVec {
+	return m.gaugeVec(&m.nodeEndpointHead, "node_endpoint_head", "the latest block of the node endpoint", "chain", "endpoint")
+}
+
+// RegisterDBStats the pool stats of the db, read on every scrape
+func RegisterDBStats(name string, db *sql.DB) error {
+	return PromRegister.Register(&dbStatsCollector{db: db, name: name})
+}
+
+type dbStatsCollector struct {
+	db   *sql.DB
+	name string
+}
+
+var (
+	dbStatsLabels       = []string{"db"}
+	dbMaxOpenDesc       = prometheus.NewDesc("db_max_open_connections", "max open connections", dbStatsLabels, nil)
+	dbOpenDesc          = prometheus.NewDesc("db_open_connections", "open connections", dbStatsLabels, nil)
+	dbInUseDesc         = prometheus.NewDesc("db_in_use_connections", "connections in use", dbStatsLabels, nil)
+	dbIdleDesc          = prometheus.NewDesc("db_idle_connections", "idle connections", dbStatsLabels, nil)
+	dbWaitCountDesc     = prometheus.NewDesc("db_wait_count_total", "waits for a connection", dbStatsLabels, nil)
+	dbWaitDurationDesc  = prometheus.NewDesc("db_wait_duration_seconds_total", "time blocked waiting for a connection", dbStatsLabels, nil)
+	dbMaxIdleClosedDesc = prometheus.NewDesc("db_max_idle_closed_total", "connections closed by max idle", dbStatsLabels, nil)
+)
+
+func (d *dbStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- dbMaxOpenDesc
+	ch <- dbOpenDesc
+	ch <- dbInUseDesc
+	ch <- dbIdleDesc
+	ch <- dbWaitCountDesc
+	ch <- dbWaitDurationDesc
+	ch <- dbMaxIdleClosedDesc
+}
+
+func (d *dbStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := d.db.Stats()
+	ch <- prometheus.MustNewConstMetric(dbMaxOpenDesc, prometheus.GaugeValue, float64(stats.MaxOpenConnections), d.name)
+	ch <- prometheus.MustNewConstMetric(dbOpenDesc, prometheus.GaugeValue, float64(stats.OpenConnections), d.name)
+	ch <- prometheus.MustNewConstMetric(dbInUseDesc, prometheus.GaugeValue, float64(stats.InUse), d.name)
+	ch <- prometheus.MustNewConstMetric(dbIdleDesc, prometheus.GaugeValue, float64(stats.Idle), d.name)
+	ch <- prometheus.MustNewConstMetric(dbWaitCountDesc, prometheus.CounterValue, float64(stats.WaitCount), d.name)
+	ch <- prometheus.MustNewConstMetric(dbWaitDurationDesc, prometheus.CounterValue, stats.WaitDuration.Seconds(), d.name)
+	ch <- prometheus.MustNewConstMetric(dbMaxIdleClosedDesc, prometheus.CounterValue, float64(stats.MaxIdleClosed), d.name)
+}
@@ -4,8 +4,10 @@ import (
 	"fmt"
 	"github.com/dotbitHQ/das-lib/http_api/logger"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/prometheus/client_golang/prometheus/push"
 	"net"
+	"net/http"
 	"sync"
 	"time"
 	"unipay/config"
@@ -27,6 +29,28 @@ type Metric struct {
 	l         sync.Mutex
 	api       *prometheus.SummaryVec
 	errNotify *prometheus.CounterVec
+	dropped   *prometheus.CounterVec
+
+	parserCurrentBlock    *prometheus.GaugeVec
+	parserChainHead       *prometheus.GaugeVec
+	parserLag             *prometheus.GaugeVec
+	parserBlocks          *prometheus.CounterVec
+	parserBlocksPerSecond *prometheus.GaugeVec
+	parserFork            *prometheus.CounterVec
+	orderCreated          *prometheus.CounterVec
+	orderPaid             *prometheus.CounterVec
+	paymentAmount         *prometheus.CounterVec
+	refundQueue           *prometheus.GaugeVec
+	refund                *prometheus.CounterVec
+	callbackDuration      *prometheus.HistogramVec
+	callbackFailed        *prometheus.CounterVec
+	walletBalance         *prometheus.GaugeVec
+	walletRefundNeed      *prometheus.GaugeVec
+	walletTronResource    *prometheus.GaugeVec
+	walletUtxoCount       *prometheus.GaugeVec
+	chainHealthy          *prometheus.GaugeVec
+	nodeEndpointUp        *prometheus.GaugeVec
+	nodeEndpointHead      *prometheus.GaugeVec
 }
 
 func (m *Metric) Api() *prometheus.SummaryVec {
@@ -35,7 +59,7 @@ func (m *Metric) Api() *prometheus.SummaryVec {
 		defer m.l.Unlock()
 		m.api = prometheus.NewSummaryVec(prometheus.SummaryOpts{
 			Name: "api",
-		}, []string{"method", "http_status", "err_no", "err_msg"})
+		}, []string{"method", "http_status", "err_no"})
 		PromRegister.MustRegister(m.api)
 	}
 	return m.api
@@ -47,21 +71,34 @@ func (m *Metric) ErrNotify() *prometheus.CounterVec {
 		defer m.l.Unlock()
 		m.errNotify = prometheus.NewCounterVec(prometheus.CounterOpts{
 			Name: "notify",
-		}, []string{"title", "text"})
+		}, []string{"severity", "category", "title"})
 		PromRegister.MustRegister(m.errNotify)
 	}
 	return m.errNotify
 }
 
+// NotifyDropped the alerts not sent, reason: dedup rate_limit queue_full send_err
+func (m *Metric) NotifyDropped() *prometheus.CounterVec {
+	if m.dropped == nil {
+		m.l.Lock()
+		defer m.l.Unlock()
+		m.dropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "notify_dropped",
+		}, []string{"notifier", "reason"})
+		PromRegister.MustRegister(m.dropped)
+	}
+	return m.dropped
+}
+
 func Init() {
 	Tools = &ToolEntity{}
 }
 
 func (t *ToolEntity) Run() {
-	if config.Cfg.Server.PrometheusPushGateway != "" && config.Cfg.Server.Name != "" {
-		t.pusher = push.New(config.Cfg.Server.PrometheusPushGateway, config.Cfg.Server.Name)
+	if config.GetCfg().Server.PrometheusPushGateway != "" && config.GetCfg().Server.Name != "" {
+		t.pusher = push.New(config.GetCfg().Server.PrometheusPushGateway, config.GetCfg().Server.Name)
 		t.pusher.Gatherer(PromRegister)
-		t.pusher.Grouping("env", fmt.Sprint(config.Cfg.Server.Net))
+		t.pusher.Grouping("env", fmt.Sprint(config.GetCfg().Server.Net))
 		t.pusher.Grouping("instance", GetLocalIp("eth0"))
 
 		go func() {
@@ -75,6 +112,17 @@ func (t *ToolEntity) Run() {
 	}
 }
 
+// ServeMetrics for the processes without the http server
+func ServeMetrics(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(PromRegister, promhttp.HandlerOpts{}))
+	go func() {
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			log.Error("ServeMetrics err:", addr, err.Error())
+		}
+	}()
+}
+
 func GetLocalIp(interfaceName string) string {
 	ief, err := net.InterfaceByName(interfaceName)
 	if err != nil {
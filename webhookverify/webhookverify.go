@@ -0,0 +1,170 @@
+// Package webhookverify signs and verifies the callbacks unipay sends to the businesses.
+// It only depends on the standard library, so that the businesses can import it.
+//
+//	body, _ := io.ReadAll(r.Body)
+//	err := webhookverify.Verify(secrets, r.Header.Get(webhookverify.HeaderSignature), body, webhookverify.DefaultTolerance)
+package webhookverify
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// HeaderSignature t=<unix seconds>,v1=<hex hmac>[,v1=<hex hmac>], one v1 per active secret
+	HeaderSignature = "X-Unipay-Signature"
+	// HeaderJws detached compact JWS with EdDSA, <protected header>..<signature>
+	HeaderJws = "X-Unipay-Jws"
+
+	DefaultTolerance = time.Minute * 5
+
+	schemeV1 = "v1"
+	algEdDSA = "EdDSA"
+)
+
+var (
+	ErrNoSignature        = errors.New("no signature")
+	ErrInvalidHeader      = errors.New("invalid signature header")
+	ErrTimestampExpired   = errors.New("timestamp outside the tolerance")
+	ErrSignatureMismatch  = errors.New("signature mismatch")
+	ErrUnsupportedJwsAlgo = errors.New("unsupported jws alg")
+)
+
+// ComputeSignature hex(hmac_sha256(secret, timestamp + "." + body))
+func ComputeSignature(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Sign returns the HeaderSignature value, signed with each of the secrets
+func Sign(secrets []string, timestamp int64, body []byte) string {
+	parts := []string{fmt.Sprintf("t=%d", timestamp)}
+	for _, secret := range secrets {
+		if secret == "" {
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s=%s", schemeV1, ComputeSignature(secret, timestamp, body)))
+	}
+	return strings.Join(parts, ",")
+}
+
+// Verify checks the HeaderSignature value against any of the secrets,
+// pass both the old and the new secret while rotating
+func Verify(secrets []string, header string, body []byte, tolerance time.Duration) error {
+	if header == "" {
+		return ErrNoSignature
+	}
+	var timestamp int64
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			return ErrInvalidHeader
+		}
+		switch kv[0] {
+		case "t":
+			ts, err := strconv.ParseInt(kv[1], 10, 64)
+			if err != nil {
+				return ErrInvalidHeader
+			}
+			timestamp = ts
+		case schemeV1:
+			signatures = append(signatures, kv[1])
+		}
+	}
+	if timestamp == 0 || len(signatures) == 0 {
+		return ErrInvalidHeader
+	}
+	if err := checkTimestamp(timestamp, tolerance); err != nil {
+		return err
+	}
+	for _, secret := range secrets {
+		if secret == "" {
+			continue
+		}
+		expected := ComputeSignature(secret, timestamp, body)
+		for _, sig := range signatures {
+			if hmac.Equal([]byte(expected), []byte(sig)) {
+				return nil
+			}
+		}
+	}
+	return ErrSignatureMismatch
+}
+
+type jwsHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid,omitempty"`
+	Iat int64  `json:"iat"`
+}
+
+// SignJws returns the HeaderJws value, the body is the detached payload
+func SignJws(privateKey ed25519.PrivateKey, kid string, timestamp int64, body []byte) (string, error) {
+	header, err := json.Marshal(jwsHeader{Alg: algEdDSA, Kid: kid, Iat: timestamp})
+	if err != nil {
+		return "", err
+	}
+	protected := base64.RawURLEncoding.EncodeToString(header)
+	signingInput := protected + "." + base64.RawURLEncoding.EncodeToString(body)
+	sig := ed25519.Sign(privateKey, []byte(signingInput))
+	return protected + ".." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// VerifyJws checks the HeaderJws value against any of the public keys, returns the kid of the header
+func VerifyJws(publicKeys []ed25519.PublicKey, jws string, body []byte, tolerance time.Duration) (string, error) {
+	if jws == "" {
+		return "", ErrNoSignature
+	}
+	parts := strings.Split(jws, ".")
+	if len(parts) != 3 || parts[1] != "" {
+		return "", ErrInvalidHeader
+	}
+	headerBys, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return "", ErrInvalidHeader
+	}
+	var header jwsHeader
+	if err := json.Unmarshal(headerBys, &header); err != nil {
+		return "", ErrInvalidHeader
+	}
+	if header.Alg != algEdDSA {
+		return "", ErrUnsupportedJwsAlgo
+	}
+	if err := checkTimestamp(header.Iat, tolerance); err != nil {
+		return "", err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", ErrInvalidHeader
+	}
+	signingInput := parts[0] + "." + base64.RawURLEncoding.EncodeToString(body)
+	for _, pub := range publicKeys {
+		if len(pub) == ed25519.PublicKeySize && ed25519.Verify(pub, []byte(signingInput), sig) {
+			return header.Kid, nil
+		}
+	}
+	return "", ErrSignatureMismatch
+}
+
+func checkTimestamp(timestamp int64, tolerance time.Duration) error {
+	if tolerance <= 0 {
+		return nil
+	}
+	diff := time.Since(time.Unix(timestamp, 0))
+	if diff > tolerance || diff < -tolerance {
+		return ErrTimestampExpired
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package webhookverify
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testBody = []byte(`{"event_type":"PAYMENT","order_id":"abc"}`)
+
+func TestSignVerify(t *testing.T) {
+	secrets := []string{"secret-old", "secret-new"}
+	header := Sign(secrets, time.Now().Unix(), testBody)
+	if err := Verify(secrets, header, testBody, DefaultTolerance); err != nil {
+		t.Fatal(err)
+	}
+	// the business still on the old secret and the one moved to the new one
+	for _, secret := range secrets {
+		if err := Verify([]string{secret}, header, testBody, DefaultTolerance); err != nil {
+			t.Fatal(secret, err)
+		}
+	}
+	if err := Verify([]string{"secret-other"}, header, testBody, DefaultTolerance); err != ErrSignatureMismatch {
+		t.Fatal(err)
+	}
+}
+
+func TestVerifyRotatedSecret(t *testing.T) {
+	// signed with the new secret only, the business verifies with the old and the new one
+	header := Sign([]string{"secret-new"}, time.Now().Unix(), testBody)
+	if err := Verify([]string{"secret-old", "secret-new"}, header, testBody, DefaultTolerance); err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify([]string{"secret-old"}, header, testBody, DefaultTolerance); err != ErrSignatureMismatch {
+		t.Fatal(err)
+	}
+}
+
+func TestVerifyTampered(t *testing.T) {
+	secrets := []string{"secret"}
+	header := Sign(secrets, time.Now().Unix(), testBody)
+
+	body := []byte(strings.Replace(string(testBody), "abc", "abd", 1))
+	if err := Verify(secrets, header, body, DefaultTolerance); err != ErrSignatureMismatch {
+		t.Fatal("body:", err)
+	}
+
+	tampered := header[:len(header)-1] + "0"
+	if strings.HasSuffix(header, "0") {
+		tampered = header[:len(header)-1] + "1"
+	}
+	if err := Verify(secrets, tampered, testBody, DefaultTolerance); err != ErrSignatureMismatch {
+		t.Fatal("signature:", err)
+	}
+
+	if err := Verify(secrets, "", testBody, DefaultTolerance); err != ErrNoSignature {
+		t.Fatal("empty:", err)
+	}
+	if err := Verify(secrets, "v1=abc", testBody, DefaultTolerance); err != ErrInvalidHeader {
+		t.Fatal("no timestamp:", err)
+	}
+}
+
+func TestVerifyTimestamp(t *testing.T) {
+	secrets := []string{"secret"}
+	tolerance := time.Minute * 5
+	list := []struct {
+		name      string
+		timestamp int64
+		err       error
+	}{
+		{"inside", time.Now().Add(-time.Minute * 4).Unix(), nil},
+		{"expired", time.Now().Add(-time.Minute * 6).Unix(), ErrTimestampExpired},
+		{"future", time.Now().Add(time.Minute * 6).Unix(), ErrTimestampExpired},
+	}
+	for _, v := range list {
+		header := Sign(secrets, v.timestamp, testBody)
+		if err := Verify(secrets, header, testBody, tolerance); err != v.err {
+			t.Fatal(v.name, err)
+		}
+	}
+	// a tolerance of 0 skips the check
+	header := Sign(secrets, time.Now().Add(-time.Hour).Unix(), testBody)
+	if err := Verify(secrets, header, testBody, 0); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newTestKey(t *testing.T) (ed25519.PublicKey, ed25519.PrivateKey) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pub, priv
+}
+
+func TestSignVerifyJws(t *testing.T) {
+	pub, priv := newTestKey(t)
+	jws, err := SignJws(priv, "key-1", time.Now().Unix(), testBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kid, err := VerifyJws([]ed25519.PublicKey{pub}, jws, testBody, DefaultTolerance)
+	if err != nil {
+		t.Fatal(err)
+	} else if kid != "key-1" {
+		t.Fatal("kid:", kid)
+	}
+
+	// the second of the rotated keys
+	pubOld, _ := newTestKey(t)
+	if _, err := VerifyJws([]ed25519.PublicKey{pubOld, pub}, jws, testBody, DefaultTolerance); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifyJws([]ed25519.PublicKey{pubOld}, jws, testBody, DefaultTolerance); err != ErrSignatureMismatch {
+		t.Fatal(err)
+	}
+}
+
+func TestVerifyJwsTampered(t *testing.T) {
+	pub, priv := newTestKey(t)
+	keys := []ed25519.PublicKey{pub}
+	jws, err := SignJws(priv, "", time.Now().Unix(), testBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := []byte(strings.Replace(string(testBody), "abc", "abd", 1))
+	if _, err := VerifyJws(keys, jws, body, DefaultTolerance); err != ErrSignatureMismatch {
+		t.Fatal("body:", err)
+	}
+
+	_, priv2 := newTestKey(t)
+	jws2, err := SignJws(priv2, "", time.Now().Unix(), testBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the header of one with the signature of the other
+	tampered := jws[:strings.LastIndex(jws, ".")] + jws2[strings.LastIndex(jws2, "."):]
+	if _, err := VerifyJws(keys, tampered, testBody, DefaultTolerance); err != ErrSignatureMismatch {
+		t.Fatal("signature:", err)
+	}
+
+	if _, err := VerifyJws(keys, "", testBody, DefaultTolerance); err != ErrNoSignature {
+		t.Fatal("empty:", err)
+	}
+	if _, err := VerifyJws(keys, "a.b.c", testBody, DefaultTolerance); err != ErrInvalidHeader {
+		t.Fatal("attached payload:", err)
+	}
+}
+
+func TestVerifyJwsTimestamp(t *testing.T) {
+	pub, priv := newTestKey(t)
+	keys := []ed25519.PublicKey{pub}
+	tolerance := time.Minute * 5
+	list := []struct {
+		name      string
+		timestamp int64
+		err       error
+	}{
+		{"inside", time.Now().Add(time.Minute * 4).Unix(), nil},
+		{"expired", time.Now().Add(-time.Minute * 6).Unix(), ErrTimestampExpired},
+		{"future", time.Now().Add(time.Minute * 6).Unix(), ErrTimestampExpired},
+	}
+	for _, v := range list {
+		jws, err := SignJws(priv, "", v.timestamp, testBody)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := VerifyJws(keys, jws, testBody, tolerance); err != v.err {
+			t.Fatal(v.name, err)
+		}
+	}
+}